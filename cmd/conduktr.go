@@ -2,13 +2,15 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
-	"path/filepath"
 	"syscall"
 	"time"
 
+	"github.com/logimos/conduktr/internal/actions"
 	"github.com/logimos/conduktr/internal/ai"
 	"github.com/logimos/conduktr/internal/analytics"
 	"github.com/logimos/conduktr/internal/config"
@@ -25,10 +27,11 @@ import (
 )
 
 var (
-	cfgFile     string
-	workflowDir string
-	port        int
-	logger      *zap.Logger
+	cfgFile         string
+	workflowDir     string
+	port            int
+	strictTemplates bool
+	logger          *zap.Logger
 )
 
 var rootCmd = &cobra.Command{
@@ -63,6 +66,7 @@ func init() {
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.reactor.yaml)")
 	rootCmd.PersistentFlags().StringVar(&workflowDir, "workflows", "./workflows", "directory containing workflow files")
+	rootCmd.PersistentFlags().BoolVar(&strictTemplates, "strict-templates", false, "fail steps that reference missing template keys")
 
 	runCmd.Flags().IntVarP(&port, "port", "p", 5000, "HTTP server port")
 
@@ -94,20 +98,53 @@ func initConfig() {
 	if err != nil {
 		panic(err)
 	}
+
+	// Resolve secrets and keep their values out of the logs
+	secretsManager, err = newSecretsManager(secretsConfig())
+	cobra.CheckErr(err)
+	logger = logger.WithOptions(zap.WrapCore(secretsManager.Redactor().WrapCore))
 }
 
 func runDaemon(cmd *cobra.Command, args []string) error {
 	cfg := &config.Config{
-		WorkflowDir: workflowDir,
-		HTTPPort:    port,
-		LogLevel:    "info",
+		WorkflowDir:     workflowDir,
+		HTTPPort:        port,
+		LogLevel:        "info",
+		PluginDir:       pluginDir(),
+		WatchDir:        watchDir(),
+		AdminToken:      adminToken(),
+		StrictTemplates: strictTemplates || viper.GetBool("strict_templates"),
+		ShellPolicy:     shellPolicy(),
+		Egress:          egressPolicy(),
+		WASM:            wasmConfig(),
+		Script:          scriptConfig(),
+		Databases:       databaseConfigs(),
+		Email:           emailConfig(),
+		Files:           filePolicy(),
+		Triggers:        triggerConfigs(),
 	}
 
 	// Initialize persistence
-	persist := persistence.NewJSONPersistence("./data")
+	persist, err := openStore()
+	if err != nil {
+		return err
+	}
 
 	// Initialize workflow engine
 	workflowEngine := engine.NewEngine(logger, persist)
+	workflowEngine.SetStrictTemplates(cfg.StrictTemplates)
+	workflowEngine.SetSecrets(secretsManager)
+	workflowEngine.SetEnv(secretsManager)
+	if err := configureEngine(workflowEngine, cfg); err != nil {
+		return err
+	}
+
+	// Start action plugins
+	pluginHost, err := startPlugins(workflowEngine, cfg.PluginDir)
+	if err != nil {
+		return err
+	}
+	defer pluginHost.Stop()
 
 	// Initialize advanced services
 	_ = web.NewDesignerService()
@@ -116,13 +153,18 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 	aiBuilder := ai.NewAIWorkflowBuilder(logger)
 	integrationHub := integrations.NewIntegrationHub(logger)
 
-	// Load workflows from directory
-	if err := loadWorkflows(workflowEngine, cfg.WorkflowDir); err != nil {
+	// Load workflows from directory and keep them in sync
+	loader := engine.NewLoader(workflowEngine, logger, cfg.WorkflowDir)
+	if _, err := loader.Reload(); err != nil {
 		return fmt.Errorf("failed to load workflows: %w", err)
 	}
+	if err := loader.Watch(); err != nil {
+		logger.Warn("Workflow hot reload disabled", zap.Error(err))
+	}
 
 	// Start all trigger systems
 	logger.Info("Starting trigger systems...")
+	triggerManager := triggers.NewTriggerManager(logger, workflowEngine, pluginHost)
 
 	// Start HTTP trigger with advanced features
 	httpTrigger := triggers.NewHTTPTrigger(logger, workflowEngine, cfg.HTTPPort)
@@ -133,17 +175,38 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 	// Register marketplace routes
 	httpTrigger.RegisterMarketplaceRoutes(marketplaceService)
 
-	go func() {
-		if err := httpTrigger.Start(); err != nil {
-			logger.Error("HTTP trigger failed", zap.Error(err))
-		}
-	}()
+	// Expose workflow reloads through the admin API
+	httpTrigger.SetWorkflowLoader(loader)
+	httpTrigger.SetAdminToken(cfg.AdminToken)
+	if cfg.AdminToken == "" {
+		logger.Info("Workflow management API disabled; set admin_token to enable it")
+	}
+	httpTrigger.SetPluginHost(pluginHost)
+	httpTrigger.SetTriggerManager(triggerManager)
+
+	// The HTTP and file triggers always run; others come from configuration
+	if err := triggerManager.Add("http", httpTrigger); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(cfg.WatchDir, 0755); err != nil {
+		return fmt.Errorf("failed to create watch directory: %w", err)
+	}
+	if err := triggerManager.Add("file", triggers.NewFileTrigger(logger, workflowEngine, cfg.WatchDir)); err != nil {
+		return err
+	}
+	if err := triggerManager.Build(toTriggerConfigs(cfg.Triggers)); err != nil {
+		return fmt.Errorf("invalid triggers configuration: %w", err)
+	}
+	triggerManager.Start(context.Background())
 
-	// Start file trigger
-	fileTrigger := triggers.NewFileTrigger(logger, workflowEngine)
+	// Expire overdue approvals
+	approvalTicker := time.NewTicker(time.Minute)
+	defer approvalTicker.Stop()
 	go func() {
-		if err := fileTrigger.Start(cfg.WorkflowDir); err != nil {
-			logger.Error("File trigger failed", zap.Error(err))
+		for range approvalTicker.C {
+			if n := workflowEngine.ExpireApprovals(); n > 0 {
+				logger.Info("Expired pending approvals", zap.Int("count", n))
+			}
 		}
 	}()
 
@@ -151,6 +214,18 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 		zap.Int("port", cfg.HTTPPort),
 		zap.String("workflow_dir", cfg.WorkflowDir))
 
+	// Reload workflows on SIGHUP
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			logger.Info("Received SIGHUP, reloading workflows")
+			if _, err := loader.Reload(); err != nil {
+				logger.Error("Workflow reload failed", zap.Error(err))
+			}
+		}
+	}()
+
 	// Wait for interrupt signal
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
@@ -162,8 +237,10 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 	defer cancel()
 
 	// Graceful shutdown
-	httpTrigger.Stop(ctx)
-	fileTrigger.Stop()
+	if err := triggerManager.Stop(ctx); err != nil {
+		logger.Warn("Failed to stop triggers", zap.Error(err))
+	}
+	loader.Stop()
 
 	return nil
 }
@@ -176,10 +253,42 @@ func validateWorkflow(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("validation failed: %w", err)
 	}
 
+	// Check template references; they are errors in strict mode
+	issues := workflow.CheckTemplates()
+	strict := workflow.StrictTemplates || strictTemplates || viper.GetBool("strict_templates")
+	for _, issue := range issues {
+		if strict {
+			fmt.Printf("❌ %s\n", issue)
+		} else {
+			fmt.Printf("⚠️  %s\n", issue)
+		}
+	}
+	if strict && len(issues) > 0 {
+		return fmt.Errorf("validation failed: %d unresolved template reference(s)", len(issues))
+	}
+
+	// Shell policy violations and templates inside shell quotes are always errors
+	violations := workflow.CheckShellPolicy(toShellPolicy(shellPolicy()))
+	for _, violation := range violations {
+		fmt.Printf("❌ %s\n", violation)
+	}
+	if len(violations) > 0 {
+		return fmt.Errorf("validation failed: %d shell policy violation(s)", len(violations))
+	}
+
 	fmt.Printf("✅ Workflow '%s' is valid\n", workflow.Name)
+	fmt.Printf("   Version: %s\n", workflow.Version)
 	fmt.Printf("   Trigger: %s\n", workflow.On.Event)
 	fmt.Printf("   Steps: %d\n", len(workflow.Workflow))
 
+	if len(workflow.Inputs) > 0 {
+		schema, err := json.MarshalIndent(workflow.JSONSchema(), "   ", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to render input schema: %w", err)
+		}
+		fmt.Printf("   Inputs: %s\n", schema)
+	}
+
 	return nil
 }
 
@@ -190,13 +299,40 @@ func executeWorkflow(cmd *cobra.Command, args []string) error {
 		eventData = args[1]
 	}
 
-	persist := persistence.NewJSONPersistence("./data")
+	persist, err := openStore()
+	if err != nil {
+		return err
+	}
 	workflowEngine := engine.NewEngine(logger, persist)
+	workflowEngine.SetStrictTemplates(strictTemplates || viper.GetBool("strict_templates"))
+	workflowEngine.SetSecrets(secretsManager)
+	workflowEngine.SetEnv(secretsManager)
+	cfg := &config.Config{
+		ShellPolicy: shellPolicy(),
+		Egress:      egressPolicy(),
+		WASM:        wasmConfig(),
+		Script:      scriptConfig(),
+		Databases:   databaseConfigs(),
+		Email:       emailConfig(),
+		Files:       filePolicy(),
+	}
+	if err := configureEngine(workflowEngine, cfg); err != nil {
+		return err
+	}
+
+	pluginHost, err := startPlugins(workflowEngine, pluginDir())
+	if err != nil {
+		return err
+	}
+	defer pluginHost.Stop()
 
 	workflow, err := engine.LoadWorkflowFromFile(workflowFile)
 	if err != nil {
 		return fmt.Errorf("failed to load workflow: %w", err)
 	}
+	if violations := workflow.CheckShellPolicy(toShellPolicy(shellPolicy())); len(violations) > 0 {
+		return fmt.Errorf("workflow violates the shell policy: %w", errors.Join(violations...))
+	}
 
 	// Create event context
 	eventCtx := &persistence.EventContext{
@@ -209,8 +345,12 @@ func executeWorkflow(cmd *cobra.Command, args []string) error {
 
 	// Parse event data if provided
 	if eventData != "{}" {
-		// Simple JSON-like parsing for demo
-		eventCtx.Event.Payload["data"] = eventData
+		var payload map[string]interface{}
+		if err := json.Unmarshal([]byte(eventData), &payload); err == nil {
+			eventCtx.Event.Payload = payload
+		} else {
+			eventCtx.Event.Payload["data"] = eventData
+		}
 	}
 
 	fmt.Printf("🚀 Executing workflow: %s\n", workflow.Name)
@@ -221,31 +361,222 @@ func executeWorkflow(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("workflow execution failed: %w", err)
 	}
 
+	instance, err := workflowEngine.GetWorkflowInstance(instanceID)
+	if err == nil && instance.Status == "paused" {
+		fmt.Printf("⏸️  Workflow paused for approval (instance: %s)\n", instanceID)
+		return nil
+	}
+
 	fmt.Printf("✅ Workflow completed successfully (instance: %s)\n", instanceID)
 	return nil
 }
 
-func loadWorkflows(workflowEngine *engine.Engine, workflowDir string) error {
-	return filepath.Walk(workflowDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+func Execute() error {
+	// The sandbox helper runs before cobra so nothing else touches the process
+	if len(os.Args) > 1 && os.Args[1] == actions.SandboxCommand {
+		if err := actions.RunSandboxed(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, "sandbox:", err)
+			os.Exit(126)
 		}
+	}
+	if len(os.Args) > 1 && os.Args[1] == actions.ScriptCommand {
+		os.Exit(actions.RunScript(os.Stdin, os.Stdout))
+	}
+	return rootCmd.Execute()
+}
 
-		if !info.IsDir() && (filepath.Ext(path) == ".yaml" || filepath.Ext(path) == ".yml") {
-			workflow, err := engine.LoadWorkflowFromFile(path)
-			if err != nil {
-				logger.Warn("Failed to load workflow", zap.String("file", path), zap.Error(err))
-				return nil
-			}
+// adminToken returns the token guarding the workflow management API
+func adminToken() string {
+	if token := os.Getenv("CONDUKTR_ADMIN_TOKEN"); token != "" {
+		return token
+	}
+	return viper.GetString("admin_token")
+}
+
+// shellPolicy returns the configured shell.exec policy, if any
+func shellPolicy() *config.ShellPolicy {
+	if !viper.IsSet("shell_policy") {
+		return nil
+	}
+	var policy config.ShellPolicy
+	if err := viper.UnmarshalKey("shell_policy", &policy); err != nil {
+		cobra.CheckErr(fmt.Errorf("invalid shell_policy configuration: %w", err))
+	}
+	return &policy
+}
+
+// scriptConfig returns the configured script.run limits, if any
+func scriptConfig() *config.ScriptConfig {
+	if !viper.IsSet("script") {
+		return nil
+	}
+	var cfg config.ScriptConfig
+	if err := viper.UnmarshalKey("script", &cfg); err != nil {
+		cobra.CheckErr(fmt.Errorf("invalid script configuration: %w", err))
+	}
+	return &cfg
+}
+
+// databaseConfigs returns the configured database connections, if any
+func databaseConfigs() map[string]config.DatabaseConfig {
+	if !viper.IsSet("databases") {
+		return nil
+	}
+	var configs map[string]config.DatabaseConfig
+	if err := viper.UnmarshalKey("databases", &configs); err != nil {
+		cobra.CheckErr(fmt.Errorf("invalid databases configuration: %w", err))
+	}
+	return configs
+}
+
+// emailConfig returns the configured SMTP server, if any
+func emailConfig() *config.EmailConfig {
+	if !viper.IsSet("email") {
+		return nil
+	}
+	var cfg config.EmailConfig
+	if err := viper.UnmarshalKey("email", &cfg); err != nil {
+		cobra.CheckErr(fmt.Errorf("invalid email configuration: %w", err))
+	}
+	return &cfg
+}
+
+// filePolicy returns the configured file policy. Without one, file steps
+// may only read the watched directory.
+func filePolicy() *config.FilePolicy {
+	if !viper.IsSet("files") {
+		return config.DefaultFilePolicy(watchDir())
+	}
+	var policy config.FilePolicy
+	if err := viper.UnmarshalKey("files", &policy); err != nil {
+		cobra.CheckErr(fmt.Errorf("invalid files configuration: %w", err))
+	}
+	return &policy
+}
+
+// watchDir returns the directory the file trigger watches
+func watchDir() string {
+	if dir := viper.GetString("watch_dir"); dir != "" {
+		return dir
+	}
+	return config.Default().WatchDir
+}
+
+// wasmConfig returns the configured WebAssembly modules and limits, if any
+func wasmConfig() *config.WASMConfig {
+	if !viper.IsSet("wasm") {
+		return nil
+	}
+	var cfg config.WASMConfig
+	if err := viper.UnmarshalKey("wasm", &cfg); err != nil {
+		cobra.CheckErr(fmt.Errorf("invalid wasm configuration: %w", err))
+	}
+	return &cfg
+}
+
+// triggerConfigs returns the configured triggers list
+func triggerConfigs() []config.TriggerConfig {
+	var configs []config.TriggerConfig
+	if err := viper.UnmarshalKey("triggers", &configs); err != nil {
+		cobra.CheckErr(fmt.Errorf("invalid triggers configuration: %w", err))
+	}
+	return configs
+}
+
+// egressPolicy returns the configured http.request egress policy, if any
+func egressPolicy() *config.EgressPolicy {
+	if !viper.IsSet("egress") {
+		return nil
+	}
+	var policy config.EgressPolicy
+	if err := viper.UnmarshalKey("egress", &policy); err != nil {
+		cobra.CheckErr(fmt.Errorf("invalid egress configuration: %w", err))
+	}
+	if err := toEgressPolicy(&policy).Validate(); err != nil {
+		cobra.CheckErr(fmt.Errorf("invalid egress configuration: %w", err))
+	}
+	return &policy
+}
+
+// configureEngine applies the step policies and limits of a configuration
+func configureEngine(e *engine.Engine, cfg *config.Config) error {
+	e.SetShellPolicy(toShellPolicy(cfg.ShellPolicy))
+	e.SetEgressPolicy(toEgressPolicy(cfg.Egress))
+	e.SetFilePolicy(toFilePolicy(cfg.Files))
+	e.SetProtectedPaths(protectedPaths()...)
+	if cfg.WASM != nil {
+		wasm := actions.WASMConfig(*cfg.WASM)
+		e.SetWASMConfig(&wasm)
+	}
+	if cfg.Script != nil {
+		script := actions.ScriptConfig(*cfg.Script)
+		e.SetScriptConfig(&script)
+	}
+	if cfg.Email != nil {
+		email := actions.EmailConfig(*cfg.Email)
+		e.SetEmailConfig(&email)
+	}
+
+	var databases map[string]actions.DatabaseConfig
+	for name, database := range cfg.Databases {
+		if databases == nil {
+			databases = make(map[string]actions.DatabaseConfig, len(cfg.Databases))
+		}
+		databases[name] = actions.DatabaseConfig(database)
+	}
+	return e.SetDatabases(databases)
+}
 
-			workflowEngine.RegisterWorkflow(workflow)
-			logger.Info("Loaded workflow", zap.String("name", workflow.Name), zap.String("file", path))
+// toShellPolicy converts a configured shell policy, including its
+// per-workflow overrides
+func toShellPolicy(cfg *config.ShellPolicy) *actions.ShellPolicy {
+	if cfg == nil {
+		return nil
+	}
+	policy := &actions.ShellPolicy{
+		AllowedCommands: cfg.AllowedCommands,
+		AllowedDirs:     cfg.AllowedDirs,
+		ClearEnv:        cfg.ClearEnv,
+		EnvPassthrough:  cfg.EnvPassthrough,
+		AllowedEnv:      cfg.AllowedEnv,
+		RunAs:           cfg.RunAs,
+	}
+	if cfg.Limits != nil {
+		limits := actions.ShellLimits(*cfg.Limits)
+		policy.Limits = &limits
+	}
+	if len(cfg.Workflows) > 0 {
+		policy.Workflows = make(map[string]*actions.ShellPolicy, len(cfg.Workflows))
+		for name, override := range cfg.Workflows {
+			policy.Workflows[name] = toShellPolicy(override)
 		}
+	}
+	return policy
+}
 
+// toEgressPolicy converts a configured egress policy
+func toEgressPolicy(cfg *config.EgressPolicy) *actions.EgressPolicy {
+	if cfg == nil {
 		return nil
-	})
+	}
+	policy := actions.EgressPolicy(*cfg)
+	return &policy
 }
 
-func Execute() error {
-	return rootCmd.Execute()
+// toFilePolicy converts a configured file policy
+func toFilePolicy(cfg *config.FilePolicy) *actions.FilePolicy {
+	if cfg == nil {
+		return nil
+	}
+	policy := actions.FilePolicy(*cfg)
+	return &policy
+}
+
+// toTriggerConfigs converts the configured triggers list
+func toTriggerConfigs(configs []config.TriggerConfig) []triggers.TriggerConfig {
+	converted := make([]triggers.TriggerConfig, 0, len(configs))
+	for _, cfg := range configs {
+		converted = append(converted, triggers.TriggerConfig(cfg))
+	}
+	return converted
 }
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/logimos/conduktr/internal/config"
+	"github.com/logimos/conduktr/internal/persistence"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var rotateDataKey bool
+
+// dataDir holds persisted instances and approvals
+const dataDir = "./data"
+
+var dataCmd = &cobra.Command{
+	Use:   "data",
+	Short: "Manage persisted workflow data",
+}
+
+var dataRekeyCmd = &cobra.Command{
+	Use:   "rekey",
+	Short: "Re-encrypt persisted data with the active data key",
+	Long:  "Rewrap the data keys of encrypted instances and approvals with the active key and encrypt any records still stored in plaintext. With --rotate, a new key is generated and made active first.",
+	Args:  cobra.NoArgs,
+	RunE:  rekeyData,
+}
+
+func init() {
+	dataRekeyCmd.Flags().BoolVar(&rotateDataKey, "rotate", false, "generate a new active key before rekeying")
+
+	dataCmd.AddCommand(dataRekeyCmd)
+	rootCmd.AddCommand(dataCmd)
+}
+
+// encryptionConfig returns the encryption configuration
+func encryptionConfig() config.EncryptionConfig {
+	cfg := config.Default().Encryption
+	if viper.IsSet("encryption") {
+		if err := viper.UnmarshalKey("encryption", &cfg); err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid encryption configuration:", err)
+		}
+	}
+	return cfg
+}
+
+// protectedPaths returns the data, workflow and plugin directories and the
+// key and secret files that file steps may never touch
+func protectedPaths() []string {
+	secretsCfg := secretsConfig()
+	paths := []string{dataDir, workflowDir, pluginDir(), secretsCfg.Keystore, secretsCfg.KeyFile, secretsCfg.Dir, encryptionConfig().KeyFile}
+	protected := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if path != "" {
+			protected = append(protected, path)
+		}
+	}
+	return protected
+}
+
+// openStore opens the instance store with secret redaction and, when
+// enabled, encryption at rest
+func openStore() (*persistence.JSONPersistence, error) {
+	persist := persistence.NewJSONPersistence(dataDir)
+	persist.SetRedactor(secretsManager.Redactor())
+
+	cfg := encryptionConfig()
+	if cfg.Enabled {
+		keyring, err := persistence.LoadKeyring(cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load data keys: %w", err)
+		}
+		persist.SetKeyring(keyring)
+	}
+
+	return persist, nil
+}
+
+func rekeyData(cmd *cobra.Command, args []string) error {
+	cfg := encryptionConfig()
+
+	if rotateDataKey {
+		if os.Getenv(persistence.DataKeyEnv) != "" {
+			return fmt.Errorf("cannot rotate a key supplied through %s; update the variable instead", persistence.DataKeyEnv)
+		}
+		if cfg.KeyFile == "" {
+			return fmt.Errorf("encryption.key_file must be configured to rotate keys")
+		}
+		id, err := persistence.GenerateDataKey(cfg.KeyFile)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("🔑 Generated data key %s\n", id)
+	}
+
+	keyring, err := persistence.LoadKeyring(cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load data keys: %w", err)
+	}
+
+	persist := persistence.NewJSONPersistence(dataDir)
+	persist.SetKeyring(keyring)
+
+	result, err := persist.Rekey()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Data rekeyed with key %s\n", keyring.ActiveKeyID())
+	fmt.Printf("   Rewrapped: %d\n", result.Rewrapped)
+	fmt.Printf("   Encrypted: %d\n", result.Encrypted)
+	fmt.Printf("   Already current: %d\n", result.Skipped)
+	if !cfg.Enabled {
+		fmt.Println("⚠️  encryption.enabled is false; new records will be written in plaintext")
+	}
+	return nil
+}
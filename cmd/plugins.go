@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/logimos/conduktr/internal/config"
+	"github.com/logimos/conduktr/internal/engine"
+	"github.com/logimos/conduktr/internal/plugins"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var pluginsCmd = &cobra.Command{
+	Use:   "plugins",
+	Short: "Manage action plugins",
+}
+
+var pluginsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Start the plugins and list the actions and triggers they provide",
+	Args:  cobra.NoArgs,
+	RunE:  listPlugins,
+}
+
+func init() {
+	pluginsCmd.AddCommand(pluginsListCmd)
+	rootCmd.AddCommand(pluginsCmd)
+}
+
+// pluginDir returns the directory plugin executables are loaded from
+func pluginDir() string {
+	if dir := viper.GetString("plugin_dir"); dir != "" {
+		return dir
+	}
+	return config.Default().PluginDir
+}
+
+// startPlugins launches the plugins and registers their actions
+func startPlugins(workflowEngine *engine.Engine, dir string) (*plugins.Host, error) {
+	host := plugins.NewHost(logger, dir)
+	host.SetEnvPassthrough(viper.GetStringSlice("plugin_env_passthrough")...)
+	if err := host.Start(); err != nil {
+		return nil, err
+	}
+	host.Register(workflowEngine.Registry())
+	return host, nil
+}
+
+func listPlugins(cmd *cobra.Command, args []string) error {
+	host := plugins.NewHost(logger, pluginDir())
+	host.SetEnvPassthrough(viper.GetStringSlice("plugin_env_passthrough")...)
+	if err := host.Start(); err != nil {
+		return err
+	}
+	defer host.Stop()
+
+	infos := host.Plugins()
+	if len(infos) == 0 {
+		fmt.Printf("No plugins found in %s\n", pluginDir())
+		return nil
+	}
+	for _, info := range infos {
+		fmt.Printf("🔌 %s %s (%s)\n", info.Name, info.Version, info.Path)
+		for _, action := range info.Actions {
+			fmt.Printf("   %s  %s\n", action.Name, action.Description)
+		}
+		for _, trigger := range info.Triggers {
+			fmt.Printf("   %s (trigger)  %s\n", trigger.Type, trigger.Description)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/logimos/conduktr/internal/config"
+	"github.com/logimos/conduktr/internal/secrets"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var secretsManager *secrets.Manager
+
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Manage secrets in the local keystore",
+}
+
+var secretsSetCmd = &cobra.Command{
+	Use:   "set [name] [value]",
+	Short: "Store a secret; the value is read from stdin when omitted",
+	Args:  cobra.RangeArgs(1, 2),
+	RunE:  setSecret,
+}
+
+var secretsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List secret names in the local keystore",
+	Args:  cobra.NoArgs,
+	RunE:  listSecrets,
+}
+
+var secretsRmCmd = &cobra.Command{
+	Use:   "rm [name]",
+	Short: "Remove a secret from the local keystore",
+	Args:  cobra.ExactArgs(1),
+	RunE:  removeSecret,
+}
+
+func init() {
+	secretsCmd.AddCommand(secretsSetCmd)
+	secretsCmd.AddCommand(secretsListCmd)
+	secretsCmd.AddCommand(secretsRmCmd)
+	rootCmd.AddCommand(secretsCmd)
+}
+
+// secretsConfig returns the secrets configuration with defaults applied
+func secretsConfig() config.SecretsConfig {
+	cfg := config.Default().Secrets
+	if viper.IsSet("secrets") {
+		if err := viper.UnmarshalKey("secrets", &cfg); err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid secrets configuration:", err)
+		}
+	}
+	return cfg
+}
+
+// newSecretsManager builds the secrets manager from the configured providers
+func newSecretsManager(cfg config.SecretsConfig) (*secrets.Manager, error) {
+	providers := make([]secrets.Provider, 0, len(cfg.Providers))
+	for _, name := range cfg.Providers {
+		switch name {
+		case "keystore":
+			providers = append(providers, secrets.NewKeystore(cfg.Keystore, cfg.KeyFile))
+		case "file":
+			providers = append(providers, secrets.NewFileProvider(cfg.Dir))
+		case "env":
+			providers = append(providers, secrets.NewEnvProvider(cfg.EnvPrefix))
+		default:
+			return nil, fmt.Errorf("unknown secrets provider: %s", name)
+		}
+	}
+	manager := secrets.NewManager(providers...)
+	manager.SetTemplateEnv(cfg.TemplateEnv)
+	return manager, nil
+}
+
+func setSecret(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	var value string
+	if len(args) > 1 {
+		value = args[1]
+	} else {
+		if stat, err := os.Stdin.Stat(); err == nil && stat.Mode()&os.ModeCharDevice != 0 {
+			fmt.Fprintf(os.Stderr, "Value for %s: ", name)
+			line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && err != io.EOF {
+				return fmt.Errorf("failed to read secret: %w", err)
+			}
+			value = strings.TrimRight(line, "\r\n")
+		} else {
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return fmt.Errorf("failed to read secret: %w", err)
+			}
+			value = strings.TrimRight(string(data), "\r\n")
+		}
+	}
+
+	cfg := secretsConfig()
+	if err := secrets.NewKeystore(cfg.Keystore, cfg.KeyFile).Set(name, value); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Secret '%s' saved\n", name)
+	return nil
+}
+
+func listSecrets(cmd *cobra.Command, args []string) error {
+	cfg := secretsConfig()
+	infos, err := secrets.NewKeystore(cfg.Keystore, cfg.KeyFile).List()
+	if err != nil {
+		return err
+	}
+
+	if len(infos) == 0 {
+		fmt.Println("No secrets stored")
+		return nil
+	}
+	for _, info := range infos {
+		fmt.Printf("%-32s %s\n", info.Name, info.UpdatedAt.Format("2006-01-02 15:04:05"))
+	}
+	return nil
+}
+
+func removeSecret(cmd *cobra.Command, args []string) error {
+	cfg := secretsConfig()
+	removed, err := secrets.NewKeystore(cfg.Keystore, cfg.KeyFile).Delete(args[0])
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return fmt.Errorf("%w: %s", secrets.ErrSecretNotFound, args[0])
+	}
+
+	fmt.Printf("🗑️  Secret '%s' removed\n", args[0])
+	return nil
+}
@@ -0,0 +1,114 @@
+#!/usr/bin/env python3
+"""Example conduktr plugin with actions and a trigger.
+
+Copy this file into the plugins/ directory and make it executable. The host
+sends one JSON request per line on stdin and expects one JSON response per
+line on stdout. Use stderr for logging.
+
+The hello.ticker trigger sends a hello.tick event every `interval` seconds
+once the host starts it.
+"""
+import json
+import sys
+import threading
+import time
+
+MANIFEST = {
+    "name": "hello",
+    "version": "1.0.0",
+    "actions": [
+        {
+            "name": "hello.greet",
+            "description": "Build a greeting",
+            "input_schema": {
+                "type": "object",
+                "properties": {"person": {"type": "string"}},
+                "required": ["person"],
+            },
+        },
+        {
+            "name": "hello.wait",
+            "description": "Sleep for a number of seconds, honouring cancellation",
+            "input_schema": {
+                "type": "object",
+                "properties": {"seconds": {"type": "number"}},
+            },
+        },
+    ],
+    "triggers": [
+        {
+            "type": "hello.ticker",
+            "description": "Emit hello.tick events on an interval",
+            "config_schema": {
+                "type": "object",
+                "properties": {"interval": {"type": "number"}},
+                "required": ["interval"],
+            },
+        },
+    ],
+}
+
+write_lock = threading.Lock()
+cancelled = set()
+tickers = {}
+
+
+def reply(message):
+    with write_lock:
+        sys.stdout.write(json.dumps(message) + "\n")
+        sys.stdout.flush()
+
+
+def execute(request_id, params):
+    action = params["action"]
+    data = params.get("input", {})
+    if action == "hello.greet":
+        print(f"greeting {data['person']}", file=sys.stderr, flush=True)
+        reply({"id": request_id, "result": {"output": {"message": f"Hello, {data['person']}!"}}})
+    elif action == "hello.wait":
+        end = time.time() + float(data.get("seconds", 1))
+        while time.time() < end:
+            if request_id in cancelled:
+                return
+            time.sleep(0.05)
+        reply({"id": request_id, "result": {"output": {"waited": data.get("seconds", 1)}}})
+    else:
+        reply({"id": request_id, "error": {"message": f"unknown action {action}"}})
+
+
+def tick(name, interval, stop):
+    count = 0
+    while not stop.wait(interval):
+        count += 1
+        reply({"method": "event", "params": {"trigger": name, "event": "hello.tick", "data": {"count": count}}})
+
+
+def start_trigger(request_id, params):
+    name = params["name"]
+    stop = threading.Event()
+    tickers[name] = stop
+    interval = float(params["config"]["interval"])
+    threading.Thread(target=tick, args=(name, interval, stop), daemon=True).start()
+    reply({"id": request_id, "result": {}})
+
+
+def stop_trigger(request_id, params):
+    stop = tickers.pop(params["name"], None)
+    if stop:
+        stop.set()
+    reply({"id": request_id, "result": {}})
+
+
+for line in sys.stdin:
+    request = json.loads(line)
+    method = request.get("method")
+    if method == "describe":
+        reply({"id": request["id"], "result": MANIFEST})
+    elif method == "execute":
+        threading.Thread(target=execute, args=(request["id"], request["params"]), daemon=True).start()
+    elif method == "cancel":
+        cancelled.add(request["params"]["id"])
+    elif method == "trigger.start":
+        start_trigger(request["id"], request["params"])
+    elif method == "trigger.stop":
+        stop_trigger(request["id"], request["params"])
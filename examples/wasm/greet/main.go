@@ -0,0 +1,75 @@
+//go:build wasip1
+
+// Command greet is an example wasm.run module. It reads the step input as
+// JSON on stdin, looks up a workflow variable through the host API and
+// writes its output as JSON on stdout.
+//
+//	GOOS=wasip1 GOARCH=wasm go build -o greet.wasm ./examples/wasm/greet
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"unsafe"
+)
+
+//go:wasmimport conduktr log
+func hostLog(level, ptr, length uint32)
+
+//go:wasmimport conduktr get_var
+func hostGetVar(namePtr, nameLen, bufPtr, bufLen uint32) int32
+
+// logInfo sends a message to the conduktr log
+func logInfo(message string) {
+	data := []byte(message)
+	if len(data) == 0 {
+		return
+	}
+	hostLog(1, uint32(uintptr(unsafe.Pointer(&data[0]))), uint32(len(data)))
+}
+
+// getVar reads a workflow variable, reporting whether it is set
+func getVar(name string, value interface{}) bool {
+	key := []byte(name)
+	buf := make([]byte, 256)
+	for {
+		n := hostGetVar(uint32(uintptr(unsafe.Pointer(&key[0]))), uint32(len(key)),
+			uint32(uintptr(unsafe.Pointer(&buf[0]))), uint32(len(buf)))
+		if n < 0 {
+			return false
+		}
+		if int(n) <= len(buf) {
+			return json.Unmarshal(buf[:n], value) == nil
+		}
+		buf = make([]byte, n)
+	}
+}
+
+func main() {
+	var input struct {
+		Person string `json:"person"`
+		// GreetingVar names a variable, such as an earlier step's output
+		GreetingVar string `json:"greeting_var"`
+	}
+	if err := json.NewDecoder(os.Stdin).Decode(&input); err != nil {
+		fmt.Fprintln(os.Stderr, "invalid input:", err)
+		os.Exit(1)
+	}
+	if input.Person == "" {
+		fmt.Fprintln(os.Stderr, "person is required")
+		os.Exit(1)
+	}
+
+	greeting := "Hello"
+	if input.GreetingVar != "" {
+		getVar(input.GreetingVar, &greeting)
+	}
+	logInfo("greeting " + input.Person)
+
+	json.NewEncoder(os.Stdout).Encode(map[string]interface{}{
+		"message": fmt.Sprintf("%s, %s!", greeting, input.Person),
+		"shout":   strings.ToUpper(input.Person),
+	})
+}
@@ -0,0 +1,225 @@
+package actions
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// ErrApprovalPending is returned by the approval action to signal that the
+// engine should pause the instance until a decision is recorded
+var ErrApprovalPending = errors.New("approval pending")
+
+// ApprovalTokenKey is the output key holding the tokens a decision must
+// present, by approver; the key is empty when anyone may decide. The engine
+// removes it from the output before anything is stored.
+const ApprovalTokenKey = "approval_token"
+
+// PendingApproval is the error the approval action returns. It wraps
+// ErrApprovalPending and holds the notification, which the engine sends
+// once the approval and the paused instance are saved.
+type PendingApproval struct {
+	action   *ApprovalAction
+	notify   map[string]interface{}
+	approval map[string]interface{}
+	tokens   map[string]string
+}
+
+func (p *PendingApproval) Error() string { return ErrApprovalPending.Error() }
+
+func (p *PendingApproval) Unwrap() error { return ErrApprovalPending }
+
+// Notify sends the approval request when the step configures notify
+func (p *PendingApproval) Notify(ctx context.Context) error {
+	if p.notify == nil {
+		return nil
+	}
+	if info, ok := StepInfoFromContext(ctx); ok {
+		ctx = WithStepInfo(ctx, info.Sub("notify"))
+	}
+	return p.action.notify(ctx, p.notify, p.approval, p.tokens)
+}
+
+// ApprovalAction implements human approval gates
+type ApprovalAction struct {
+	logger   *zap.Logger
+	registry *Registry
+}
+
+// NewApprovalAction creates a new approval action
+func NewApprovalAction(logger *zap.Logger, registry *Registry) *ApprovalAction {
+	return &ApprovalAction{
+		logger:   logger,
+		registry: registry,
+	}
+}
+
+// Execute creates a pending approval; the PendingApproval it returns sends
+// the optional notification
+func (a *ApprovalAction) Execute(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+	message, _ := input["message"].(string)
+	if message == "" {
+		message = "Approval required"
+	}
+
+	approvers := parseStringList(input["approvers"])
+
+	// Set expiry (default 24 hours)
+	expiresIn := 24 * time.Hour
+	if e, ok := input["expires_in"].(string); ok && e != "" {
+		d, err := time.ParseDuration(e)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expires_in: %w", err)
+		}
+		expiresIn = d
+	}
+
+	approvalID := uuid.New().String()
+	expiresAt := time.Now().Add(expiresIn)
+
+	// Each approver gets a token of their own, so a decision names who made it
+	tokens := make(map[string]string)
+	holders := approvers
+	if len(holders) == 0 {
+		holders = []string{""}
+	}
+	for _, holder := range holders {
+		token, err := newApprovalToken()
+		if err != nil {
+			return nil, err
+		}
+		tokens[holder] = token
+	}
+
+	result := map[string]interface{}{
+		"approval_id": approvalID,
+		"message":     message,
+		"approvers":   approvers,
+		"expires_at":  expiresAt.Format(time.RFC3339),
+		"status":      "pending",
+	}
+	pending := &PendingApproval{action: a, approval: result, tokens: tokens}
+	if notify, ok := input["notify"].(map[string]interface{}); ok {
+		pending.notify = notify
+	}
+
+	a.logger.Info("Approval requested",
+		zap.String("approval_id", approvalID),
+		zap.Strings("approvers", approvers),
+		zap.Time("expires_at", expiresAt))
+
+	output := make(map[string]interface{}, len(result)+1)
+	for key, value := range result {
+		output[key] = value
+	}
+	output[ApprovalTokenKey] = tokens
+	return output, pending
+}
+
+// newApprovalToken returns a random token for deciding one approval
+func newApprovalToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate approval token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// notify sends the approval request through another registered action. The
+// default body carries a decision token, so only recipients can decide; each
+// approver is sent their own.
+func (a *ApprovalAction) notify(ctx context.Context, notify map[string]interface{}, approval map[string]interface{}, tokens map[string]string) error {
+	actionName, ok := notify["action"].(string)
+	if !ok || actionName == "" {
+		return fmt.Errorf("notify.action is required")
+	}
+	if actionName == "approval.request" {
+		return fmt.Errorf("notify.action cannot be approval.request")
+	}
+
+	action, err := a.registry.GetAction(actionName)
+	if err != nil {
+		return err
+	}
+
+	notifyInput := func() map[string]interface{} {
+		input := make(map[string]interface{}, len(notify))
+		for key, value := range notify {
+			if key != "action" {
+				input[key] = value
+			}
+		}
+		return input
+	}
+
+	// An explicit body carries no token and is sent once
+	if _, ok := notify["body"]; ok {
+		_, err = action.Execute(ctx, notifyInput())
+		return err
+	}
+
+	holders := make([]string, 0, len(tokens))
+	for holder := range tokens {
+		holders = append(holders, holder)
+	}
+	sort.Strings(holders)
+
+	id := approval["approval_id"].(string)
+	for _, holder := range holders {
+		token := tokens[holder]
+		input := notifyInput()
+		body := map[string]interface{}{
+			"approval_id": id,
+			"message":     approval["message"],
+			"approvers":   approval["approvers"],
+			"expires_at":  approval["expires_at"],
+			"token":       token,
+			"approve_url": fmt.Sprintf("/approvals/%s/approve?token=%s", id, token),
+			"reject_url":  fmt.Sprintf("/approvals/%s/reject?token=%s", id, token),
+		}
+		if holder != "" {
+			body["approver"] = holder
+		}
+		input["body"] = body
+		if _, err := action.Execute(ctx, input); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseStringList accepts a list or a comma-separated string
+func parseStringList(value interface{}) []string {
+	result := make([]string, 0)
+
+	switch v := value.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if s := strings.TrimSpace(fmt.Sprintf("%v", item)); s != "" {
+				result = append(result, s)
+			}
+		}
+	case []string:
+		for _, item := range v {
+			if s := strings.TrimSpace(item); s != "" {
+				result = append(result, s)
+			}
+		}
+	case string:
+		for _, item := range strings.Split(v, ",") {
+			if s := strings.TrimSpace(item); s != "" {
+				result = append(result, s)
+			}
+		}
+	}
+
+	return result
+}
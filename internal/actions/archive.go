@@ -0,0 +1,482 @@
+package actions
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// defaultMaxExtractBytes caps what one archive.extract step writes unless
+// configured, so a small archive cannot fill the disk
+const defaultMaxExtractBytes = 1 << 30
+
+// ArchiveAction creates and extracts zip and tar archives inside the roots
+// allowed by the file policy. It is registered as archive.zip, archive.tar
+// and archive.extract.
+type ArchiveAction struct {
+	logger    *zap.Logger
+	files     *fileSystem
+	operation string
+}
+
+// NewArchiveAction creates the archive action for an operation, using the registry's file policy
+func NewArchiveAction(logger *zap.Logger, registry *Registry, operation string) *ArchiveAction {
+	return &ArchiveAction{logger: logger, files: registry.files, operation: operation}
+}
+
+// TypedFields keeps the type of a source list given as a single template reference
+func (a *ArchiveAction) TypedFields(config map[string]interface{}) []string {
+	return []string{"sources"}
+}
+
+// Execute runs the operation
+func (a *ArchiveAction) Execute(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+	if a.operation == "extract" {
+		return a.extract(ctx, input)
+	}
+	return a.create(ctx, input)
+}
+
+// archiveEntry is a file or directory added to an archive
+type archiveEntry struct {
+	path string
+	name string
+	info fs.FileInfo
+}
+
+// create writes the sources into a new archive. Each source is stored
+// under its own name, with directories included recursively.
+func (a *ArchiveAction) create(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+	var sources []string
+	switch v := input["sources"].(type) {
+	case string:
+		sources = []string{v}
+	case []interface{}:
+		for _, item := range v {
+			source, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("sources must be paths")
+			}
+			sources = append(sources, source)
+		}
+	}
+	if source, _ := input["source"].(string); source != "" {
+		sources = append(sources, source)
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("sources parameter is required")
+	}
+	destination, _ := input["destination"].(string)
+	if destination == "" {
+		return nil, fmt.Errorf("destination parameter is required")
+	}
+	realDestination, err := a.files.resolve(destination, true)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Lstat(realDestination); err == nil && !boolInput(input, "overwrite", false) {
+		return nil, fmt.Errorf("%s already exists; set overwrite to replace it", destination)
+	}
+
+	var entries []archiveEntry
+	for _, source := range sources {
+		real, err := a.files.resolve(source, false)
+		if err != nil {
+			return nil, err
+		}
+		collected, err := collectEntries(real, realDestination, a.files.isProtected)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, collected...)
+	}
+
+	if boolInput(input, "create_dirs", true) {
+		if err := os.MkdirAll(filepath.Dir(realDestination), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(realDestination), "."+filepath.Base(realDestination)+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if a.operation == "zip" {
+		err = writeZip(ctx, tmp, entries)
+	} else {
+		compress := boolInput(input, "gzip", strings.HasSuffix(destination, ".gz") || strings.HasSuffix(destination, ".tgz"))
+		err = writeTar(ctx, tmp, entries, compress)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", destination, err)
+	}
+	if err := os.Rename(tmp.Name(), realDestination); err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(realDestination)
+	if err != nil {
+		return nil, err
+	}
+	files := 0
+	for _, entry := range entries {
+		if !entry.info.IsDir() {
+			files++
+		}
+	}
+	a.logger.Info("Archive created", zap.String("path", realDestination), zap.Int("files", files))
+	return map[string]interface{}{
+		"path":  realDestination,
+		"files": files,
+		"size":  info.Size(),
+	}, nil
+}
+
+// collectEntries lists a source and, for a directory, everything inside
+// it. Symbolic links are skipped, as are the archive being written and
+// protected paths.
+func collectEntries(source, skip string, protected func(string) bool) ([]archiveEntry, error) {
+	base := filepath.Dir(source)
+	var entries []archiveEntry
+	err := filepath.WalkDir(source, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if protected(p) {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if p == skip || !(entry.IsDir() || entry.Type().IsRegular()) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(base, p)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, archiveEntry{path: p, name: filepath.ToSlash(rel), info: info})
+		return nil
+	})
+	return entries, err
+}
+
+// writeZip writes entries as a zip archive
+func writeZip(ctx context.Context, w io.Writer, entries []archiveEntry) error {
+	zw := zip.NewWriter(w)
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		header, err := zip.FileInfoHeader(entry.info)
+		if err != nil {
+			return err
+		}
+		header.Name = entry.name
+		if entry.info.IsDir() {
+			header.Name += "/"
+		} else {
+			header.Method = zip.Deflate
+		}
+		out, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		if !entry.info.IsDir() {
+			if err := copyFrom(out, entry.path); err != nil {
+				return err
+			}
+		}
+	}
+	return zw.Close()
+}
+
+// writeTar writes entries as a tar archive, gzip compressed with compress
+func writeTar(ctx context.Context, w io.Writer, entries []archiveEntry, compress bool) error {
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(w)
+		w = gz
+	}
+	tw := tar.NewWriter(w)
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(entry.info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = entry.name
+		if entry.info.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !entry.info.IsDir() {
+			if err := copyFrom(tw, entry.path); err != nil {
+				return err
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if gz != nil {
+		return gz.Close()
+	}
+	return nil
+}
+
+// copyFrom copies a file's contents to w
+func copyFrom(w io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(w, file)
+	return err
+}
+
+// extractor writes archive entries below a destination directory,
+// refusing entries that would land outside it and stopping once the
+// extracted bytes pass the limit
+type extractor struct {
+	destination string
+	overwrite   bool
+	remaining   int64
+	limit       int64
+	files       int
+	skipped     []string
+}
+
+// target returns where an entry is written, or "" for the root itself
+func (x *extractor) target(name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if path.IsAbs(name) {
+		return "", fmt.Errorf("entry %q has an absolute path", name)
+	}
+	clean := path.Clean(name)
+	if clean == "." {
+		return "", nil
+	}
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("entry %q escapes the destination", name)
+	}
+	// Resolving links catches directories replaced by links to elsewhere
+	real, err := realPath(filepath.Join(x.destination, filepath.FromSlash(clean)))
+	if err != nil {
+		return "", err
+	}
+	if !insideRoots(real, []string{x.destination}) {
+		return "", fmt.Errorf("entry %q escapes the destination", name)
+	}
+	return real, nil
+}
+
+// dir creates a directory entry
+func (x *extractor) dir(name string) error {
+	target, err := x.target(name)
+	if err != nil || target == "" {
+		return err
+	}
+	return os.MkdirAll(target, 0o755)
+}
+
+// file writes a regular file entry
+func (x *extractor) file(name string, r io.Reader, mode fs.FileMode) error {
+	target, err := x.target(name)
+	if err != nil || target == "" {
+		return err
+	}
+	if _, err := os.Lstat(target); err == nil && !x.overwrite {
+		return fmt.Errorf("%s already exists; set overwrite to replace it", target)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+
+	limited := &io.LimitedReader{R: r, N: x.remaining + 1}
+	if err := writeFileAtomic(target, limited, mode.Perm()|0o600); err != nil {
+		return err
+	}
+	x.remaining = limited.N - 1
+	if x.remaining < 0 {
+		os.Remove(target)
+		return fmt.Errorf("archive expands to more than %d bytes", x.limit)
+	}
+	x.files++
+	return nil
+}
+
+// extract unpacks a zip or tar archive into a directory. Links and special
+// files in the archive are skipped.
+func (a *ArchiveAction) extract(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+	source, _ := input["source"].(string)
+	destination, _ := input["destination"].(string)
+	if source == "" || destination == "" {
+		return nil, fmt.Errorf("source and destination parameters are required")
+	}
+	realSource, err := a.files.resolve(source, false)
+	if err != nil {
+		return nil, err
+	}
+	realDestination, err := a.files.resolve(destination, true)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := intInput(input, "max_bytes", a.files.maxExtractBytes())
+	if err != nil {
+		return nil, err
+	}
+	if max := a.files.maxExtractBytes(); limit > max {
+		limit = max
+	}
+
+	format, _ := input["format"].(string)
+	if format == "" {
+		format = archiveFormat(source)
+	}
+	if err := os.MkdirAll(realDestination, 0o755); err != nil {
+		return nil, err
+	}
+	x := &extractor{
+		destination: realDestination,
+		overwrite:   boolInput(input, "overwrite", false),
+		remaining:   limit,
+		limit:       limit,
+		skipped:     make([]string, 0),
+	}
+
+	switch format {
+	case "zip":
+		err = extractZip(ctx, realSource, x)
+	case "tar", "tar.gz":
+		err = extractTar(ctx, realSource, format == "tar.gz", x)
+	default:
+		return nil, fmt.Errorf("format must be zip, tar or tar.gz")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract %s: %w", source, err)
+	}
+
+	a.logger.Info("Archive extracted",
+		zap.String("source", realSource),
+		zap.String("destination", realDestination),
+		zap.Int("files", x.files))
+	return map[string]interface{}{
+		"destination": realDestination,
+		"files":       x.files,
+		"bytes":       limit - x.remaining,
+		"skipped":     x.skipped,
+	}, nil
+}
+
+// archiveFormat guesses the format of an archive from its name
+func archiveFormat(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return "zip"
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return "tar.gz"
+	case strings.HasSuffix(lower, ".tar"):
+		return "tar"
+	}
+	return ""
+}
+
+func extractZip(ctx context.Context, source string, x *extractor) error {
+	zr, err := zip.OpenReader(source)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, entry := range zr.File {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		mode := entry.Mode()
+		switch {
+		case mode.IsDir():
+			err = x.dir(entry.Name)
+		case mode.IsRegular():
+			var r io.ReadCloser
+			if r, err = entry.Open(); err == nil {
+				err = x.file(entry.Name, r, mode)
+				r.Close()
+			}
+		default:
+			x.skipped = append(x.skipped, entry.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractTar(ctx context.Context, source string, compressed bool, x *extractor) error {
+	file, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if compressed {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = x.dir(header.Name)
+		case tar.TypeReg:
+			err = x.file(header.Name, tr, fs.FileMode(header.Mode))
+		default:
+			x.skipped = append(x.skipped, header.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package actions
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type testEntry struct {
+	name     string
+	body     string
+	linkname string
+}
+
+func writeTestZip(t *testing.T, path string, entries []testEntry) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	zw := zip.NewWriter(file)
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry.name, Method: zip.Deflate}
+		header.SetMode(0o644)
+		body := entry.body
+		if entry.linkname != "" {
+			header.SetMode(os.ModeSymlink | 0o777)
+			body = entry.linkname
+		}
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTestTar(t *testing.T, path string, entries []testEntry) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	tw := tar.NewWriter(file)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Mode: 0o644, Size: int64(len(entry.body)), Typeflag: tar.TypeReg}
+		if entry.linkname != "" {
+			header = &tar.Header{Name: entry.name, Linkname: entry.linkname, Mode: 0o777, Typeflag: tar.TypeSymlink}
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(entry.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArchiveExtractRejectsEscapes(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []testEntry
+		wantErr string
+	}{
+		{name: "zip slip", entries: []testEntry{{name: "../evil.txt", body: "x"}}, wantErr: "escapes"},
+		{name: "nested zip slip", entries: []testEntry{{name: "a/../../evil.txt", body: "x"}}, wantErr: "escapes"},
+		{name: "absolute", entries: []testEntry{{name: "/tmp/evil.txt", body: "x"}}, wantErr: "absolute"},
+		{name: "backslashes", entries: []testEntry{{name: `..\evil.txt`, body: "x"}}, wantErr: "escapes"},
+	}
+
+	for _, format := range []string{"zip", "tar"} {
+		for _, tt := range tests {
+			t.Run(format+"/"+tt.name, func(t *testing.T) {
+				registry, root := newTestFiles(t)
+				archive := filepath.Join(root, "in."+format)
+				if format == "zip" {
+					writeTestZip(t, archive, tt.entries)
+				} else {
+					writeTestTar(t, archive, tt.entries)
+				}
+
+				_, err := runFileAction(t, registry, "archive.extract", map[string]interface{}{"source": archive, "destination": "out"})
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				if _, err := os.Stat(filepath.Join(root, "evil.txt")); !os.IsNotExist(err) {
+					t.Error("entry was written outside the destination")
+				}
+			})
+		}
+	}
+}
+
+func TestArchiveExtractSkipsSymlinks(t *testing.T) {
+	outside := t.TempDir()
+	entries := []testEntry{
+		{name: "link", linkname: outside},
+		{name: "link/evil.txt", body: "x"},
+	}
+
+	for _, format := range []string{"zip", "tar"} {
+		t.Run(format, func(t *testing.T) {
+			registry, root := newTestFiles(t)
+			archive := filepath.Join(root, "in."+format)
+			if format == "zip" {
+				writeTestZip(t, archive, entries)
+			} else {
+				writeTestTar(t, archive, entries)
+			}
+
+			result, err := runFileAction(t, registry, "archive.extract", map[string]interface{}{"source": archive, "destination": "out"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if skipped := result["skipped"].([]string); len(skipped) != 1 || skipped[0] != "link" {
+				t.Errorf("skipped = %v, want [link]", skipped)
+			}
+			if info, err := os.Lstat(filepath.Join(root, "out", "link")); err != nil || info.Mode()&os.ModeSymlink != 0 {
+				t.Errorf("link entry was extracted as a symlink: %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(outside, "evil.txt")); !os.IsNotExist(err) {
+				t.Error("entry was written through a link")
+			}
+		})
+	}
+}
+
+func TestArchiveExtractRejectsExistingSymlink(t *testing.T) {
+	registry, root := newTestFiles(t)
+	outside := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "out"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "out", "link")); err != nil {
+		t.Fatal(err)
+	}
+	archive := filepath.Join(root, "in.zip")
+	writeTestZip(t, archive, []testEntry{{name: "link/evil.txt", body: "x"}})
+
+	_, err := runFileAction(t, registry, "archive.extract", map[string]interface{}{"source": archive, "destination": "out"})
+	if err == nil || !strings.Contains(err.Error(), "escapes") {
+		t.Fatalf("err = %v, want escape error", err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "evil.txt")); !os.IsNotExist(err) {
+		t.Error("entry was written through an existing link")
+	}
+}
+
+func TestArchiveExtractLimit(t *testing.T) {
+	registry, root := newTestFiles(t)
+	archive := filepath.Join(root, "in.zip")
+	writeTestZip(t, archive, []testEntry{{name: "big.txt", body: strings.Repeat("x", 1024)}})
+
+	_, err := runFileAction(t, registry, "archive.extract", map[string]interface{}{"source": archive, "destination": "out", "max_bytes": 100})
+	if err == nil || !strings.Contains(err.Error(), "more than 100 bytes") {
+		t.Fatalf("err = %v, want limit error", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "out", "big.txt")); !os.IsNotExist(err) {
+		t.Error("oversized entry left behind")
+	}
+}
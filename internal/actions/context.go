@@ -0,0 +1,61 @@
+package actions
+
+import (
+	"context"
+	"strings"
+
+	"github.com/logimos/conduktr/internal/persistence"
+)
+
+// StepInfo identifies the workflow step an action runs for
+type StepInfo struct {
+	Workflow   string
+	Step       string
+	InstanceID string
+	// Templated lists the config keys whose values contain templates.
+	// Keys of nested maps appear as "parent.key".
+	Templated []string
+	// Variables are the workflow's variables; actions must not modify them
+	Variables map[string]interface{}
+	// Event is the event that started the workflow
+	Event *persistence.Event
+	// Steps are the states of the steps run so far
+	Steps map[string]*persistence.StepState
+}
+
+// IsTemplated reports whether a config key was rendered from a template
+func (i StepInfo) IsTemplated(key string) bool {
+	for _, templated := range i.Templated {
+		if templated == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Sub returns the step info for an action run with the nested config under
+// key, such as the notification an approval sends
+func (i StepInfo) Sub(key string) StepInfo {
+	prefix := key + "."
+	sub := i
+	sub.Templated = nil
+	for _, templated := range i.Templated {
+		if strings.HasPrefix(templated, prefix) {
+			sub.Templated = append(sub.Templated, strings.TrimPrefix(templated, prefix))
+		}
+	}
+	return sub
+}
+
+type stepInfoKey struct{}
+
+// WithStepInfo returns a context carrying the current step
+func WithStepInfo(ctx context.Context, info StepInfo) context.Context {
+	return context.WithValue(ctx, stepInfoKey{}, info)
+}
+
+// StepInfoFromContext returns the step an action runs for, if known
+func StepInfoFromContext(ctx context.Context) (StepInfo, bool) {
+	info, ok := ctx.Value(stepInfoKey{}).(StepInfo)
+	return info, ok
+}
@@ -0,0 +1,550 @@
+package actions
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql" // MySQL driver
+	_ "github.com/lib/pq"              // PostgreSQL driver
+	"go.uber.org/zap"
+	_ "modernc.org/sqlite" // SQLite driver
+)
+
+const (
+	// defaultDBTimeout bounds a statement unless the step sets a timeout
+	defaultDBTimeout = 30 * time.Second
+	// defaultDBMaxRows caps the rows db.query returns unless the step sets max_rows
+	defaultDBMaxRows = 1000
+	// defaultTxIdleTimeout rolls back a transaction left open between steps
+	defaultTxIdleTimeout = 5 * time.Minute
+)
+
+// databaseDrivers maps configured driver names to database/sql drivers
+var databaseDrivers = map[string]string{
+	"postgres":   "postgres",
+	"postgresql": "postgres",
+	"mysql":      "mysql",
+	"sqlite":     "sqlite",
+	"sqlite3":    "sqlite",
+}
+
+// DatabaseConfig is a named connection db.query and db.exec steps use
+type DatabaseConfig struct {
+	// Driver is postgres, mysql or sqlite
+	Driver string `json:"driver"`
+	DSN    string `json:"-"`
+	// MaxOpenConns and MaxIdleConns size the pool; zero keeps the defaults
+	MaxOpenConns int `json:"max_open_conns,omitempty"`
+	MaxIdleConns int `json:"max_idle_conns,omitempty"`
+	// ConnMaxLifetime closes connections older than this
+	ConnMaxLifetime time.Duration `json:"conn_max_lifetime,omitempty"`
+	// TxIdleTimeout rolls back a transaction no step has used for this long
+	TxIdleTimeout time.Duration `json:"tx_idle_timeout,omitempty"`
+}
+
+// database is an open connection pool and its settings
+type database struct {
+	db          *sql.DB
+	idleTimeout time.Duration
+}
+
+// openTx is a transaction spanning steps of one instance
+type openTx struct {
+	mu     sync.Mutex
+	tx     *sql.Tx
+	cancel context.CancelFunc
+	timer  *time.Timer
+	// failed is why the transaction was rolled back before it was finished
+	failed error
+}
+
+// databases holds the configured connections and the open transactions,
+// keyed by instance ID and connection name
+type databases struct {
+	mu           sync.Mutex
+	connections  map[string]*database
+	transactions map[[2]string]*openTx
+}
+
+func newDatabases() *databases {
+	return &databases{
+		connections:  make(map[string]*database),
+		transactions: make(map[[2]string]*openTx),
+	}
+}
+
+// open adds a named connection pool. The database is not contacted until a
+// step uses it.
+func (d *databases) open(name string, config DatabaseConfig) error {
+	driver, ok := databaseDrivers[strings.ToLower(config.Driver)]
+	if !ok {
+		return fmt.Errorf("database %s: unsupported driver %q", name, config.Driver)
+	}
+	if config.DSN == "" {
+		return fmt.Errorf("database %s: dsn is required", name)
+	}
+	db, err := sql.Open(driver, config.DSN)
+	if err != nil {
+		return fmt.Errorf("database %s: %w", name, err)
+	}
+	if config.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(config.MaxOpenConns)
+	}
+	if config.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(config.MaxIdleConns)
+	}
+	if config.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(config.ConnMaxLifetime)
+	}
+	idleTimeout := config.TxIdleTimeout
+	if idleTimeout <= 0 {
+		idleTimeout = defaultTxIdleTimeout
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if previous, exists := d.connections[name]; exists {
+		previous.db.Close()
+	}
+	d.connections[name] = &database{db: db, idleTimeout: idleTimeout}
+	return nil
+}
+
+// connection returns the named pool, or the only one when no name is given
+func (d *databases) connection(name string) (string, *database, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if name == "" && len(d.connections) == 1 {
+		for only := range d.connections {
+			name = only
+		}
+	}
+	db, err := lookup("database", d.connections, name)
+	return name, db, err
+}
+
+// begin starts a transaction for an instance. It outlives the step that
+// begins it, so it has its own context, cancelled when it ends.
+func (d *databases) begin(ctx context.Context, key [2]string, db *database) (*openTx, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, exists := d.transactions[key]; exists {
+		return nil, fmt.Errorf("a transaction is already open on %s", key[1])
+	}
+
+	txCtx, cancel := context.WithCancel(context.Background())
+	tx, err := beginTx(ctx, txCtx, db.db)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	t := &openTx{tx: tx, cancel: cancel}
+	t.timer = time.AfterFunc(db.idleTimeout, func() {
+		t.rollback(fmt.Errorf("transaction idle for more than %s", db.idleTimeout))
+	})
+	d.transactions[key] = t
+	return t, nil
+}
+
+// beginTx begins a transaction bound to txCtx, giving up when the step's
+// ctx is done first
+func beginTx(ctx, txCtx context.Context, db *sql.DB) (*sql.Tx, error) {
+	type result struct {
+		tx  *sql.Tx
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		tx, err := db.BeginTx(txCtx, nil)
+		done <- result{tx, err}
+	}()
+	select {
+	case r := <-done:
+		return r.tx, r.err
+	case <-ctx.Done():
+		go func() {
+			if r := <-done; r.tx != nil {
+				r.tx.Rollback()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// transaction returns the instance's open transaction on a connection
+func (d *databases) transaction(key [2]string) *openTx {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.transactions[key]
+}
+
+// forget drops a finished transaction
+func (d *databases) forget(key [2]string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.transactions, key)
+}
+
+// finishInstance rolls back transactions an instance left open
+func (d *databases) finishInstance(instanceID string) {
+	d.mu.Lock()
+	var open []*openTx
+	for key, t := range d.transactions {
+		if key[0] == instanceID {
+			open = append(open, t)
+			delete(d.transactions, key)
+		}
+	}
+	d.mu.Unlock()
+
+	for _, t := range open {
+		t.rollback(fmt.Errorf("instance finished without committing"))
+	}
+}
+
+// rollback ends the transaction, recording why unless it already ended
+func (t *openTx) rollback(reason error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.tx == nil {
+		return
+	}
+	t.tx.Rollback()
+	t.cancel()
+	t.timer.Stop()
+	t.tx = nil
+	t.failed = reason
+}
+
+// DatabaseAction runs parameterized SQL on a configured connection. Values
+// are only ever passed as params, never templated into the statement.
+//
+// A step with transaction: begin opens a transaction that the following
+// db.query and db.exec steps of the instance on the same connection run in,
+// until a step with transaction: commit or rollback. A failing statement
+// rolls the transaction back, and so does the instance finishing or
+// pausing with it open.
+type DatabaseAction struct {
+	logger    *zap.Logger
+	databases *databases
+	query     bool
+}
+
+// NewDatabaseQueryAction creates the db.query action, returning rows
+func NewDatabaseQueryAction(logger *zap.Logger, registry *Registry) *DatabaseAction {
+	return &DatabaseAction{logger: logger, databases: registry.databases, query: true}
+}
+
+// NewDatabaseExecAction creates the db.exec action, returning affected rows
+func NewDatabaseExecAction(logger *zap.Logger, registry *Registry) *DatabaseAction {
+	return &DatabaseAction{logger: logger, databases: registry.databases}
+}
+
+// TypedFields keeps the types of params given as single template references
+func (d *DatabaseAction) TypedFields(config map[string]interface{}) []string {
+	return []string{"params"}
+}
+
+// FinishInstance rolls back transactions the instance left open
+func (d *DatabaseAction) FinishInstance(instanceID string) {
+	d.databases.finishInstance(instanceID)
+}
+
+// Execute runs the statement, in the instance's transaction if one is open
+func (d *DatabaseAction) Execute(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+	info, _ := StepInfoFromContext(ctx)
+	statement, _ := input["sql"].(string)
+	if info.IsTemplated("sql") {
+		return nil, fmt.Errorf("sql must not contain templates; pass values in params")
+	}
+
+	mode, _ := input["transaction"].(string)
+	switch mode {
+	case "", "begin", "commit", "rollback":
+	default:
+		return nil, fmt.Errorf("transaction must be begin, commit or rollback")
+	}
+	if statement == "" && mode == "" {
+		return nil, fmt.Errorf("sql parameter is required")
+	}
+
+	params, err := statementParams(input["params"])
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := durationInput(input, "timeout", defaultDBTimeout)
+	if err != nil {
+		return nil, err
+	}
+	maxRows, err := intInput(input, "max_rows", defaultDBMaxRows)
+	if err != nil {
+		return nil, err
+	}
+
+	connection, _ := input["connection"].(string)
+	name, db, err := d.databases.connection(connection)
+	if err != nil {
+		return nil, err
+	}
+
+	stepCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	key := [2]string{info.InstanceID, name}
+	var t *openTx
+	if mode == "begin" {
+		if info.InstanceID == "" {
+			return nil, fmt.Errorf("transactions need a workflow instance")
+		}
+		if t, err = d.databases.begin(stepCtx, key, db); err != nil {
+			return nil, err
+		}
+	} else if info.InstanceID != "" {
+		t = d.databases.transaction(key)
+	}
+	if t == nil && (mode == "commit" || mode == "rollback") {
+		return nil, fmt.Errorf("no transaction is open on %s", name)
+	}
+
+	if t == nil {
+		return d.run(stepCtx, db.db, statement, params, maxRows)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.tx == nil {
+		d.databases.forget(key)
+		return nil, fmt.Errorf("transaction on %s was rolled back: %w", name, t.failed)
+	}
+	t.timer.Reset(db.idleTimeout)
+
+	output := map[string]interface{}{}
+	if mode == "rollback" {
+		t.tx.Rollback()
+	} else if statement != "" {
+		if output, err = d.run(stepCtx, t.tx, statement, params, maxRows); err != nil {
+			t.tx.Rollback()
+			t.failed = err
+			t.tx = nil
+			t.cancel()
+			t.timer.Stop()
+			return nil, fmt.Errorf("%w (transaction rolled back)", err)
+		}
+	}
+
+	switch mode {
+	case "commit":
+		err = t.tx.Commit()
+	case "rollback":
+	default:
+		output["transaction"] = "open"
+		return output, nil
+	}
+	t.tx = nil
+	t.cancel()
+	t.timer.Stop()
+	d.databases.forget(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	if mode == "commit" {
+		output["transaction"] = "committed"
+	} else {
+		output["transaction"] = "rolled_back"
+	}
+	return output, nil
+}
+
+// querier is a connection pool or a transaction
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// run executes the statement and describes its result
+func (d *DatabaseAction) run(ctx context.Context, q querier, statement string, params []interface{}, maxRows int64) (map[string]interface{}, error) {
+	if !d.query {
+		result, err := q.ExecContext(ctx, statement, params...)
+		if err != nil {
+			return nil, statementError(ctx, err)
+		}
+		output := make(map[string]interface{})
+		if n, err := result.RowsAffected(); err == nil {
+			output["rows_affected"] = n
+		}
+		if id, err := result.LastInsertId(); err == nil {
+			output["last_insert_id"] = id
+		}
+		return output, nil
+	}
+
+	rows, err := q.QueryContext(ctx, statement, params...)
+	if err != nil {
+		return nil, statementError(ctx, err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = column.Name()
+	}
+
+	results := make([]interface{}, 0)
+	truncated := false
+	for rows.Next() {
+		if int64(len(results)) >= maxRows {
+			truncated = true
+			break
+		}
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, fmt.Errorf("failed to read row: %w", err)
+		}
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			row[names[i]] = columnValue(values[i], column.DatabaseTypeName())
+		}
+		results = append(results, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, statementError(ctx, err)
+	}
+
+	var first interface{}
+	if len(results) > 0 {
+		first = results[0]
+	}
+	return map[string]interface{}{
+		"rows":      results,
+		"row":       first,
+		"count":     len(results),
+		"columns":   names,
+		"truncated": truncated,
+	}, nil
+}
+
+// statementError reports a timeout plainly
+func statementError(ctx context.Context, err error) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("statement timed out: %w", err)
+	}
+	return fmt.Errorf("statement failed: %w", err)
+}
+
+// statementParams reads positional params from a list, or named params
+// (:name, @name or $name as the driver supports) from an object
+func statementParams(value interface{}) ([]interface{}, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		params := make([]interface{}, len(v))
+		for i, param := range v {
+			converted, err := paramValue(param)
+			if err != nil {
+				return nil, fmt.Errorf("params[%d]: %w", i, err)
+			}
+			params[i] = converted
+		}
+		return params, nil
+	case map[string]interface{}:
+		params := make([]interface{}, 0, len(v))
+		for _, name := range sortedKeys(v) {
+			converted, err := paramValue(v[name])
+			if err != nil {
+				return nil, fmt.Errorf("params.%s: %w", name, err)
+			}
+			params = append(params, sql.Named(name, converted))
+		}
+		return params, nil
+	}
+	return nil, fmt.Errorf("params must be a list or an object")
+}
+
+// paramValue converts a JSON value to a driver value. Whole numbers are
+// passed as integers and structured values as JSON text.
+func paramValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int64(v), nil
+		}
+		return v, nil
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	}
+	return value, nil
+}
+
+// columnValue converts a scanned value to JSON, using the column's
+// database type for drivers that return text
+func columnValue(value interface{}, dbType string) interface{} {
+	dbType = strings.ToUpper(dbType)
+	switch v := value.(type) {
+	case []byte:
+		return bytesValue(v, dbType)
+	case string:
+		return bytesValue([]byte(v), dbType)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case int64:
+		// SQLite stores booleans as integers
+		if dbType == "BOOL" || dbType == "BOOLEAN" {
+			return v != 0
+		}
+	}
+	return value
+}
+
+// bytesValue interprets raw column data by its database type
+func bytesValue(data []byte, dbType string) interface{} {
+	text := string(data)
+	switch {
+	case strings.Contains(dbType, "INT"):
+		if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return n
+		}
+	case dbType == "DECIMAL" || dbType == "NUMERIC":
+		// Exact digits, written as a JSON number
+		if _, err := strconv.ParseFloat(text, 64); err == nil {
+			return json.Number(text)
+		}
+	case dbType == "FLOAT" || dbType == "DOUBLE" || dbType == "REAL" || strings.HasPrefix(dbType, "FLOAT"):
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			return f
+		}
+	case dbType == "BOOL" || dbType == "BOOLEAN":
+		if b, err := strconv.ParseBool(text); err == nil {
+			return b
+		}
+	case dbType == "JSON" || dbType == "JSONB":
+		var decoded interface{}
+		if err := json.Unmarshal(data, &decoded); err == nil {
+			return decoded
+		}
+	case dbType == "BYTEA" || strings.Contains(dbType, "BLOB") || strings.Contains(dbType, "BINARY"):
+		return base64.StdEncoding.EncodeToString(data)
+	}
+	return text
+}
@@ -0,0 +1,235 @@
+package actions
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// newTestDatabase returns a registry with an in-memory SQLite database named
+// main holding an empty users table. One connection keeps every statement
+// on the same in-memory database.
+func newTestDatabase(t *testing.T) *Registry {
+	t.Helper()
+	registry := NewRegistry(zap.NewNop())
+	if err := registry.RegisterDatabase("main", DatabaseConfig{Driver: "sqlite", DSN: ":memory:", MaxOpenConns: 1}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { registry.databases.connections["main"].db.Close() })
+
+	runDB(t, registry, "db.exec", "", map[string]interface{}{
+		"sql": "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL, score REAL, active BOOLEAN, tags JSON)",
+	})
+	return registry
+}
+
+// runDB runs a database step as part of an instance, failing the test on error
+func runDB(t *testing.T, registry *Registry, name, instanceID string, input map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	output, err := execDB(registry, name, instanceID, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return output
+}
+
+func execDB(registry *Registry, name, instanceID string, input map[string]interface{}) (map[string]interface{}, error) {
+	action, err := registry.GetAction(name)
+	if err != nil {
+		return nil, err
+	}
+	ctx := WithStepInfo(context.Background(), StepInfo{Workflow: "db", Step: "run", InstanceID: instanceID})
+	return action.Execute(ctx, input)
+}
+
+// userNames returns the names in the users table in id order
+func userNames(t *testing.T, registry *Registry) []interface{} {
+	t.Helper()
+	output := runDB(t, registry, "db.query", "", map[string]interface{}{"sql": "SELECT name FROM users ORDER BY id"})
+	names := make([]interface{}, 0)
+	for _, row := range output["rows"].([]interface{}) {
+		names = append(names, row.(map[string]interface{})["name"])
+	}
+	return names
+}
+
+func TestDatabaseParams(t *testing.T) {
+	registry := newTestDatabase(t)
+
+	hostile := "x'); DROP TABLE users; --"
+	output := runDB(t, registry, "db.exec", "", map[string]interface{}{
+		"sql":    "INSERT INTO users (name, score, active, tags) VALUES (?, ?, ?, ?)",
+		"params": []interface{}{hostile, 2.5, true, []interface{}{"a", "b"}},
+	})
+	if output["rows_affected"] != int64(1) || output["last_insert_id"] != int64(1) {
+		t.Errorf("exec output = %v", output)
+	}
+	runDB(t, registry, "db.exec", "", map[string]interface{}{
+		"sql":    "INSERT INTO users (name, score, active) VALUES (:name, :score, :active)",
+		"params": map[string]interface{}{"name": "bob", "score": float64(3), "active": false},
+	})
+
+	output = runDB(t, registry, "db.query", "", map[string]interface{}{
+		"sql":    "SELECT id, name, score, active, tags FROM users WHERE id = ?",
+		"params": []interface{}{float64(1)},
+	})
+	want := map[string]interface{}{
+		"id":     int64(1),
+		"name":   hostile,
+		"score":  2.5,
+		"active": true,
+		"tags":   []interface{}{"a", "b"},
+	}
+	if !reflect.DeepEqual(output["row"], want) {
+		t.Errorf("row = %#v, want %#v", output["row"], want)
+	}
+	if output["count"] != 1 || !reflect.DeepEqual(output["columns"], []string{"id", "name", "score", "active", "tags"}) {
+		t.Errorf("query output = %v", output)
+	}
+
+	output = runDB(t, registry, "db.query", "", map[string]interface{}{
+		"sql":    "SELECT name, active, tags FROM users WHERE name = @name",
+		"params": map[string]interface{}{"name": "bob"},
+	})
+	if row := output["row"].(map[string]interface{}); row["active"] != false || row["tags"] != nil {
+		t.Errorf("row = %#v", row)
+	}
+}
+
+func TestDatabaseQueryMaxRows(t *testing.T) {
+	registry := newTestDatabase(t)
+	for _, name := range []string{"a", "b", "c"} {
+		runDB(t, registry, "db.exec", "", map[string]interface{}{"sql": "INSERT INTO users (name) VALUES (?)", "params": []interface{}{name}})
+	}
+
+	tests := []struct {
+		name      string
+		maxRows   interface{}
+		count     int
+		truncated bool
+	}{
+		{name: "under the limit", maxRows: float64(5), count: 3},
+		{name: "at the limit", maxRows: float64(3), count: 3},
+		{name: "over the limit", maxRows: float64(2), count: 2, truncated: true},
+		{name: "default", count: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := map[string]interface{}{"sql": "SELECT name FROM users ORDER BY id"}
+			if tt.maxRows != nil {
+				input["max_rows"] = tt.maxRows
+			}
+			output := runDB(t, registry, "db.query", "", input)
+			if output["count"] != tt.count || output["truncated"] != tt.truncated {
+				t.Errorf("count = %v, truncated = %v; want %d, %v", output["count"], output["truncated"], tt.count, tt.truncated)
+			}
+		})
+	}
+
+	output := runDB(t, registry, "db.query", "", map[string]interface{}{"sql": "SELECT name FROM users WHERE name = 'z'"})
+	if output["count"] != 0 || output["row"] != nil {
+		t.Errorf("empty result = %v", output)
+	}
+}
+
+func TestDatabaseTransactions(t *testing.T) {
+	registry := newTestDatabase(t)
+	insert := func(instanceID, name string, extra map[string]interface{}) (map[string]interface{}, error) {
+		input := map[string]interface{}{"sql": "INSERT INTO users (name) VALUES (?)", "params": []interface{}{name}}
+		for key, value := range extra {
+			input[key] = value
+		}
+		return execDB(registry, "db.exec", instanceID, input)
+	}
+
+	// Committed
+	if output, err := insert("i1", "committed", map[string]interface{}{"transaction": "begin"}); err != nil || output["transaction"] != "open" {
+		t.Fatalf("begin = %v, %v", output, err)
+	}
+	if _, err := insert("i1", "committed too", nil); err != nil {
+		t.Fatal(err)
+	}
+	if output := runDB(t, registry, "db.exec", "i1", map[string]interface{}{"transaction": "commit"}); output["transaction"] != "committed" {
+		t.Errorf("commit = %v", output)
+	}
+
+	// Rolled back by a step
+	if _, err := insert("i2", "rolled back", map[string]interface{}{"transaction": "begin"}); err != nil {
+		t.Fatal(err)
+	}
+	if output := runDB(t, registry, "db.exec", "i2", map[string]interface{}{"transaction": "rollback"}); output["transaction"] != "rolled_back" {
+		t.Errorf("rollback = %v", output)
+	}
+
+	// Rolled back by a failing statement
+	if _, err := insert("i3", "failed", map[string]interface{}{"transaction": "begin"}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := execDB(registry, "db.exec", "i3", map[string]interface{}{"sql": "INSERT INTO users (name) VALUES (NULL)"})
+	if err == nil || !strings.Contains(err.Error(), "transaction rolled back") {
+		t.Fatalf("err = %v, want the transaction rolled back", err)
+	}
+	_, err = execDB(registry, "db.exec", "i3", map[string]interface{}{"transaction": "commit"})
+	if err == nil || !strings.Contains(err.Error(), "was rolled back") {
+		t.Fatalf("commit err = %v, want the earlier rollback reported", err)
+	}
+
+	// Rolled back when the instance finishes
+	if _, err := insert("i4", "unfinished", map[string]interface{}{"transaction": "begin"}); err != nil {
+		t.Fatal(err)
+	}
+	registry.FinishInstance("i4")
+
+	if got, want := userNames(t, registry), []interface{}{"committed", "committed too"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("users = %v, want %v", got, want)
+	}
+
+	if _, err := execDB(registry, "db.exec", "i5", map[string]interface{}{"transaction": "commit"}); err == nil || !strings.Contains(err.Error(), "no transaction is open") {
+		t.Errorf("commit without begin err = %v", err)
+	}
+	if _, err := insert("", "x", map[string]interface{}{"transaction": "begin"}); err == nil || !strings.Contains(err.Error(), "need a workflow instance") {
+		t.Errorf("begin without instance err = %v", err)
+	}
+}
+
+func TestDatabaseErrors(t *testing.T) {
+	registry := newTestDatabase(t)
+
+	tests := []struct {
+		name  string
+		input map[string]interface{}
+		info  StepInfo
+		err   string
+	}{
+		{name: "missing sql", input: map[string]interface{}{}, err: "sql parameter is required"},
+		{name: "templated sql", input: map[string]interface{}{"sql": "SELECT 1"}, info: StepInfo{Templated: []string{"sql"}}, err: "must not contain templates"},
+		{name: "unknown transaction mode", input: map[string]interface{}{"sql": "SELECT 1", "transaction": "maybe"}, err: "begin, commit or rollback"},
+		{name: "bad params", input: map[string]interface{}{"sql": "SELECT ?", "params": "1"}, err: "params must be a list or an object"},
+		{name: "bad max_rows", input: map[string]interface{}{"sql": "SELECT 1", "max_rows": "many"}, err: "max_rows"},
+		{name: "unknown connection", input: map[string]interface{}{"sql": "SELECT 1", "connection": "other"}, err: "other"},
+		{name: "syntax error", input: map[string]interface{}{"sql": "SELEC 1"}, err: "statement failed"},
+		{name: "missing table", input: map[string]interface{}{"sql": "SELECT * FROM orders"}, err: "statement failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			action, err := registry.GetAction("db.query")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = action.Execute(WithStepInfo(context.Background(), tt.info), tt.input)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("err = %v, want %q", err, tt.err)
+			}
+		})
+	}
+
+	if err := registry.RegisterDatabase("bad", DatabaseConfig{Driver: "oracle", DSN: "x"}); err == nil || !strings.Contains(err.Error(), "unsupported driver") {
+		t.Errorf("unsupported driver err = %v", err)
+	}
+	if err := registry.RegisterDatabase("bad", DatabaseConfig{Driver: "sqlite"}); err == nil || !strings.Contains(err.Error(), "dsn is required") {
+		t.Errorf("missing dsn err = %v", err)
+	}
+}
@@ -0,0 +1,207 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// ErrEgressDenied is returned when a request destination breaks the egress policy
+var ErrEgressDenied = errors.New("egress denied")
+
+// Private address handling modes
+const (
+	BlockPrivateTemplated = "templated"
+	BlockPrivateAlways    = "always"
+	BlockPrivateNever     = "never"
+)
+
+// specialRanges are blocked along with loopback, private and link-local
+// addresses: "this network", carrier-grade NAT and IETF protocol assignments
+var specialRanges = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+}
+
+// EgressPolicy restricts the destinations http.request may connect to.
+// Addresses are checked when connections are dialled, after DNS resolution,
+// so a hostname cannot be rebound to an internal address between checks.
+type EgressPolicy struct {
+	// AllowedHosts and AllowedCIDRs, when set, are the only permitted
+	// destinations. Hosts match exactly or by "*.example.com" suffix.
+	AllowedHosts []string `json:"allowed_hosts,omitempty"`
+	AllowedCIDRs []string `json:"allowed_cidrs,omitempty"`
+	// DeniedHosts and DeniedCIDRs always win
+	DeniedHosts []string `json:"denied_hosts,omitempty"`
+	DeniedCIDRs []string `json:"denied_cidrs,omitempty"`
+
+	// BlockPrivate blocks loopback, private, link-local and other internal
+	// addresses: "templated" (default) when the URL comes from a template,
+	// "always" or "never". AllowedCIDRs re-allow specific ranges.
+	BlockPrivate string `json:"block_private,omitempty"`
+}
+
+// Validate checks the policy's CIDRs and mode
+func (p *EgressPolicy) Validate() error {
+	for _, cidr := range append(append([]string{}, p.AllowedCIDRs...), p.DeniedCIDRs...) {
+		if _, err := netip.ParsePrefix(cidr); err != nil {
+			return fmt.Errorf("invalid CIDR %q: %w", cidr, err)
+		}
+	}
+	switch p.BlockPrivate {
+	case "", BlockPrivateTemplated, BlockPrivateAlways, BlockPrivateNever:
+		return nil
+	}
+	return fmt.Errorf("block_private must be %q, %q or %q", BlockPrivateTemplated, BlockPrivateAlways, BlockPrivateNever)
+}
+
+// blocksPrivate reports whether internal addresses are blocked for a URL
+func (p *EgressPolicy) blocksPrivate(templated bool) bool {
+	switch p.BlockPrivate {
+	case BlockPrivateAlways:
+		return true
+	case BlockPrivateNever:
+		return false
+	}
+	return templated
+}
+
+// CheckHost applies the hostname rules before any connection is made
+func (p *EgressPolicy) CheckHost(host string) error {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if matchesHost(p.DeniedHosts, host) {
+		return fmt.Errorf("%w: host %s is denied by the egress policy", ErrEgressDenied, host)
+	}
+	return nil
+}
+
+// CheckAddress reports whether a connection to ip, resolved from host, is allowed
+func (p *EgressPolicy) CheckAddress(host string, ip netip.Addr, blockPrivate bool) error {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	ip = ip.Unmap()
+
+	if err := p.CheckHost(host); err != nil {
+		return err
+	}
+	if containsAddr(p.DeniedCIDRs, ip) {
+		return fmt.Errorf("%w: %s (%s) is in a denied range", ErrEgressDenied, host, ip)
+	}
+	if containsAddr(p.AllowedCIDRs, ip) {
+		return nil
+	}
+	if blockPrivate && isInternal(ip) {
+		return fmt.Errorf("%w: %s resolves to internal address %s; templated URLs may not reach private, loopback or link-local addresses", ErrEgressDenied, host, ip)
+	}
+	if len(p.AllowedHosts) > 0 || len(p.AllowedCIDRs) > 0 {
+		if !matchesHost(p.AllowedHosts, host) {
+			return fmt.Errorf("%w: %s (%s) is not in the allowed hosts or ranges", ErrEgressDenied, host, ip)
+		}
+	}
+	return nil
+}
+
+// dialContext resolves the destination, checks every address against the
+// policy and connects to the first allowed one
+func (p *EgressPolicy) dialContext(blockPrivate bool) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		var addrs []netip.Addr
+		if ip, err := netip.ParseAddr(host); err == nil {
+			addrs = []netip.Addr{ip}
+		} else {
+			if err := p.CheckHost(host); err != nil {
+				return nil, err
+			}
+			if addrs, err = net.DefaultResolver.LookupNetIP(ctx, "ip", host); err != nil {
+				return nil, err
+			}
+		}
+
+		var firstErr error
+		for _, ip := range addrs {
+			if err := p.CheckAddress(host, ip, blockPrivate); err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			// Dial the checked address so the name cannot be resolved again
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.Unmap().String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			if firstErr == nil || errors.Is(firstErr, ErrEgressDenied) {
+				firstErr = err
+			}
+		}
+		if firstErr == nil {
+			firstErr = fmt.Errorf("no addresses found for %s", host)
+		}
+		return nil, firstErr
+	}
+}
+
+// transport returns an HTTP transport that enforces the policy on every
+// dial, including redirects. Proxies are not used, since a proxy would
+// connect to the destination on the transport's behalf.
+func (p *EgressPolicy) transport(blockPrivate bool) *http.Transport {
+	return &http.Transport{
+		DialContext:           p.dialContext(blockPrivate),
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// isInternal reports whether an address is not publicly routable
+func isInternal(ip netip.Addr) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, prefix := range specialRanges {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsAddr reports whether ip is inside any of the CIDRs
+func containsAddr(cidrs []string, ip netip.Addr) bool {
+	for _, cidr := range cidrs {
+		if prefix, err := netip.ParsePrefix(cidr); err == nil && prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesHost matches a hostname against exact names and "*.domain" patterns
+func matchesHost(patterns []string, host string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if strings.HasPrefix(pattern, "*.") {
+			if strings.HasSuffix(host, pattern[1:]) {
+				return true
+			}
+		} else if pattern == host {
+			return true
+		}
+	}
+	return false
+}
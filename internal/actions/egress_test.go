@@ -0,0 +1,184 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestEgressPolicyCheckAddress(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       EgressPolicy
+		host         string
+		ip           string
+		blockPrivate bool
+		allowed      bool
+	}{
+		{name: "public address", host: "example.com", ip: "93.184.216.34", blockPrivate: true, allowed: true},
+		{name: "loopback blocked", host: "localhost", ip: "127.0.0.1", blockPrivate: true},
+		{name: "loopback when not blocking", host: "localhost", ip: "127.0.0.1", allowed: true},
+		{name: "private 10/8", host: "internal", ip: "10.1.2.3", blockPrivate: true},
+		{name: "private 172.16/12", host: "internal", ip: "172.20.0.1", blockPrivate: true},
+		{name: "private 192.168/16", host: "router", ip: "192.168.1.1", blockPrivate: true},
+		{name: "link-local metadata", host: "metadata", ip: "169.254.169.254", blockPrivate: true},
+		{name: "carrier-grade NAT", host: "cgnat", ip: "100.64.0.1", blockPrivate: true},
+		{name: "this network", host: "zero", ip: "0.0.0.0", blockPrivate: true},
+		{name: "ipv6 loopback", host: "localhost", ip: "::1", blockPrivate: true},
+		{name: "ipv6 unique local", host: "internal", ip: "fd00::1", blockPrivate: true},
+		{name: "ipv6 link-local", host: "internal", ip: "fe80::1", blockPrivate: true},
+		{name: "ipv4-mapped loopback", host: "mapped", ip: "::ffff:127.0.0.1", blockPrivate: true},
+		{
+			name:         "allowed CIDR re-allows private range",
+			policy:       EgressPolicy{AllowedCIDRs: []string{"10.0.0.0/8"}},
+			host:         "internal",
+			ip:           "10.1.2.3",
+			blockPrivate: true,
+			allowed:      true,
+		},
+		{
+			name:    "denied host",
+			policy:  EgressPolicy{DeniedHosts: []string{"evil.example.com"}},
+			host:    "Evil.Example.com.",
+			ip:      "93.184.216.34",
+			allowed: false,
+		},
+		{
+			name:    "denied wildcard host",
+			policy:  EgressPolicy{DeniedHosts: []string{"*.example.com"}},
+			host:    "api.example.com",
+			ip:      "93.184.216.34",
+			allowed: false,
+		},
+		{
+			name:    "denied CIDR wins over allowed CIDR",
+			policy:  EgressPolicy{AllowedCIDRs: []string{"10.0.0.0/8"}, DeniedCIDRs: []string{"10.1.0.0/16"}},
+			host:    "internal",
+			ip:      "10.1.2.3",
+			allowed: false,
+		},
+		{
+			name:    "allowed host",
+			policy:  EgressPolicy{AllowedHosts: []string{"*.example.com"}},
+			host:    "api.example.com",
+			ip:      "93.184.216.34",
+			allowed: true,
+		},
+		{
+			name:    "host not in allow list",
+			policy:  EgressPolicy{AllowedHosts: []string{"*.example.com"}},
+			host:    "example.org",
+			ip:      "93.184.216.34",
+			allowed: false,
+		},
+		{
+			name:         "allowed host still blocked on private address",
+			policy:       EgressPolicy{AllowedHosts: []string{"api.example.com"}},
+			host:         "api.example.com",
+			ip:           "10.0.0.5",
+			blockPrivate: true,
+			allowed:      false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.CheckAddress(tt.host, netip.MustParseAddr(tt.ip), tt.blockPrivate)
+			if tt.allowed && err != nil {
+				t.Fatalf("err = %v, want allowed", err)
+			}
+			if !tt.allowed && !errors.Is(err, ErrEgressDenied) {
+				t.Fatalf("err = %v, want %v", err, ErrEgressDenied)
+			}
+		})
+	}
+}
+
+func TestEgressPolicyBlocksPrivate(t *testing.T) {
+	tests := []struct {
+		mode      string
+		templated bool
+		want      bool
+	}{
+		{mode: "", templated: true, want: true},
+		{mode: "", templated: false, want: false},
+		{mode: BlockPrivateTemplated, templated: true, want: true},
+		{mode: BlockPrivateTemplated, templated: false, want: false},
+		{mode: BlockPrivateAlways, templated: false, want: true},
+		{mode: BlockPrivateNever, templated: true, want: false},
+	}
+	for _, tt := range tests {
+		policy := EgressPolicy{BlockPrivate: tt.mode}
+		if got := policy.blocksPrivate(tt.templated); got != tt.want {
+			t.Errorf("blocksPrivate(%q, templated=%v) = %v, want %v", tt.mode, tt.templated, got, tt.want)
+		}
+	}
+}
+
+func TestEgressPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy EgressPolicy
+		err    string
+	}{
+		{name: "empty"},
+		{name: "valid", policy: EgressPolicy{AllowedCIDRs: []string{"10.0.0.0/8"}, DeniedCIDRs: []string{"fd00::/8"}, BlockPrivate: BlockPrivateAlways}},
+		{name: "bad allowed CIDR", policy: EgressPolicy{AllowedCIDRs: []string{"10.0.0.0"}}, err: "invalid CIDR"},
+		{name: "bad denied CIDR", policy: EgressPolicy{DeniedCIDRs: []string{"nope"}}, err: "invalid CIDR"},
+		{name: "bad mode", policy: EgressPolicy{BlockPrivate: "sometimes"}, err: "block_private must be"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate()
+			if tt.err == "" && err != nil {
+				t.Fatalf("err = %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("err = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestHTTPEgressBlocksPrivateAddresses(t *testing.T) {
+	server := newTestServer(t, http.StatusOK, `{"ok":true}`)
+
+	tests := []struct {
+		name      string
+		policy    *EgressPolicy
+		templated bool
+		allowed   bool
+	}{
+		{name: "literal URL", allowed: true},
+		{name: "templated URL", templated: true},
+		{name: "templated URL with loopback allowed", policy: &EgressPolicy{AllowedCIDRs: []string{"127.0.0.0/8"}}, templated: true, allowed: true},
+		{name: "always blocks literal URL", policy: &EgressPolicy{BlockPrivate: BlockPrivateAlways}},
+		{name: "never blocks", policy: &EgressPolicy{BlockPrivate: BlockPrivateNever}, templated: true, allowed: true},
+		{name: "denied host", policy: &EgressPolicy{DeniedHosts: []string{"127.0.0.1"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			action := NewHTTPAction(zap.NewNop(), NewRegistry(zap.NewNop()))
+			action.SetEgressPolicy(tt.policy)
+
+			info := StepInfo{Workflow: "test", Step: "fetch"}
+			if tt.templated {
+				info.Templated = []string{"url"}
+			}
+			ctx := WithStepInfo(context.Background(), info)
+
+			_, err := action.Execute(ctx, map[string]interface{}{"url": server.URL})
+			if tt.allowed && err != nil {
+				t.Fatalf("err = %v, want allowed", err)
+			}
+			if !tt.allowed && (err == nil || !strings.Contains(err.Error(), "egress denied")) {
+				t.Fatalf("err = %v, want egress denied", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,639 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultEmailTimeout bounds sending a message unless configured
+	defaultEmailTimeout = 30 * time.Second
+	// maxAttachmentBytes caps the attachments of one message
+	maxAttachmentBytes = 25 << 20
+)
+
+// EmailConfig is the SMTP server email.send steps use. Steps may choose
+// another server with smtp_host and smtp_port, subject to the egress policy;
+// the configured credentials are never sent to it.
+type EmailConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"-"`
+	// TLS is starttls, tls or none; the default is tls on port 465 and
+	// starttls elsewhere
+	TLS string `json:"tls,omitempty"`
+	// InsecureSkipVerify accepts any server certificate, for local relays
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+	// From is the sender when a step sets none
+	From    string        `json:"from,omitempty"`
+	Timeout time.Duration `json:"timeout,omitempty"`
+}
+
+// reservedHeaders are written by email.send and may not be set through headers
+var reservedHeaders = map[string]bool{
+	"From":                      true,
+	"Sender":                    true,
+	"To":                        true,
+	"Cc":                        true,
+	"Bcc":                       true,
+	"Subject":                   true,
+	"Date":                      true,
+	"Message-Id":                true,
+	"Mime-Version":              true,
+	"Content-Type":              true,
+	"Content-Transfer-Encoding": true,
+}
+
+// attachment is a file added to a message
+type attachment struct {
+	name        string
+	contentType string
+	data        []byte
+}
+
+// EmailAction sends email over SMTP. Messages have a text body, an HTML
+// body or both, and attachments read from files or given inline, such as
+// the output of an earlier step.
+type EmailAction struct {
+	logger *zap.Logger
+	files  *fileSystem
+
+	mu     sync.RWMutex
+	config EmailConfig
+	policy *EgressPolicy
+}
+
+// NewEmailAction creates a new email action reading attachments through
+// the registry's file policy
+func NewEmailAction(logger *zap.Logger, registry *Registry) *EmailAction {
+	return &EmailAction{logger: logger, files: registry.files, policy: &EgressPolicy{}}
+}
+
+// SetEgressPolicy restricts the SMTP servers steps may point smtp_host and
+// smtp_port at. A nil policy only blocks internal addresses for templated
+// hosts.
+func (e *EmailAction) SetEgressPolicy(policy *EgressPolicy) {
+	if policy == nil {
+		policy = &EgressPolicy{}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.policy = policy
+}
+
+// SetConfig sets the default SMTP server
+func (e *EmailAction) SetConfig(config *EmailConfig) {
+	if config == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.config = *config
+}
+
+// TypedFields keeps the types of recipient lists and attachments given as
+// single template references
+func (e *EmailAction) TypedFields(config map[string]interface{}) []string {
+	return []string{"to", "cc", "bcc", "attachments"}
+}
+
+// Execute builds the message and sends it
+func (e *EmailAction) Execute(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+	server, overridden, err := e.server(input)
+	if err != nil {
+		return nil, err
+	}
+
+	// Servers chosen by the step are checked like http.request URLs
+	dial := (&net.Dialer{}).DialContext
+	if overridden {
+		e.mu.RLock()
+		policy := e.policy
+		e.mu.RUnlock()
+		if err := policy.CheckHost(server.Host); err != nil {
+			return nil, fmt.Errorf("SMTP server %s blocked: %w", server.Host, err)
+		}
+		info, _ := StepInfoFromContext(ctx)
+		dial = policy.dialContext(policy.blocksPrivate(info.IsTemplated("smtp_host")))
+	}
+
+	from := server.From
+	if v, ok := input["from"].(string); ok && v != "" {
+		from = v
+	}
+	if from == "" {
+		return nil, fmt.Errorf("from parameter is required")
+	}
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid from address: %w", err)
+	}
+
+	recipients := make(map[string][]*mail.Address)
+	var envelope []string
+	for _, field := range []string{"to", "cc", "bcc"} {
+		addresses, err := addressList(input[field])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s address: %w", field, err)
+		}
+		recipients[field] = addresses
+		for _, address := range addresses {
+			envelope = append(envelope, address.Address)
+		}
+	}
+	if len(envelope) == 0 {
+		return nil, fmt.Errorf("to, cc or bcc parameter is required")
+	}
+
+	subject, _ := input["subject"].(string)
+	if strings.ContainsAny(subject, "\r\n") {
+		return nil, fmt.Errorf("subject must be a single line")
+	}
+	text, _ := input["text"].(string)
+	if text == "" {
+		text, _ = input["body"].(string)
+	}
+	html, _ := input["html"].(string)
+	if text == "" && html == "" {
+		return nil, fmt.Errorf("text, body or html parameter is required")
+	}
+
+	attachments, err := e.attachmentList(input["attachments"])
+	if err != nil {
+		return nil, err
+	}
+
+	headers := make(map[string]interface{})
+	if extra, ok := input["headers"].(map[string]interface{}); ok {
+		for name, value := range extra {
+			headers[name] = value
+		}
+	}
+	if replyTo, ok := input["reply_to"].(string); ok && replyTo != "" {
+		address, err := mail.ParseAddress(replyTo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reply_to address: %w", err)
+		}
+		headers["Reply-To"] = address.String()
+	}
+
+	messageID := newMessageID(sender.Address)
+	message, err := buildMessage(sender, recipients, subject, text, html, attachments, headers, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout, err := durationInput(input, "timeout", server.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if err := sendMail(ctx, server, dial, sender.Address, envelope, message); err != nil {
+		return nil, fmt.Errorf("failed to send email via %s: %w", net.JoinHostPort(server.Host, strconv.Itoa(server.Port)), err)
+	}
+
+	e.logger.Info("Email sent",
+		zap.String("subject", subject),
+		zap.Int("recipients", len(envelope)),
+		zap.String("message_id", messageID))
+	return map[string]interface{}{
+		"message_id":  messageID,
+		"recipients":  len(envelope),
+		"attachments": len(attachments),
+	}, nil
+}
+
+// server returns the configured SMTP server with the step's overrides and
+// reports whether the step chose the host or port. The configured
+// credentials and TLS settings only apply to the configured server, and
+// steps may only change TLS settings for a server they choose.
+func (e *EmailAction) server(input map[string]interface{}) (EmailConfig, bool, error) {
+	e.mu.RLock()
+	server := e.config
+	e.mu.RUnlock()
+	configured := net.JoinHostPort(server.Host, strconv.Itoa(server.Port))
+
+	if v, ok := input["smtp_host"].(string); ok && v != "" {
+		server.Host = v
+	}
+	port, err := intInput(input, "smtp_port", int64(server.Port))
+	if err != nil {
+		return server, false, err
+	}
+	server.Port = int(port)
+	overridden := net.JoinHostPort(server.Host, strconv.Itoa(server.Port)) != configured
+
+	_, setsTLS := input["smtp_tls"]
+	_, setsVerify := input["smtp_insecure_skip_verify"]
+	if overridden {
+		server.Username, server.Password = "", ""
+		server.TLS, server.InsecureSkipVerify = "", false
+	} else if setsTLS || setsVerify {
+		return server, false, fmt.Errorf("smtp_tls and smtp_insecure_skip_verify can only be set with smtp_host or smtp_port")
+	}
+
+	for key, target := range map[string]*string{
+		"smtp_username": &server.Username,
+		"smtp_password": &server.Password,
+		"smtp_tls":      &server.TLS,
+	} {
+		if v, ok := input[key].(string); ok && v != "" {
+			*target = v
+		}
+	}
+	server.InsecureSkipVerify = boolInput(input, "smtp_insecure_skip_verify", server.InsecureSkipVerify)
+
+	if server.Host == "" {
+		return server, false, fmt.Errorf("no SMTP server is configured; set email.host or smtp_host")
+	}
+	if server.Port == 0 {
+		server.Port = 587
+	}
+	if server.TLS == "" {
+		server.TLS = "starttls"
+		if server.Port == 465 {
+			server.TLS = "tls"
+		}
+	}
+	switch server.TLS {
+	case "starttls", "tls", "none":
+	default:
+		return server, false, fmt.Errorf("smtp tls must be starttls, tls or none")
+	}
+	if server.Timeout <= 0 {
+		server.Timeout = defaultEmailTimeout
+	}
+	return server, overridden, nil
+}
+
+// addressList reads addresses from a list or a comma separated string
+func addressList(value interface{}) ([]*mail.Address, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return nil, nil
+		}
+		return mail.ParseAddressList(v)
+	case []interface{}:
+		var addresses []*mail.Address
+		for _, item := range v {
+			str, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("addresses must be strings")
+			}
+			parsed, err := addressList(str)
+			if err != nil {
+				return nil, err
+			}
+			addresses = append(addresses, parsed...)
+		}
+		return addresses, nil
+	}
+	return nil, fmt.Errorf("must be an address or a list of addresses")
+}
+
+// attachmentList reads attachments. Each is a file path, or an object with
+// either path or content, and optionally name, content_type and encoding
+// (base64 when content is base64 encoded). Paths are resolved through the
+// file policy.
+func (e *EmailAction) attachmentList(value interface{}) ([]attachment, error) {
+	var items []interface{}
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		items = v
+	case string, map[string]interface{}:
+		items = []interface{}{v}
+	default:
+		return nil, fmt.Errorf("attachments must be a list")
+	}
+
+	var attachments []attachment
+	total := 0
+	for i, item := range items {
+		spec, ok := item.(map[string]interface{})
+		if path, isPath := item.(string); isPath {
+			spec, ok = map[string]interface{}{"path": path}, true
+		}
+		if !ok {
+			return nil, fmt.Errorf("attachments[%d] must be a path or an object", i)
+		}
+
+		a := attachment{}
+		a.name, _ = spec["name"].(string)
+		a.contentType, _ = spec["content_type"].(string)
+		if path, _ := spec["path"].(string); path != "" {
+			real, err := e.files.resolve(path, false)
+			if err != nil {
+				return nil, fmt.Errorf("attachments[%d]: %w", i, err)
+			}
+			info, err := os.Stat(real)
+			if err != nil {
+				return nil, fmt.Errorf("attachments[%d]: %w", i, err)
+			}
+			if total+int(info.Size()) > maxAttachmentBytes {
+				return nil, fmt.Errorf("attachments exceed %d bytes", maxAttachmentBytes)
+			}
+			data, err := os.ReadFile(real)
+			if err != nil {
+				return nil, fmt.Errorf("attachments[%d]: %w", i, err)
+			}
+			a.data = data
+			if a.name == "" {
+				a.name = filepath.Base(path)
+			}
+		} else if content, exists := spec["content"]; exists {
+			switch c := content.(type) {
+			case string:
+				a.data = []byte(c)
+				if spec["encoding"] == "base64" {
+					decoded, err := base64.StdEncoding.DecodeString(c)
+					if err != nil {
+						return nil, fmt.Errorf("attachments[%d]: content is not valid base64: %w", i, err)
+					}
+					a.data = decoded
+				}
+			default:
+				data, err := json.MarshalIndent(c, "", "  ")
+				if err != nil {
+					return nil, fmt.Errorf("attachments[%d]: %w", i, err)
+				}
+				a.data = data
+				if a.contentType == "" {
+					a.contentType = "application/json"
+				}
+			}
+			if a.name == "" {
+				a.name = fmt.Sprintf("attachment-%d", i+1)
+			}
+		} else {
+			return nil, fmt.Errorf("attachments[%d] needs a path or content", i)
+		}
+
+		if a.contentType == "" {
+			a.contentType = mime.TypeByExtension(filepath.Ext(a.name))
+		}
+		if a.contentType == "" {
+			a.contentType = "application/octet-stream"
+		}
+		total += len(a.data)
+		if total > maxAttachmentBytes {
+			return nil, fmt.Errorf("attachments exceed %d bytes", maxAttachmentBytes)
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, nil
+}
+
+// newMessageID returns a unique Message-ID in the sender's domain
+func newMessageID(sender string) string {
+	random := make([]byte, 16)
+	rand.Read(random)
+	domain := "localhost"
+	if at := strings.LastIndex(sender, "@"); at >= 0 {
+		domain = sender[at+1:]
+	}
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(random), domain)
+}
+
+// buildMessage writes a MIME message. Bcc recipients are left out of the
+// headers. The bodies are an alternative part inside a mixed part when
+// there are attachments.
+func buildMessage(sender *mail.Address, recipients map[string][]*mail.Address, subject, text, html string, attachments []attachment, extra map[string]interface{}, messageID string) ([]byte, error) {
+	var buf bytes.Buffer
+	header := func(name, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", name, value)
+	}
+	header("From", sender.String())
+	for _, field := range []string{"To", "Cc"} {
+		if addresses := recipients[strings.ToLower(field)]; len(addresses) > 0 {
+			formatted := make([]string, len(addresses))
+			for i, address := range addresses {
+				formatted[i] = address.String()
+			}
+			header(field, strings.Join(formatted, ", "))
+		}
+	}
+	header("Subject", mime.QEncoding.Encode("utf-8", subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", messageID)
+	header("MIME-Version", "1.0")
+	for _, name := range sortedKeys(extra) {
+		value := fmt.Sprint(extra[name])
+		if strings.ContainsAny(name, "\r\n: ") || strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("header %q must be a single line", name)
+		}
+		canonical := textproto.CanonicalMIMEHeaderKey(name)
+		if reservedHeaders[canonical] {
+			return nil, fmt.Errorf("header %s is set by email.send and cannot be given in headers", canonical)
+		}
+		header(canonical, mime.QEncoding.Encode("utf-8", value))
+	}
+
+	if len(attachments) == 0 {
+		if err := writeBody(&buf, nil, text, html); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	mixed := multipart.NewWriter(&buf)
+	header("Content-Type", "multipart/mixed; boundary="+mixed.Boundary())
+	buf.WriteString("\r\n")
+	if err := writeBody(&buf, mixed, text, html); err != nil {
+		return nil, err
+	}
+	for _, a := range attachments {
+		mediaType, params, err := mime.ParseMediaType(a.contentType)
+		if err != nil {
+			return nil, fmt.Errorf("attachment %s: invalid content_type: %w", a.name, err)
+		}
+		params["name"] = a.name
+		part, err := mixed.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(mediaType, params)},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.name})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		writeBase64(part, a.data)
+	}
+	if err := mixed.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeBody writes the text and HTML bodies, as a part of parent when given
+// and otherwise as the rest of the message
+func writeBody(buf *bytes.Buffer, parent *multipart.Writer, text, html string) error {
+	type body struct{ contentType, content string }
+	var bodies []body
+	if text != "" {
+		bodies = append(bodies, body{"text/plain; charset=utf-8", text})
+	}
+	if html != "" {
+		bodies = append(bodies, body{"text/html; charset=utf-8", html})
+	}
+
+	var content bytes.Buffer
+	header := textproto.MIMEHeader{}
+	if len(bodies) == 1 {
+		header.Set("Content-Type", bodies[0].contentType)
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+		if err := writeQuotedPrintable(&content, bodies[0].content); err != nil {
+			return err
+		}
+	} else {
+		alternative := multipart.NewWriter(&content)
+		header.Set("Content-Type", "multipart/alternative; boundary="+alternative.Boundary())
+		for _, b := range bodies {
+			part, err := alternative.CreatePart(textproto.MIMEHeader{
+				"Content-Type":              {b.contentType},
+				"Content-Transfer-Encoding": {"quoted-printable"},
+			})
+			if err != nil {
+				return err
+			}
+			if err := writeQuotedPrintable(part, b.content); err != nil {
+				return err
+			}
+		}
+		if err := alternative.Close(); err != nil {
+			return err
+		}
+	}
+
+	if parent != nil {
+		part, err := parent.CreatePart(header)
+		if err != nil {
+			return err
+		}
+		_, err = part.Write(content.Bytes())
+		return err
+	}
+	for _, name := range []string{"Content-Type", "Content-Transfer-Encoding"} {
+		if value := header.Get(name); value != "" {
+			fmt.Fprintf(buf, "%s: %s\r\n", name, value)
+		}
+	}
+	buf.WriteString("\r\n")
+	buf.Write(content.Bytes())
+	return nil
+}
+
+// writeQuotedPrintable writes content in quoted-printable encoding
+func writeQuotedPrintable(w io.Writer, content string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(content)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// writeBase64 writes data in base64 with lines of 76 characters
+func writeBase64(w io.Writer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		w.Write([]byte(encoded[:76] + "\r\n"))
+		encoded = encoded[76:]
+	}
+	w.Write([]byte(encoded + "\r\n"))
+}
+
+// sendMail delivers a message over a connection from dial, giving up when
+// ctx is done
+func sendMail(ctx context.Context, server EmailConfig, dial func(ctx context.Context, network, addr string) (net.Conn, error), from string, to []string, message []byte) error {
+	address := net.JoinHostPort(server.Host, strconv.Itoa(server.Port))
+	tlsConfig := &tls.Config{ServerName: server.Host, InsecureSkipVerify: server.InsecureSkipVerify}
+
+	conn, err := dial(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if server.TLS == "tls" {
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return err
+		}
+		conn = tlsConn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	client, err := smtp.NewClient(conn, server.Host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if server.TLS == "starttls" {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("server does not support STARTTLS; set tls to none to send unencrypted")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if server.Username != "" {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return fmt.Errorf("server does not support authentication")
+		}
+		if err := client.Auth(smtp.PlainAuth("", server.Username, server.Password, server.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	for _, recipient := range to {
+		if err := client.Rcpt(recipient); err != nil {
+			return fmt.Errorf("recipient %s rejected: %w", recipient, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,445 @@
+package actions
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpSession is what the stub server received from one client
+type smtpSession struct {
+	startTLS bool
+	auth     string
+	from     string
+	rcpt     []string
+	data     string
+}
+
+// smtpStub is an in-process SMTP server offering STARTTLS and AUTH PLAIN
+type smtpStub struct {
+	listener net.Listener
+	tls      *tls.Config
+	sessions chan *smtpSession
+}
+
+func newSMTPStub(t *testing.T) *smtpStub {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub := &smtpStub{listener: listener, tls: testTLSConfig(t), sessions: make(chan *smtpSession, 1)}
+	t.Cleanup(func() { listener.Close() })
+	go stub.serve()
+	return stub
+}
+
+func (s *smtpStub) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpStub) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpStub) handle(conn net.Conn) {
+	defer conn.Close()
+	session := &smtpSession{}
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	reply("220 stub ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch verb {
+		case "EHLO", "HELO":
+			if !session.startTLS {
+				reply("250-stub")
+				reply("250 STARTTLS")
+			} else {
+				reply("250-stub")
+				reply("250 AUTH PLAIN")
+			}
+		case "STARTTLS":
+			reply("220 ready")
+			tlsConn := tls.Server(conn, s.tls)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			r = bufio.NewReader(conn)
+			session.startTLS = true
+		case "AUTH":
+			fields := strings.Fields(line)
+			decoded, _ := base64.StdEncoding.DecodeString(fields[len(fields)-1])
+			session.auth = string(decoded)
+			reply("235 ok")
+		case "MAIL":
+			session.from = line
+			reply("250 ok")
+		case "RCPT":
+			session.rcpt = append(session.rcpt, line)
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			session.data = data.String()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			s.sessions <- session
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+// testTLSConfig returns a server configuration with a self-signed certificate
+func testTLSConfig(t *testing.T) *tls.Config {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+}
+
+func newTestEmailAction(t *testing.T, stub *smtpStub) (*EmailAction, string) {
+	t.Helper()
+	registry, root := newTestFiles(t)
+	action, _ := registry.GetAction("email.send")
+	email := action.(*EmailAction)
+	email.SetConfig(&EmailConfig{
+		Host:               "127.0.0.1",
+		Port:               stub.port(),
+		Username:           "notifications",
+		Password:           "smtp-password",
+		TLS:                "starttls",
+		InsecureSkipVerify: true,
+		From:               "Conduktr <noreply@example.com>",
+	})
+	return email, root
+}
+
+func TestEmailSend(t *testing.T) {
+	stub := newSMTPStub(t)
+	email, root := newTestEmailAction(t, stub)
+	if err := os.WriteFile(filepath.Join(root, "report.csv"), []byte("id,total\n1,9.99\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := email.Execute(context.Background(), map[string]interface{}{
+		"to":      []interface{}{"Ada <ada@example.com>"},
+		"cc":      "ops@example.com",
+		"bcc":     []interface{}{"audit@example.com"},
+		"subject": "Order ready",
+		"text":    "Your order is ready.",
+		"html":    "<p>Your order is <b>ready</b>.</p>",
+		"attachments": []interface{}{
+			"report.csv",
+			map[string]interface{}{"name": "order.json", "content": map[string]interface{}{"id": 1}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result["recipients"] != 3 || result["attachments"] != 2 {
+		t.Errorf("result = %v", result)
+	}
+
+	var session *smtpSession
+	select {
+	case session = <-stub.sessions:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no session received")
+	}
+
+	if !session.startTLS {
+		t.Error("message was sent without STARTTLS")
+	}
+	if session.auth != "\x00notifications\x00smtp-password" {
+		t.Errorf("auth = %q", session.auth)
+	}
+	if !strings.HasPrefix(session.from, "MAIL FROM:<noreply@example.com>") {
+		t.Errorf("from = %q", session.from)
+	}
+	if len(session.rcpt) != 3 || !strings.Contains(session.rcpt[2], "audit@example.com") {
+		t.Errorf("rcpt = %v", session.rcpt)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(session.data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bcc := msg.Header.Get("Bcc"); bcc != "" {
+		t.Errorf("Bcc header = %q", bcc)
+	}
+	if strings.Contains(session.data, "audit@example.com") {
+		t.Error("Bcc recipient appears in the message")
+	}
+	if got := msg.Header.Get("Cc"); !strings.Contains(got, "ops@example.com") {
+		t.Errorf("Cc header = %q", got)
+	}
+
+	// multipart/mixed holding multipart/alternative and two attachments
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("Content-Type = %q, %v", mediaType, err)
+	}
+	mixed := multipart.NewReader(msg.Body, params["boundary"])
+	var parts []string
+	for {
+		part, err := mixed.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		contentType := part.Header.Get("Content-Type")
+		if strings.HasPrefix(contentType, "multipart/alternative") {
+			_, altParams, _ := mime.ParseMediaType(contentType)
+			alternative := multipart.NewReader(part, altParams["boundary"])
+			for {
+				body, err := alternative.NextPart()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				data, _ := io.ReadAll(body)
+				parts = append(parts, body.Header.Get("Content-Type")+"="+string(data))
+			}
+			continue
+		}
+		data, _ := io.ReadAll(part)
+		decoded, _ := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(data), "\r\n", ""))
+		parts = append(parts, part.FileName()+"="+string(decoded))
+	}
+
+	want := []string{
+		"text/plain; charset=utf-8=Your order is ready.",
+		"text/html; charset=utf-8=<p>Your order is <b>ready</b>.</p>",
+		"report.csv=id,total\n1,9.99\n",
+		"order.json={\n  \"id\": 1\n}",
+	}
+	if len(parts) != len(want) {
+		t.Fatalf("parts = %q", parts)
+	}
+	for i := range want {
+		if parts[i] != want[i] {
+			t.Errorf("part %d = %q, want %q", i, parts[i], want[i])
+		}
+	}
+}
+
+func TestEmailAttachmentOutsideFilePolicy(t *testing.T) {
+	stub := newSMTPStub(t)
+	email, _ := newTestEmailAction(t, stub)
+	outside := filepath.Join(t.TempDir(), "keystore.key")
+	if err := os.WriteFile(outside, []byte("master-key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := email.Execute(context.Background(), map[string]interface{}{
+		"to":          "ada@example.com",
+		"text":        "hi",
+		"attachments": []interface{}{outside},
+	})
+	if err == nil || !strings.Contains(err.Error(), "outside the roots") {
+		t.Fatalf("err = %v, want file policy error", err)
+	}
+}
+
+func TestEmailServerOverrideEgress(t *testing.T) {
+	stub := newSMTPStub(t)
+	email, _ := newTestEmailAction(t, stub)
+	email.SetConfig(&EmailConfig{Host: "smtp.invalid", Port: 25, TLS: "none", From: "noreply@example.com"})
+	input := map[string]interface{}{
+		"to":        "ada@example.com",
+		"text":      "hi",
+		"smtp_host": "127.0.0.1",
+		"smtp_port": strconv.Itoa(stub.port()),
+		"smtp_tls":  "none",
+	}
+
+	// A templated host may not reach internal addresses
+	ctx := WithStepInfo(context.Background(), StepInfo{Templated: []string{"smtp_host"}})
+	if _, err := email.Execute(ctx, input); err == nil || !strings.Contains(err.Error(), "egress denied") {
+		t.Fatalf("err = %v, want egress denied", err)
+	}
+
+	email.SetEgressPolicy(&EgressPolicy{DeniedHosts: []string{"127.0.0.1"}})
+	if _, err := email.Execute(context.Background(), input); err == nil || !strings.Contains(err.Error(), "egress denied") {
+		t.Fatalf("err = %v, want egress denied", err)
+	}
+
+	email.SetEgressPolicy(nil)
+	if _, err := email.Execute(context.Background(), input); err != nil {
+		t.Fatalf("static override: %v", err)
+	}
+	<-stub.sessions
+}
+
+func TestEmailServerOverrideDropsCredentials(t *testing.T) {
+	stub := newSMTPStub(t)
+	email, _ := newTestEmailAction(t, stub)
+	// The configured server is elsewhere; the step points at the stub
+	email.SetConfig(&EmailConfig{
+		Host:     "127.0.0.1",
+		Port:     1,
+		Username: "notifications",
+		Password: "smtp-password",
+		From:     "noreply@example.com",
+	})
+
+	tests := []struct {
+		name string
+		set  map[string]interface{}
+		auth string
+	}{
+		{name: "configured credentials withheld"},
+		{name: "step credentials", set: map[string]interface{}{"smtp_username": "relay", "smtp_password": "relay-password"}, auth: "\x00relay\x00relay-password"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := map[string]interface{}{
+				"to":                        "ada@example.com",
+				"text":                      "hi",
+				"smtp_port":                 stub.port(),
+				"smtp_insecure_skip_verify": true,
+			}
+			for key, value := range tt.set {
+				input[key] = value
+			}
+			if _, err := email.Execute(context.Background(), input); err != nil {
+				t.Fatal(err)
+			}
+			session := <-stub.sessions
+			if !session.startTLS {
+				t.Error("message was sent without STARTTLS")
+			}
+			if session.auth != tt.auth {
+				t.Errorf("auth = %q, want %q", session.auth, tt.auth)
+			}
+		})
+	}
+}
+
+func TestEmailServerOverrideResetsTLS(t *testing.T) {
+	stub := newSMTPStub(t)
+	email, _ := newTestEmailAction(t, stub)
+
+	// The configured server's insecure_skip_verify does not carry over
+	_, err := email.Execute(context.Background(), map[string]interface{}{
+		"to":        "ada@example.com",
+		"text":      "hi",
+		"smtp_host": "localhost",
+		"smtp_port": stub.port(),
+	})
+	if err == nil || !strings.Contains(err.Error(), "certificate") {
+		t.Fatalf("err = %v, want certificate error", err)
+	}
+
+	// TLS settings of the configured server cannot be changed by a step
+	for _, key := range []string{"smtp_tls", "smtp_insecure_skip_verify"} {
+		_, err := email.Execute(context.Background(), map[string]interface{}{
+			"to":   "ada@example.com",
+			"text": "hi",
+			key:    "none",
+		})
+		if err == nil || !strings.Contains(err.Error(), "can only be set with smtp_host") {
+			t.Errorf("%s: err = %v, want override refused", key, err)
+		}
+	}
+}
+
+func TestEmailReservedHeaders(t *testing.T) {
+	stub := newSMTPStub(t)
+	email, _ := newTestEmailAction(t, stub)
+
+	tests := []struct {
+		header string
+		err    bool
+	}{
+		{header: "X-Campaign"},
+		{header: "from", err: true},
+		{header: "Bcc", err: true},
+		{header: "content-type", err: true},
+		{header: "Content-Transfer-Encoding", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			_, err := email.Execute(context.Background(), map[string]interface{}{
+				"to":      "ada@example.com",
+				"text":    "hi",
+				"headers": map[string]interface{}{tt.header: "attacker@example.com"},
+			})
+			if !tt.err {
+				if err != nil {
+					t.Fatal(err)
+				}
+				session := <-stub.sessions
+				if !strings.Contains(session.data, "X-Campaign: attacker@example.com") {
+					t.Errorf("header missing from message:\n%s", session.data)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), "cannot be given in headers") {
+				t.Fatalf("err = %v, want header refused", err)
+			}
+		})
+	}
+}
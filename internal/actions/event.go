@@ -0,0 +1,161 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/logimos/conduktr/internal/persistence"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// maxEmitDepth bounds chains of workflows emitting events for each other,
+// so a workflow that triggers itself cannot run forever
+const maxEmitDepth = 16
+
+// Event metadata keys linking emitted events to the instances involved
+const (
+	MetadataEventID       = "event_id"
+	MetadataCorrelationID = "correlation_id"
+	MetadataCausationID   = "causation_id"
+	MetadataEmitDepth     = "emit_depth"
+)
+
+// RoutedEvent reports the instance started for an emitted event
+type RoutedEvent struct {
+	Workflow   string
+	InstanceID string
+	// Status and Outputs are set when the emitter waited for the instance
+	Status  string
+	Outputs map[string]interface{}
+}
+
+// EventRouter delivers events emitted by steps to the workflow subscribed
+// to them. It returns nil when no workflow is subscribed. With wait the
+// instance has finished when RouteEvent returns.
+type EventRouter interface {
+	RouteEvent(ctx context.Context, event *persistence.Event, wait bool) (*RoutedEvent, error)
+}
+
+// EventAction emits events back into the engine so workflows can chain.
+// Each emitted event carries the correlation ID of the chain it belongs to
+// and the ID of the instance that emitted it as its causation ID.
+type EventAction struct {
+	logger *zap.Logger
+	router EventRouter
+}
+
+// NewEventAction creates a new event emission action
+func NewEventAction(logger *zap.Logger) *EventAction {
+	return &EventAction{logger: logger}
+}
+
+// SetRouter sets where emitted events are delivered
+func (e *EventAction) SetRouter(router EventRouter) {
+	e.router = router
+}
+
+// TypedFields keeps the type of a payload given as a single template reference
+func (e *EventAction) TypedFields(config map[string]interface{}) []string {
+	return []string{"payload"}
+}
+
+// Execute emits the event and starts the subscribed workflow
+func (e *EventAction) Execute(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+	if e.router == nil {
+		return nil, fmt.Errorf("event emission is not available")
+	}
+	eventType, ok := input["event"].(string)
+	if !ok || eventType == "" {
+		return nil, fmt.Errorf("event parameter is required")
+	}
+
+	payload := make(map[string]interface{})
+	switch v := input["payload"].(type) {
+	case nil:
+	case map[string]interface{}:
+		payload = v
+	default:
+		return nil, fmt.Errorf("payload must be an object")
+	}
+
+	info, _ := StepInfoFromContext(ctx)
+	metadata := emitMetadata(info)
+	if depth := metadata[MetadataEmitDepth].(int); depth > maxEmitDepth {
+		return nil, fmt.Errorf("event %s not emitted: chain of emitted events is deeper than %d", eventType, maxEmitDepth)
+	}
+	if extra, ok := input["metadata"].(map[string]interface{}); ok {
+		for key, value := range extra {
+			if _, reserved := metadata[key]; !reserved {
+				metadata[key] = value
+			}
+		}
+	}
+
+	event := &persistence.Event{
+		Type:      eventType,
+		Payload:   payload,
+		Metadata:  metadata,
+		Timestamp: time.Now().Unix(),
+	}
+	wait := boolInput(input, "wait", false)
+
+	routed, err := e.router.RouteEvent(ctx, event, wait)
+	result := map[string]interface{}{
+		"event":          eventType,
+		"event_id":       metadata[MetadataEventID],
+		"correlation_id": metadata[MetadataCorrelationID],
+		"causation_id":   metadata[MetadataCausationID],
+		"routed":         routed != nil,
+	}
+	if routed != nil {
+		result["workflow"] = routed.Workflow
+		result["instance_id"] = routed.InstanceID
+		if wait {
+			result["status"] = routed.Status
+			result["outputs"] = routed.Outputs
+		}
+	}
+	if err != nil {
+		return result, fmt.Errorf("emitted event %s failed: %w", eventType, err)
+	}
+
+	if routed == nil {
+		e.logger.Warn("No workflow subscribed to emitted event", zap.String("event", eventType))
+	} else {
+		e.logger.Info("Event emitted",
+			zap.String("event", eventType),
+			zap.String("workflow", routed.Workflow),
+			zap.String("instance_id", routed.InstanceID))
+	}
+	return result, nil
+}
+
+// emitMetadata links a new event to the instance emitting it. The chain's
+// correlation ID is inherited, or starts with the emitting instance.
+func emitMetadata(info StepInfo) map[string]interface{} {
+	correlationID := info.InstanceID
+	depth := 1
+	if info.Event != nil {
+		if id, ok := info.Event.Metadata[MetadataCorrelationID].(string); ok && id != "" {
+			correlationID = id
+		}
+		switch d := info.Event.Metadata[MetadataEmitDepth].(type) {
+		case int:
+			depth = d + 1
+		case float64: // read back from a persisted instance
+			depth = int(d) + 1
+		}
+	}
+
+	return map[string]interface{}{
+		MetadataEventID:       uuid.New().String(),
+		MetadataCorrelationID: correlationID,
+		MetadataCausationID:   info.InstanceID,
+		MetadataEmitDepth:     depth,
+		"source_workflow":     info.Workflow,
+		"source_step":         info.Step,
+	}
+}
@@ -0,0 +1,770 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+)
+
+// errNoFileRoots is returned when no roots are configured for file steps
+var errNoFileRoots = errors.New("file steps are disabled; configure files.allowed_roots to enable them")
+
+const (
+	// defaultMaxReadBytes caps what file.read loads unless configured
+	defaultMaxReadBytes = 10 << 20
+	// maxListEntries caps the files file.list returns
+	maxListEntries = 10000
+)
+
+// FilePolicy restricts the paths file and archive steps may use. Without
+// allowed roots file and archive steps are disabled.
+type FilePolicy struct {
+	// AllowedRoots lists the directories steps may read and write inside.
+	// Relative step paths are resolved against the first.
+	AllowedRoots []string `json:"allowed_roots,omitempty"`
+	// ReadOnlyRoots lists directories steps may only read
+	ReadOnlyRoots []string `json:"read_only_roots,omitempty"`
+	// MaxReadBytes caps the size of files file.read loads
+	MaxReadBytes int64 `json:"max_read_bytes,omitempty"`
+	// MaxExtractBytes caps the bytes one archive.extract step writes
+	MaxExtractBytes int64 `json:"max_extract_bytes,omitempty"`
+}
+
+// fileSystem applies the file policy shared by the file and archive actions
+type fileSystem struct {
+	mu     sync.RWMutex
+	policy FilePolicy
+	// protected lists paths steps may never use, such as the data
+	// directory and the secrets keystore, whatever the roots allow
+	protected []string
+}
+
+func newFileSystem() *fileSystem {
+	return &fileSystem{}
+}
+
+// setPolicy replaces the policy
+func (f *fileSystem) setPolicy(policy *FilePolicy) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if policy == nil {
+		f.policy = FilePolicy{}
+		return
+	}
+	f.policy = *policy
+}
+
+// setProtected replaces the paths no step may use
+func (f *fileSystem) setProtected(paths []string) {
+	protected := absoluteRoots(paths)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.protected = protected
+}
+
+// isProtected reports whether path is a protected path or inside one
+func (f *fileSystem) isProtected(path string) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return insideRoots(path, f.protected)
+}
+
+// containsProtected reports whether a protected path is inside path
+func (f *fileSystem) containsProtected(path string) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for _, protected := range f.protected {
+		if insideRoots(protected, []string{path}) {
+			return true
+		}
+	}
+	return false
+}
+
+// roots returns the writable and read-only roots as absolute paths
+func (f *fileSystem) roots() (writable, readOnly []string) {
+	f.mu.RLock()
+	allowed, readable := f.policy.AllowedRoots, f.policy.ReadOnlyRoots
+	f.mu.RUnlock()
+
+	return absoluteRoots(allowed), absoluteRoots(readable)
+}
+
+func absoluteRoots(roots []string) []string {
+	resolved := make([]string, 0, len(roots))
+	for _, root := range roots {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			continue
+		}
+		if real, err := filepath.EvalSymlinks(abs); err == nil {
+			abs = real
+		}
+		resolved = append(resolved, abs)
+	}
+	return resolved
+}
+
+// maxReadBytes returns the configured read limit
+func (f *fileSystem) maxReadBytes() int64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.policy.MaxReadBytes > 0 {
+		return f.policy.MaxReadBytes
+	}
+	return defaultMaxReadBytes
+}
+
+// maxExtractBytes returns the configured extraction limit
+func (f *fileSystem) maxExtractBytes() int64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.policy.MaxExtractBytes > 0 {
+		return f.policy.MaxExtractBytes
+	}
+	return defaultMaxExtractBytes
+}
+
+// resolve returns the real path of a step path after checking it is inside
+// an allowed root. Symbolic links are followed, so a link cannot lead out
+// of the roots. With write the path must be inside a writable root and may
+// not contain a protected path.
+func (f *fileSystem) resolve(path string, write bool) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("path is required")
+	}
+	writable, readOnly := f.roots()
+	if len(writable) == 0 && len(readOnly) == 0 {
+		return "", errNoFileRoots
+	}
+	if !filepath.IsAbs(path) {
+		base := readOnly
+		if len(writable) > 0 {
+			base = writable
+		}
+		path = filepath.Join(base[0], path)
+	}
+	real, err := realPath(filepath.Clean(path))
+	if err != nil {
+		return "", err
+	}
+	if f.isProtected(real) || (write && f.containsProtected(real)) {
+		return "", fmt.Errorf("path %q is protected", path)
+	}
+
+	if insideRoots(real, writable) {
+		return real, nil
+	}
+	if insideRoots(real, readOnly) {
+		if !write {
+			return real, nil
+		}
+		return "", fmt.Errorf("path %q is read-only under the file policy", path)
+	}
+	return "", fmt.Errorf("path %q is outside the roots allowed by the file policy", path)
+}
+
+// realPath resolves the symbolic links of the existing part of a path
+func realPath(path string) (string, error) {
+	var missing []string
+	current := path
+	for {
+		real, err := filepath.EvalSymlinks(current)
+		if err == nil {
+			for i := len(missing) - 1; i >= 0; i-- {
+				real = filepath.Join(real, missing[i])
+			}
+			return real, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return path, nil
+		}
+		missing = append(missing, filepath.Base(current))
+		current = parent
+	}
+}
+
+// insideRoots reports whether path is one of the roots or inside one
+func insideRoots(path string, roots []string) bool {
+	for _, root := range roots {
+		if path == root || strings.HasPrefix(path, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// isRoot reports whether path is itself an allowed root
+func (f *fileSystem) isRoot(path string) bool {
+	writable, readOnly := f.roots()
+	for _, root := range append(writable, readOnly...) {
+		if path == root {
+			return true
+		}
+	}
+	return false
+}
+
+// FileAction works on files inside the roots allowed by the file policy.
+// Each operation is registered as its own action: file.read, file.write,
+// file.move, file.copy, file.delete, file.list and file.checksum.
+type FileAction struct {
+	logger    *zap.Logger
+	files     *fileSystem
+	operation string
+}
+
+// NewFileAction creates the file action for an operation, using the registry's file policy
+func NewFileAction(logger *zap.Logger, registry *Registry, operation string) *FileAction {
+	return &FileAction{logger: logger, files: registry.files, operation: operation}
+}
+
+// TypedFields keeps the type of content given as a single template reference
+func (f *FileAction) TypedFields(config map[string]interface{}) []string {
+	if f.operation == "write" {
+		return []string{"content"}
+	}
+	return nil
+}
+
+// Execute runs the operation
+func (f *FileAction) Execute(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+	switch f.operation {
+	case "read":
+		return f.read(input)
+	case "write":
+		return f.write(input)
+	case "move", "copy":
+		return f.transfer(input)
+	case "delete":
+		return f.remove(input)
+	case "list":
+		return f.list(input)
+	case "checksum":
+		return f.checksum(input)
+	}
+	return nil, fmt.Errorf("unknown file operation %s", f.operation)
+}
+
+// read loads a file as text, JSON, CSV or base64
+func (f *FileAction) read(input map[string]interface{}) (map[string]interface{}, error) {
+	path, _ := input["path"].(string)
+	real, err := f.files.resolve(path, false)
+	if err != nil {
+		return nil, err
+	}
+	maxBytes, err := intInput(input, "max_bytes", f.files.maxReadBytes())
+	if err != nil {
+		return nil, err
+	}
+	if max := f.files.maxReadBytes(); maxBytes > max {
+		maxBytes = max
+	}
+
+	info, err := os.Stat(real)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", path)
+	}
+	if info.Size() > maxBytes {
+		return nil, fmt.Errorf("%s is %d bytes, more than max_bytes (%d)", path, info.Size(), maxBytes)
+	}
+	data, err := os.ReadFile(real)
+	if err != nil {
+		return nil, err
+	}
+
+	output := map[string]interface{}{
+		"path": real,
+		"size": len(data),
+	}
+	format, _ := input["format"].(string)
+	switch format {
+	case "", "text":
+		if !utf8.Valid(data) {
+			return nil, fmt.Errorf("%s is not UTF-8 text; read it with format base64", path)
+		}
+		output["content"] = string(data)
+	case "base64":
+		output["content"] = base64.StdEncoding.EncodeToString(data)
+	case "json":
+		var parsed interface{}
+		if err := json.Unmarshal(data, &parsed); err != nil {
+			return nil, fmt.Errorf("%s is not valid JSON: %w", path, err)
+		}
+		output["data"] = parsed
+	case "csv":
+		rows, columns, err := readCSV(data, input)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		output["rows"] = rows
+		output["count"] = len(rows)
+		if columns != nil {
+			output["columns"] = columns
+		}
+	default:
+		return nil, fmt.Errorf("format must be text, json, csv or base64")
+	}
+	return output, nil
+}
+
+// readCSV parses CSV data. With a header row (the default) each row is an
+// object keyed by column name, otherwise a list of values.
+func readCSV(data []byte, input map[string]interface{}) ([]interface{}, []string, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	if delimiter, _ := input["delimiter"].(string); delimiter != "" {
+		r, size := utf8.DecodeRuneInString(delimiter)
+		if size != len(delimiter) {
+			return nil, nil, fmt.Errorf("delimiter must be a single character")
+		}
+		reader.Comma = r
+	}
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows := make([]interface{}, 0, len(records))
+	if !boolInput(input, "header", true) {
+		for _, record := range records {
+			values := make([]interface{}, len(record))
+			for i, value := range record {
+				values[i] = value
+			}
+			rows = append(rows, values)
+		}
+		return rows, nil, nil
+	}
+
+	if len(records) == 0 {
+		return rows, []string{}, nil
+	}
+	columns := records[0]
+	for _, record := range records[1:] {
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			if i < len(record) {
+				row[column] = record[i]
+			} else {
+				row[column] = nil
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, columns, nil
+}
+
+// write creates or replaces a file. Content that is not a string is
+// written as JSON. The file is written beside its destination and renamed
+// into place, so readers never see it half written.
+func (f *FileAction) write(input map[string]interface{}) (map[string]interface{}, error) {
+	path, _ := input["path"].(string)
+	real, err := f.files.resolve(path, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	switch content := input["content"].(type) {
+	case nil:
+		return nil, fmt.Errorf("content parameter is required")
+	case string:
+		data = []byte(content)
+		if input["encoding"] == "base64" {
+			if data, err = base64.StdEncoding.DecodeString(content); err != nil {
+				return nil, fmt.Errorf("content is not valid base64: %w", err)
+			}
+		}
+	default:
+		if data, err = json.MarshalIndent(content, "", "  "); err != nil {
+			return nil, fmt.Errorf("failed to encode content: %w", err)
+		}
+		data = append(data, '\n')
+	}
+
+	mode, err := fileMode(input, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if boolInput(input, "create_dirs", true) {
+		if err := os.MkdirAll(filepath.Dir(real), 0o755); err != nil {
+			return nil, err
+		}
+	}
+
+	if boolInput(input, "append", false) {
+		file, err := os.OpenFile(real, os.O_WRONLY|os.O_CREATE|os.O_APPEND, mode)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := file.Write(data); err != nil {
+			file.Close()
+			return nil, err
+		}
+		if err := file.Close(); err != nil {
+			return nil, err
+		}
+	} else {
+		if !boolInput(input, "overwrite", true) {
+			if _, err := os.Lstat(real); err == nil {
+				return nil, fmt.Errorf("%s already exists", path)
+			}
+		}
+		if err := writeFileAtomic(real, bytes.NewReader(data), mode); err != nil {
+			return nil, err
+		}
+	}
+
+	f.logger.Info("File written", zap.String("path", real), zap.Int("bytes", len(data)))
+	return map[string]interface{}{
+		"path": real,
+		"size": len(data),
+	}, nil
+}
+
+// fileMode reads a permission mode given as an octal string such as "0640"
+func fileMode(input map[string]interface{}, defaultMode os.FileMode) (os.FileMode, error) {
+	value, exists := input["mode"]
+	if !exists || value == nil {
+		return defaultMode, nil
+	}
+	str, ok := value.(string)
+	if !ok {
+		return 0, fmt.Errorf("mode must be an octal string such as \"0644\"")
+	}
+	mode, err := strconv.ParseUint(str, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, fmt.Errorf("mode must be an octal string such as \"0644\"")
+	}
+	return os.FileMode(mode), nil
+}
+
+// writeFileAtomic writes to a temporary file in the destination directory
+// and renames it over the destination
+func writeFileAtomic(path string, r io.Reader, mode os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// transfer moves or copies a file or directory
+func (f *FileAction) transfer(input map[string]interface{}) (map[string]interface{}, error) {
+	source, _ := input["source"].(string)
+	destination, _ := input["destination"].(string)
+	if source == "" || destination == "" {
+		return nil, fmt.Errorf("source and destination parameters are required")
+	}
+	move := f.operation == "move"
+
+	realSource, err := f.files.resolve(source, move)
+	if err != nil {
+		return nil, err
+	}
+	realDestination, err := f.files.resolve(destination, true)
+	if err != nil {
+		return nil, err
+	}
+	if move && f.files.isRoot(realSource) {
+		return nil, fmt.Errorf("cannot move an allowed root")
+	}
+
+	info, err := os.Lstat(realSource)
+	if err != nil {
+		return nil, err
+	}
+	if realDestination == realSource || (info.IsDir() && insideRoots(realDestination, []string{realSource})) {
+		return nil, fmt.Errorf("destination %s is inside source %s", destination, source)
+	}
+	if _, err := os.Lstat(realDestination); err == nil {
+		if !boolInput(input, "overwrite", false) {
+			return nil, fmt.Errorf("%s already exists; set overwrite to replace it", destination)
+		}
+		if err := os.RemoveAll(realDestination); err != nil {
+			return nil, err
+		}
+	}
+	if boolInput(input, "create_dirs", true) {
+		if err := os.MkdirAll(filepath.Dir(realDestination), 0o755); err != nil {
+			return nil, err
+		}
+	}
+
+	if move {
+		err = os.Rename(realSource, realDestination)
+		var linkErr *os.LinkError
+		if errors.As(err, &linkErr) && errors.Is(linkErr.Err, syscall.EXDEV) {
+			// Across file systems a move is a copy and a delete
+			if err = copyPath(realSource, realDestination, f.files.isProtected); err == nil {
+				err = os.RemoveAll(realSource)
+			}
+		}
+	} else {
+		err = copyPath(realSource, realDestination, f.files.isProtected)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s %s: %w", f.operation, source, err)
+	}
+
+	message := "File copied"
+	if move {
+		message = "File moved"
+	}
+	f.logger.Info(message, zap.String("source", realSource), zap.String("destination", realDestination))
+	return map[string]interface{}{
+		"source":      realSource,
+		"destination": realDestination,
+	}, nil
+}
+
+// copyPath copies a file, or a directory with its contents. Symbolic links
+// inside a directory are skipped rather than followed, as are paths skip
+// reports.
+func copyPath(source, destination string, skip func(string) bool) error {
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return copyFile(source, destination, info.Mode().Perm())
+	}
+
+	return filepath.WalkDir(source, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if skip(path) {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(destination, rel)
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case entry.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0o700)
+		case entry.Type().IsRegular():
+			return copyFile(path, target, info.Mode().Perm())
+		}
+		return nil
+	})
+}
+
+// copyFile copies one regular file
+func copyFile(source, destination string, mode os.FileMode) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return writeFileAtomic(destination, in, mode)
+}
+
+// remove deletes a file, or a directory when recursive is set
+func (f *FileAction) remove(input map[string]interface{}) (map[string]interface{}, error) {
+	path, _ := input["path"].(string)
+	real, err := f.files.resolve(path, true)
+	if err != nil {
+		return nil, err
+	}
+	if f.files.isRoot(real) {
+		return nil, fmt.Errorf("cannot delete an allowed root")
+	}
+
+	info, err := os.Lstat(real)
+	if errors.Is(err, fs.ErrNotExist) && boolInput(input, "missing_ok", false) {
+		return map[string]interface{}{"path": real, "deleted": false}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() && boolInput(input, "recursive", false) {
+		err = os.RemoveAll(real)
+	} else {
+		err = os.Remove(real)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	f.logger.Info("File deleted", zap.String("path", real))
+	return map[string]interface{}{"path": real, "deleted": true}, nil
+}
+
+// list returns the entries of a directory whose names match a glob
+// pattern, descending into subdirectories with recursive
+func (f *FileAction) list(input map[string]interface{}) (map[string]interface{}, error) {
+	dir, _ := input["path"].(string)
+	if dir == "" {
+		dir = "."
+	}
+	real, err := f.files.resolve(dir, false)
+	if err != nil {
+		return nil, err
+	}
+	pattern, _ := input["pattern"].(string)
+	if pattern == "" {
+		pattern = "*"
+	}
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	recursive := boolInput(input, "recursive", false)
+	includeDirs := boolInput(input, "include_dirs", false)
+
+	files := make([]interface{}, 0)
+	truncated := false
+	err = filepath.WalkDir(real, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == real {
+			return nil
+		}
+		if f.files.isProtected(path) {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		matched, _ := filepath.Match(pattern, entry.Name())
+		if matched && (!entry.IsDir() || includeDirs) {
+			if len(files) >= maxListEntries {
+				truncated = true
+				return fs.SkipAll
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(real, path)
+			files = append(files, map[string]interface{}{
+				"path":     path,
+				"relative": filepath.ToSlash(rel),
+				"name":     entry.Name(),
+				"size":     info.Size(),
+				"modified": info.ModTime().UTC().Format(time.RFC3339),
+				"dir":      entry.IsDir(),
+			})
+		}
+		if entry.IsDir() && !recursive {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"path":      real,
+		"files":     files,
+		"count":     len(files),
+		"truncated": truncated,
+	}, nil
+}
+
+// checksumAlgorithms are the hashes file.checksum supports
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// checksum hashes a file, failing when it differs from an expected value
+func (f *FileAction) checksum(input map[string]interface{}) (map[string]interface{}, error) {
+	path, _ := input["path"].(string)
+	real, err := f.files.resolve(path, false)
+	if err != nil {
+		return nil, err
+	}
+	algorithm, _ := input["algorithm"].(string)
+	if algorithm == "" {
+		algorithm = "sha256"
+	}
+	newHash, ok := checksumAlgorithms[strings.ToLower(algorithm)]
+	if !ok {
+		names := make([]string, 0, len(checksumAlgorithms))
+		for name := range checksumAlgorithms {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("algorithm must be one of: %s", strings.Join(names, ", "))
+	}
+
+	file, err := os.Open(real)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	h := newHash()
+	size, err := io.Copy(h, file)
+	if err != nil {
+		return nil, err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+
+	if expected, _ := input["expected"].(string); expected != "" && !strings.EqualFold(expected, sum) {
+		return nil, fmt.Errorf("%s checksum of %s is %s, expected %s", algorithm, path, sum, expected)
+	}
+	return map[string]interface{}{
+		"path":      real,
+		"algorithm": strings.ToLower(algorithm),
+		"checksum":  sum,
+		"size":      size,
+	}, nil
+}
@@ -0,0 +1,116 @@
+package actions
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// newTestFiles returns a registry whose file policy allows one temporary root
+func newTestFiles(t *testing.T) (*Registry, string) {
+	t.Helper()
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := NewRegistry(zap.NewNop())
+	registry.SetFilePolicy(&FilePolicy{AllowedRoots: []string{root}})
+	return registry, root
+}
+
+func runFileAction(t *testing.T, registry *Registry, name string, input map[string]interface{}) (map[string]interface{}, error) {
+	t.Helper()
+	action, err := registry.GetAction(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return action.Execute(context.Background(), input)
+}
+
+func TestFileActionsDisabledWithoutRoots(t *testing.T) {
+	registry := NewRegistry(zap.NewNop())
+	_, err := runFileAction(t, registry, "file.read", map[string]interface{}{"path": "go.mod"})
+	if err == nil || !strings.Contains(err.Error(), "disabled") {
+		t.Fatalf("err = %v, want file steps disabled", err)
+	}
+}
+
+func TestFilePolicyRejectsPathsOutsideRoots(t *testing.T) {
+	registry, root := newTestFiles(t)
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{name: "absolute", path: filepath.Join(outside, "secret.txt")},
+		{name: "dot dot", path: "../" + filepath.Base(outside) + "/secret.txt"},
+		{name: "symlink", path: "link/secret.txt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := runFileAction(t, registry, "file.read", map[string]interface{}{"path": tt.path}); err == nil {
+				t.Errorf("file.read %s succeeded", tt.path)
+			}
+		})
+	}
+}
+
+func TestFilePolicyProtectedPaths(t *testing.T) {
+	registry, root := newTestFiles(t)
+	data := filepath.Join(root, "data")
+	if err := os.MkdirAll(filepath.Join(data, "secrets"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(data, "secrets", "keystore.key"), []byte("master-key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "report.txt"), []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	registry.SetProtectedPaths(data)
+
+	if _, err := runFileAction(t, registry, "file.read", map[string]interface{}{"path": "data/secrets/keystore.key"}); err == nil {
+		t.Error("file.read of a protected file succeeded")
+	}
+	if _, err := runFileAction(t, registry, "file.delete", map[string]interface{}{"path": root, "recursive": true}); err == nil {
+		t.Error("file.delete of a directory holding a protected path succeeded")
+	}
+
+	result, err := runFileAction(t, registry, "file.list", map[string]interface{}{"path": ".", "recursive": true, "include_dirs": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range result["files"].([]interface{}) {
+		if path := entry.(map[string]interface{})["path"].(string); strings.HasPrefix(path, data) {
+			t.Errorf("file.list returned protected path %s", path)
+		}
+	}
+
+	if _, err := runFileAction(t, registry, "file.copy", map[string]interface{}{"source": ".", "destination": filepath.Join(root, "copy")}); err == nil {
+		t.Error("file.copy into a subdirectory of the source succeeded")
+	}
+	if _, err := runFileAction(t, registry, "archive.zip", map[string]interface{}{"sources": []interface{}{root}, "destination": "out.zip"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runFileAction(t, registry, "archive.extract", map[string]interface{}{"source": "out.zip", "destination": "unpacked"}); err != nil {
+		t.Fatal(err)
+	}
+	base := filepath.Base(root)
+	if _, err := os.Stat(filepath.Join(root, "unpacked", base, "report.txt")); err != nil {
+		t.Errorf("archived file missing: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "unpacked", base, "data")); !os.IsNotExist(err) {
+		t.Errorf("protected directory was archived: %v", err)
+	}
+}
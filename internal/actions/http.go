@@ -1,12 +1,13 @@
 package actions
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	neturl "net/url"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
@@ -15,17 +16,35 @@ import (
 // HTTPAction implements HTTP request actions
 type HTTPAction struct {
 	logger *zap.Logger
-	client *http.Client
+	policy *EgressPolicy
+	files  *fileSystem
+
+	mu         sync.Mutex
+	transports map[transportKey]*http.Transport
+	tokens     map[string]*oauthToken
+}
+
+// NewHTTPAction creates a new HTTP action with the default egress policy.
+// Uploads, certificates and downloads go through the registry's file policy.
+func NewHTTPAction(logger *zap.Logger, registry *Registry) *HTTPAction {
+	h := &HTTPAction{logger: logger, files: registry.files}
+	h.SetEgressPolicy(nil)
+	return h
 }
 
-// NewHTTPAction creates a new HTTP action
-func NewHTTPAction(logger *zap.Logger) *HTTPAction {
-	return &HTTPAction{
-		logger: logger,
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+// SetEgressPolicy restricts the destinations requests may reach. A nil
+// policy only blocks internal addresses for templated URLs.
+func (h *HTTPAction) SetEgressPolicy(policy *EgressPolicy) {
+	if policy == nil {
+		policy = &EgressPolicy{}
 	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.policy = policy
+	h.transports = make(map[transportKey]*http.Transport)
+	h.tokens = make(map[string]*oauthToken)
 }
 
 // Execute performs an HTTP request
@@ -41,13 +60,14 @@ func (h *HTTPAction) Execute(ctx context.Context, input map[string]interface{})
 		method = m
 	}
 
-	var body io.Reader
-	if bodyData, ok := input["body"]; ok {
-		bodyBytes, err := json.Marshal(bodyData)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal request body: %w", err)
-		}
-		body = bytes.NewReader(bodyBytes)
+	timeout, err := durationInput(input, "timeout", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	body, contentType, err := h.buildBody(input)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create request
@@ -55,6 +75,47 @@ func (h *HTTPAction) Execute(ctx context.Context, input map[string]interface{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
+	if err := applyQuery(req.URL, input); err != nil {
+		return nil, err
+	}
+	if err := h.policy.CheckHost(req.URL.Hostname()); err != nil {
+		return nil, fmt.Errorf("HTTP request to %s blocked: %w", url, err)
+	}
+
+	// URLs rendered from templates may not reach internal addresses
+	info, _ := StepInfoFromContext(ctx)
+	tlsOpts, err := h.parseTLSOptions(input)
+	if err != nil {
+		return nil, err
+	}
+	transport, err := h.transport(h.policy.blocksPrivate(info.IsTemplated("url")), tlsOpts)
+	if err != nil {
+		return nil, err
+	}
+	checkRedirect, err := h.redirectPolicy(input)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{
+		Transport:     transport,
+		Timeout:       timeout,
+		CheckRedirect: checkRedirect,
+	}
+
+	isSuccess, err := statusMatcher(input)
+	if err != nil {
+		return nil, err
+	}
+	respOpts, err := parseResponseOptions(input)
+	if err != nil {
+		return nil, err
+	}
+	saveTo := ""
+	if respOpts.saveTo != "" {
+		if saveTo, err = h.files.resolve(respOpts.saveTo, true); err != nil {
+			return nil, fmt.Errorf("save_to: %w", err)
+		}
+	}
 
 	// Set headers
 	if headers, ok := input["headers"].(map[string]interface{}); ok {
@@ -63,9 +124,13 @@ func (h *HTTPAction) Execute(ctx context.Context, input map[string]interface{})
 		}
 	}
 
-	// Set default content type for POST/PUT requests with body
+	// Set the content type for the body unless a header sets it
 	if body != nil && req.Header.Get("Content-Type") == "" {
-		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	if err := h.applyAuth(ctx, req, input, client); err != nil {
+		return nil, err
 	}
 
 	h.logger.Info("Executing HTTP request", 
@@ -73,48 +138,95 @@ func (h *HTTPAction) Execute(ctx context.Context, input map[string]interface{})
 		zap.String("url", url))
 
 	// Perform request
-	resp, err := h.client.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
+		if errors.Is(err, ErrEgressDenied) {
+			var urlErr *neturl.Error
+			if errors.As(err, &urlErr) {
+				err = urlErr.Err
+			}
+			return nil, fmt.Errorf("HTTP request to %s blocked: %w", url, err)
+		}
 		return nil, fmt.Errorf("HTTP request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
-	// Read response body
-	responseBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+	// An expected status counts as success even outside success_status
+	success := isSuccess(resp.StatusCode)
+	if respOpts.expect != nil && respOpts.expect.status != nil && respOpts.expect.status(resp.StatusCode) {
+		success = true
 	}
-
-	// Parse JSON response if possible
-	var responseData interface{}
-	if len(responseBody) > 0 {
-		if err := json.Unmarshal(responseBody, &responseData); err != nil {
-			// If JSON parsing fails, use raw string
-			responseData = string(responseBody)
-		}
-	}
-
 	result := map[string]interface{}{
 		"status_code": resp.StatusCode,
-		"headers":     make(map[string]string),
-		"body":        responseData,
-		"success":     resp.StatusCode >= 200 && resp.StatusCode < 300,
+		"success":     success,
 	}
 
-	// Convert headers to string map
+	// Convert headers to string maps, keeping every value in header_values
 	headers := make(map[string]string)
+	headerValues := make(map[string][]string)
 	for key, values := range resp.Header {
 		if len(values) > 0 {
 			headers[key] = values[0]
+			headerValues[key] = values
 		}
 	}
 	result["headers"] = headers
+	result["header_values"] = headerValues
+
+	var responseData interface{}
+	var responseBody []byte
+	if saveTo != "" && success {
+		// Stream downloads to disk instead of keeping them in the instance
+		written, sum, err := saveBody(resp.Body, saveTo, respOpts.maxBytes)
+		if err != nil {
+			return result, err
+		}
+		result["saved_to"] = saveTo
+		result["bytes"] = written
+		result["sha256"] = sum
+	} else {
+		maxBytes := respOpts.maxBytes
+		if saveTo != "" && maxBytes == 0 {
+			maxBytes = defaultMaxResponseBytes
+		}
+
+		// Read response body
+		responseBody, err = readBody(resp.Body, maxBytes)
+		if err != nil {
+			return result, fmt.Errorf("failed to read response body: %w", err)
+		}
+
+		// Parse JSON response if possible
+		if len(responseBody) > 0 {
+			if err := json.Unmarshal(responseBody, &responseData); err != nil {
+				// If JSON parsing fails, use raw string
+				responseData = string(responseBody)
+			}
+		}
+		result["body"] = responseData
+
+		if respOpts.extract != nil {
+			extracted, err := extractOutputs(respOpts.extract, responseData)
+			if err != nil {
+				return result, err
+			}
+			for name, value := range extracted {
+				result[name] = value
+			}
+		}
+	}
 
 	h.logger.Info("HTTP request completed", 
 		zap.String("url", url),
 		zap.Int("status_code", resp.StatusCode))
 
-	if !result["success"].(bool) {
+	if respOpts.expect != nil {
+		if err := respOpts.expect.check(resp, responseData, responseBody); err != nil {
+			return result, fmt.Errorf("HTTP response expectation failed: %w", err)
+		}
+	}
+
+	if !success {
 		return result, fmt.Errorf("HTTP request failed with status %d", resp.StatusCode)
 	}
 
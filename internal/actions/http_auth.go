@@ -0,0 +1,152 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+)
+
+// tokenExpiryMargin renews OAuth2 tokens shortly before they expire
+const tokenExpiryMargin = 30 * time.Second
+
+// oauthToken is a cached client credentials access token
+type oauthToken struct {
+	accessToken string
+	tokenType   string
+	expires     time.Time
+}
+
+// applyAuth sets credentials from the "auth" input: basic, bearer or
+// oauth2_client_credentials
+func (h *HTTPAction) applyAuth(ctx context.Context, req *http.Request, input map[string]interface{}, client *http.Client) error {
+	raw, exists := input["auth"]
+	if !exists || raw == nil {
+		return nil
+	}
+	auth, ok := raw.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("auth must be a map")
+	}
+
+	str := func(key string) string {
+		value, _ := auth[key].(string)
+		return value
+	}
+
+	switch authType := str("type"); authType {
+	case "basic":
+		req.SetBasicAuth(str("username"), str("password"))
+	case "bearer":
+		if str("token") == "" {
+			return fmt.Errorf("auth.token is required for bearer auth")
+		}
+		req.Header.Set("Authorization", "Bearer "+str("token"))
+	case "oauth2_client_credentials":
+		token, err := h.clientCredentialsToken(ctx, auth, client)
+		if err != nil {
+			return fmt.Errorf("oauth2 token request failed: %w", err)
+		}
+		req.Header.Set("Authorization", token.tokenType+" "+token.accessToken)
+	default:
+		return fmt.Errorf("auth.type must be basic, bearer or oauth2_client_credentials, got %q", authType)
+	}
+	return nil
+}
+
+// clientCredentialsToken returns a cached token or requests a new one.
+// Client credentials are sent with basic auth unless client_auth is "body".
+func (h *HTTPAction) clientCredentialsToken(ctx context.Context, auth map[string]interface{}, client *http.Client) (*oauthToken, error) {
+	tokenURL, _ := auth["token_url"].(string)
+	clientID, _ := auth["client_id"].(string)
+	clientSecret, _ := auth["client_secret"].(string)
+	if tokenURL == "" || clientID == "" {
+		return nil, fmt.Errorf("auth.token_url and auth.client_id are required")
+	}
+
+	form := neturl.Values{"grant_type": {"client_credentials"}}
+	var scopes []string
+	for _, scope := range formValues(auth["scopes"]) {
+		if scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(scopes) > 0 {
+		form.Set("scope", strings.Join(scopes, " "))
+	}
+	if audience, ok := auth["audience"].(string); ok && audience != "" {
+		form.Set("audience", audience)
+	}
+	inBody := auth["client_auth"] == "body"
+	if inBody {
+		form.Set("client_id", clientID)
+		form.Set("client_secret", clientSecret)
+	}
+
+	cacheKey := strings.Join([]string{tokenURL, clientID, clientSecret, form.Encode()}, "\x00")
+	h.mu.Lock()
+	cached, exists := h.tokens[cacheKey]
+	h.mu.Unlock()
+	if exists && time.Now().Before(cached.expires) {
+		return cached, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if !inBody {
+		req.SetBasicAuth(neturl.QueryEscape(clientID), neturl.QueryEscape(clientSecret))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("token endpoint returned status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		AccessToken string      `json:"access_token"`
+		TokenType   string      `json:"token_type"`
+		ExpiresIn   json.Number `json:"expires_in"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, fmt.Errorf("invalid token response: %w", err)
+	}
+	if body.AccessToken == "" {
+		return nil, fmt.Errorf("token response has no access_token")
+	}
+
+	token := &oauthToken{
+		accessToken: body.AccessToken,
+		tokenType:   "Bearer",
+		expires:     time.Now().Add(time.Hour),
+	}
+	if body.TokenType != "" && !strings.EqualFold(body.TokenType, "bearer") {
+		token.tokenType = body.TokenType
+	}
+	if seconds, err := body.ExpiresIn.Int64(); err == nil && seconds > 0 {
+		token.expires = time.Now().Add(time.Duration(seconds) * time.Second)
+	}
+	token.expires = token.expires.Add(-tokenExpiryMargin)
+
+	h.mu.Lock()
+	h.tokens[cacheKey] = token
+	h.mu.Unlock()
+
+	return token, nil
+}
@@ -0,0 +1,369 @@
+package actions
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// defaultMaxRedirects matches the limit of Go's default client
+	defaultMaxRedirects = 10
+	// maxCachedTransports bounds the transports kept for distinct TLS settings
+	maxCachedTransports = 32
+)
+
+// tlsOptions configures server verification and client certificates. PEM
+// values may come from secrets; files are read when the transport is built.
+type tlsOptions struct {
+	CAFile             string
+	CAPEM              string
+	CertFile           string
+	CertPEM            string
+	KeyFile            string
+	KeyPEM             string
+	ServerName         string
+	InsecureSkipVerify bool
+}
+
+// parseTLSOptions reads the "tls" and "insecure_skip_verify" inputs,
+// resolving certificate and key files through the file policy
+func (h *HTTPAction) parseTLSOptions(input map[string]interface{}) (tlsOptions, error) {
+	var opts tlsOptions
+	opts.InsecureSkipVerify = boolInput(input, "insecure_skip_verify", false)
+
+	raw, exists := input["tls"]
+	if !exists || raw == nil {
+		return opts, nil
+	}
+	config, ok := raw.(map[string]interface{})
+	if !ok {
+		return opts, fmt.Errorf("tls must be a map")
+	}
+
+	opts.CAFile, _ = config["ca_file"].(string)
+	opts.CAPEM, _ = config["ca"].(string)
+	opts.CertFile, _ = config["cert_file"].(string)
+	opts.CertPEM, _ = config["cert"].(string)
+	opts.KeyFile, _ = config["key_file"].(string)
+	opts.KeyPEM, _ = config["key"].(string)
+	opts.ServerName, _ = config["server_name"].(string)
+	opts.InsecureSkipVerify = boolInput(config, "insecure_skip_verify", opts.InsecureSkipVerify)
+
+	if (opts.CertFile != "" || opts.CertPEM != "") != (opts.KeyFile != "" || opts.KeyPEM != "") {
+		return opts, fmt.Errorf("tls client certificates need both a certificate and a key")
+	}
+
+	for key, path := range map[string]*string{"ca_file": &opts.CAFile, "cert_file": &opts.CertFile, "key_file": &opts.KeyFile} {
+		if *path == "" {
+			continue
+		}
+		real, err := h.files.resolve(*path, false)
+		if err != nil {
+			return opts, fmt.Errorf("tls.%s: %w", key, err)
+		}
+		*path = real
+	}
+	return opts, nil
+}
+
+// config builds the crypto/tls configuration, or nil for the defaults
+func (o tlsOptions) config() (*tls.Config, error) {
+	if o == (tlsOptions{}) {
+		return nil, nil
+	}
+
+	config := &tls.Config{
+		ServerName:         o.ServerName,
+		InsecureSkipVerify: o.InsecureSkipVerify,
+	}
+
+	if o.CAFile != "" || o.CAPEM != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		caPEM := []byte(o.CAPEM)
+		if o.CAFile != "" {
+			if caPEM, err = os.ReadFile(o.CAFile); err != nil {
+				return nil, fmt.Errorf("failed to read CA file: %w", err)
+			}
+		}
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no CA certificates found in tls ca")
+		}
+		config.RootCAs = pool
+	}
+
+	if o.CertFile != "" || o.CertPEM != "" {
+		certPEM, keyPEM := []byte(o.CertPEM), []byte(o.KeyPEM)
+		var err error
+		if o.CertFile != "" {
+			if certPEM, err = os.ReadFile(o.CertFile); err != nil {
+				return nil, fmt.Errorf("failed to read client certificate: %w", err)
+			}
+		}
+		if o.KeyFile != "" {
+			if keyPEM, err = os.ReadFile(o.KeyFile); err != nil {
+				return nil, fmt.Errorf("failed to read client key: %w", err)
+			}
+		}
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// transportKey identifies a cached transport
+type transportKey struct {
+	blockPrivate bool
+	tls          tlsOptions
+}
+
+// transport returns a cached transport for the egress and TLS settings, so
+// connections are reused between requests with the same settings. Once
+// maxCachedTransports are cached, an arbitrary one is dropped.
+func (h *HTTPAction) transport(blockPrivate bool, opts tlsOptions) (*http.Transport, error) {
+	key := transportKey{blockPrivate: blockPrivate, tls: opts}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if transport, exists := h.transports[key]; exists {
+		return transport, nil
+	}
+
+	tlsConfig, err := opts.config()
+	if err != nil {
+		return nil, err
+	}
+	for evict, cached := range h.transports {
+		if len(h.transports) < maxCachedTransports {
+			break
+		}
+		cached.CloseIdleConnections()
+		delete(h.transports, evict)
+	}
+	transport := h.policy.transport(blockPrivate)
+	transport.TLSClientConfig = tlsConfig
+	h.transports[key] = transport
+	return transport, nil
+}
+
+// applyQuery adds the "query" input to a URL; list values repeat the key
+func applyQuery(u *neturl.URL, input map[string]interface{}) error {
+	raw, exists := input["query"]
+	if !exists || raw == nil {
+		return nil
+	}
+	params, ok := raw.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("query must be a map")
+	}
+
+	query := u.Query()
+	for _, key := range sortedKeys(params) {
+		for _, value := range formValues(params[key]) {
+			query.Add(key, value)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return nil
+}
+
+// buildBody encodes the "body" input according to "body_type": json
+// (default), form, multipart or raw. It returns the body and its content type.
+func (h *HTTPAction) buildBody(input map[string]interface{}) (io.Reader, string, error) {
+	bodyData, hasBody := input["body"]
+	files, hasFiles := input["files"].(map[string]interface{})
+
+	bodyType, _ := input["body_type"].(string)
+	if bodyType == "" {
+		bodyType = "json"
+		if hasFiles {
+			bodyType = "multipart"
+		}
+	}
+	if !hasBody && !hasFiles {
+		return nil, "", nil
+	}
+
+	switch bodyType {
+	case "json":
+		bodyBytes, err := json.Marshal(bodyData)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to marshal request body: %w", err)
+		}
+		return bytes.NewReader(bodyBytes), "application/json", nil
+
+	case "raw":
+		switch v := bodyData.(type) {
+		case string:
+			return strings.NewReader(v), "text/plain; charset=utf-8", nil
+		case []byte:
+			return bytes.NewReader(v), "application/octet-stream", nil
+		}
+		return nil, "", fmt.Errorf("raw body must be a string")
+
+	case "form":
+		fields, ok := bodyData.(map[string]interface{})
+		if !ok {
+			return nil, "", fmt.Errorf("form body must be a map")
+		}
+		values := neturl.Values{}
+		for _, key := range sortedKeys(fields) {
+			for _, value := range formValues(fields[key]) {
+				values.Add(key, value)
+			}
+		}
+		return strings.NewReader(values.Encode()), "application/x-www-form-urlencoded", nil
+
+	case "multipart":
+		var buf bytes.Buffer
+		writer := multipart.NewWriter(&buf)
+		if hasBody {
+			fields, ok := bodyData.(map[string]interface{})
+			if !ok {
+				return nil, "", fmt.Errorf("multipart body must be a map")
+			}
+			for _, key := range sortedKeys(fields) {
+				for _, value := range formValues(fields[key]) {
+					if err := writer.WriteField(key, value); err != nil {
+						return nil, "", err
+					}
+				}
+			}
+		}
+		for _, field := range sortedKeys(files) {
+			path, ok := files[field].(string)
+			if !ok {
+				return nil, "", fmt.Errorf("files.%s must be a file path", field)
+			}
+			real, err := h.files.resolve(path, false)
+			if err != nil {
+				return nil, "", fmt.Errorf("files.%s: %w", field, err)
+			}
+			if err := addFilePart(writer, field, real); err != nil {
+				return nil, "", err
+			}
+		}
+		if err := writer.Close(); err != nil {
+			return nil, "", err
+		}
+		return &buf, writer.FormDataContentType(), nil
+	}
+
+	return nil, "", fmt.Errorf("body_type must be json, form, multipart or raw")
+}
+
+// addFilePart copies a file into a multipart request
+func addFilePart(writer *multipart.Writer, field, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open files.%s: %w", field, err)
+	}
+	defer file.Close()
+
+	part, err := writer.CreateFormFile(field, filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(part, file)
+	return err
+}
+
+// formValues converts a value into form values; lists become repeated values
+func formValues(value interface{}) []string {
+	switch v := value.(type) {
+	case nil:
+		return []string{""}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			values = append(values, fmt.Sprintf("%v", item))
+		}
+		return values
+	case map[string]interface{}:
+		encoded, _ := json.Marshal(v)
+		return []string{string(encoded)}
+	}
+	return []string{fmt.Sprintf("%v", value)}
+}
+
+// redirectPolicy builds the client's redirect check from "follow_redirects"
+// and "max_redirects". Redirect targets are subject to the egress policy.
+func (h *HTTPAction) redirectPolicy(input map[string]interface{}) (func(*http.Request, []*http.Request) error, error) {
+	follow := boolInput(input, "follow_redirects", true)
+	maxRedirects, err := intInput(input, "max_redirects", defaultMaxRedirects)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(req *http.Request, via []*http.Request) error {
+		if !follow {
+			return http.ErrUseLastResponse
+		}
+		if int64(len(via)) >= maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		return h.policy.CheckHost(req.URL.Hostname())
+	}, nil
+}
+
+// statusMatcher reports whether a status code counts as success. Entries in
+// "success_status" are codes (200) or classes ("2xx"); the default is 2xx.
+func statusMatcher(input map[string]interface{}) (func(int) bool, error) {
+	raw, exists := input["success_status"]
+	if !exists || raw == nil {
+		return func(code int) bool { return code >= 200 && code < 300 }, nil
+	}
+
+	list, ok := raw.([]interface{})
+	if !ok {
+		list = []interface{}{raw}
+	}
+
+	codes := make(map[int]bool)
+	classes := make(map[int]bool)
+	for _, item := range list {
+		entry := strings.ToLower(strings.TrimSpace(fmt.Sprintf("%v", item)))
+		if len(entry) == 3 && strings.HasSuffix(entry, "xx") && entry[0] >= '1' && entry[0] <= '5' {
+			classes[int(entry[0]-'0')] = true
+			continue
+		}
+		code, err := strconv.Atoi(entry)
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("success_status entries must be status codes or classes like 2xx, got %q", entry)
+		}
+		codes[code] = true
+	}
+
+	return func(code int) bool {
+		return codes[code] || classes[code/100]
+	}, nil
+}
+
+// sortedKeys returns map keys in a stable order
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
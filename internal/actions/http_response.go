@@ -0,0 +1,254 @@
+package actions
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/logimos/conduktr/internal/persistence"
+)
+
+// defaultMaxResponseBytes caps response bodies read into memory
+const defaultMaxResponseBytes = 10 << 20
+
+// builtinOutputs are the outputs extract rules may not replace
+var builtinOutputs = map[string]bool{
+	"status_code":   true,
+	"headers":       true,
+	"header_values": true,
+	"body":          true,
+	"success":       true,
+	"saved_to":      true,
+	"bytes":         true,
+	"sha256":        true,
+}
+
+// responseOptions controls how a response body is read and checked
+type responseOptions struct {
+	maxBytes int64
+	saveTo   string
+	extract  map[string]string
+	expect   *expectation
+}
+
+// expectation fails a step when the response does not match
+type expectation struct {
+	status       func(int) bool
+	bodyContains []string
+	json         map[string]interface{}
+	headers      map[string]string
+}
+
+// parseResponseOptions reads max_response_bytes, save_to, extract and expect
+func parseResponseOptions(input map[string]interface{}) (*responseOptions, error) {
+	opts := &responseOptions{}
+	opts.saveTo, _ = input["save_to"].(string)
+
+	// Downloads are unlimited unless a limit is given
+	defaultMax := int64(defaultMaxResponseBytes)
+	if opts.saveTo != "" {
+		defaultMax = 0
+	}
+	var err error
+	if opts.maxBytes, err = intInput(input, "max_response_bytes", defaultMax); err != nil {
+		return nil, err
+	}
+
+	if raw, exists := input["extract"]; exists && raw != nil {
+		rules, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("extract must be a map of output names to JSONPath expressions")
+		}
+		opts.extract = make(map[string]string, len(rules))
+		for name, path := range rules {
+			expr, ok := path.(string)
+			if !ok {
+				return nil, fmt.Errorf("extract.%s must be a JSONPath expression", name)
+			}
+			if builtinOutputs[name] {
+				return nil, fmt.Errorf("extract.%s would replace a built-in output", name)
+			}
+			opts.extract[name] = expr
+		}
+	}
+
+	if raw, exists := input["expect"]; exists && raw != nil {
+		block, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expect must be a map")
+		}
+		if opts.expect, err = parseExpectation(block); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.saveTo != "" && (opts.extract != nil || (opts.expect != nil && (opts.expect.bodyContains != nil || opts.expect.json != nil))) {
+		return nil, fmt.Errorf("extract and body expectations cannot be used with save_to")
+	}
+	return opts, nil
+}
+
+// parseExpectation reads an expect block: status, body_contains, json and headers
+func parseExpectation(block map[string]interface{}) (*expectation, error) {
+	exp := &expectation{}
+
+	if _, exists := block["status"]; exists {
+		matcher, err := statusMatcher(map[string]interface{}{"success_status": block["status"]})
+		if err != nil {
+			return nil, fmt.Errorf("expect.status: %w", err)
+		}
+		exp.status = matcher
+	}
+
+	if raw, exists := block["body_contains"]; exists {
+		for _, value := range formValues(raw) {
+			exp.bodyContains = append(exp.bodyContains, value)
+		}
+	}
+
+	if raw, exists := block["json"]; exists {
+		rules, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expect.json must be a map of JSONPath expressions to values")
+		}
+		exp.json = rules
+	}
+
+	if raw, exists := block["headers"]; exists {
+		rules, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expect.headers must be a map")
+		}
+		exp.headers = make(map[string]string, len(rules))
+		for name, value := range rules {
+			exp.headers[name] = fmt.Sprintf("%v", value)
+		}
+	}
+
+	return exp, nil
+}
+
+// check returns an error describing the first mismatch
+func (e *expectation) check(resp *http.Response, body interface{}, raw []byte) error {
+	if e.status != nil && !e.status(resp.StatusCode) {
+		return fmt.Errorf("expected status to match, got %d", resp.StatusCode)
+	}
+
+	for _, want := range e.bodyContains {
+		if !strings.Contains(string(raw), want) {
+			return fmt.Errorf("expected body to contain %q", want)
+		}
+	}
+
+	for _, path := range sortedKeys(e.json) {
+		got, found, err := persistence.JSONPath(body, path)
+		if err != nil {
+			return fmt.Errorf("expect.json %s: %w", path, err)
+		}
+		if !found {
+			return fmt.Errorf("expected %s in the response body", path)
+		}
+		if !jsonEqual(got, e.json[path]) {
+			return fmt.Errorf("expected %s to be %v, got %v", path, e.json[path], got)
+		}
+	}
+
+	for name, want := range e.headers {
+		if got := resp.Header.Get(name); !strings.Contains(got, want) {
+			return fmt.Errorf("expected header %s to contain %q, got %q", name, want, got)
+		}
+	}
+
+	return nil
+}
+
+// jsonEqual compares values after normalising them through JSON, so a YAML
+// integer matches a decoded JSON number
+func jsonEqual(a, b interface{}) bool {
+	normalize := func(v interface{}) interface{} {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return v
+		}
+		var out interface{}
+		if err := json.Unmarshal(data, &out); err != nil {
+			return v
+		}
+		return out
+	}
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
+
+// readBody reads a response body, failing when it exceeds max bytes
+func readBody(r io.Reader, max int64) ([]byte, error) {
+	if max <= 0 {
+		return io.ReadAll(r)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > max {
+		return nil, fmt.Errorf("response body exceeds max_response_bytes (%d)", max)
+	}
+	return data, nil
+}
+
+// saveBody streams a response body to a file, replacing it only once the
+// download is complete. It returns the size and SHA-256 of the content.
+func saveBody(r io.Reader, path string, max int64) (int64, string, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, "", fmt.Errorf("failed to create directory for save_to: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to create save_to file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	reader := r
+	if max > 0 {
+		reader = io.LimitReader(r, max+1)
+	}
+	written, err := io.Copy(io.MultiWriter(tmp, hash), reader)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return written, "", fmt.Errorf("failed to save response body: %w", err)
+	}
+	if max > 0 && written > max {
+		return written, "", fmt.Errorf("response body exceeds max_response_bytes (%d)", max)
+	}
+
+	// CreateTemp makes owner-only files; downloads get the usual mode
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return written, "", fmt.Errorf("failed to save response body: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return written, "", fmt.Errorf("failed to save response body: %w", err)
+	}
+	return written, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// extractOutputs evaluates extract rules against the decoded body
+func extractOutputs(rules map[string]string, body interface{}) (map[string]interface{}, error) {
+	outputs := make(map[string]interface{}, len(rules))
+	for name, path := range rules {
+		value, _, err := persistence.JSONPath(body, path)
+		if err != nil {
+			return nil, fmt.Errorf("extract.%s: %w", name, err)
+		}
+		outputs[name] = value
+	}
+	return outputs, nil
+}
@@ -0,0 +1,163 @@
+package actions
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func newTestServer(t *testing.T, status int, body string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHTTPExpectedStatusOverridesSuccess(t *testing.T) {
+	registry, _ := newTestFiles(t)
+	server := newTestServer(t, http.StatusNotFound, `{"error":"missing"}`)
+
+	tests := []struct {
+		name    string
+		expect  map[string]interface{}
+		success bool
+		err     string
+	}{
+		{name: "no expectation", success: false, err: "failed with status 404"},
+		{name: "expected status", expect: map[string]interface{}{"status": 404}, success: true},
+		{name: "expected status and body", expect: map[string]interface{}{"status": 404, "body_contains": "missing"}, success: true},
+		{name: "other status expected", expect: map[string]interface{}{"status": 200}, success: false, err: "expectation failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := map[string]interface{}{"url": server.URL}
+			if tt.expect != nil {
+				input["expect"] = tt.expect
+			}
+			result, err := runFileAction(t, registry, "http.request", input)
+			if tt.err == "" && err != nil {
+				t.Fatalf("err = %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("err = %v, want %q", err, tt.err)
+			}
+			if result["success"] != tt.success {
+				t.Errorf("success = %v, want %v", result["success"], tt.success)
+			}
+		})
+	}
+}
+
+func TestHTTPSaveToUsesFilePolicy(t *testing.T) {
+	registry, root := newTestFiles(t)
+	server := newTestServer(t, http.StatusOK, "payload")
+
+	result, err := runFileAction(t, registry, "http.request", map[string]interface{}{
+		"url":     server.URL,
+		"save_to": "downloads/file.bin",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := filepath.Join(root, "downloads", "file.bin")
+	if result["saved_to"] != saved {
+		t.Errorf("saved_to = %v, want %s", result["saved_to"], saved)
+	}
+	if data, err := os.ReadFile(saved); err != nil || string(data) != "payload" {
+		t.Errorf("saved file = %q, %v", data, err)
+	}
+
+	outside := filepath.Join(t.TempDir(), "file.bin")
+	_, err = runFileAction(t, registry, "http.request", map[string]interface{}{
+		"url":     server.URL,
+		"save_to": outside,
+	})
+	if err == nil || !strings.Contains(err.Error(), "outside the roots") {
+		t.Fatalf("err = %v, want file policy error", err)
+	}
+	if _, err := os.Stat(outside); !os.IsNotExist(err) {
+		t.Error("download written outside the file policy")
+	}
+}
+
+func TestHTTPFilesUseFilePolicy(t *testing.T) {
+	registry, root := newTestFiles(t)
+	var uploaded string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, _, err := r.FormFile("report")
+		if err == nil {
+			data, _ := io.ReadAll(file)
+			uploaded = string(data)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	if err := os.WriteFile(filepath.Join(root, "report.csv"), []byte("id\n1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	outside := filepath.Join(t.TempDir(), "client.pem")
+	if err := os.WriteFile(outside, []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := runFileAction(t, registry, "http.request", map[string]interface{}{
+		"url":    server.URL,
+		"method": "POST",
+		"files":  map[string]interface{}{"report": "report.csv"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if uploaded != "id\n1\n" {
+		t.Errorf("uploaded = %q", uploaded)
+	}
+
+	tests := []struct {
+		name  string
+		input map[string]interface{}
+		err   string
+	}{
+		{name: "upload", input: map[string]interface{}{"files": map[string]interface{}{"report": outside}}, err: "files.report"},
+		{name: "ca file", input: map[string]interface{}{"tls": map[string]interface{}{"ca_file": outside}}, err: "tls.ca_file"},
+		{name: "client certificate", input: map[string]interface{}{"tls": map[string]interface{}{"cert_file": outside, "key_file": "key.pem"}}, err: "tls.cert_file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.input["url"] = server.URL
+			_, err := runFileAction(t, registry, "http.request", tt.input)
+			if err == nil || !strings.Contains(err.Error(), tt.err) || !strings.Contains(err.Error(), "outside the roots") {
+				t.Fatalf("err = %v, want %s outside the file policy", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestHTTPTransportCacheIsBounded(t *testing.T) {
+	registry, _ := newTestFiles(t)
+	action, _ := registry.GetAction("http.request")
+	h := action.(*HTTPAction)
+
+	for i := 0; i < maxCachedTransports*2; i++ {
+		if _, err := h.transport(false, tlsOptions{ServerName: strconv.Itoa(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(h.transports) > maxCachedTransports {
+		t.Errorf("cached transports = %d, want at most %d", len(h.transports), maxCachedTransports)
+	}
+
+	// The most recent settings stay cached
+	last := tlsOptions{ServerName: strconv.Itoa(maxCachedTransports*2 - 1)}
+	first, _ := h.transport(false, last)
+	again, _ := h.transport(false, last)
+	if first != again {
+		t.Error("transport for the same settings was not reused")
+	}
+}
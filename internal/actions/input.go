@@ -0,0 +1,123 @@
+package actions
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QuotingAction is implemented by actions whose string inputs are parsed as
+// command lines. Template values in the returned fields are shell-quoted so
+// payload data cannot add arguments or shell syntax.
+type QuotingAction interface {
+	QuotedFields(config map[string]interface{}) []string
+}
+
+// TypedAction is implemented by actions that take structured values. In
+// the returned fields, and the lists and objects within them, a string that
+// is a single template reference such as "{{ .steps.fetch.output.items }}"
+// receives the referenced value itself rather than its string form.
+type TypedAction interface {
+	TypedFields(config map[string]interface{}) []string
+}
+
+// InstanceFinisher is implemented by actions holding state for a workflow
+// instance across steps. FinishInstance is called when the instance stops
+// running, whether it completed, failed or paused.
+type InstanceFinisher interface {
+	FinishInstance(instanceID string)
+}
+
+// durationInput reads a duration given as seconds (number or numeric string)
+// or as a Go duration string such as "1m30s"
+func durationInput(input map[string]interface{}, key string, defaultValue time.Duration) (time.Duration, error) {
+	value, exists := input[key]
+	if !exists || value == nil {
+		return defaultValue, nil
+	}
+
+	switch v := value.(type) {
+	case int:
+		return time.Duration(v) * time.Second, nil
+	case int64:
+		return time.Duration(v) * time.Second, nil
+	case float64:
+		return time.Duration(v * float64(time.Second)), nil
+	case string:
+		v = strings.TrimSpace(v)
+		if seconds, err := strconv.ParseFloat(v, 64); err == nil {
+			return time.Duration(seconds * float64(time.Second)), nil
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, fmt.Errorf("%s must be a number of seconds or a duration: %w", key, err)
+		}
+		return d, nil
+	}
+	return 0, fmt.Errorf("%s must be a number of seconds or a duration", key)
+}
+
+// intInput reads an integer that may have been decoded from YAML, JSON or a template
+func intInput(input map[string]interface{}, key string, defaultValue int64) (int64, error) {
+	value, exists := input[key]
+	if !exists || value == nil {
+		return defaultValue, nil
+	}
+
+	switch v := value.(type) {
+	case int:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case float64:
+		return int64(v), nil
+	case string:
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%s must be an integer", key)
+		}
+		return n, nil
+	}
+	return 0, fmt.Errorf("%s must be an integer", key)
+}
+
+// boolInput reads a boolean that may have been rendered by a template
+func boolInput(input map[string]interface{}, key string, defaultValue bool) bool {
+	switch v := input[key].(type) {
+	case bool:
+		return v
+	case string:
+		if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
+
+// limitedBuffer keeps the first max bytes written and counts the rest
+type limitedBuffer struct {
+	buf       []byte
+	max       int64
+	total     int64
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.total += int64(len(p))
+	if remaining := b.max - int64(len(b.buf)); remaining > 0 {
+		if int64(len(p)) > remaining {
+			b.buf = append(b.buf, p[:remaining]...)
+			b.truncated = true
+		} else {
+			b.buf = append(b.buf, p...)
+		}
+	} else if len(p) > 0 {
+		b.truncated = true
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	return string(b.buf)
+}
@@ -0,0 +1,700 @@
+package actions
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultParseLimit caps the rows a parse step returns unless the step sets limit
+	defaultParseLimit = 10000
+	// maxJSONLineBytes caps one line of JSON Lines input
+	maxJSONLineBytes = 10 << 20
+)
+
+// ParseAction reads records from CSV, JSON Lines or XML, given as a file
+// path or as content such as an earlier step's output. Records are read
+// one at a time and reading stops at the row limit, so a large file is
+// never loaded whole. It is registered as csv.parse, jsonl.parse and
+// xml.parse.
+type ParseAction struct {
+	logger *zap.Logger
+	files  *fileSystem
+	format string
+}
+
+// NewParseAction creates the parse action for a format, reading paths under the registry's file policy
+func NewParseAction(logger *zap.Logger, registry *Registry, format string) *ParseAction {
+	return &ParseAction{logger: logger, files: registry.files, format: format}
+}
+
+// rowCollector gathers parsed rows up to a limit
+type rowCollector struct {
+	rows      []interface{}
+	limit     int64
+	truncated bool
+}
+
+// add keeps a row, returning false once the limit is reached
+func (c *rowCollector) add(row interface{}) bool {
+	if int64(len(c.rows)) >= c.limit {
+		c.truncated = true
+		return false
+	}
+	c.rows = append(c.rows, row)
+	return true
+}
+
+// Execute parses the source
+func (p *ParseAction) Execute(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+	limit, err := intInput(input, "limit", defaultParseLimit)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		return nil, fmt.Errorf("limit must be positive")
+	}
+	batchSize, err := intInput(input, "batch_size", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	source, name, err := p.open(input)
+	if err != nil {
+		return nil, err
+	}
+	defer source.Close()
+
+	collector := &rowCollector{rows: make([]interface{}, 0), limit: limit}
+	output := make(map[string]interface{})
+	switch p.format {
+	case "csv":
+		err = parseCSV(ctx, source, input, collector, output)
+	case "jsonl":
+		err = parseJSONLines(ctx, source, input, collector)
+	case "xml":
+		err = parseXML(ctx, source, input, collector, output)
+	default:
+		err = fmt.Errorf("unknown format %s", p.format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+
+	if _, whole := output["data"]; !whole {
+		output["rows"] = collector.rows
+		output["count"] = len(collector.rows)
+		output["truncated"] = collector.truncated
+		if batchSize > 0 {
+			output["batches"] = batchRows(collector.rows, int(batchSize))
+		}
+	}
+	return output, nil
+}
+
+// open returns the file at path or the content to parse, and a name for errors
+func (p *ParseAction) open(input map[string]interface{}) (io.ReadCloser, string, error) {
+	if path, _ := input["path"].(string); path != "" {
+		real, err := p.files.resolve(path, false)
+		if err != nil {
+			return nil, "", err
+		}
+		file, err := os.Open(real)
+		if err != nil {
+			return nil, "", err
+		}
+		return file, path, nil
+	}
+	content, exists := input["content"]
+	if !exists {
+		return nil, "", fmt.Errorf("path or content parameter is required")
+	}
+	str, ok := content.(string)
+	if !ok {
+		return nil, "", fmt.Errorf("content must be a string")
+	}
+	return io.NopCloser(strings.NewReader(str)), "content", nil
+}
+
+// batchRows splits rows into consecutive batches of at most size rows
+func batchRows(rows []interface{}, size int) []interface{} {
+	batches := make([]interface{}, 0, (len(rows)+size-1)/size)
+	for start := 0; start < len(rows); start += size {
+		end := start + size
+		if end > len(rows) {
+			end = len(rows)
+		}
+		batches = append(batches, rows[start:end])
+	}
+	return batches
+}
+
+// inferValue converts text to the number, bool or null it spells, and
+// leaves anything else, including numbers with leading zeros, as text
+func inferValue(text string) interface{} {
+	trimmed := strings.TrimSpace(text)
+	switch strings.ToLower(trimmed) {
+	case "":
+		return nil
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if !looksNumeric(trimmed) {
+		return text
+	}
+	digits := strings.TrimLeft(trimmed, "+-")
+	if len(digits) > 1 && digits[0] == '0' && digits[1] != '.' {
+		return text
+	}
+	if n, err := strconv.ParseInt(trimmed, 10, 64); err == nil {
+		return n
+	}
+	if strings.ContainsAny(trimmed, ".eE") {
+		if f, err := strconv.ParseFloat(trimmed, 64); err == nil {
+			return f
+		}
+	}
+	return text
+}
+
+// looksNumeric reports whether text has only the characters of a decimal number
+func looksNumeric(text string) bool {
+	hasDigit := false
+	for _, r := range text {
+		switch {
+		case r >= '0' && r <= '9':
+			hasDigit = true
+		case r == '.' || r == '-' || r == '+' || r == 'e' || r == 'E':
+		default:
+			return false
+		}
+	}
+	return hasDigit
+}
+
+// columnTypes reads the types option: column name to a type coerce accepts
+func columnTypes(input map[string]interface{}) (map[string]string, error) {
+	types := make(map[string]string)
+	raw, exists := input["types"]
+	if !exists {
+		return types, nil
+	}
+	specified, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("types must map column names to types")
+	}
+	for column, kind := range specified {
+		str, ok := kind.(string)
+		if !ok {
+			return nil, fmt.Errorf("type of %s must be a string", column)
+		}
+		types[column] = str
+	}
+	return types, nil
+}
+
+// typedValue converts a text value by its column's type, or by inference
+func typedValue(text string, column string, types map[string]string, infer bool) (interface{}, error) {
+	if kind, ok := types[column]; ok {
+		if strings.TrimSpace(text) == "" && kind != "string" {
+			return nil, nil
+		}
+		value, err := coerce(text, kind)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", column, err)
+		}
+		return value, nil
+	}
+	if infer {
+		return inferValue(text), nil
+	}
+	return text, nil
+}
+
+// columnMapping reads the mapping option, renaming source columns or
+// fields. A column mapped to an empty name is dropped.
+func columnMapping(input map[string]interface{}) (map[string]string, error) {
+	mapping := make(map[string]string)
+	raw, exists := input["mapping"]
+	if !exists {
+		return mapping, nil
+	}
+	specified, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("mapping must map source names to output names")
+	}
+	for from, to := range specified {
+		name, ok := to.(string)
+		if !ok && to != nil {
+			return nil, fmt.Errorf("mapping of %s must be a name", from)
+		}
+		mapping[from] = name
+	}
+	return mapping, nil
+}
+
+// parseCSV reads CSV records. With a header row, or with columns given,
+// each row is an object; otherwise each row is a list of values.
+func parseCSV(ctx context.Context, source io.Reader, input map[string]interface{}, collector *rowCollector, output map[string]interface{}) error {
+	reader := csv.NewReader(bufio.NewReader(source))
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	reader.TrimLeadingSpace = boolInput(input, "trim_space", false)
+	if delimiter, _ := input["delimiter"].(string); delimiter != "" {
+		r, size := utf8.DecodeRuneInString(delimiter)
+		if size != len(delimiter) {
+			return fmt.Errorf("delimiter must be a single character")
+		}
+		reader.Comma = r
+	}
+	if comment, _ := input["comment"].(string); comment != "" {
+		r, _ := utf8.DecodeRuneInString(comment)
+		reader.Comment = r
+	}
+
+	infer := boolInput(input, "infer_types", true)
+	types, err := columnTypes(input)
+	if err != nil {
+		return err
+	}
+	mapping, err := columnMapping(input)
+	if err != nil {
+		return err
+	}
+
+	var columns []string
+	if given, ok := input["columns"].([]interface{}); ok {
+		for _, column := range given {
+			columns = append(columns, fmt.Sprint(column))
+		}
+	}
+	header := boolInput(input, "header", true)
+
+	for line := 1; ; line++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if header && line == 1 {
+			// Given columns replace the header row's names
+			if columns == nil {
+				columns = append([]string(nil), record...)
+				if len(columns) > 0 {
+					columns[0] = strings.TrimPrefix(columns[0], "\ufeff")
+				}
+			}
+			continue
+		}
+
+		if columns == nil {
+			values := make([]interface{}, len(record))
+			for i, text := range record {
+				if values[i], err = typedValue(text, strconv.Itoa(i), types, infer); err != nil {
+					return fmt.Errorf("line %d: %w", line, err)
+				}
+			}
+			if !collector.add(values) {
+				break
+			}
+			continue
+		}
+
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			name := column
+			if renamed, ok := mapping[column]; ok {
+				if renamed == "" {
+					continue
+				}
+				name = renamed
+			}
+			if i >= len(record) {
+				row[name] = nil
+				continue
+			}
+			if row[name], err = typedValue(record[i], column, types, infer); err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+		if !collector.add(row) {
+			break
+		}
+	}
+
+	if columns != nil {
+		names := make([]string, 0, len(columns))
+		for _, column := range columns {
+			name := column
+			if renamed, ok := mapping[column]; ok {
+				if renamed == "" {
+					continue
+				}
+				name = renamed
+			}
+			names = append(names, name)
+		}
+		output["columns"] = names
+	}
+	return nil
+}
+
+// parseJSONLines reads one JSON value per line, skipping blank lines. With
+// skip_invalid, lines that are not valid JSON are counted and skipped.
+func parseJSONLines(ctx context.Context, source io.Reader, input map[string]interface{}, collector *rowCollector) error {
+	mapping, err := columnMapping(input)
+	if err != nil {
+		return err
+	}
+	skipInvalid := boolInput(input, "skip_invalid", false)
+
+	scanner := bufio.NewScanner(source)
+	scanner.Buffer(make([]byte, 64<<10), maxJSONLineBytes)
+	line := 0
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var value interface{}
+		if err := json.Unmarshal(text, &value); err != nil {
+			if skipInvalid {
+				continue
+			}
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if object, ok := value.(map[string]interface{}); ok && len(mapping) > 0 {
+			value = renameFields(object, mapping)
+		}
+		if !collector.add(value) {
+			break
+		}
+	}
+	if errors.Is(scanner.Err(), bufio.ErrTooLong) {
+		return fmt.Errorf("line %d is longer than %d bytes", line+1, maxJSONLineBytes)
+	}
+	return scanner.Err()
+}
+
+// renameFields applies a mapping to an object's keys
+func renameFields(object map[string]interface{}, mapping map[string]string) map[string]interface{} {
+	renamed := make(map[string]interface{}, len(object))
+	for key, value := range object {
+		if name, ok := mapping[key]; ok {
+			if name == "" {
+				continue
+			}
+			key = name
+		}
+		renamed[key] = value
+	}
+	return renamed
+}
+
+// parseXML converts XML to JSON values. Attributes become keys prefixed
+// with @, and text inside elements that also have attributes or children
+// becomes #text. With items, each element at that slash separated path
+// is a row, read one at a time; otherwise the whole document is data.
+func parseXML(ctx context.Context, source io.Reader, input map[string]interface{}, collector *rowCollector, output map[string]interface{}) error {
+	infer := boolInput(input, "infer_types", true)
+	mapping, err := columnMapping(input)
+	if err != nil {
+		return err
+	}
+	items, _ := input["items"].(string)
+	var path []string
+	if items = strings.Trim(items, "/"); items != "" {
+		path = strings.Split(items, "/")
+	}
+
+	decoder := xml.NewDecoder(source)
+	decoder.Strict = boolInput(input, "strict", true)
+	var stack []string
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			stack = append(stack, t.Name.Local)
+			if path != nil && !matchesTail(stack, path) {
+				continue
+			}
+			value, err := readElement(decoder, t, infer)
+			if err != nil {
+				return err
+			}
+			stack = stack[:len(stack)-1]
+			if path == nil {
+				output["data"] = map[string]interface{}{t.Name.Local: value}
+				return nil
+			}
+			if object, ok := value.(map[string]interface{}); ok && len(mapping) > 0 {
+				value = renameFields(object, mapping)
+			}
+			if !collector.add(value) {
+				return nil
+			}
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	if path == nil {
+		return fmt.Errorf("no root element")
+	}
+	return nil
+}
+
+// matchesTail reports whether the innermost elements of stack are path
+func matchesTail(stack, path []string) bool {
+	if len(stack) < len(path) {
+		return false
+	}
+	tail := stack[len(stack)-len(path):]
+	for i := range path {
+		if tail[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// readElement reads the rest of an element after its start token. An
+// element with only text is its value; repeated children become lists.
+func readElement(decoder *xml.Decoder, start xml.StartElement, infer bool) (interface{}, error) {
+	object := make(map[string]interface{})
+	for _, attr := range start.Attr {
+		object["@"+attr.Name.Local] = xmlValue(attr.Value, infer)
+	}
+
+	repeated := make(map[string]bool)
+	var text strings.Builder
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("element %s is not closed", start.Name.Local)
+			}
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			child, err := readElement(decoder, t, infer)
+			if err != nil {
+				return nil, err
+			}
+			name := t.Name.Local
+			if existing, seen := object[name]; !seen {
+				object[name] = child
+			} else if list, ok := existing.([]interface{}); ok && repeated[name] {
+				object[name] = append(list, child)
+			} else {
+				object[name] = []interface{}{existing, child}
+				repeated[name] = true
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			content := strings.TrimSpace(text.String())
+			if len(object) == 0 {
+				if content == "" {
+					return nil, nil
+				}
+				return xmlValue(content, infer), nil
+			}
+			if content != "" {
+				object["#text"] = xmlValue(content, infer)
+			}
+			return object, nil
+		}
+	}
+}
+
+// xmlValue converts element text or an attribute value
+func xmlValue(text string, infer bool) interface{} {
+	if infer {
+		if value := inferValue(text); value != nil {
+			return value
+		}
+	}
+	return text
+}
+
+// CSVWriteAction writes rows as CSV, to a file under the file policy or
+// as the step output's content
+type CSVWriteAction struct {
+	logger *zap.Logger
+	files  *fileSystem
+}
+
+// NewCSVWriteAction creates the csv.write action, writing paths under the registry's file policy
+func NewCSVWriteAction(logger *zap.Logger, registry *Registry) *CSVWriteAction {
+	return &CSVWriteAction{logger: logger, files: registry.files}
+}
+
+// TypedFields keeps the type of rows given as a single template reference
+func (c *CSVWriteAction) TypedFields(config map[string]interface{}) []string {
+	return []string{"rows"}
+}
+
+// Execute writes the rows. Rows are objects, written in the order of
+// columns (by default the sorted keys of all rows), or lists of values.
+func (c *CSVWriteAction) Execute(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+	rows, ok := input["rows"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("rows parameter must be a list")
+	}
+
+	var columns []string
+	if given, ok := input["columns"].([]interface{}); ok {
+		for _, column := range given {
+			columns = append(columns, fmt.Sprint(column))
+		}
+	} else {
+		keys := make(map[string]interface{})
+		for _, row := range rows {
+			if object, ok := row.(map[string]interface{}); ok {
+				for key := range object {
+					keys[key] = true
+				}
+			}
+		}
+		columns = sortedKeys(keys)
+	}
+
+	path, _ := input["path"].(string)
+	var real string
+	appending := boolInput(input, "append", false)
+	header := boolInput(input, "header", true)
+	if path != "" {
+		var err error
+		if real, err = c.files.resolve(path, true); err != nil {
+			return nil, err
+		}
+		// Appending to rows already written adds no second header
+		if info, err := os.Stat(real); err == nil && appending && info.Size() > 0 {
+			header = false
+		}
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if delimiter, _ := input["delimiter"].(string); delimiter != "" {
+		r, size := utf8.DecodeRuneInString(delimiter)
+		if size != len(delimiter) {
+			return nil, fmt.Errorf("delimiter must be a single character")
+		}
+		writer.Comma = r
+	}
+	if header && len(columns) > 0 {
+		writer.Write(columns)
+	}
+
+	for i, row := range rows {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var record []string
+		switch r := row.(type) {
+		case map[string]interface{}:
+			record = make([]string, len(columns))
+			for j, column := range columns {
+				record[j] = csvField(r[column])
+			}
+		case []interface{}:
+			record = make([]string, len(r))
+			for j, value := range r {
+				record[j] = csvField(value)
+			}
+		default:
+			return nil, fmt.Errorf("rows[%d] must be an object or a list", i)
+		}
+		if err := writer.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, err
+	}
+
+	output := map[string]interface{}{
+		"count":   len(rows),
+		"columns": columns,
+	}
+	if path == "" {
+		output["content"] = buf.String()
+		return output, nil
+	}
+
+	if appending {
+		file, err := os.OpenFile(real, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		_, err = file.Write(buf.Bytes())
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, err
+		}
+	} else if err := writeFileAtomic(real, &buf, 0o644); err != nil {
+		return nil, err
+	}
+
+	c.logger.Info("CSV written", zap.String("path", real), zap.Int("rows", len(rows)))
+	output["path"] = real
+	return output, nil
+}
+
+// csvField formats a value as a CSV field; objects and lists become JSON
+func csvField(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+	return fmt.Sprint(value)
+}
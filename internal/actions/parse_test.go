@@ -0,0 +1,99 @@
+package actions
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseActions(t *testing.T) {
+	tests := []struct {
+		name   string
+		action string
+		input  map[string]interface{}
+		rows   string
+		check  func(t *testing.T, output map[string]interface{})
+	}{
+		{
+			name:   "csv typed values",
+			action: "csv.parse",
+			input:  map[string]interface{}{"content": "id,zip,paid,note\n1,02134,true,\n2,10001,false,rush\n"},
+			rows:   `[{"id":1,"note":null,"paid":true,"zip":"02134"},{"id":2,"note":"rush","paid":false,"zip":10001}]`,
+		},
+		{
+			name:   "csv mapping and types",
+			action: "csv.parse",
+			input: map[string]interface{}{
+				"content":   "Order ID;Zip;Internal\n7;10001;x\n",
+				"delimiter": ";",
+				"mapping":   map[string]interface{}{"Order ID": "id", "Internal": ""},
+				"types":     map[string]interface{}{"Zip": "string"},
+			},
+			rows: `[{"Zip":"10001","id":7}]`,
+		},
+		{
+			name:   "csv limit",
+			action: "csv.parse",
+			input:  map[string]interface{}{"content": "n\n1\n2\n3\n", "limit": 2},
+			rows:   `[{"n":1},{"n":2}]`,
+			check: func(t *testing.T, output map[string]interface{}) {
+				if output["truncated"] != true {
+					t.Errorf("truncated = %v", output["truncated"])
+				}
+			},
+		},
+		{
+			name:   "jsonl skip invalid",
+			action: "jsonl.parse",
+			input:  map[string]interface{}{"content": "{\"a\":1}\nnot json\n\n{\"a\":2}\n", "skip_invalid": true},
+			rows:   `[{"a":1},{"a":2}]`,
+		},
+		{
+			name:   "xml items",
+			action: "xml.parse",
+			input:  map[string]interface{}{"content": `<orders><order id="1"><sku>A</sku></order><order id="2"><sku>B</sku><sku>C</sku></order></orders>`, "items": "orders/order"},
+			rows:   `[{"@id":1,"sku":"A"},{"@id":2,"sku":["B","C"]}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry, _ := newTestFiles(t)
+			output, err := runFileAction(t, registry, tt.action, tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows, err := json.Marshal(output["rows"])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(rows) != tt.rows {
+				t.Errorf("rows = %s, want %s", rows, tt.rows)
+			}
+			if tt.check != nil {
+				tt.check(t, output)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		action string
+		input  map[string]interface{}
+		err    string
+	}{
+		{name: "invalid jsonl", action: "jsonl.parse", input: map[string]interface{}{"content": "{\"a\":1}\nnot json\n"}, err: "line 2"},
+		{name: "path outside roots", action: "csv.parse", input: map[string]interface{}{"path": "/etc/passwd"}, err: "outside the roots"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry, _ := newTestFiles(t)
+			_, err := runFileAction(t, registry, tt.action, tt.input)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("err = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package actions
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultPath is used when the environment is cleared and PATH is not passed through
+const defaultPath = "/usr/local/bin:/usr/bin:/bin"
+
+// ShellPolicy restricts what shell.exec steps may run. A nil policy allows
+// everything.
+type ShellPolicy struct {
+	// AllowedCommands lists binaries by name ("git") or absolute path
+	// ("/usr/bin/git"). Shell mode requires the shell itself to be listed.
+	AllowedCommands []string `json:"allowed_commands,omitempty"`
+	// AllowedDirs lists roots that working_dir must be inside. Steps without
+	// a working_dir run in the first root.
+	AllowedDirs []string `json:"allowed_dirs,omitempty"`
+
+	// ClearEnv starts commands with an empty environment instead of the daemon's
+	ClearEnv bool `json:"clear_env,omitempty"`
+	// EnvPassthrough lists daemon variables kept when ClearEnv is set; a
+	// trailing * matches a prefix
+	EnvPassthrough []string `json:"env_passthrough,omitempty"`
+	// AllowedEnv lists variables steps may set through env; empty allows any
+	AllowedEnv []string `json:"allowed_env,omitempty"`
+
+	// RunAs runs commands as another user, given as "user" or "uid:gid"
+	RunAs  string       `json:"run_as,omitempty"`
+	Limits *ShellLimits `json:"limits,omitempty"`
+
+	// Workflows overrides settings for individual workflows by name
+	Workflows map[string]*ShellPolicy `json:"workflows,omitempty"`
+}
+
+// ShellLimits are resource limits applied to each command
+type ShellLimits struct {
+	CPUSeconds  uint64 `json:"cpu_seconds,omitempty"`
+	MemoryBytes uint64 `json:"memory_bytes,omitempty"`
+	OpenFiles   uint64 `json:"open_files,omitempty"`
+}
+
+// ForWorkflow returns the policy for a workflow with its overrides applied
+func (p *ShellPolicy) ForWorkflow(name string) *ShellPolicy {
+	if p == nil {
+		return nil
+	}
+
+	merged := *p
+	merged.Workflows = nil
+
+	override, exists := p.Workflows[name]
+	if !exists || override == nil {
+		return &merged
+	}
+
+	if override.AllowedCommands != nil {
+		merged.AllowedCommands = override.AllowedCommands
+	}
+	if override.AllowedDirs != nil {
+		merged.AllowedDirs = override.AllowedDirs
+	}
+	if override.ClearEnv {
+		merged.ClearEnv = true
+	}
+	if override.EnvPassthrough != nil {
+		merged.EnvPassthrough = override.EnvPassthrough
+	}
+	if override.AllowedEnv != nil {
+		merged.AllowedEnv = override.AllowedEnv
+	}
+	if override.RunAs != "" {
+		merged.RunAs = override.RunAs
+	}
+	if override.Limits != nil {
+		merged.Limits = override.Limits
+	}
+	return &merged
+}
+
+// CheckCommand reports whether a binary may be executed
+func (p *ShellPolicy) CheckCommand(binary string) error {
+	if p == nil || len(p.AllowedCommands) == 0 {
+		return nil
+	}
+
+	for _, allowed := range p.AllowedCommands {
+		if filepath.IsAbs(allowed) {
+			if filepath.IsAbs(binary) && filepath.Clean(binary) == filepath.Clean(allowed) {
+				return nil
+			}
+			// A bare name is allowed when it resolves to the listed path
+			if !strings.Contains(binary, "/") {
+				if resolved, err := lookPath(binary, p.path()); err == nil && resolved == filepath.Clean(allowed) {
+					return nil
+				}
+			}
+			continue
+		}
+		// Names only match bare names, so "./echo" cannot pass as "echo"
+		if binary == allowed {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("command %q is not allowed by the shell policy", binary)
+}
+
+// ResolveDir returns the working directory a command should run in
+func (p *ShellPolicy) ResolveDir(dir string) (string, error) {
+	if p == nil || len(p.AllowedDirs) == 0 {
+		return dir, nil
+	}
+	if dir == "" {
+		return p.AllowedDirs[0], nil
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+
+	for _, root := range p.AllowedDirs {
+		rootAbs, err := filepath.Abs(root)
+		if err != nil {
+			continue
+		}
+		if resolved, err := filepath.EvalSymlinks(rootAbs); err == nil {
+			rootAbs = resolved
+		}
+		if abs == rootAbs || strings.HasPrefix(abs, rootAbs+string(filepath.Separator)) {
+			return abs, nil
+		}
+	}
+
+	return "", fmt.Errorf("working directory %q is outside the directories allowed by the shell policy", dir)
+}
+
+// CheckEnv reports whether a step may set an environment variable
+func (p *ShellPolicy) CheckEnv(name string) error {
+	if p == nil || len(p.AllowedEnv) == 0 || matchesName(p.AllowedEnv, name) {
+		return nil
+	}
+	return fmt.Errorf("environment variable %q is not allowed by the shell policy", name)
+}
+
+// BaseEnv returns the environment commands start with
+func (p *ShellPolicy) BaseEnv() []string {
+	if p == nil || !p.ClearEnv {
+		return os.Environ()
+	}
+
+	env := make([]string, 0)
+	hasPath := false
+	for _, entry := range os.Environ() {
+		name := entry
+		if i := strings.Index(entry, "="); i >= 0 {
+			name = entry[:i]
+		}
+		if matchesName(p.EnvPassthrough, name) {
+			env = append(env, entry)
+			hasPath = hasPath || name == "PATH"
+		}
+	}
+	if !hasPath {
+		env = append(env, "PATH="+defaultPath)
+	}
+	return env
+}
+
+// path returns the PATH commands are resolved against
+func (p *ShellPolicy) path() string {
+	for _, entry := range p.BaseEnv() {
+		if strings.HasPrefix(entry, "PATH=") {
+			return strings.TrimPrefix(entry, "PATH=")
+		}
+	}
+	return ""
+}
+
+// CheckConfig statically checks a shell.exec step. Values containing
+// templates are checked at run time instead.
+func (p *ShellPolicy) CheckConfig(config map[string]interface{}) []error {
+	if p == nil {
+		return nil
+	}
+
+	var errs []error
+	static := func(value interface{}) (string, bool) {
+		s, ok := value.(string)
+		return s, ok && !strings.Contains(s, "{{")
+	}
+
+	if shell, ok := static(config["shell"]); ok && shell != "" {
+		if err := p.CheckCommand(shell); err != nil {
+			errs = append(errs, err)
+		}
+	} else if command, ok := static(config["command"]); ok {
+		binary := command
+		if _, hasArgs := config["args"]; !hasArgs {
+			words, err := splitCommand(command)
+			if err != nil {
+				errs = append(errs, err)
+				binary = ""
+			} else if len(words) > 0 {
+				binary = words[0]
+			}
+		}
+		if binary != "" {
+			if err := p.CheckCommand(binary); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	if dir, ok := static(config["working_dir"]); ok && dir != "" {
+		if _, err := p.ResolveDir(dir); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if env, ok := config["env"].(map[string]interface{}); ok {
+		for name := range env {
+			if err := p.CheckEnv(name); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errs
+}
+
+// matchesName matches a name against names and prefix* patterns
+func matchesName(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if strings.HasSuffix(pattern, "*") {
+			if strings.HasPrefix(name, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		} else if pattern == name {
+			return true
+		}
+	}
+	return false
+}
+
+// lookPath resolves a bare command name against a PATH value
+func lookPath(name, path string) (string, error) {
+	for _, dir := range filepath.SplitList(path) {
+		candidate := filepath.Join(dir, name)
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() && info.Mode()&0111 != 0 {
+			return filepath.Clean(candidate), nil
+		}
+	}
+	return "", fmt.Errorf("%s not found in PATH", name)
+}
@@ -0,0 +1,222 @@
+package actions
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestShellPolicyCheckCommand(t *testing.T) {
+	echo, err := lookPath("echo", os.Getenv("PATH"))
+	if err != nil {
+		t.Skip("echo not found in PATH")
+	}
+
+	tests := []struct {
+		name    string
+		policy  *ShellPolicy
+		binary  string
+		allowed bool
+	}{
+		{name: "nil policy", binary: "rm", allowed: true},
+		{name: "empty allowlist", policy: &ShellPolicy{}, binary: "rm", allowed: true},
+		{name: "listed name", policy: &ShellPolicy{AllowedCommands: []string{"echo"}}, binary: "echo", allowed: true},
+		{name: "unlisted name", policy: &ShellPolicy{AllowedCommands: []string{"echo"}}, binary: "rm"},
+		{name: "relative path does not match name", policy: &ShellPolicy{AllowedCommands: []string{"echo"}}, binary: "./echo"},
+		{name: "absolute path does not match name", policy: &ShellPolicy{AllowedCommands: []string{"echo"}}, binary: echo},
+		{name: "listed path", policy: &ShellPolicy{AllowedCommands: []string{echo}}, binary: echo, allowed: true},
+		{name: "bare name resolving to listed path", policy: &ShellPolicy{AllowedCommands: []string{echo}}, binary: "echo", allowed: true},
+		{name: "other path", policy: &ShellPolicy{AllowedCommands: []string{echo}}, binary: "/tmp/echo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.CheckCommand(tt.binary)
+			if tt.allowed && err != nil {
+				t.Fatalf("err = %v, want allowed", err)
+			}
+			if !tt.allowed && (err == nil || !strings.Contains(err.Error(), "not allowed")) {
+				t.Fatalf("err = %v, want not allowed", err)
+			}
+		})
+	}
+}
+
+func TestShellPolicyResolveDir(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	outside, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "work"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	policy := &ShellPolicy{AllowedDirs: []string{root}}
+
+	tests := []struct {
+		name string
+		dir  string
+		want string
+		err  bool
+	}{
+		{name: "default to first root", dir: "", want: root},
+		{name: "root", dir: root, want: root},
+		{name: "subdirectory", dir: filepath.Join(root, "work"), want: filepath.Join(root, "work")},
+		{name: "dot-dot escape", dir: filepath.Join(root, "work", "..", ".."), err: true},
+		{name: "symlink escape", dir: filepath.Join(root, "escape"), err: true},
+		{name: "sibling with root prefix", dir: root + "-other", err: true},
+		{name: "outside", dir: outside, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := policy.ResolveDir(tt.dir)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("ResolveDir(%q) = %q, want error", tt.dir, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ResolveDir(%q) = %q, want %q", tt.dir, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShellPolicyForWorkflow(t *testing.T) {
+	policy := &ShellPolicy{
+		AllowedCommands: []string{"echo"},
+		AllowedEnv:      []string{"APP_*"},
+		Limits:          &ShellLimits{CPUSeconds: 10},
+		Workflows: map[string]*ShellPolicy{
+			"deploy": {AllowedCommands: []string{"git"}, ClearEnv: true, Limits: &ShellLimits{CPUSeconds: 60}},
+		},
+	}
+
+	other := policy.ForWorkflow("report")
+	if strings.Join(other.AllowedCommands, ",") != "echo" || other.ClearEnv || other.Limits.CPUSeconds != 10 || other.Workflows != nil {
+		t.Errorf("report policy = %+v", other)
+	}
+
+	deploy := policy.ForWorkflow("deploy")
+	if strings.Join(deploy.AllowedCommands, ",") != "git" || !deploy.ClearEnv || deploy.Limits.CPUSeconds != 60 {
+		t.Errorf("deploy policy = %+v", deploy)
+	}
+	if strings.Join(deploy.AllowedEnv, ",") != "APP_*" {
+		t.Errorf("deploy allowed env = %v, want the base setting", deploy.AllowedEnv)
+	}
+
+	if (*ShellPolicy)(nil).ForWorkflow("deploy") != nil {
+		t.Error("nil policy should stay nil")
+	}
+}
+
+func TestShellPolicyCheckConfig(t *testing.T) {
+	policy := &ShellPolicy{
+		AllowedCommands: []string{"echo", "sh"},
+		AllowedDirs:     []string{t.TempDir()},
+		AllowedEnv:      []string{"APP_*"},
+	}
+
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+		errs   int
+	}{
+		{name: "allowed command", config: map[string]interface{}{"command": "echo hello"}},
+		{name: "denied command", config: map[string]interface{}{"command": "rm -rf /"}, errs: 1},
+		{name: "args name the binary", config: map[string]interface{}{"command": "rm", "args": []interface{}{"-rf"}}, errs: 1},
+		{name: "allowed shell", config: map[string]interface{}{"shell": "sh", "command": "rm -rf / | cat"}},
+		{name: "denied shell", config: map[string]interface{}{"shell": "bash", "command": "echo hi"}, errs: 1},
+		{name: "templated command checked at run time", config: map[string]interface{}{"command": "{{ .event.cmd }}"}},
+		{name: "denied directory", config: map[string]interface{}{"command": "echo", "working_dir": "/"}, errs: 1},
+		{name: "denied env", config: map[string]interface{}{"command": "echo", "env": map[string]interface{}{"APP_MODE": "x", "LD_PRELOAD": "x"}}, errs: 1},
+		{name: "several violations", config: map[string]interface{}{"command": "rm", "working_dir": "/", "env": map[string]interface{}{"PATH": "/tmp"}}, errs: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if errs := policy.CheckConfig(tt.config); len(errs) != tt.errs {
+				t.Errorf("errors = %v, want %d", errs, tt.errs)
+			}
+		})
+	}
+}
+
+func TestShellExecAppliesPolicy(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONDUKTR_TEST_SECRET", "hunter2")
+	t.Setenv("CONDUKTR_TEST_PASS", "kept")
+
+	shell := NewShellAction(zap.NewNop())
+	shell.SetPolicy(&ShellPolicy{
+		AllowedCommands: []string{"sh", "pwd"},
+		AllowedDirs:     []string{dir},
+		ClearEnv:        true,
+		EnvPassthrough:  []string{"PATH", "CONDUKTR_TEST_PASS"},
+		AllowedEnv:      []string{"APP_*"},
+		Workflows: map[string]*ShellPolicy{
+			"locked": {AllowedCommands: []string{"true"}},
+		},
+	})
+	ctx := WithStepInfo(context.Background(), StepInfo{Workflow: "build", Step: "run"})
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		input  map[string]interface{}
+		stdout string
+		err    string
+	}{
+		{name: "runs in first allowed dir", input: map[string]interface{}{"command": "pwd"}, stdout: dir + "\n"},
+		{
+			name:   "clears the environment",
+			input:  map[string]interface{}{"shell": "sh", "command": `echo "$CONDUKTR_TEST_SECRET|$CONDUKTR_TEST_PASS|$APP_MODE"`, "env": map[string]interface{}{"APP_MODE": "ci"}},
+			stdout: "|kept|ci\n",
+		},
+		{name: "denied command", input: map[string]interface{}{"command": "echo hi"}, err: "not allowed"},
+		{name: "denied env", input: map[string]interface{}{"command": "pwd", "env": map[string]interface{}{"LD_PRELOAD": "x"}}, err: "not allowed"},
+		{name: "denied directory", input: map[string]interface{}{"command": "pwd", "working_dir": "/"}, err: "outside the directories"},
+		{
+			name:  "workflow override",
+			ctx:   WithStepInfo(context.Background(), StepInfo{Workflow: "locked", Step: "run"}),
+			input: map[string]interface{}{"command": "pwd"},
+			err:   "not allowed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runCtx := ctx
+			if tt.ctx != nil {
+				runCtx = tt.ctx
+			}
+			result, err := shell.Execute(runCtx, tt.input)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result["stdout"] != tt.stdout {
+				t.Errorf("stdout = %q, want %q", result["stdout"], tt.stdout)
+			}
+		})
+	}
+}
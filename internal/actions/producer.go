@@ -0,0 +1,280 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// RedisConnection is a configured Redis connection steps publish through
+type RedisConnection interface {
+	// Publish sends a message to a channel and returns how many
+	// subscribers received it
+	Publish(ctx context.Context, channel string, message []byte) (int64, error)
+	// XAdd appends an entry to a stream, trimming it to about maxLen entries
+	// when maxLen is positive, and returns the entry ID
+	XAdd(ctx context.Context, stream string, values map[string]interface{}, maxLen int64) (string, error)
+}
+
+// KafkaConnection is a configured Kafka connection steps produce to
+type KafkaConnection interface {
+	Produce(ctx context.Context, topic string, key, value []byte, headers map[string]string) error
+}
+
+// connections holds the named connections of the producer actions
+type connections struct {
+	mu    sync.RWMutex
+	redis map[string]RedisConnection
+	kafka map[string]KafkaConnection
+}
+
+func newConnections() *connections {
+	return &connections{
+		redis: make(map[string]RedisConnection),
+		kafka: make(map[string]KafkaConnection),
+	}
+}
+
+// lookup returns the named connection, or the only one when no name is given
+func lookup[T any](kind string, available map[string]T, name string) (T, error) {
+	var zero T
+	if name != "" {
+		conn, ok := available[name]
+		if !ok {
+			return zero, fmt.Errorf("%s connection %s is not configured", kind, name)
+		}
+		return conn, nil
+	}
+
+	switch len(available) {
+	case 0:
+		return zero, fmt.Errorf("no %s connection is configured", kind)
+	case 1:
+		for _, conn := range available {
+			return conn, nil
+		}
+	}
+	names := make([]string, 0, len(available))
+	for name := range available {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return zero, fmt.Errorf("connection is required, one of: %s", strings.Join(names, ", "))
+}
+
+func (c *connections) redisConnection(name string) (RedisConnection, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return lookup("redis", c.redis, name)
+}
+
+func (c *connections) kafkaConnection(name string) (KafkaConnection, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return lookup("kafka", c.kafka, name)
+}
+
+// encodeMessage returns a string message as is and anything else as JSON
+func encodeMessage(value interface{}) ([]byte, error) {
+	if str, ok := value.(string); ok {
+		return []byte(str), nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode message: %w", err)
+	}
+	return data, nil
+}
+
+// RedisPublishAction publishes messages to Redis pub/sub channels. With
+// event set the channel defaults to reactor:<event>, which Redis triggers
+// subscribe to.
+type RedisPublishAction struct {
+	logger      *zap.Logger
+	connections *connections
+}
+
+// NewRedisPublishAction creates a new Redis publish action using the registry's connections
+func NewRedisPublishAction(logger *zap.Logger, registry *Registry) *RedisPublishAction {
+	return &RedisPublishAction{logger: logger, connections: registry.connections}
+}
+
+// TypedFields keeps the type of a message given as a single template reference
+func (r *RedisPublishAction) TypedFields(config map[string]interface{}) []string {
+	return []string{"message"}
+}
+
+// Execute publishes the message
+func (r *RedisPublishAction) Execute(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+	channel, _ := input["channel"].(string)
+	if event, _ := input["event"].(string); channel == "" && event != "" {
+		channel = "reactor:" + event
+	}
+	if channel == "" {
+		return nil, fmt.Errorf("channel or event parameter is required")
+	}
+
+	connection, _ := input["connection"].(string)
+	conn, err := r.connections.redisConnection(connection)
+	if err != nil {
+		return nil, err
+	}
+	message, err := encodeMessage(input["message"])
+	if err != nil {
+		return nil, err
+	}
+
+	receivers, err := conn.Publish(ctx, channel, message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to publish to %s: %w", channel, err)
+	}
+	r.logger.Info("Redis message published", zap.String("channel", channel), zap.Int64("receivers", receivers))
+	return map[string]interface{}{
+		"channel":   channel,
+		"receivers": receivers,
+	}, nil
+}
+
+// RedisStreamAction appends entries to Redis streams. The stream defaults
+// to reactor:events, which Redis triggers read, and event sets the entry's
+// event field.
+type RedisStreamAction struct {
+	logger      *zap.Logger
+	connections *connections
+}
+
+// NewRedisStreamAction creates a new Redis stream action using the registry's connections
+func NewRedisStreamAction(logger *zap.Logger, registry *Registry) *RedisStreamAction {
+	return &RedisStreamAction{logger: logger, connections: registry.connections}
+}
+
+// TypedFields keeps the type of values given as a single template reference
+func (r *RedisStreamAction) TypedFields(config map[string]interface{}) []string {
+	return []string{"values"}
+}
+
+// Execute adds the entry
+func (r *RedisStreamAction) Execute(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+	stream, _ := input["stream"].(string)
+	if stream == "" {
+		stream = "reactor:events"
+	}
+
+	values := make(map[string]interface{})
+	switch v := input["values"].(type) {
+	case nil:
+	case map[string]interface{}:
+		// Stream fields are strings, so structured values are stored as JSON
+		for key, value := range v {
+			switch value.(type) {
+			case map[string]interface{}, []interface{}:
+				data, err := json.Marshal(value)
+				if err != nil {
+					return nil, fmt.Errorf("failed to encode %s: %w", key, err)
+				}
+				values[key] = string(data)
+			default:
+				values[key] = value
+			}
+		}
+	default:
+		return nil, fmt.Errorf("values must be an object")
+	}
+	if event, _ := input["event"].(string); event != "" {
+		values["event"] = event
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("values or event parameter is required")
+	}
+
+	maxLen, err := intInput(input, "max_len", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	connection, _ := input["connection"].(string)
+	conn, err := r.connections.redisConnection(connection)
+	if err != nil {
+		return nil, err
+	}
+	id, err := conn.XAdd(ctx, stream, values, maxLen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add to stream %s: %w", stream, err)
+	}
+	r.logger.Info("Redis stream entry added", zap.String("stream", stream), zap.String("id", id))
+	return map[string]interface{}{
+		"stream": stream,
+		"id":     id,
+	}, nil
+}
+
+// KafkaPublishAction produces messages to Kafka topics. The topic defaults
+// to reactor-events, and event sets the event-type header Kafka triggers
+// route by. Messages carry the correlation and causation IDs of the
+// producing instance as headers.
+type KafkaPublishAction struct {
+	logger      *zap.Logger
+	connections *connections
+}
+
+// NewKafkaPublishAction creates a new Kafka publish action using the registry's connections
+func NewKafkaPublishAction(logger *zap.Logger, registry *Registry) *KafkaPublishAction {
+	return &KafkaPublishAction{logger: logger, connections: registry.connections}
+}
+
+// TypedFields keeps the type of a message given as a single template reference
+func (k *KafkaPublishAction) TypedFields(config map[string]interface{}) []string {
+	return []string{"message"}
+}
+
+// Execute produces the message
+func (k *KafkaPublishAction) Execute(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+	topic, _ := input["topic"].(string)
+	if topic == "" {
+		topic = "reactor-events"
+	}
+	event, _ := input["event"].(string)
+	key, _ := input["key"].(string)
+	if key == "" {
+		key = event
+	}
+
+	message, err := encodeMessage(input["message"])
+	if err != nil {
+		return nil, err
+	}
+
+	headers := make(map[string]string)
+	if extra, ok := input["headers"].(map[string]interface{}); ok {
+		for name, value := range extra {
+			headers[name] = fmt.Sprint(value)
+		}
+	}
+	if event != "" {
+		headers["event-type"] = event
+	}
+	if info, ok := StepInfoFromContext(ctx); ok && info.InstanceID != "" {
+		metadata := emitMetadata(info)
+		headers["correlation-id"] = metadata[MetadataCorrelationID].(string)
+		headers["causation-id"] = info.InstanceID
+	}
+
+	connection, _ := input["connection"].(string)
+	conn, err := k.connections.kafkaConnection(connection)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Produce(ctx, topic, []byte(key), message, headers); err != nil {
+		return nil, fmt.Errorf("failed to publish to %s: %w", topic, err)
+	}
+	k.logger.Info("Kafka message published", zap.String("topic", topic), zap.String("key", key))
+	return map[string]interface{}{
+		"topic": topic,
+		"key":   key,
+	}, nil
+}
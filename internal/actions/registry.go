@@ -14,21 +14,48 @@ type Action interface {
 
 // Registry manages available workflow actions
 type Registry struct {
-	logger  *zap.Logger
-	actions map[string]Action
+	logger      *zap.Logger
+	actions     map[string]Action
+	connections *connections
+	databases   *databases
+	files       *fileSystem
 }
 
 // NewRegistry creates a new action registry with built-in actions
 func NewRegistry(logger *zap.Logger) *Registry {
 	registry := &Registry{
-		logger:  logger,
-		actions: make(map[string]Action),
+		logger:      logger,
+		actions:     make(map[string]Action),
+		connections: newConnections(),
+		databases:   newDatabases(),
+		files:       newFileSystem(),
 	}
 
 	// Register built-in actions
-	registry.RegisterAction("http.request", NewHTTPAction(logger))
+	registry.RegisterAction("http.request", NewHTTPAction(logger, registry))
 	registry.RegisterAction("shell.exec", NewShellAction(logger))
 	registry.RegisterAction("log.info", NewLogAction(logger))
+	registry.RegisterAction("approval.request", NewApprovalAction(logger, registry))
+	registry.RegisterAction("wasm.run", NewWASMAction(logger, registry))
+	registry.RegisterAction("script.run", NewScriptAction(logger))
+	registry.RegisterAction("transform.map", NewTransformAction(logger))
+	registry.RegisterAction("event.emit", NewEventAction(logger))
+	registry.RegisterAction("redis.publish", NewRedisPublishAction(logger, registry))
+	registry.RegisterAction("redis.xadd", NewRedisStreamAction(logger, registry))
+	registry.RegisterAction("kafka.publish", NewKafkaPublishAction(logger, registry))
+	registry.RegisterAction("db.query", NewDatabaseQueryAction(logger, registry))
+	registry.RegisterAction("db.exec", NewDatabaseExecAction(logger, registry))
+	registry.RegisterAction("email.send", NewEmailAction(logger, registry))
+	for _, operation := range []string{"read", "write", "move", "copy", "delete", "list", "checksum"} {
+		registry.RegisterAction("file."+operation, NewFileAction(logger, registry, operation))
+	}
+	for _, operation := range []string{"zip", "tar", "extract"} {
+		registry.RegisterAction("archive."+operation, NewArchiveAction(logger, registry, operation))
+	}
+	for _, format := range []string{"csv", "jsonl", "xml"} {
+		registry.RegisterAction(format+".parse", NewParseAction(logger, registry, format))
+	}
+	registry.RegisterAction("csv.write", NewCSVWriteAction(logger, registry))
 
 	return registry
 }
@@ -39,6 +66,63 @@ func (r *Registry) RegisterAction(name string, action Action) {
 	r.logger.Info("Action registered", zap.String("name", name))
 }
 
+// RegisterWASMModule makes a WebAssembly module available to wasm.run steps by name
+func (r *Registry) RegisterWASMModule(name, path string) error {
+	action, exists := r.actions["wasm.run"]
+	if !exists {
+		return fmt.Errorf("action not found: wasm.run")
+	}
+	wasm, ok := action.(*WASMAction)
+	if !ok {
+		return fmt.Errorf("wasm.run is not the built-in WebAssembly action")
+	}
+	wasm.RegisterModule(name, path)
+	return nil
+}
+
+// RegisterRedisConnection makes a Redis connection available to
+// redis.publish and redis.xadd steps by name
+func (r *Registry) RegisterRedisConnection(name string, conn RedisConnection) {
+	r.connections.mu.Lock()
+	defer r.connections.mu.Unlock()
+	r.connections.redis[name] = conn
+}
+
+// RegisterKafkaConnection makes a Kafka connection available to
+// kafka.publish steps by name
+func (r *Registry) RegisterKafkaConnection(name string, conn KafkaConnection) {
+	r.connections.mu.Lock()
+	defer r.connections.mu.Unlock()
+	r.connections.kafka[name] = conn
+}
+
+// RegisterDatabase opens a connection pool available to db.query and
+// db.exec steps by name
+func (r *Registry) RegisterDatabase(name string, config DatabaseConfig) error {
+	return r.databases.open(name, config)
+}
+
+// SetFilePolicy restricts the paths file and archive steps may use
+func (r *Registry) SetFilePolicy(policy *FilePolicy) {
+	r.files.setPolicy(policy)
+}
+
+// SetProtectedPaths sets paths file and archive steps may never use, such
+// as the data directory and secrets keystore, whatever the policy allows
+func (r *Registry) SetProtectedPaths(paths ...string) {
+	r.files.setProtected(paths)
+}
+
+// FinishInstance releases what actions hold for an instance that stopped
+// running, such as open database transactions
+func (r *Registry) FinishInstance(instanceID string) {
+	for _, action := range r.actions {
+		if finisher, ok := action.(InstanceFinisher); ok {
+			finisher.FinishInstance(instanceID)
+		}
+	}
+}
+
 // GetAction retrieves an action by name
 func (r *Registry) GetAction(name string) (Action, error) {
 	action, exists := r.actions[name]
@@ -0,0 +1,29 @@
+//go:build !linux && !darwin
+
+package actions
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// SandboxCommand is the hidden sub-command that applies resource limits
+// before executing a shell.exec command
+const SandboxCommand = "__sandbox-exec"
+
+// sandboxCommand reports an error when limits or run_as are requested on a
+// platform that does not support them
+func sandboxCommand(policy *ShellPolicy, argv []string) ([]string, *syscall.SysProcAttr, error) {
+	if policy == nil || (policy.Limits == nil && policy.RunAs == "") {
+		return argv, nil, nil
+	}
+	return nil, nil, fmt.Errorf("shell policy limits and run_as are not supported on this platform")
+}
+
+// RunSandboxed is not supported on this platform
+func RunSandboxed(args []string) error {
+	return fmt.Errorf("sandboxed execution is not supported on this platform")
+}
+
+// limitAddressSpace is not supported on this platform
+func limitAddressSpace(extra int64) {}
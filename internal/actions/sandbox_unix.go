@@ -0,0 +1,142 @@
+//go:build linux || darwin
+
+package actions
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// SandboxCommand is the hidden sub-command that applies resource limits
+// before executing a shell.exec command
+const SandboxCommand = "__sandbox-exec"
+
+// sandboxCommand wraps argv in the sandbox helper when the policy sets
+// limits or a user to run as
+func sandboxCommand(policy *ShellPolicy, argv []string) ([]string, *syscall.SysProcAttr, error) {
+	if policy == nil || (policy.Limits == nil && policy.RunAs == "") {
+		return argv, nil, nil
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to locate sandbox helper: %w", err)
+	}
+
+	wrapped := []string{self, SandboxCommand}
+	if limits := policy.Limits; limits != nil {
+		if limits.CPUSeconds > 0 {
+			wrapped = append(wrapped, fmt.Sprintf("cpu=%d", limits.CPUSeconds))
+		}
+		if limits.MemoryBytes > 0 {
+			wrapped = append(wrapped, fmt.Sprintf("as=%d", limits.MemoryBytes))
+		}
+		if limits.OpenFiles > 0 {
+			wrapped = append(wrapped, fmt.Sprintf("nofile=%d", limits.OpenFiles))
+		}
+	}
+	wrapped = append(append(wrapped, "--"), argv...)
+
+	var attr *syscall.SysProcAttr
+	if policy.RunAs != "" {
+		uid, gid, err := lookupRunAs(policy.RunAs)
+		if err != nil {
+			return nil, nil, err
+		}
+		attr = &syscall.SysProcAttr{
+			Credential: &syscall.Credential{Uid: uid, Gid: gid},
+		}
+	}
+
+	return wrapped, attr, nil
+}
+
+// lookupRunAs resolves "user", "uid" or "uid:gid"
+func lookupRunAs(runAs string) (uint32, uint32, error) {
+	name, group, _ := strings.Cut(runAs, ":")
+
+	var uid, gid uint64
+	var err error
+	if uid, err = strconv.ParseUint(name, 10, 32); err != nil {
+		u, lookupErr := user.Lookup(name)
+		if lookupErr != nil {
+			return 0, 0, fmt.Errorf("run_as user %q: %w", name, lookupErr)
+		}
+		uid, _ = strconv.ParseUint(u.Uid, 10, 32)
+		gid, _ = strconv.ParseUint(u.Gid, 10, 32)
+	} else {
+		gid = uid
+	}
+
+	if group != "" {
+		if gid, err = strconv.ParseUint(group, 10, 32); err != nil {
+			g, lookupErr := user.LookupGroup(group)
+			if lookupErr != nil {
+				return 0, 0, fmt.Errorf("run_as group %q: %w", group, lookupErr)
+			}
+			gid, _ = strconv.ParseUint(g.Gid, 10, 32)
+		}
+	}
+
+	return uint32(uid), uint32(gid), nil
+}
+
+// RunSandboxed applies "name=value" resource limits and replaces the current
+// process with the command following "--". It only returns on error.
+func RunSandboxed(args []string) error {
+	limits := map[string]int{
+		"cpu":    syscall.RLIMIT_CPU,
+		"as":     syscall.RLIMIT_AS,
+		"nofile": syscall.RLIMIT_NOFILE,
+	}
+
+	for len(args) > 0 && args[0] != "--" {
+		name, value, _ := strings.Cut(args[0], "=")
+		resource, known := limits[name]
+		if !known {
+			return fmt.Errorf("unknown limit %q", name)
+		}
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s limit %q", name, value)
+		}
+		if err := syscall.Setrlimit(resource, &syscall.Rlimit{Cur: n, Max: n}); err != nil {
+			return fmt.Errorf("failed to set %s limit: %w", name, err)
+		}
+		args = args[1:]
+	}
+	if len(args) < 2 {
+		return fmt.Errorf("no command given")
+	}
+
+	argv := args[1:]
+	path, err := exec.LookPath(argv[0])
+	if err != nil {
+		return err
+	}
+	return syscall.Exec(path, argv, os.Environ())
+}
+
+// limitAddressSpace caps the address space of the current process at its
+// present size plus extra bytes. It does nothing where the size is unknown.
+func limitAddressSpace(extra int64) {
+	statm, err := os.ReadFile("/proc/self/statm")
+	if err != nil {
+		return
+	}
+	fields := strings.Fields(string(statm))
+	if len(fields) == 0 {
+		return
+	}
+	pages, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return
+	}
+	n := pages*uint64(os.Getpagesize()) + uint64(extra)
+	syscall.Setrlimit(syscall.RLIMIT_AS, &syscall.Rlimit{Cur: n, Max: n})
+}
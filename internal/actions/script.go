@@ -0,0 +1,212 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultScriptTimeout bounds a script when neither the step nor the
+	// configuration sets a timeout
+	defaultScriptTimeout = 5 * time.Second
+	// defaultScriptMemory is the memory a script may hold unless configured
+	defaultScriptMemory = 64 << 20
+	// defaultScriptSteps is the number of Starlark steps a script may run
+	// unless configured
+	defaultScriptSteps = 10_000_000
+	// maxScriptOutputBytes caps the response read from the script runner
+	maxScriptOutputBytes = 10 << 20
+	// maxScriptStderrBytes caps the runner stderr kept for error messages
+	maxScriptStderrBytes = 64 << 10
+)
+
+// scriptOptions are the step keys read by script.run rather than passed to
+// the script as input
+var scriptOptions = map[string]bool{
+	"script":           true,
+	"timeout":          true,
+	"max_memory_bytes": true,
+	"max_steps":        true,
+}
+
+// ScriptConfig sets the limits of script.run steps
+type ScriptConfig struct {
+	// Timeout is the default run time of a script
+	Timeout time.Duration `json:"timeout,omitempty"`
+	// MaxMemoryBytes is the default and the most a step may request
+	MaxMemoryBytes int64 `json:"max_memory_bytes,omitempty"`
+	// MaxSteps is the default and the most Starlark steps a step may request
+	MaxSteps int64 `json:"max_steps,omitempty"`
+}
+
+// ScriptAction evaluates inline Starlark scripts. A script is either one
+// expression or statements that assign result; the value becomes the step
+// output. Scripts see event, variables, steps and input (the other step
+// keys) as frozen values, plus the json and math modules. They have no
+// access to files, the network, the clock or randomness, so the same
+// inputs always give the same output.
+//
+// Each script runs in a child process of this binary so that a runaway
+// script can be killed and its memory measured on its own.
+type ScriptAction struct {
+	logger *zap.Logger
+
+	mu        sync.RWMutex
+	timeout   time.Duration
+	maxMemory int64
+	maxSteps  int64
+}
+
+// NewScriptAction creates a new script action
+func NewScriptAction(logger *zap.Logger) *ScriptAction {
+	return &ScriptAction{
+		logger:    logger,
+		timeout:   defaultScriptTimeout,
+		maxMemory: defaultScriptMemory,
+		maxSteps:  defaultScriptSteps,
+	}
+}
+
+// SetConfig applies the configured limits
+func (s *ScriptAction) SetConfig(config *ScriptConfig) {
+	if config == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if config.Timeout > 0 {
+		s.timeout = config.Timeout
+	}
+	if config.MaxMemoryBytes > 0 {
+		s.maxMemory = config.MaxMemoryBytes
+	}
+	if config.MaxSteps > 0 {
+		s.maxSteps = config.MaxSteps
+	}
+}
+
+// Execute runs the script and returns its result
+func (s *ScriptAction) Execute(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+	script, ok := input["script"].(string)
+	if !ok || strings.TrimSpace(script) == "" {
+		return nil, fmt.Errorf("script parameter is required")
+	}
+
+	s.mu.RLock()
+	defaultTimeout, maxMemory, maxSteps := s.timeout, s.maxMemory, s.maxSteps
+	s.mu.RUnlock()
+
+	timeout, err := durationInput(input, "timeout", defaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+	memory, err := intInput(input, "max_memory_bytes", maxMemory)
+	if err != nil {
+		return nil, err
+	}
+	if memory <= 0 || memory > maxMemory {
+		return nil, fmt.Errorf("max_memory_bytes must be between 1 and %d", maxMemory)
+	}
+	steps, err := intInput(input, "max_steps", maxSteps)
+	if err != nil {
+		return nil, err
+	}
+	if steps <= 0 || steps > maxSteps {
+		return nil, fmt.Errorf("max_steps must be between 1 and %d", maxSteps)
+	}
+
+	params := make(map[string]interface{}, len(input))
+	for key, value := range input {
+		if !scriptOptions[key] {
+			params[key] = value
+		}
+	}
+	info, _ := StepInfoFromContext(ctx)
+	globals := map[string]interface{}{
+		"event":     info.Event,
+		"variables": info.Variables,
+		"steps":     info.Steps,
+		"input":     params,
+	}
+	if info.Variables == nil {
+		globals["variables"] = map[string]interface{}{}
+	}
+	if info.Steps == nil {
+		globals["steps"] = map[string]interface{}{}
+	}
+
+	request, err := json.Marshal(scriptRequest{
+		Script:    script,
+		Globals:   globals,
+		MaxSteps:  uint64(steps),
+		MaxMemory: memory,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode script input: %w", err)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate script runner: %w", err)
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stdout := &limitedBuffer{max: maxScriptOutputBytes}
+	stderr := &limitedBuffer{max: maxScriptStderrBytes}
+	cmd := exec.CommandContext(runCtx, executable, ScriptCommand)
+	cmd.Env = []string{}
+	cmd.Stdin = bytes.NewReader(request)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	s.logger.Debug("Running script",
+		zap.Duration("timeout", timeout),
+		zap.Int64("max_memory_bytes", memory),
+		zap.Int64("max_steps", steps))
+
+	err = cmd.Run()
+	switch {
+	case errors.Is(runCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil:
+		return nil, fmt.Errorf("script timed out after %s", timeout)
+	case ctx.Err() != nil:
+		return nil, ctx.Err()
+	case err != nil && strings.Contains(stderr.String(), "out of memory"):
+		return nil, fmt.Errorf("script failed: script exceeded max_memory_bytes (%d)", memory)
+	case err != nil:
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("script runner failed: %w: %s", err, message)
+		}
+		return nil, fmt.Errorf("script runner failed: %w", err)
+	}
+	if stdout.truncated {
+		return nil, fmt.Errorf("script output exceeds %d bytes", maxScriptOutputBytes)
+	}
+
+	var response scriptResponse
+	if err := json.Unmarshal(stdout.buf, &response); err != nil {
+		return nil, fmt.Errorf("invalid script runner response: %w", err)
+	}
+	for _, line := range response.Prints {
+		s.logger.Info("Script output", zap.String("step", info.Step), zap.String("print", line))
+	}
+	if response.Error != "" {
+		return nil, fmt.Errorf("script failed: %s", response.Error)
+	}
+
+	if object, ok := response.Output.(map[string]interface{}); ok {
+		return object, nil
+	}
+	return map[string]interface{}{"result": response.Output}, nil
+}
@@ -0,0 +1,287 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"runtime/debug"
+	"runtime/metrics"
+	"sort"
+	"sync"
+	"time"
+
+	starlarkjson "go.starlark.net/lib/json"
+	starlarkmath "go.starlark.net/lib/math"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// ScriptCommand is the hidden argument that makes the binary run a
+// script.run request from stdin instead of the CLI
+const ScriptCommand = "__script-run"
+
+const (
+	// maxScriptPrints caps the print() lines returned to the daemon
+	maxScriptPrints = 100
+	// scriptMemoryCheckInterval is how often the runner samples its heap
+	scriptMemoryCheckInterval = 5 * time.Millisecond
+	// heapMetric is the runtime metric compared with the memory limit
+	heapMetric = "/memory/classes/heap/objects:bytes"
+	// scriptAddressSpaceMargin is address space allowed beyond twice the
+	// memory limit for the runtime's own reservations
+	scriptAddressSpaceMargin = 256 << 20
+)
+
+// scriptRequest is what the daemon sends a script runner
+type scriptRequest struct {
+	Script    string                 `json:"script"`
+	Globals   map[string]interface{} `json:"globals"`
+	MaxSteps  uint64                 `json:"max_steps"`
+	MaxMemory int64                  `json:"max_memory_bytes"`
+}
+
+// scriptResponse is the result a script runner writes to stdout
+type scriptResponse struct {
+	Output interface{} `json:"output,omitempty"`
+	Error  string      `json:"error,omitempty"`
+	Prints []string    `json:"prints,omitempty"`
+	Steps  uint64      `json:"steps"`
+}
+
+// scriptFileOptions are the Starlark dialect scripts are written in.
+// Recursion stays disabled, as in standard Starlark.
+var scriptFileOptions = &syntax.FileOptions{
+	Set:             true,
+	While:           true,
+	TopLevelControl: true,
+	GlobalReassign:  true,
+}
+
+// RunScript evaluates one script request in this process, which does
+// nothing else, so its heap is the script's memory use. It always writes a
+// response and returns the exit code.
+func RunScript(stdin io.Reader, stdout io.Writer) int {
+	var mu sync.Mutex
+	respond := func(resp scriptResponse) {
+		mu.Lock()
+		json.NewEncoder(stdout).Encode(resp)
+		os.Exit(0)
+	}
+
+	var req scriptRequest
+	decoder := json.NewDecoder(stdin)
+	decoder.UseNumber()
+	if err := decoder.Decode(&req); err != nil {
+		respond(scriptResponse{Error: fmt.Sprintf("invalid script request: %v", err)})
+	}
+
+	thread := &starlark.Thread{Name: "script"}
+	var prints []string
+	thread.Print = func(_ *starlark.Thread, msg string) {
+		if len(prints) < maxScriptPrints {
+			prints = append(prints, msg)
+		}
+	}
+	thread.Load = func(_ *starlark.Thread, module string) (starlark.StringDict, error) {
+		return nil, fmt.Errorf("load is not available in script.run")
+	}
+	if req.MaxSteps > 0 {
+		thread.SetMaxExecutionSteps(req.MaxSteps)
+		thread.OnMaxSteps = func(thread *starlark.Thread) {
+			thread.Cancel(fmt.Sprintf("script exceeded max_steps (%d)", req.MaxSteps))
+		}
+	}
+	memoryError := fmt.Sprintf("script exceeded max_memory_bytes (%d)", req.MaxMemory)
+	var guard *memoryGuard
+	if req.MaxMemory > 0 {
+		guard = newMemoryGuard(req.MaxMemory)
+		go guard.watch(func() {
+			respond(scriptResponse{Error: memoryError, Steps: thread.ExecutionSteps()})
+		})
+	}
+
+	predeclared := starlark.StringDict{
+		"json": starlarkjson.Module,
+		"math": starlarkmath.Module,
+	}
+	for name, value := range req.Globals {
+		converted, err := toStarlark(value)
+		if err != nil {
+			respond(scriptResponse{Error: fmt.Sprintf("%s: %v", name, err)})
+		}
+		converted.Freeze()
+		predeclared[name] = converted
+	}
+
+	value, err := evalScript(thread, req.Script, predeclared)
+	if guard != nil && guard.exceeded() {
+		respond(scriptResponse{Error: memoryError, Prints: prints, Steps: thread.ExecutionSteps()})
+	}
+	if err != nil {
+		respond(scriptResponse{Error: err.Error(), Prints: prints, Steps: thread.ExecutionSteps()})
+	}
+	output, err := fromStarlark(value)
+	if err != nil {
+		respond(scriptResponse{Error: fmt.Sprintf("script result: %v", err), Prints: prints, Steps: thread.ExecutionSteps()})
+	}
+	respond(scriptResponse{Output: output, Prints: prints, Steps: thread.ExecutionSteps()})
+	return 0
+}
+
+// evalScript evaluates a script that is a single expression, or runs a
+// script and returns its result global
+func evalScript(thread *starlark.Thread, src string, predeclared starlark.StringDict) (starlark.Value, error) {
+	if expr, err := scriptFileOptions.ParseExpr("script", src, 0); err == nil {
+		return starlark.EvalExprOptions(scriptFileOptions, thread, expr, predeclared)
+	}
+
+	globals, err := starlark.ExecFileOptions(scriptFileOptions, thread, "script", src, predeclared)
+	if err != nil {
+		return nil, err
+	}
+	result, ok := globals["result"]
+	if !ok {
+		return nil, fmt.Errorf("script must be an expression or assign result")
+	}
+	return result, nil
+}
+
+// memoryGuard measures the heap a script holds against its limit. The
+// runtime memory limit makes the collector keep garbage below it, so what
+// remains is memory the script holds.
+type memoryGuard struct {
+	limit    uint64
+	baseline uint64
+}
+
+// newMemoryGuard records the heap in use before the script runs. The
+// address space is also capped well above the limit so that a single huge
+// allocation fails rather than exhausting the host.
+func newMemoryGuard(limit int64) *memoryGuard {
+	guard := &memoryGuard{limit: uint64(limit)}
+	guard.baseline = guard.heap()
+	debug.SetMemoryLimit(int64(guard.baseline) + limit)
+	limitAddressSpace(2*limit + scriptAddressSpaceMargin)
+	return guard
+}
+
+// heap returns the bytes of heap objects, reachable or not yet collected
+func (g *memoryGuard) heap() uint64 {
+	sample := []metrics.Sample{{Name: heapMetric}}
+	metrics.Read(sample)
+	return sample[0].Value.Uint64()
+}
+
+// exceeded reports whether the heap has grown by more than the limit
+func (g *memoryGuard) exceeded() bool {
+	used := g.heap()
+	return used > g.baseline && used-g.baseline > g.limit
+}
+
+// watch calls onExceeded once the limit is exceeded
+func (g *memoryGuard) watch(onExceeded func()) {
+	for range time.Tick(scriptMemoryCheckInterval) {
+		if g.exceeded() {
+			onExceeded()
+			return
+		}
+	}
+}
+
+// toStarlark converts JSON-decoded data to Starlark values. Dict keys are
+// inserted in sorted order so iteration is deterministic.
+func toStarlark(value interface{}) (starlark.Value, error) {
+	switch v := value.(type) {
+	case nil:
+		return starlark.None, nil
+	case bool:
+		return starlark.Bool(v), nil
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return starlark.MakeInt64(n), nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return starlark.Float(f), nil
+	case string:
+		return starlark.String(v), nil
+	case []interface{}:
+		items := make([]starlark.Value, len(v))
+		for i, item := range v {
+			converted, err := toStarlark(item)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = converted
+		}
+		return starlark.NewList(items), nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		dict := starlark.NewDict(len(v))
+		for _, key := range keys {
+			converted, err := toStarlark(v[key])
+			if err != nil {
+				return nil, err
+			}
+			dict.SetKey(starlark.String(key), converted)
+		}
+		return dict, nil
+	}
+	return nil, fmt.Errorf("unsupported value of type %T", value)
+}
+
+// fromStarlark converts a script result to JSON-compatible data
+func fromStarlark(value starlark.Value) (interface{}, error) {
+	switch v := value.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(v), nil
+	case starlark.Int:
+		if n, ok := v.Int64(); ok {
+			return n, nil
+		}
+		return nil, fmt.Errorf("integer %s is too large", v)
+	case starlark.Float:
+		f := float64(v)
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("%s is not a JSON number", v)
+		}
+		return f, nil
+	case starlark.String:
+		return string(v), nil
+	case starlark.Indexable: // list, tuple
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			item, err := fromStarlark(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	case *starlark.Dict:
+		result := make(map[string]interface{}, v.Len())
+		for _, item := range v.Items() {
+			key, ok := item[0].(starlark.String)
+			if !ok {
+				return nil, fmt.Errorf("dict key %s is not a string", item[0])
+			}
+			converted, err := fromStarlark(item[1])
+			if err != nil {
+				return nil, err
+			}
+			result[string(key)] = converted
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("cannot convert %s to JSON", value.Type())
+}
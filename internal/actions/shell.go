@@ -2,18 +2,24 @@ package actions
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
+	"io"
 	"os/exec"
 	"strings"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
 )
 
+// defaultMaxOutputBytes caps captured stdout and stderr per stream
+const defaultMaxOutputBytes = 1 << 20
+
 // ShellAction implements shell command execution
 type ShellAction struct {
 	logger *zap.Logger
+	policy *ShellPolicy
 }
 
 // NewShellAction creates a new shell action
@@ -23,7 +29,23 @@ func NewShellAction(logger *zap.Logger) *ShellAction {
 	}
 }
 
-// Execute runs a shell command
+// SetPolicy restricts the commands this action may run; nil allows everything
+func (s *ShellAction) SetPolicy(policy *ShellPolicy) {
+	s.policy = policy
+}
+
+// QuotedFields quotes template values in command unless it names the
+// binary for an explicit args list
+func (s *ShellAction) QuotedFields(config map[string]interface{}) []string {
+	if _, hasArgs := config["args"]; hasArgs && config["shell"] == nil {
+		return nil
+	}
+	return []string{"command"}
+}
+
+// Execute runs a command. By default the command line is split into words
+// without a shell; "args" gives the exact argv and "shell" runs the command
+// through "<shell> -c" so pipes and redirects work.
 func (s *ShellAction) Execute(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
 	// Parse input parameters
 	command, ok := input["command"].(string)
@@ -31,80 +53,228 @@ func (s *ShellAction) Execute(ctx context.Context, input map[string]interface{})
 		return nil, fmt.Errorf("command parameter is required")
 	}
 
+	shell, _ := input["shell"].(string)
+
+	var args []string
+	if rawArgs, exists := input["args"]; exists {
+		list, ok := rawArgs.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("args must be a list")
+		}
+		for _, arg := range list {
+			args = append(args, fmt.Sprintf("%v", arg))
+		}
+	}
+
+	// Build argv
+	var argv []string
+	switch {
+	case shell != "":
+		// Extra args become $1, $2, ... in the script
+		argv = append([]string{shell, "-c", command, shell}, args...)
+	case args != nil:
+		argv = append([]string{command}, args...)
+	default:
+		words, err := splitCommand(command)
+		if err != nil {
+			return nil, err
+		}
+		argv = words
+	}
+	if len(argv) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+
+	// Apply the shell policy for this workflow
+	var policy *ShellPolicy
+	if s.policy != nil {
+		info, _ := StepInfoFromContext(ctx)
+		policy = s.policy.ForWorkflow(info.Workflow)
+	}
+	if err := policy.CheckCommand(argv[0]); err != nil {
+		return nil, err
+	}
+
 	// Set working directory if provided
 	workDir := ""
 	if wd, ok := input["working_dir"].(string); ok {
 		workDir = wd
 	}
+	workDir, err := policy.ResolveDir(workDir)
+	if err != nil {
+		return nil, err
+	}
 
 	// Set timeout (default 30 seconds)
-	timeout := 30 * time.Second
-	if t, ok := input["timeout"].(float64); ok {
-		timeout = time.Duration(t) * time.Second
+	timeout, err := durationInput(input, "timeout", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	maxOutput, err := intInput(input, "max_output_bytes", defaultMaxOutputBytes)
+	if err != nil {
+		return nil, err
 	}
 
 	// Parse environment variables
-	env := os.Environ()
+	env := policy.BaseEnv()
 	if envVars, ok := input["env"].(map[string]interface{}); ok {
 		for key, value := range envVars {
+			if err := policy.CheckEnv(key); err != nil {
+				return nil, err
+			}
 			env = append(env, fmt.Sprintf("%s=%v", key, value))
 		}
 	}
 
-	s.logger.Info("Executing shell command", 
-		zap.String("command", command),
+	// Resolve bare names against the policy's PATH rather than the daemon's
+	runArgv := argv
+	if policy != nil && !strings.Contains(argv[0], "/") {
+		if resolved, err := lookPath(argv[0], policy.path()); err == nil {
+			runArgv = append([]string{resolved}, argv[1:]...)
+		}
+	}
+
+	// Run through the sandbox helper when limits or run_as are set
+	execArgv, sysProcAttr, err := sandboxCommand(policy, runArgv)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Executing shell command",
+		zap.Strings("argv", argv),
 		zap.String("working_dir", workDir))
 
 	// Create context with timeout
 	cmdCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	// Parse command (simple space-based splitting)
-	parts := strings.Fields(command)
-	if len(parts) == 0 {
-		return nil, fmt.Errorf("empty command")
-	}
-
 	// Create command
-	cmd := exec.CommandContext(cmdCtx, parts[0], parts[1:]...)
-	
+	cmd := exec.CommandContext(cmdCtx, execArgv[0], execArgv[1:]...)
+	cmd.SysProcAttr = sysProcAttr
+
 	if workDir != "" {
 		cmd.Dir = workDir
 	}
-	
+
 	cmd.Env = env
 
-	// Execute command and capture output
-	output, err := cmd.CombinedOutput()
-	outputStr := string(output)
+	if stdin, ok := input["stdin"].(string); ok {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+
+	// Capture stdout and stderr separately and interleaved, within limits
+	stdout := &limitedBuffer{max: maxOutput}
+	stderr := &limitedBuffer{max: maxOutput}
+	combinedBuf := &limitedBuffer{max: maxOutput}
+	combined := &syncWriter{w: combinedBuf}
+	cmd.Stdout = io.MultiWriter(stdout, combined)
+	cmd.Stderr = io.MultiWriter(stderr, combined)
+
+	start := time.Now()
+	err = cmd.Run()
 
 	result := map[string]interface{}{
-		"command":    command,
-		"output":     outputStr,
-		"success":    err == nil,
-		"exit_code":  0,
+		"command":          command,
+		"argv":             argv,
+		"output":           combinedBuf.String(),
+		"stdout":           stdout.String(),
+		"stderr":           stderr.String(),
+		"stdout_truncated": stdout.truncated,
+		"stderr_truncated": stderr.truncated,
+		"duration_ms":      time.Since(start).Milliseconds(),
+		"success":          err == nil,
+		"exit_code":        0,
 	}
 
 	if err != nil {
 		// Try to get exit code
-		if exitError, ok := err.(*exec.ExitError); ok {
+		var exitError *exec.ExitError
+		if errors.As(err, &exitError) {
 			result["exit_code"] = exitError.ExitCode()
 		} else {
 			result["exit_code"] = -1
 		}
+		if cmdCtx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("timed out after %s: %w", timeout, err)
+		}
 		result["error"] = err.Error()
-		
-		s.logger.Error("Shell command failed", 
-			zap.String("command", command),
+
+		s.logger.Error("Shell command failed",
+			zap.Strings("argv", argv),
 			zap.Error(err),
-			zap.String("output", outputStr))
-		
+			zap.String("stderr", stderr.String()))
+
 		return result, fmt.Errorf("command failed: %w", err)
 	}
 
-	s.logger.Info("Shell command completed", 
-		zap.String("command", command),
-		zap.String("output", outputStr))
+	s.logger.Info("Shell command completed",
+		zap.Strings("argv", argv),
+		zap.String("output", stdout.String()))
 
 	return result, nil
 }
+
+// syncWriter serialises writes from the stdout and stderr copiers
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
+
+// splitCommand splits a command line into words using POSIX shell quoting
+// rules: single quotes, double quotes and backslash escapes. No expansion,
+// globbing, pipes or redirects are performed.
+func splitCommand(command string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case c == '\'':
+			end := strings.IndexByte(command[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote in command")
+			}
+			word.WriteString(command[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case c == '"':
+			i++
+			for ; i < len(command) && command[i] != '"'; i++ {
+				if command[i] == '\\' && i+1 < len(command) && strings.IndexByte("\\\"$`", command[i+1]) >= 0 {
+					i++
+				}
+				word.WriteByte(command[i])
+			}
+			if i >= len(command) {
+				return nil, fmt.Errorf("unterminated double quote in command")
+			}
+			inWord = true
+		case c == '\\' && i+1 < len(command):
+			i++
+			word.WriteByte(command[i])
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,99 @@
+package actions
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/logimos/conduktr/internal/persistence"
+
+	"go.uber.org/zap"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		want    []string
+		err     bool
+	}{
+		{name: "words", command: "echo hello  world", want: []string{"echo", "hello", "world"}},
+		{name: "tabs and newlines", command: "echo\ta\nb", want: []string{"echo", "a", "b"}},
+		{name: "empty", command: "   ", want: nil},
+		{name: "single quotes", command: `echo 'a b' 'c"d'`, want: []string{"echo", "a b", `c"d`}},
+		{name: "single quotes keep backslashes", command: `echo 'a\nb'`, want: []string{"echo", `a\nb`}},
+		{name: "double quotes", command: `echo "a b" "c'd"`, want: []string{"echo", "a b", "c'd"}},
+		{name: "double quote escapes", command: `echo "a\"b\\c\$d\e"`, want: []string{"echo", `a"b\c$d\e`}},
+		{name: "backslash escapes", command: `echo a\ b \'`, want: []string{"echo", "a b", "'"}},
+		{name: "adjacent quoted parts", command: `echo pre'a b'"c d"post`, want: []string{"echo", "prea bc dpost"}},
+		{name: "empty quoted word", command: `echo '' ""`, want: []string{"echo", "", ""}},
+		{name: "escaped single quote idiom", command: `echo 'it'\''s'`, want: []string{"echo", "it's"}},
+		{name: "no expansion", command: "echo $HOME $(id) `id` *.go | cat > out", want: []string{"echo", "$HOME", "$(id)", "`id`", "*.go", "|", "cat", ">", "out"}},
+		{name: "trailing backslash", command: `echo a\`, want: []string{"echo", `a\`}},
+		{name: "unterminated single quote", command: "echo 'a", err: true},
+		{name: "unterminated double quote", command: `echo "a`, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := splitCommand(tt.command)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("splitCommand(%q) = %q, want error", tt.command, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitCommand(%q) = %q, want %q", tt.command, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestQuotedTemplateValuesStayOneWord resolves hostile payloads into a
+// command line and runs it both split into argv and through sh -c
+func TestQuotedTemplateValuesStayOneWord(t *testing.T) {
+	values := []string{
+		"a b",
+		"$(echo INJECTED)",
+		"`echo INJECTED`",
+		`x"; echo INJECTED; "`,
+		`x'; echo INJECTED; '`,
+		`\'; echo INJECTED #`,
+		"a\nb",
+		"*",
+		"",
+	}
+	shell := NewShellAction(zap.NewNop())
+
+	for _, value := range values {
+		t.Run(value, func(t *testing.T) {
+			ctx := &persistence.EventContext{
+				Event:     &persistence.Event{Payload: map[string]interface{}{"x": value}},
+				Variables: map[string]interface{}{},
+			}
+			command, err := ctx.ResolveQuotedTemplate("printf [%s] {{ .event.payload.x }}")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			argv, err := splitCommand(command)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []string{"printf", "[%s]", value}; !reflect.DeepEqual(argv, want) {
+				t.Errorf("argv = %q, want %q", argv, want)
+			}
+
+			result, err := shell.Execute(context.Background(), map[string]interface{}{"shell": "sh", "command": command})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := "[" + value + "]"; result["stdout"] != want {
+				t.Errorf("sh -c stdout = %q, want %q", result["stdout"], want)
+			}
+		})
+	}
+}
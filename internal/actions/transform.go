@@ -0,0 +1,454 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/jmespath/go-jmespath"
+	"go.uber.org/zap"
+)
+
+// fieldSpec describes how one output field is computed. A mapping entry
+// that is a string is shorthand for a spec with only a path.
+type fieldSpec struct {
+	// Path is the JMESPath expression selecting the value
+	Path string
+	// Filter keeps the elements of an array for which it is truthy
+	Filter string
+	// Flatten flattens nested arrays completely
+	Flatten bool
+	// Fields maps an object, or each object of an array, with a nested mapping
+	Fields map[string]interface{}
+	// GroupBy groups an array by the value of this expression
+	GroupBy string
+	// Default replaces a null result
+	Default interface{}
+	// Type converts the result: string, int, number, bool, array or object
+	Type string
+}
+
+// fieldSpecKeys are the keys allowed in a field spec
+var fieldSpecKeys = map[string]bool{
+	"path":     true,
+	"filter":   true,
+	"flatten":  true,
+	"fields":   true,
+	"group_by": true,
+	"default":  true,
+	"type":     true,
+}
+
+// TransformAction reshapes data without code. Each key of the mapping is
+// an output field computed from the source with a JMESPath expression and
+// optional filtering, flattening, grouping, defaulting and type
+// conversion. Values keep their JSON types in the step output.
+//
+// The source defaults to the workflow context: event, variables and steps.
+type TransformAction struct {
+	logger *zap.Logger
+}
+
+// NewTransformAction creates a new transform action
+func NewTransformAction(logger *zap.Logger) *TransformAction {
+	return &TransformAction{logger: logger}
+}
+
+// TypedFields keeps the type of a source given as a single template reference
+func (t *TransformAction) TypedFields(config map[string]interface{}) []string {
+	return []string{"source"}
+}
+
+// Execute applies the mapping to the source
+func (t *TransformAction) Execute(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+	mapping, ok := input["mapping"].(map[string]interface{})
+	if !ok || len(mapping) == 0 {
+		return nil, fmt.Errorf("mapping parameter is required")
+	}
+
+	source, exists := input["source"]
+	if !exists {
+		info, _ := StepInfoFromContext(ctx)
+		source = map[string]interface{}{
+			"event":     info.Event,
+			"variables": info.Variables,
+			"steps":     info.Steps,
+		}
+	}
+	if str, ok := source.(string); ok && input["source_format"] == "json" {
+		if err := json.Unmarshal([]byte(str), &source); err != nil {
+			return nil, fmt.Errorf("source is not valid JSON: %w", err)
+		}
+	}
+
+	// JMESPath works on JSON values, with every number a float64
+	data, err := normalizeJSON(source)
+	if err != nil {
+		return nil, fmt.Errorf("source cannot be transformed: %w", err)
+	}
+
+	output, err := applyMapping(mapping, data, "")
+	if err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// applyMapping computes each field of a mapping from data. The prefix
+// names nested fields in errors.
+func applyMapping(mapping map[string]interface{}, data interface{}, prefix string) (map[string]interface{}, error) {
+	output := make(map[string]interface{}, len(mapping))
+	for _, name := range sortedKeys(mapping) {
+		raw := mapping[name]
+		field := prefix + name
+		spec, err := parseFieldSpec(raw)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field, err)
+		}
+		value, err := spec.apply(data, field)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field, err)
+		}
+		output[name] = value
+	}
+	return output, nil
+}
+
+// parseFieldSpec reads a mapping entry
+func parseFieldSpec(raw interface{}) (*fieldSpec, error) {
+	switch v := raw.(type) {
+	case string:
+		return &fieldSpec{Path: v}, nil
+	case map[string]interface{}:
+		for key := range v {
+			if !fieldSpecKeys[key] {
+				return nil, fmt.Errorf("unknown option %s", key)
+			}
+		}
+		spec := &fieldSpec{Default: v["default"]}
+		for key, target := range map[string]*string{
+			"path":     &spec.Path,
+			"filter":   &spec.Filter,
+			"group_by": &spec.GroupBy,
+			"type":     &spec.Type,
+		} {
+			if value, set := v[key]; set {
+				str, ok := value.(string)
+				if !ok {
+					return nil, fmt.Errorf("%s must be a string", key)
+				}
+				*target = str
+			}
+		}
+		spec.Flatten = boolInput(v, "flatten", false)
+		if fields, set := v["fields"]; set {
+			nested, ok := fields.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("fields must be a mapping")
+			}
+			spec.Fields = nested
+		}
+		return spec, nil
+	}
+	return nil, fmt.Errorf("must be a JMESPath expression or a field spec")
+}
+
+// apply computes the field's value from data
+func (s *fieldSpec) apply(data interface{}, field string) (interface{}, error) {
+	value := data
+	if s.Path != "" {
+		var err error
+		if value, err = search(s.Path, data); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.Filter != "" && value != nil {
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("filter needs an array, got %s", jsonType(value))
+		}
+		expr, err := compile(s.Filter)
+		if err != nil {
+			return nil, err
+		}
+		kept := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			result, err := expr.Search(item)
+			if err != nil {
+				return nil, err
+			}
+			if truthy(result) {
+				kept = append(kept, item)
+			}
+		}
+		value = kept
+	}
+
+	if s.Flatten && value != nil {
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("flatten needs an array, got %s", jsonType(value))
+		}
+		value = flatten(items, nil)
+	}
+
+	if s.Fields != nil && value != nil {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			mapped, err := applyMapping(s.Fields, v, field+".")
+			if err != nil {
+				return nil, err
+			}
+			value = mapped
+		case []interface{}:
+			items := make([]interface{}, len(v))
+			for i, item := range v {
+				mapped, err := applyMapping(s.Fields, item, fmt.Sprintf("%s[%d].", field, i))
+				if err != nil {
+					return nil, err
+				}
+				items[i] = mapped
+			}
+			value = items
+		default:
+			return nil, fmt.Errorf("fields needs an object or array, got %s", jsonType(value))
+		}
+	}
+
+	if s.GroupBy != "" && value != nil {
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("group_by needs an array, got %s", jsonType(value))
+		}
+		grouped, err := groupBy(items, s.GroupBy)
+		if err != nil {
+			return nil, err
+		}
+		value = grouped
+	}
+
+	if value == nil {
+		value = s.Default
+	}
+	if s.Type != "" {
+		return coerce(value, s.Type)
+	}
+	return value, nil
+}
+
+// compile parses a JMESPath expression
+func compile(expression string) (*jmespath.JMESPath, error) {
+	expr, err := jmespath.Compile(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", expression, err)
+	}
+	return expr, nil
+}
+
+// search evaluates a JMESPath expression against data
+func search(expression string, data interface{}) (interface{}, error) {
+	expr, err := compile(expression)
+	if err != nil {
+		return nil, err
+	}
+	result, err := expr.Search(data)
+	if err != nil {
+		return nil, fmt.Errorf("expression %q: %w", expression, err)
+	}
+	return result, nil
+}
+
+// groupBy groups items by the string form of an expression's value. Items
+// whose key is null are dropped.
+func groupBy(items []interface{}, expression string) (map[string]interface{}, error) {
+	expr, err := compile(expression)
+	if err != nil {
+		return nil, err
+	}
+	groups := make(map[string]interface{})
+	for _, item := range items {
+		key, err := expr.Search(item)
+		if err != nil {
+			return nil, err
+		}
+		if key == nil {
+			continue
+		}
+		name, err := coerce(key, "string")
+		if err != nil {
+			return nil, err
+		}
+		group, _ := groups[name.(string)].([]interface{})
+		groups[name.(string)] = append(group, item)
+	}
+	return groups, nil
+}
+
+// flatten appends the non-array values of nested arrays to result
+func flatten(items []interface{}, result []interface{}) []interface{} {
+	if result == nil {
+		result = make([]interface{}, 0, len(items))
+	}
+	for _, item := range items {
+		if nested, ok := item.([]interface{}); ok {
+			result = flatten(nested, result)
+		} else {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// truthy applies JMESPath truthiness: false, null and empty values are false
+func truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	}
+	return true
+}
+
+// coerce converts a JSON value to the named type. Null stays null.
+func coerce(value interface{}, kind string) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	fail := func() (interface{}, error) {
+		return nil, fmt.Errorf("cannot convert %s to %s", jsonType(value), kind)
+	}
+
+	switch kind {
+	case "string":
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		case bool:
+			return strconv.FormatBool(v), nil
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+
+	case "int", "integer":
+		switch v := value.(type) {
+		case float64:
+			return int64(math.Trunc(v)), nil
+		case bool:
+			if v {
+				return int64(1), nil
+			}
+			return int64(0), nil
+		case string:
+			s := strings.TrimSpace(v)
+			if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+				return n, nil
+			}
+			if f, err := strconv.ParseFloat(s, 64); err == nil {
+				return int64(math.Trunc(f)), nil
+			}
+		}
+		return fail()
+
+	case "number", "float":
+		switch v := value.(type) {
+		case float64:
+			return v, nil
+		case bool:
+			if v {
+				return float64(1), nil
+			}
+			return float64(0), nil
+		case string:
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				return f, nil
+			}
+		}
+		return fail()
+
+	case "bool", "boolean":
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case float64:
+			return v != 0, nil
+		case string:
+			if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+				return b, nil
+			}
+		}
+		return fail()
+
+	case "array":
+		switch v := value.(type) {
+		case []interface{}:
+			return v, nil
+		case string:
+			var items []interface{}
+			if err := json.Unmarshal([]byte(v), &items); err == nil {
+				return items, nil
+			}
+		}
+		return []interface{}{value}, nil
+
+	case "object":
+		switch v := value.(type) {
+		case map[string]interface{}:
+			return v, nil
+		case string:
+			var object map[string]interface{}
+			if err := json.Unmarshal([]byte(v), &object); err == nil {
+				return object, nil
+			}
+		}
+		return fail()
+	}
+	return nil, fmt.Errorf("unknown type %s", kind)
+}
+
+// jsonType names the JSON type of a value for error messages
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// normalizeJSON converts a value to plain JSON types through a round trip
+func normalizeJSON(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var result interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,302 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/logimos/conduktr/internal/persistence"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultWASMTimeout bounds a module run when neither the step nor the
+	// configuration sets a timeout
+	defaultWASMTimeout = 30 * time.Second
+	// defaultWASMMemory is the memory a module may use unless configured
+	defaultWASMMemory = 64 << 20
+	// maxWASMOutputBytes caps the JSON a module may write to stdout
+	maxWASMOutputBytes = 10 << 20
+	// maxWASMStderrBytes caps the stderr kept for error messages
+	maxWASMStderrBytes = 64 << 10
+	// wasmPageSize is the size of a WebAssembly memory page
+	wasmPageSize = 65536
+)
+
+// wasmOptions are the step keys read by wasm.run rather than passed to the module
+var wasmOptions = map[string]bool{
+	"module":           true,
+	"timeout":          true,
+	"max_memory_bytes": true,
+}
+
+// WASMConfig sets the modules available by name and the limits of wasm.run
+type WASMConfig struct {
+	// Modules maps names usable as a step's module to .wasm files
+	Modules map[string]string `json:"modules,omitempty"`
+	// MaxMemoryBytes is the default and the most a step may request
+	MaxMemoryBytes int64 `json:"max_memory_bytes,omitempty"`
+	// Timeout is the default run time of a module
+	Timeout time.Duration `json:"timeout,omitempty"`
+}
+
+// WASMAction runs WebAssembly modules in a sandbox. The step input, minus
+// the wasm.run options, is written to the module's stdin as JSON and the
+// module writes its output to stdout as JSON. Modules get no file system,
+// network or environment; the "conduktr" host module lets them log and read
+// workflow variables:
+//
+//	log(level, ptr, len)                          levels 0-3: debug, info, warn, error
+//	get_var(name_ptr, name_len, buf_ptr, buf_len) writes the variable at a dotted
+//	                                              path as JSON and returns its length,
+//	                                              or -1 if it is not set
+//
+// get_var writes nothing when the buffer is too small, so callers retry
+// with the returned length.
+type WASMAction struct {
+	logger *zap.Logger
+	cache  wazero.CompilationCache
+	files  *fileSystem
+
+	mu        sync.RWMutex
+	modules   map[string]string
+	maxMemory int64
+	timeout   time.Duration
+}
+
+// NewWASMAction creates a new WebAssembly action. Modules given by path
+// rather than registered name are read through the registry's file policy.
+func NewWASMAction(logger *zap.Logger, registry *Registry) *WASMAction {
+	return &WASMAction{
+		logger:    logger,
+		cache:     wazero.NewCompilationCache(),
+		files:     registry.files,
+		modules:   make(map[string]string),
+		maxMemory: defaultWASMMemory,
+		timeout:   defaultWASMTimeout,
+	}
+}
+
+// SetConfig registers the configured modules and applies its limits
+func (w *WASMAction) SetConfig(config *WASMConfig) {
+	if config == nil {
+		return
+	}
+	w.mu.Lock()
+	if config.MaxMemoryBytes > 0 {
+		w.maxMemory = config.MaxMemoryBytes
+	}
+	if config.Timeout > 0 {
+		w.timeout = config.Timeout
+	}
+	w.mu.Unlock()
+
+	for name, path := range config.Modules {
+		w.RegisterModule(name, path)
+	}
+}
+
+// RegisterModule makes a module file available to steps by name
+func (w *WASMAction) RegisterModule(name, path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.modules[name] = path
+	w.logger.Info("WASM module registered", zap.String("name", name), zap.String("path", path))
+}
+
+// Execute runs the module with the step input and returns its JSON output
+func (w *WASMAction) Execute(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+	module, ok := input["module"].(string)
+	if !ok || module == "" {
+		return nil, fmt.Errorf("module parameter is required")
+	}
+
+	w.mu.RLock()
+	path, registered := w.modules[module]
+	maxMemory, defaultTimeout := w.maxMemory, w.timeout
+	w.mu.RUnlock()
+
+	// Other modules must be files under the file policy, named in the workflow
+	info, _ := StepInfoFromContext(ctx)
+	if !registered {
+		if info.IsTemplated("module") {
+			return nil, fmt.Errorf("wasm module %s is not registered; templated modules must use a registered name", module)
+		}
+		resolved, err := w.files.resolve(module, false)
+		if err != nil {
+			return nil, fmt.Errorf("wasm module %s is not registered: %w", module, err)
+		}
+		path = resolved
+	}
+
+	timeout, err := durationInput(input, "timeout", defaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+	memory, err := intInput(input, "max_memory_bytes", maxMemory)
+	if err != nil {
+		return nil, err
+	}
+	if memory <= 0 || memory > maxMemory {
+		return nil, fmt.Errorf("max_memory_bytes must be between 1 and %d", maxMemory)
+	}
+
+	payload := make(map[string]interface{}, len(input))
+	for key, value := range input {
+		if !wasmOptions[key] {
+			payload[key] = value
+		}
+	}
+	stdin, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode module input: %w", err)
+	}
+
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read wasm module %s: %w", module, err)
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	pages := uint32((memory + wasmPageSize - 1) / wasmPageSize)
+	runtime := wazero.NewRuntimeWithConfig(runCtx, wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(pages).
+		WithCloseOnContextDone(true).
+		WithCompilationCache(w.cache))
+	defer runtime.Close(context.Background())
+
+	if _, err := wasi_snapshot_preview1.Instantiate(runCtx, runtime); err != nil {
+		return nil, fmt.Errorf("failed to set up WASI: %w", err)
+	}
+	if err := w.instantiateHostModule(runCtx, runtime, module, info.Variables); err != nil {
+		return nil, fmt.Errorf("failed to set up host functions: %w", err)
+	}
+
+	compiled, err := runtime.CompileModule(runCtx, code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile wasm module %s: %w", module, err)
+	}
+
+	stdout := &limitedBuffer{max: maxWASMOutputBytes}
+	stderr := &limitedBuffer{max: maxWASMStderrBytes}
+	config := wazero.NewModuleConfig().
+		WithName(module).
+		WithArgs(module).
+		WithStdin(bytes.NewReader(stdin)).
+		WithStdout(stdout).
+		WithStderr(stderr).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithSysNanosleep().
+		WithRandSource(rand.Reader)
+
+	w.logger.Info("Running WASM module",
+		zap.String("module", module),
+		zap.Duration("timeout", timeout),
+		zap.Int64("max_memory_bytes", memory))
+
+	instance, err := runtime.InstantiateModule(runCtx, compiled, config)
+	if instance != nil {
+		instance.Close(context.Background())
+	}
+	if err != nil {
+		var exitErr *sys.ExitError
+		switch {
+		case errors.Is(runCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil:
+			return nil, fmt.Errorf("wasm module %s timed out after %s", module, timeout)
+		case ctx.Err() != nil:
+			return nil, ctx.Err()
+		case errors.As(err, &exitErr) && exitErr.ExitCode() == 0:
+			// A command module exiting normally
+		default:
+			message := strings.TrimSpace(stderr.String())
+			if message != "" {
+				return nil, fmt.Errorf("wasm module %s failed: %w: %s", module, err, message)
+			}
+			return nil, fmt.Errorf("wasm module %s failed: %w", module, err)
+		}
+	}
+
+	if stdout.truncated {
+		return nil, fmt.Errorf("wasm module %s output exceeds %d bytes", module, maxWASMOutputBytes)
+	}
+	if message := strings.TrimSpace(stderr.String()); message != "" {
+		w.logger.Debug("WASM module stderr", zap.String("module", module), zap.String("stderr", message))
+	}
+
+	output := make(map[string]interface{})
+	if len(bytes.TrimSpace(stdout.buf)) == 0 {
+		return output, nil
+	}
+	var result interface{}
+	if err := json.Unmarshal(stdout.buf, &result); err != nil {
+		return nil, fmt.Errorf("wasm module %s wrote invalid JSON: %w", module, err)
+	}
+	if object, ok := result.(map[string]interface{}); ok {
+		return object, nil
+	}
+	output["result"] = result
+	return output, nil
+}
+
+// instantiateHostModule provides the conduktr log and get_var functions
+func (w *WASMAction) instantiateHostModule(ctx context.Context, runtime wazero.Runtime, module string, variables map[string]interface{}) error {
+	logger := w.logger.With(zap.String("module", module))
+
+	_, err := runtime.NewHostModuleBuilder("conduktr").
+		NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, m api.Module, level, ptr, length uint32) {
+			data, ok := m.Memory().Read(ptr, length)
+			if !ok {
+				return
+			}
+			message := string(data)
+			switch level {
+			case 0:
+				logger.Debug(message)
+			case 1:
+				logger.Info(message)
+			case 2:
+				logger.Warn(message)
+			default:
+				logger.Error(message)
+			}
+		}).
+		Export("log").
+		NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, m api.Module, namePtr, nameLen, bufPtr, bufLen uint32) int32 {
+			name, ok := m.Memory().Read(namePtr, nameLen)
+			if !ok {
+				return -1
+			}
+			value, found, err := persistence.JSONPath(variables, string(name))
+			if err != nil || !found {
+				return -1
+			}
+			data, err := json.Marshal(value)
+			if err != nil {
+				return -1
+			}
+			if uint32(len(data)) <= bufLen && !m.Memory().Write(bufPtr, data) {
+				return -1
+			}
+			return int32(len(data))
+		}).
+		Export("get_var").
+		Instantiate(ctx)
+	return err
+}
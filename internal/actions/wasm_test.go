@@ -0,0 +1,49 @@
+package actions
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWASMModuleLookup(t *testing.T) {
+	registry, root := newTestFiles(t)
+	if err := os.WriteFile(filepath.Join(root, "bad.wasm"), []byte("not wasm"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	outside := filepath.Join(t.TempDir(), "other.wasm")
+	if err := os.WriteFile(outside, []byte("not wasm"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.RegisterWASMModule("registered", outside); err != nil {
+		t.Fatal(err)
+	}
+	action, _ := registry.GetAction("wasm.run")
+
+	tests := []struct {
+		name      string
+		module    string
+		templated bool
+		err       string
+	}{
+		{name: "registered name", module: "registered", err: "failed to compile"},
+		{name: "path under the file policy", module: "bad.wasm", err: "failed to compile"},
+		{name: "path outside the file policy", module: outside, err: "outside the roots"},
+		{name: "templated path", module: "bad.wasm", templated: true, err: "must use a registered name"},
+		{name: "templated registered name", module: "registered", templated: true, err: "failed to compile"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.templated {
+				ctx = WithStepInfo(ctx, StepInfo{Templated: []string{"module"}})
+			}
+			_, err := action.Execute(ctx, map[string]interface{}{"module": tt.module})
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("err = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
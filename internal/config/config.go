@@ -6,6 +6,64 @@ type Config struct {
 	HTTPPort    int    `mapstructure:"http_port"`
 	LogLevel    string `mapstructure:"log_level"`
 	DataDir     string `mapstructure:"data_dir"`
+	// PluginDir holds action plugin executables
+	PluginDir string `mapstructure:"plugin_dir"`
+	// PluginEnvPassthrough names daemon environment variables plugins
+	// receive besides PATH, HOME, TMPDIR, LANG and TZ
+	PluginEnvPassthrough []string `mapstructure:"plugin_env_passthrough"`
+	// WatchDir is the directory the file trigger watches
+	WatchDir string `mapstructure:"watch_dir"`
+
+	// AdminToken enables the endpoints that change workflows; clients send
+	// it as a bearer token. CONDUKTR_ADMIN_TOKEN overrides it.
+	AdminToken string `mapstructure:"admin_token"`
+
+	// StrictTemplates fails steps that reference missing template keys
+	StrictTemplates bool `mapstructure:"strict_templates"`
+
+	Secrets    SecretsConfig    `mapstructure:"secrets"`
+	Encryption EncryptionConfig `mapstructure:"encryption"`
+
+	// ShellPolicy restricts shell.exec steps; nil allows any command
+	ShellPolicy *ShellPolicy `mapstructure:"shell_policy"`
+	// Egress restricts http.request destinations; nil only blocks internal
+	// addresses for templated URLs
+	Egress *EgressPolicy `mapstructure:"egress"`
+	// WASM names WebAssembly modules and limits wasm.run steps
+	WASM *WASMConfig `mapstructure:"wasm"`
+	// Script limits script.run steps
+	Script *ScriptConfig `mapstructure:"script"`
+	// Databases names the connections of db.query and db.exec steps
+	Databases map[string]DatabaseConfig `mapstructure:"databases"`
+	// Email is the SMTP server of email.send steps
+	Email *EmailConfig `mapstructure:"email"`
+	// Files restricts the paths of file and archive steps; nil disables them.
+	// The default lets them read WatchDir.
+	Files *FilePolicy `mapstructure:"files"`
+
+	// Triggers lists event sources started besides the HTTP and file triggers
+	Triggers []TriggerConfig `mapstructure:"triggers"`
+}
+
+// EncryptionConfig configures encryption of persisted instances and approvals
+type EncryptionConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// KeyFile holds "id:base64key" lines, the first being the active key.
+	// CONDUKTR_DATA_KEY overrides it.
+	KeyFile string `mapstructure:"key_file"`
+}
+
+// SecretsConfig configures where {{ secret "name" }} values come from
+type SecretsConfig struct {
+	// Providers lists the backends to consult, in order
+	Providers []string `mapstructure:"providers"`
+	Keystore  string   `mapstructure:"keystore"`
+	KeyFile   string   `mapstructure:"key_file"`
+	Dir       string   `mapstructure:"dir"`
+	EnvPrefix string   `mapstructure:"env_prefix"`
+	// TemplateEnv lists the environment variables {{ env "NAME" }} may
+	// read, as names or prefixes ending in "*"
+	TemplateEnv []string `mapstructure:"template_env"`
 }
 
 // Default returns a configuration with default values
@@ -15,5 +73,15 @@ func Default() *Config {
 		HTTPPort:    8000,
 		LogLevel:    "info",
 		DataDir:     "./data",
+		PluginDir:   "./plugins",
+		WatchDir:    "./watch",
+		Files:       DefaultFilePolicy("./watch"),
+		Secrets: SecretsConfig{
+			Providers: []string{"keystore", "file", "env"},
+			Keystore:  "./data/secrets/keystore.json",
+			KeyFile:   "./data/secrets/keystore.key",
+			Dir:       "/run/secrets",
+			EnvPrefix: "CONDUKTR_SECRET_",
+		},
 	}
 }
@@ -0,0 +1,107 @@
+package config
+
+import "time"
+
+// ShellPolicy restricts what shell.exec steps may run
+type ShellPolicy struct {
+	// AllowedCommands lists binaries by name ("git") or absolute path
+	// ("/usr/bin/git"). Shell mode requires the shell itself to be listed.
+	AllowedCommands []string `mapstructure:"allowed_commands"`
+	// AllowedDirs lists roots that working_dir must be inside
+	AllowedDirs []string `mapstructure:"allowed_dirs"`
+
+	// ClearEnv starts commands with an empty environment instead of the daemon's
+	ClearEnv bool `mapstructure:"clear_env"`
+	// EnvPassthrough lists daemon variables kept when ClearEnv is set; a
+	// trailing * matches a prefix
+	EnvPassthrough []string `mapstructure:"env_passthrough"`
+	// AllowedEnv lists variables steps may set through env; empty allows any
+	AllowedEnv []string `mapstructure:"allowed_env"`
+
+	// RunAs runs commands as another user, given as "user" or "uid:gid"
+	RunAs  string       `mapstructure:"run_as"`
+	Limits *ShellLimits `mapstructure:"limits"`
+
+	// Workflows overrides settings for individual workflows by name
+	Workflows map[string]*ShellPolicy `mapstructure:"workflows"`
+}
+
+// ShellLimits are resource limits applied to each command
+type ShellLimits struct {
+	CPUSeconds  uint64 `mapstructure:"cpu_seconds"`
+	MemoryBytes uint64 `mapstructure:"memory_bytes"`
+	OpenFiles   uint64 `mapstructure:"open_files"`
+}
+
+// EgressPolicy restricts the destinations http.request and overridden
+// email.send servers may connect to
+type EgressPolicy struct {
+	AllowedHosts []string `mapstructure:"allowed_hosts"`
+	AllowedCIDRs []string `mapstructure:"allowed_cidrs"`
+	DeniedHosts  []string `mapstructure:"denied_hosts"`
+	DeniedCIDRs  []string `mapstructure:"denied_cidrs"`
+	// BlockPrivate is "templated" (default), "always" or "never"
+	BlockPrivate string `mapstructure:"block_private"`
+}
+
+// WASMConfig names WebAssembly modules and limits wasm.run steps
+type WASMConfig struct {
+	// Modules maps names usable as a step's module to .wasm files
+	Modules        map[string]string `mapstructure:"modules"`
+	MaxMemoryBytes int64             `mapstructure:"max_memory_bytes"`
+	Timeout        time.Duration     `mapstructure:"timeout"`
+}
+
+// ScriptConfig limits script.run steps
+type ScriptConfig struct {
+	Timeout        time.Duration `mapstructure:"timeout"`
+	MaxMemoryBytes int64         `mapstructure:"max_memory_bytes"`
+	MaxSteps       int64         `mapstructure:"max_steps"`
+}
+
+// DatabaseConfig is a named connection of db.query and db.exec steps
+type DatabaseConfig struct {
+	// Driver is postgres, mysql or sqlite
+	Driver          string        `mapstructure:"driver"`
+	DSN             string        `mapstructure:"dsn"`
+	MaxOpenConns    int           `mapstructure:"max_open_conns"`
+	MaxIdleConns    int           `mapstructure:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`
+	TxIdleTimeout   time.Duration `mapstructure:"tx_idle_timeout"`
+}
+
+// EmailConfig is the SMTP server of email.send steps
+type EmailConfig struct {
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	// TLS is starttls, tls or none
+	TLS                string        `mapstructure:"tls"`
+	InsecureSkipVerify bool          `mapstructure:"insecure_skip_verify"`
+	From               string        `mapstructure:"from"`
+	Timeout            time.Duration `mapstructure:"timeout"`
+}
+
+// FilePolicy restricts the paths of file and archive steps
+type FilePolicy struct {
+	AllowedRoots    []string `mapstructure:"allowed_roots"`
+	ReadOnlyRoots   []string `mapstructure:"read_only_roots"`
+	MaxReadBytes    int64    `mapstructure:"max_read_bytes"`
+	MaxExtractBytes int64    `mapstructure:"max_extract_bytes"`
+}
+
+// DefaultFilePolicy lets file steps read the watched directory and nothing
+// else, so workflows can process the files that trigger them
+func DefaultFilePolicy(watchDir string) *FilePolicy {
+	return &FilePolicy{ReadOnlyRoots: []string{watchDir}}
+}
+
+// TriggerConfig is one entry of the triggers list. Keys other than name,
+// type and enabled are the trigger's own options.
+type TriggerConfig struct {
+	Name    string                 `mapstructure:"name"`
+	Type    string                 `mapstructure:"type"`
+	Enabled *bool                  `mapstructure:"enabled"`
+	Options map[string]interface{} `mapstructure:",remain"`
+}
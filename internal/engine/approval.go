@@ -0,0 +1,371 @@
+package engine
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/logimos/conduktr/internal/actions"
+	"github.com/logimos/conduktr/internal/persistence"
+
+	"go.uber.org/zap"
+)
+
+// ErrNotApprover is returned when the decider is not listed as an approver
+var ErrNotApprover = errors.New("not an approver")
+
+// ErrInvalidApprovalToken is returned when a decision presents a wrong token
+var ErrInvalidApprovalToken = errors.New("invalid approval token")
+
+// ErrDecidedByRequired is returned when a decision does not say who made it
+var ErrDecidedByRequired = errors.New("decided_by is required")
+
+// suspendForApproval saves the paused instance and its pending approval,
+// then sends the approval's notification. Nothing that can carry a token
+// leaves the engine before a decision could be applied.
+func (e *Engine) suspendForApproval(ctx context.Context, workflow *Workflow, instance *persistence.WorkflowInstance, stepIndex int, stepExec persistence.StepExecution, pending *actions.PendingApproval) error {
+	output := stepExec.Output
+	approvalID, _ := output["approval_id"].(string)
+	if approvalID == "" {
+		return fmt.Errorf("approval step '%s' did not return an approval id", stepExec.Name)
+	}
+
+	// Tokens are only kept as hashes; the step output must not carry them
+	tokens, _ := output[actions.ApprovalTokenKey].(map[string]string)
+	delete(output, actions.ApprovalTokenKey)
+	if len(tokens) == 0 {
+		return fmt.Errorf("approval step '%s' did not return an approval token", stepExec.Name)
+	}
+
+	approval := &persistence.Approval{
+		ID:         approvalID,
+		InstanceID: instance.ID,
+		Workflow:   workflow.Name,
+		Step:       stepExec.Name,
+		StepIndex:  stepIndex,
+		Status:     "pending",
+		CreatedAt:  time.Now(),
+	}
+	approval.Message, _ = output["message"].(string)
+	if approvers, ok := output["approvers"].([]string); ok {
+		approval.Approvers = approvers
+	}
+	if expiresAt, ok := output["expires_at"].(string); ok {
+		if t, err := time.Parse(time.RFC3339, expiresAt); err == nil {
+			approval.ExpiresAt = t
+		}
+	}
+	if token, ok := tokens[""]; ok {
+		approval.TokenHash = hashApprovalToken(token)
+	} else {
+		approval.TokenHashes = make(map[string]string, len(tokens))
+		for approver, token := range tokens {
+			approval.TokenHashes[approver] = hashApprovalToken(token)
+		}
+	}
+
+	stepExec.Status = "waiting"
+	instance.Status = "paused"
+	instance.Steps = append(instance.Steps, stepExec)
+	instance.Context.SetStepState(stepExec.Name, &persistence.StepState{Status: "waiting", Output: output})
+
+	// The instance must be waiting before the approval can be decided
+	if err := e.persistence.SaveWorkflowInstance(instance); err != nil {
+		return fmt.Errorf("failed to save paused workflow instance: %w", err)
+	}
+	if err := e.persistence.SaveApproval(approval); err != nil {
+		return fmt.Errorf("failed to save approval: %w", err)
+	}
+
+	e.logger.Info("Workflow paused for approval",
+		zap.String("instance_id", instance.ID),
+		zap.String("workflow", workflow.Name),
+		zap.String("step", stepExec.Name),
+		zap.String("approval_id", approvalID))
+
+	if pending != nil {
+		if err := pending.Notify(ctx); err != nil {
+			e.logger.Warn("Approval notification failed",
+				zap.String("approval_id", approvalID),
+				zap.Error(err))
+		}
+	}
+
+	return nil
+}
+
+// GetApproval retrieves an approval by ID
+func (e *Engine) GetApproval(approvalID string) (*persistence.Approval, error) {
+	approval, err := e.persistence.GetApproval(approvalID)
+	if err != nil {
+		return nil, err
+	}
+	return withoutToken(approval), nil
+}
+
+// ListApprovals returns all approvals, optionally filtered by status
+func (e *Engine) ListApprovals(status string) ([]*persistence.Approval, error) {
+	approvals, err := e.persistence.ListApprovals()
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := make([]*persistence.Approval, 0, len(approvals))
+	for _, approval := range approvals {
+		if status == "" || approval.Status == status {
+			filtered = append(filtered, withoutToken(approval))
+		}
+	}
+	return filtered, nil
+}
+
+// DecideApproval records an approve or reject decision and resumes the
+// paused instance. The token is one sent with the approval request; when the
+// approval lists approvers it names the decider, and decidedBy may be empty.
+func (e *Engine) DecideApproval(approvalID string, approved bool, decidedBy, comment, token string) (*persistence.Approval, error) {
+	return e.decideApproval(approvalID, approved, comment, func(approval *persistence.Approval) (string, error) {
+		hash := []byte(hashApprovalToken(token))
+		for approver, tokenHash := range approval.TokenHashes {
+			if subtle.ConstantTimeCompare(hash, []byte(tokenHash)) != 1 {
+				continue
+			}
+			if decidedBy != "" && decidedBy != approver {
+				return "", fmt.Errorf("%q holds the token of %q for %s: %w", decidedBy, approver, approvalID, ErrNotApprover)
+			}
+			return approver, nil
+		}
+		if approval.TokenHash == "" || subtle.ConstantTimeCompare(hash, []byte(approval.TokenHash)) != 1 {
+			return "", fmt.Errorf("%s: %w", approvalID, ErrInvalidApprovalToken)
+		}
+		return decidedBy, nil
+	})
+}
+
+// DecideApprovalAsAdmin records a decision made by an operator who has
+// already authenticated, without the approval's token
+func (e *Engine) DecideApprovalAsAdmin(approvalID string, approved bool, decidedBy, comment string) (*persistence.Approval, error) {
+	return e.decideApproval(approvalID, approved, comment, func(*persistence.Approval) (string, error) {
+		return decidedBy, nil
+	})
+}
+
+// decideApproval records a decision the authorize check accepts; authorize
+// returns who decided
+func (e *Engine) decideApproval(approvalID string, approved bool, comment string, authorize func(*persistence.Approval) (string, error)) (*persistence.Approval, error) {
+	e.approvalMu.Lock()
+	defer e.approvalMu.Unlock()
+
+	approval, err := e.persistence.GetApproval(approvalID)
+	if err != nil {
+		return nil, err
+	}
+
+	decidedBy, err := authorize(approval)
+	if err != nil {
+		return withoutToken(approval), err
+	}
+	if decidedBy == "" {
+		return withoutToken(approval), fmt.Errorf("%s: %w", approvalID, ErrDecidedByRequired)
+	}
+
+	if approval.Status != "pending" {
+		return withoutToken(approval), fmt.Errorf("approval %s is already %s", approvalID, approval.Status)
+	}
+
+	if len(approval.Approvers) > 0 && !containsString(approval.Approvers, decidedBy) {
+		return withoutToken(approval), fmt.Errorf("%q for %s: %w", decidedBy, approvalID, ErrNotApprover)
+	}
+
+	now := time.Now()
+	approval.DecidedAt = &now
+	approval.DecidedBy = decidedBy
+	approval.Comment = comment
+
+	switch {
+	case !approval.ExpiresAt.IsZero() && now.After(approval.ExpiresAt):
+		approval.Status = "expired"
+	case approved:
+		approval.Status = "approved"
+	default:
+		approval.Status = "rejected"
+	}
+
+	if err := e.persistence.SaveApproval(approval); err != nil {
+		return withoutToken(approval), fmt.Errorf("failed to save approval: %w", err)
+	}
+
+	e.logger.Info("Approval decided",
+		zap.String("approval_id", approvalID),
+		zap.String("status", approval.Status),
+		zap.String("decided_by", decidedBy))
+
+	if approval.Status == "expired" {
+		e.resumeAfterApproval(context.Background(), approval)
+		return withoutToken(approval), fmt.Errorf("approval %s has expired", approvalID)
+	}
+
+	go e.resumeAfterApproval(context.Background(), approval)
+
+	return withoutToken(approval), nil
+}
+
+// ExpireApprovals marks overdue pending approvals as expired and fails their instances
+func (e *Engine) ExpireApprovals() int {
+	e.approvalMu.Lock()
+	defer e.approvalMu.Unlock()
+
+	approvals, err := e.persistence.ListApprovals()
+	if err != nil {
+		e.logger.Error("Failed to list approvals", zap.Error(err))
+		return 0
+	}
+
+	now := time.Now()
+	expired := 0
+	for _, approval := range approvals {
+		if approval.Status != "pending" || approval.ExpiresAt.IsZero() || now.Before(approval.ExpiresAt) {
+			continue
+		}
+
+		approval.Status = "expired"
+		approval.DecidedAt = &now
+		if err := e.persistence.SaveApproval(approval); err != nil {
+			e.logger.Error("Failed to save expired approval", zap.String("approval_id", approval.ID), zap.Error(err))
+			continue
+		}
+
+		e.resumeAfterApproval(context.Background(), approval)
+		expired++
+	}
+
+	return expired
+}
+
+// resumeAfterApproval applies a decided approval to its instance and continues execution
+func (e *Engine) resumeAfterApproval(ctx context.Context, approval *persistence.Approval) {
+	logger := e.logger.With(
+		zap.String("approval_id", approval.ID),
+		zap.String("instance_id", approval.InstanceID))
+
+	instance, err := e.persistence.GetWorkflowInstance(approval.InstanceID)
+	if err != nil {
+		logger.Error("Failed to load paused instance", zap.Error(err))
+		return
+	}
+
+	workflow, exists := e.GetWorkflowVersion(instance.WorkflowName, instance.WorkflowVersion)
+	if !exists {
+		logger.Error("Workflow version for paused instance is no longer registered",
+			zap.String("workflow", instance.WorkflowName),
+			zap.String("version", instance.WorkflowVersion))
+		return
+	}
+
+	if instance.Context == nil {
+		instance.Context = &persistence.EventContext{}
+	}
+	if instance.Context.Variables == nil {
+		instance.Context.Variables = make(map[string]interface{})
+	}
+
+	// Locate the waiting step execution
+	stepExec := -1
+	for i := len(instance.Steps) - 1; i >= 0; i-- {
+		if instance.Steps[i].Name == approval.Step && instance.Steps[i].Status == "waiting" {
+			stepExec = i
+			break
+		}
+	}
+	if stepExec < 0 {
+		logger.Error("No waiting step found for approval", zap.String("step", approval.Step))
+		return
+	}
+
+	step := &instance.Steps[stepExec]
+	if step.Output == nil {
+		step.Output = make(map[string]interface{})
+	}
+	step.Output["status"] = approval.Status
+	step.Output["approved"] = approval.Status == "approved"
+	step.Output["decided_by"] = approval.DecidedBy
+	step.Output["comment"] = approval.Comment
+	if approval.DecidedAt != nil {
+		step.Output["decided_at"] = approval.DecidedAt.Format(time.RFC3339)
+	}
+	instance.Context.Variables[step.Name] = step.Output
+	state := &persistence.StepState{Status: "completed", Output: step.Output}
+	instance.Context.SetStepState(step.Name, state)
+
+	now := time.Now()
+	step.EndTime = &now
+
+	if approval.Status != "approved" {
+		step.Status = "failed"
+		step.Error = fmt.Sprintf("approval %s", approval.Status)
+		if approval.DecidedBy != "" {
+			step.Error = fmt.Sprintf("approval %s by %s", approval.Status, approval.DecidedBy)
+		}
+		state.Status = "failed"
+		state.Error = step.Error
+		instance.Status = "failed"
+		instance.Error = fmt.Sprintf("Step '%s' failed: %s", step.Name, step.Error)
+		instance.EndTime = &now
+
+		if err := e.persistence.SaveWorkflowInstance(instance); err != nil {
+			logger.Error("Failed to save workflow instance", zap.Error(err))
+		}
+		return
+	}
+
+	// Extract the approval step's declared outputs now that it has a decision
+	if approval.StepIndex < len(workflow.Workflow) {
+		if declared := workflow.Workflow[approval.StepIndex].Outputs; len(declared) > 0 {
+			outputs, err := e.resolveOutputs(declared, instance.Context)
+			if err != nil {
+				logger.Warn("Failed to resolve approval step outputs", zap.Error(err))
+			}
+			step.Outputs = outputs
+			state.Outputs = outputs
+		}
+	}
+
+	step.Status = "completed"
+	instance.Status = "running"
+	if err := e.persistence.SaveWorkflowInstance(instance); err != nil {
+		logger.Error("Failed to save workflow instance", zap.Error(err))
+	}
+
+	logger.Info("Resuming workflow after approval", zap.String("workflow", workflow.Name))
+
+	if err := e.runSteps(ctx, workflow, instance, approval.StepIndex+1); err != nil {
+		logger.Error("Workflow execution failed after approval", zap.Error(err))
+	}
+}
+
+// hashApprovalToken returns the stored form of an approval token
+func hashApprovalToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// withoutToken returns a copy of an approval without its token hash
+func withoutToken(approval *persistence.Approval) *persistence.Approval {
+	public := *approval
+	public.TokenHash = ""
+	public.TokenHashes = nil
+	return &public
+}
+
+// containsString reports whether list contains value
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
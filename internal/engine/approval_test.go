@@ -0,0 +1,272 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/logimos/conduktr/internal/actions"
+	"github.com/logimos/conduktr/internal/persistence"
+
+	"go.uber.org/zap"
+)
+
+const approvalWorkflow = `name: deploy
+on:
+  event: deploy.requested
+workflow:
+  - name: gate
+    action: approval.request
+    message: Deploy?
+    approvers: [alice]
+    notify:
+      action: test.notify
+      url: "{{ .event.payload.hook }}"
+      channel: ops
+`
+
+// notifyRecorder captures what an approval notification received
+type notifyRecorder struct {
+	input map[string]interface{}
+	info  actions.StepInfo
+}
+
+func (n *notifyRecorder) Execute(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+	n.input = input
+	n.info, _ = actions.StepInfoFromContext(ctx)
+	return nil, nil
+}
+
+func startApproval(t *testing.T) (*Engine, *notifyRecorder, string) {
+	t.Helper()
+	e := NewEngine(zap.NewNop(), persistence.NewJSONPersistence(t.TempDir()))
+	recorder := &notifyRecorder{}
+	e.Registry().RegisterAction("test.notify", recorder)
+
+	workflow := mustLoadWorkflow(t, approvalWorkflow)
+	eventCtx := &persistence.EventContext{
+		Event: &persistence.Event{
+			Type:    "deploy.requested",
+			Payload: map[string]interface{}{"hook": "http://hooks.example.com/approve"},
+		},
+		Variables: map[string]interface{}{},
+	}
+	instanceID, err := e.ExecuteWorkflow(context.Background(), workflow, eventCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.RegisterWorkflow(workflow); err != nil {
+		t.Fatal(err)
+	}
+	return e, recorder, instanceID
+}
+
+func approvalToken(t *testing.T, recorder *notifyRecorder) (string, string) {
+	t.Helper()
+	body, _ := recorder.input["body"].(map[string]interface{})
+	approveURL, err := url.Parse(body["approve_url"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body["approval_id"].(string), approveURL.Query().Get("token")
+}
+
+func TestApprovalNotifyKeepsNestedTemplatedFlags(t *testing.T) {
+	_, recorder, _ := startApproval(t)
+	if !recorder.info.IsTemplated("url") {
+		t.Errorf("notify templated = %v, want url", recorder.info.Templated)
+	}
+	if recorder.info.IsTemplated("channel") {
+		t.Errorf("notify templated = %v, channel is static", recorder.info.Templated)
+	}
+}
+
+func TestDecideApprovalRequiresToken(t *testing.T) {
+	e, recorder, instanceID := startApproval(t)
+	approvalID, token := approvalToken(t, recorder)
+	if token == "" {
+		t.Fatal("notification carries no token")
+	}
+
+	instance, err := e.GetWorkflowInstance(instanceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range instance.Steps {
+		if _, ok := step.Output[actions.ApprovalTokenKey]; ok {
+			t.Fatal("token stored in the step output")
+		}
+	}
+	if approval, _ := e.GetApproval(approvalID); approval.TokenHash != "" || approval.TokenHashes != nil {
+		t.Error("GetApproval exposes the token hash")
+	}
+
+	tests := []struct {
+		name      string
+		decidedBy string
+		token     string
+		want      error
+	}{
+		{name: "missing token", decidedBy: "alice", token: "", want: ErrInvalidApprovalToken},
+		{name: "wrong token", decidedBy: "alice", token: strings.Repeat("0", 64), want: ErrInvalidApprovalToken},
+		{name: "not an approver", decidedBy: "mallory", token: token, want: ErrNotApprover},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := e.DecideApproval(approvalID, true, tt.decidedBy, "", tt.token); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	approval, err := e.DecideApproval(approvalID, false, "alice", "not today", token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if approval.Status != "rejected" || approval.DecidedBy != "alice" {
+		t.Errorf("approval = %+v", approval)
+	}
+	waitForStatus(t, e, instanceID, "failed")
+}
+
+func TestDecideApprovalAsAdmin(t *testing.T) {
+	e, recorder, instanceID := startApproval(t)
+	approvalID, _ := approvalToken(t, recorder)
+
+	if _, err := e.DecideApprovalAsAdmin(approvalID, false, "mallory", ""); !errors.Is(err, ErrNotApprover) {
+		t.Fatalf("err = %v, want ErrNotApprover", err)
+	}
+	if _, err := e.DecideApprovalAsAdmin(approvalID, false, "alice", ""); err != nil {
+		t.Fatal(err)
+	}
+	waitForStatus(t, e, instanceID, "failed")
+}
+
+// decidingNotifier approves as soon as it is notified, like a fast approver
+type decidingNotifier struct {
+	engine *Engine
+	err    error
+}
+
+func (n *decidingNotifier) Execute(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+	body := input["body"].(map[string]interface{})
+	_, n.err = n.engine.DecideApproval(body["approval_id"].(string), true, "", "", body["token"].(string))
+	return nil, nil
+}
+
+func TestApprovalDecidedDuringNotification(t *testing.T) {
+	e := NewEngine(zap.NewNop(), persistence.NewJSONPersistence(t.TempDir()))
+	notifier := &decidingNotifier{engine: e}
+	e.Registry().RegisterAction("test.notify", notifier)
+	workflow := mustLoadWorkflow(t, approvalWorkflow)
+	if err := e.RegisterWorkflow(workflow); err != nil {
+		t.Fatal(err)
+	}
+
+	instanceID, err := e.ExecuteWorkflow(context.Background(), workflow, &persistence.EventContext{
+		Event:     &persistence.Event{Type: "deploy.requested", Payload: map[string]interface{}{}},
+		Variables: map[string]interface{}{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if notifier.err != nil {
+		t.Fatal(notifier.err)
+	}
+	waitForStatus(t, e, instanceID, "completed")
+
+	instance, err := e.GetWorkflowInstance(instanceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := instance.Steps[0].Output["decided_by"]; got != "alice" {
+		t.Errorf("decided_by = %v, want alice", got)
+	}
+}
+
+func TestApprovalNotSentWhenSaveFails(t *testing.T) {
+	dir := t.TempDir()
+	// A file where the approvals directory belongs makes saving fail
+	if err := os.WriteFile(filepath.Join(dir, "approvals"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	e := NewEngine(zap.NewNop(), persistence.NewJSONPersistence(dir))
+	recorder := &notifyRecorder{}
+	e.Registry().RegisterAction("test.notify", recorder)
+
+	instanceID, err := e.ExecuteWorkflow(context.Background(), mustLoadWorkflow(t, approvalWorkflow), &persistence.EventContext{
+		Event:     &persistence.Event{Type: "deploy.requested", Payload: map[string]interface{}{}},
+		Variables: map[string]interface{}{},
+	})
+	if err == nil {
+		t.Fatal("want an error when the approval cannot be saved")
+	}
+	if recorder.input != nil {
+		t.Error("notification sent for an unsaved approval")
+	}
+	if instance, err := e.GetWorkflowInstance(instanceID); err != nil || instance.Status != "failed" {
+		t.Errorf("instance = %+v, %v; want failed", instance, err)
+	}
+}
+
+// tokenRecorder collects the token each approver is sent
+type tokenRecorder struct {
+	tokens map[string]string
+	id     string
+}
+
+func (n *tokenRecorder) Execute(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+	body := input["body"].(map[string]interface{})
+	n.id = body["approval_id"].(string)
+	n.tokens[body["approver"].(string)] = body["token"].(string)
+	return nil, nil
+}
+
+func TestDecideApprovalTakesDeciderFromToken(t *testing.T) {
+	e := NewEngine(zap.NewNop(), persistence.NewJSONPersistence(t.TempDir()))
+	recorder := &tokenRecorder{tokens: map[string]string{}}
+	e.Registry().RegisterAction("test.notify", recorder)
+	workflow := mustLoadWorkflow(t, strings.Replace(approvalWorkflow, "[alice]", "[alice, bob]", 1))
+	if err := e.RegisterWorkflow(workflow); err != nil {
+		t.Fatal(err)
+	}
+	instanceID, err := e.ExecuteWorkflow(context.Background(), workflow, &persistence.EventContext{
+		Event:     &persistence.Event{Type: "deploy.requested", Payload: map[string]interface{}{}},
+		Variables: map[string]interface{}{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recorder.tokens) != 2 || recorder.tokens["alice"] == recorder.tokens["bob"] {
+		t.Fatalf("tokens = %v, want one per approver", recorder.tokens)
+	}
+
+	if _, err := e.DecideApproval(recorder.id, true, "alice", "", recorder.tokens["bob"]); !errors.Is(err, ErrNotApprover) {
+		t.Fatalf("err = %v, want ErrNotApprover", err)
+	}
+	approval, err := e.DecideApproval(recorder.id, false, "", "", recorder.tokens["bob"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if approval.DecidedBy != "bob" {
+		t.Errorf("decided_by = %q, want bob", approval.DecidedBy)
+	}
+	waitForStatus(t, e, instanceID, "failed")
+}
+
+func waitForStatus(t *testing.T, e *Engine, instanceID, status string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if instance, err := e.GetWorkflowInstance(instanceID); err == nil && instance.Status == status {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("instance %s did not reach %s", instanceID, status)
+}
@@ -0,0 +1,284 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// disabledSuffix marks workflow files that are kept on disk but not loaded
+const disabledSuffix = ".disabled"
+
+var (
+	// ErrWorkflowNotFound is returned when no definition exists for a workflow name
+	ErrWorkflowNotFound = errors.New("workflow not found")
+	// ErrWorkflowExists is returned when creating a workflow whose name is taken
+	ErrWorkflowExists = errors.New("workflow already exists")
+)
+
+// workflowNamePattern restricts names that are used as file names
+var workflowNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// WorkflowDefinition is a workflow file together with its parsed form
+type WorkflowDefinition struct {
+	Name     string    `json:"name"`
+	File     string    `json:"file"`
+	Enabled  bool      `json:"enabled"`
+	Source   string    `json:"source"`
+	Workflow *Workflow `json:"workflow,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// GetDefinition returns the source and parsed form of a workflow
+func (l *Loader) GetDefinition(name string) (*WorkflowDefinition, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	path, enabled, err := l.findFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workflow file: %w", err)
+	}
+
+	definition := &WorkflowDefinition{
+		Name:    name,
+		File:    path,
+		Enabled: enabled,
+		Source:  string(data),
+	}
+
+	workflow, err := LoadWorkflowFromYAML(data)
+	if err != nil {
+		definition.Error = err.Error()
+	} else {
+		definition.Workflow = workflow
+	}
+
+	return definition, nil
+}
+
+// CreateDefinition validates a new workflow, writes it to the workflow
+// directory and registers it
+func (l *Loader) CreateDefinition(data []byte) (*Workflow, error) {
+	workflow, err := parseDefinition(data)
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, _, err := l.findFile(workflow.Name); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrWorkflowExists, workflow.Name)
+	}
+
+	path := filepath.Join(l.dir, workflow.Name+".yaml")
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("%w: file %s already exists", ErrWorkflowExists, path)
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return nil, err
+	}
+
+	if err := l.applyLocked(path); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return workflow, nil
+}
+
+// UpdateDefinition replaces the definition of an existing workflow
+func (l *Loader) UpdateDefinition(name string, data []byte) (*Workflow, error) {
+	workflow, err := parseDefinition(data)
+	if err != nil {
+		return nil, err
+	}
+	if workflow.Name != name {
+		return nil, fmt.Errorf("workflow name '%s' does not match '%s'", workflow.Name, name)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	path, enabled, err := l.findFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	previous, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workflow file: %w", err)
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return nil, err
+	}
+
+	if !enabled {
+		return workflow, nil
+	}
+	if err := l.applyLocked(path); err != nil {
+		// Put the previous definition back so the file matches what runs
+		if restoreErr := writeFileAtomic(path, previous); restoreErr != nil {
+			return nil, fmt.Errorf("%v; restoring previous definition failed: %w", err, restoreErr)
+		}
+		delete(l.rejected, path)
+		l.reloadLocked()
+		return nil, err
+	}
+	return workflow, nil
+}
+
+// DeleteDefinition removes a workflow file and unregisters the workflow
+func (l *Loader) DeleteDefinition(name string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	path, _, err := l.findFile(name)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to delete workflow file: %w", err)
+	}
+
+	_, err = l.reloadLocked()
+	return err
+}
+
+// SetDefinitionEnabled enables or disables a workflow. Disabled workflow
+// files stay on disk with a .disabled suffix and are not loaded.
+func (l *Loader) SetDefinitionEnabled(name string, enabled bool) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	path, current, err := l.findFile(name)
+	if err != nil {
+		return err
+	}
+	if current == enabled {
+		return nil
+	}
+
+	target := path + disabledSuffix
+	if enabled {
+		target = strings.TrimSuffix(path, disabledSuffix)
+	}
+
+	if err := os.Rename(path, target); err != nil {
+		return fmt.Errorf("failed to rename workflow file: %w", err)
+	}
+
+	if enabled {
+		return l.applyLocked(target)
+	}
+	_, err = l.reloadLocked()
+	return err
+}
+
+// applyLocked reloads the directory and reports whether the given file was
+// rejected. A previous rejection of the same content is forgotten first, so
+// it is reported again rather than deduplicated.
+func (l *Loader) applyLocked(path string) error {
+	delete(l.rejected, path)
+	changes, err := l.reloadLocked()
+	if err != nil {
+		return err
+	}
+
+	for _, change := range changes {
+		if change.File == path && change.Action == "rejected" {
+			return fmt.Errorf("workflow rejected: %s", change.Error)
+		}
+	}
+	return nil
+}
+
+// findFile locates the file defining a workflow, including disabled files.
+// The caller must hold l.mu.
+func (l *Loader) findFile(name string) (string, bool, error) {
+	for path, loaded := range l.files {
+		if loaded.name == name {
+			return path, true, nil
+		}
+	}
+
+	var found string
+	err := filepath.Walk(l.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || found != "" {
+			return err
+		}
+		if !strings.HasSuffix(path, disabledSuffix) || !isWorkflowFile(strings.TrimSuffix(path, disabledSuffix)) {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		var header struct {
+			Name string `yaml:"name"`
+		}
+		if yaml.Unmarshal(data, &header) == nil && header.Name == name {
+			found = path
+		}
+		return nil
+	})
+	if err != nil {
+		return "", false, fmt.Errorf("failed to scan workflow directory: %w", err)
+	}
+
+	if found == "" {
+		return "", false, fmt.Errorf("%w: %s", ErrWorkflowNotFound, name)
+	}
+	return found, false, nil
+}
+
+// parseDefinition validates workflow YAML and its name
+func parseDefinition(data []byte) (*Workflow, error) {
+	workflow, err := LoadWorkflowFromYAML(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if !workflowNamePattern.MatchString(workflow.Name) {
+		return nil, fmt.Errorf("workflow name '%s' may only contain letters, digits, '.', '_' and '-'", workflow.Name)
+	}
+
+	return workflow, nil
+}
+
+// writeFileAtomic writes data to a temporary file and renames it into place
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".workflow-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write workflow file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write workflow file: %w", err)
+	}
+
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to set workflow file permissions: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write workflow file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/logimos/conduktr/internal/actions"
+	"github.com/logimos/conduktr/internal/persistence"
+
+	"go.uber.org/zap"
+)
+
+const echoWorkflow = `name: greet
+on:
+  event: greet.requested
+workflow:
+  - name: say
+    action: shell.exec
+    command: echo hello
+`
+
+const removeWorkflow = `name: greet
+on:
+  event: greet.requested
+workflow:
+  - name: say
+    action: shell.exec
+    command: rm -rf /tmp/nothing
+`
+
+func newTestLoader(t *testing.T) (*Loader, string) {
+	t.Helper()
+	dir := t.TempDir()
+	e := NewEngine(zap.NewNop(), persistence.NewJSONPersistence(t.TempDir()))
+	e.SetShellPolicy(&actions.ShellPolicy{AllowedCommands: []string{"echo"}})
+	return NewLoader(e, zap.NewNop(), dir), dir
+}
+
+func TestUpdateDefinitionRestoresRejectedDefinition(t *testing.T) {
+	loader, dir := newTestLoader(t)
+	if _, err := loader.CreateDefinition([]byte(echoWorkflow)); err != nil {
+		t.Fatalf("CreateDefinition: %v", err)
+	}
+	path := filepath.Join(dir, "greet.yaml")
+
+	// Rejected twice: the second attempt must not slip through either
+	for i := 0; i < 2; i++ {
+		if _, err := loader.UpdateDefinition("greet", []byte(removeWorkflow)); err == nil {
+			t.Fatalf("attempt %d: UpdateDefinition accepted a command outside the policy", i+1)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != echoWorkflow {
+			t.Fatalf("attempt %d: file not restored:\n%s", i+1, data)
+		}
+	}
+
+	updated := strings.Replace(echoWorkflow, "echo hello", "echo bye", 1)
+	if _, err := loader.UpdateDefinition("greet", []byte(updated)); err != nil {
+		t.Fatalf("UpdateDefinition: %v", err)
+	}
+	definition, err := loader.GetDefinition("greet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if definition.Source != updated {
+		t.Errorf("source = %q, want the update", definition.Source)
+	}
+}
+
+func TestCreateDefinitionRemovesRejectedFile(t *testing.T) {
+	loader, dir := newTestLoader(t)
+
+	// Posting the same invalid definition again must fail the same way
+	for attempt := 1; attempt <= 2; attempt++ {
+		if _, err := loader.CreateDefinition([]byte(removeWorkflow)); err == nil {
+			t.Fatalf("attempt %d: CreateDefinition accepted a command outside the policy", attempt)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "greet.yaml")); !os.IsNotExist(err) {
+			t.Errorf("attempt %d: rejected workflow file left behind: %v", attempt, err)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package engine
+
+import (
+	"context"
+
+	"github.com/logimos/conduktr/internal/actions"
+	"github.com/logimos/conduktr/internal/persistence"
+
+	"go.uber.org/zap"
+)
+
+// connectEventAction lets event.emit steps start workflows on this engine
+func (e *Engine) connectEventAction() {
+	if action, err := e.registry.GetAction("event.emit"); err == nil {
+		if emit, ok := action.(*actions.EventAction); ok {
+			emit.SetRouter(e)
+		}
+	}
+}
+
+// RouteEvent starts the workflow subscribed to an event emitted by a step.
+// Without wait the instance runs in the background, outliving the step.
+func (e *Engine) RouteEvent(ctx context.Context, event *persistence.Event, wait bool) (*actions.RoutedEvent, error) {
+	workflow, exists := e.GetWorkflowForEvent(event.Type)
+	if !exists {
+		return nil, nil
+	}
+
+	variables := make(map[string]interface{}, len(event.Payload))
+	for key, value := range event.Payload {
+		variables[key] = value
+	}
+	instance, err := e.startInstance(workflow, &persistence.EventContext{
+		Event:     event,
+		Variables: variables,
+	})
+	if err != nil {
+		return nil, err
+	}
+	routed := &actions.RoutedEvent{Workflow: workflow.Name, InstanceID: instance.ID}
+
+	if !wait {
+		go func() {
+			if err := e.runSteps(context.WithoutCancel(ctx), workflow, instance, 0); err != nil {
+				e.logger.Error("Workflow for emitted event failed",
+					zap.String("event", event.Type),
+					zap.String("instance_id", instance.ID),
+					zap.Error(err))
+			}
+		}()
+		return routed, nil
+	}
+
+	err = e.runSteps(ctx, workflow, instance, 0)
+	routed.Status = instance.Status
+	routed.Outputs = instance.Outputs
+	return routed, err
+}
@@ -2,7 +2,10 @@ package engine
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/logimos/conduktr/internal/actions"
@@ -18,56 +21,274 @@ type Engine struct {
 	registry    *actions.Registry
 	persistence persistence.Store
 	workflows   map[string]*Workflow
+	versions    map[string][]*WorkflowVersion
+	mu          sync.RWMutex
+	approvalMu  sync.Mutex
+	strict      bool
+	secrets     persistence.SecretResolver
+	env         persistence.EnvResolver
+	shellPolicy *actions.ShellPolicy
+}
+
+// ErrVersionConflict is returned when a registered workflow version is
+// reused with different content
+var ErrVersionConflict = errors.New("workflow version already registered with different content")
+
+// WorkflowVersion records a registered revision of a workflow definition
+type WorkflowVersion struct {
+	Version      string    `json:"version"`
+	Checksum     string    `json:"checksum"`
+	RegisteredAt time.Time `json:"registered_at"`
+	Workflow     *Workflow `json:"-"`
 }
 
 // NewEngine creates a new workflow engine
 func NewEngine(logger *zap.Logger, store persistence.Store) *Engine {
-	return &Engine{
+	e := &Engine{
 		logger:      logger,
 		registry:    actions.NewRegistry(logger),
 		persistence: store,
 		workflows:   make(map[string]*Workflow),
+		versions:    make(map[string][]*WorkflowVersion),
 	}
+	e.connectEventAction()
+	e.restoreVersions()
+	return e
+}
+
+// SetStrictTemplates makes missing template keys fail steps in every workflow
+func (e *Engine) SetStrictTemplates(strict bool) {
+	e.strict = strict
+}
+
+// SetSecrets sets the resolver used for {{ secret "name" }} references
+func (e *Engine) SetSecrets(resolver persistence.SecretResolver) {
+	e.secrets = resolver
+}
+
+// SetEnv sets the resolver used for {{ env "NAME" }} references; without
+// one templates cannot read the environment
+func (e *Engine) SetEnv(resolver persistence.EnvResolver) {
+	e.env = resolver
+}
+
+// RegisterTemplateFunction makes a Go function available to step templates.
+// Functions are shared by all engines in the process.
+func (e *Engine) RegisterTemplateFunction(name string, fn interface{}) error {
+	return persistence.RegisterTemplateFunction(name, fn)
+}
+
+// Registry returns the action registry, so plugins can add actions
+func (e *Engine) Registry() *actions.Registry {
+	return e.registry
 }
 
-// RegisterWorkflow registers a workflow with the engine
-func (e *Engine) RegisterWorkflow(workflow *Workflow) {
+// RegisterWorkflow registers a workflow with the engine, keeping earlier
+// versions available for instances that started on them. A version that is
+// already registered may not change content.
+func (e *Engine) RegisterWorkflow(workflow *Workflow) error {
+	if err := workflow.ensureVersion(); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var existing *WorkflowVersion
+	for _, v := range e.versions[workflow.Name] {
+		if v.Version == workflow.Version {
+			existing = v
+			break
+		}
+	}
+	if existing != nil && existing.Checksum != workflow.Checksum {
+		return fmt.Errorf("%w: %s version %s", ErrVersionConflict, workflow.Name, workflow.Version)
+	}
+
+	// Drop the previous registration if the workflow moved to another event
+	for event, active := range e.workflows {
+		if active.Name == workflow.Name && event != workflow.On.Event {
+			delete(e.workflows, event)
+		}
+	}
 	e.workflows[workflow.On.Event] = workflow
+
+	if existing != nil {
+		existing.Workflow = workflow
+	} else {
+		version := &WorkflowVersion{
+			Version:      workflow.Version,
+			Checksum:     workflow.Checksum,
+			RegisteredAt: time.Now(),
+			Workflow:     workflow,
+		}
+		e.versions[workflow.Name] = append(e.versions[workflow.Name], version)
+		e.saveVersion(version)
+	}
+
 	e.logger.Info("Workflow registered",
 		zap.String("name", workflow.Name),
+		zap.String("version", workflow.Version),
 		zap.String("event", workflow.On.Event))
+	return nil
+}
+
+// UnregisterWorkflow removes the active definition of a workflow. Earlier
+// versions stay available so paused instances can still resume.
+func (e *Engine) UnregisterWorkflow(name string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	removed := false
+	for event, workflow := range e.workflows {
+		if workflow.Name == name {
+			delete(e.workflows, event)
+			removed = true
+		}
+	}
+
+	if removed {
+		e.logger.Info("Workflow unregistered", zap.String("name", name))
+	}
+	return removed
 }
 
 // GetWorkflowForEvent returns the workflow associated with an event type
 func (e *Engine) GetWorkflowForEvent(eventType string) (*Workflow, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
 	workflow, exists := e.workflows[eventType]
 	return workflow, exists
 }
 
+// GetWorkflowVersion returns a specific version of a workflow, falling back
+// to the current definition when no version is given
+func (e *Engine) GetWorkflowVersion(name, version string) (*Workflow, bool) {
+	if version == "" {
+		return e.getWorkflowByName(name)
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for _, v := range e.versions[name] {
+		if v.Version == version {
+			return v.Workflow, true
+		}
+	}
+	return nil, false
+}
+
+// ListWorkflowVersions returns the registered versions of a workflow, oldest first
+func (e *Engine) ListWorkflowVersions(name string) ([]WorkflowVersion, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	versions, exists := e.versions[name]
+	if !exists {
+		return nil, false
+	}
+
+	result := make([]WorkflowVersion, 0, len(versions))
+	for _, v := range versions {
+		result = append(result, *v)
+	}
+	return result, true
+}
+
+// getWorkflowByName returns the active workflow with the given name
+func (e *Engine) getWorkflowByName(name string) (*Workflow, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for _, workflow := range e.workflows {
+		if workflow.Name == name {
+			return workflow, true
+		}
+	}
+	return nil, false
+}
+
+// ListWorkflows returns the currently active workflow definitions
+func (e *Engine) ListWorkflows() []*Workflow {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	workflows := make([]*Workflow, 0, len(e.workflows))
+	for _, workflow := range e.workflows {
+		workflows = append(workflows, workflow)
+	}
+	sort.Slice(workflows, func(i, j int) bool {
+		return workflows[i].Name < workflows[j].Name
+	})
+	return workflows
+}
+
 // ExecuteWorkflow executes a workflow with the given event context
 func (e *Engine) ExecuteWorkflow(ctx context.Context, workflow *Workflow, eventCtx *persistence.EventContext) (string, error) {
+	instance, err := e.startInstance(workflow, eventCtx)
+	if err != nil {
+		return "", err
+	}
+	return instance.ID, e.runSteps(ctx, workflow, instance, 0)
+}
+
+// startInstance validates the event and saves a new running instance
+func (e *Engine) startInstance(workflow *Workflow, eventCtx *persistence.EventContext) (*persistence.WorkflowInstance, error) {
+	// Validate the payload against declared inputs before starting
+	if eventCtx.Event.Payload == nil {
+		eventCtx.Event.Payload = make(map[string]interface{})
+	}
+	if err := workflow.ValidateInputs(eventCtx.Event.Payload); err != nil {
+		e.logger.Warn("Event payload rejected",
+			zap.String("workflow", workflow.Name),
+			zap.Error(err))
+		return nil, err
+	}
+
 	instanceID := uuid.New().String()
 
 	instance := &persistence.WorkflowInstance{
-		ID:           instanceID,
-		WorkflowName: workflow.Name,
-		Status:       "running",
-		StartTime:    time.Now(),
-		Context:      eventCtx,
-		Steps:        make([]persistence.StepExecution, 0),
+		ID:              instanceID,
+		WorkflowName:    workflow.Name,
+		WorkflowVersion: workflow.Version,
+		CorrelationID:   instanceID,
+		Status:          "running",
+		StartTime:       time.Now(),
+		Context:         eventCtx,
+		Steps:           make([]persistence.StepExecution, 0),
+	}
+	// Events emitted by other instances link this one to their chain
+	if id, ok := eventCtx.Event.Metadata[actions.MetadataCorrelationID].(string); ok && id != "" {
+		instance.CorrelationID = id
+	}
+	if id, ok := eventCtx.Event.Metadata[actions.MetadataCausationID].(string); ok {
+		instance.CausationID = id
 	}
 
 	e.logger.Info("Starting workflow execution",
 		zap.String("instance_id", instanceID),
-		zap.String("workflow", workflow.Name))
+		zap.String("workflow", workflow.Name),
+		zap.String("version", workflow.Version))
 
 	// Save initial instance state
 	if err := e.persistence.SaveWorkflowInstance(instance); err != nil {
 		e.logger.Error("Failed to save workflow instance", zap.Error(err))
 	}
+	return instance, nil
+}
 
-	// Execute workflow steps
-	for _, step := range workflow.Workflow {
+// runSteps executes workflow steps starting at the given index
+func (e *Engine) runSteps(ctx context.Context, workflow *Workflow, instance *persistence.WorkflowInstance, start int) error {
+	eventCtx := instance.Context
+	eventCtx.StrictTemplates = workflow.StrictTemplates || e.strict
+	eventCtx.Secrets = e.secrets
+	eventCtx.Env = e.env
+	defer e.registry.FinishInstance(instance.ID)
+
+	for i := start; i < len(workflow.Workflow); i++ {
+		step := workflow.Workflow[i]
 		stepExec := persistence.StepExecution{
 			Name:      step.Name,
 			Status:    "running",
@@ -83,6 +304,7 @@ func (e *Engine) ExecuteWorkflow(ctx context.Context, workflow *Workflow, eventC
 				stepExec.Status = "failed"
 				stepExec.Error = fmt.Sprintf("condition evaluation failed: %v", err)
 				instance.Steps = append(instance.Steps, stepExec)
+				eventCtx.SetStepState(step.Name, &persistence.StepState{Status: "failed", Error: stepExec.Error})
 				continue
 			}
 			if !shouldExecute {
@@ -90,11 +312,21 @@ func (e *Engine) ExecuteWorkflow(ctx context.Context, workflow *Workflow, eventC
 				now := time.Now()
 				stepExec.EndTime = &now
 				instance.Steps = append(instance.Steps, stepExec)
+				eventCtx.SetStepState(step.Name, &persistence.StepState{Status: "skipped"})
 				continue
 			}
 		}
 
 		// Execute step with retry logic
+		stepCtx := actions.WithStepInfo(ctx, actions.StepInfo{
+			Workflow:   workflow.Name,
+			Step:       step.Name,
+			InstanceID: instance.ID,
+			Templated:  templatedFields(step.Config),
+			Variables:  eventCtx.Variables,
+			Event:      eventCtx.Event,
+			Steps:      eventCtx.Steps,
+		})
 		var err error
 		maxRetries := 1
 		if step.Retry != nil && step.Retry.Max > 0 {
@@ -113,8 +345,8 @@ func (e *Engine) ExecuteWorkflow(ctx context.Context, workflow *Workflow, eventC
 			}
 
 			stepExec.Retries = attempt
-			err = e.executeStep(ctx, &step, eventCtx, &stepExec)
-			if err == nil {
+			err = e.executeStep(stepCtx, &step, eventCtx, &stepExec)
+			if err == nil || errors.Is(err, actions.ErrApprovalPending) {
 				break
 			}
 
@@ -124,6 +356,21 @@ func (e *Engine) ExecuteWorkflow(ctx context.Context, workflow *Workflow, eventC
 				zap.Error(err))
 		}
 
+		// Pause the instance until a human decision is recorded
+		if errors.Is(err, actions.ErrApprovalPending) {
+			var pending *actions.PendingApproval
+			errors.As(err, &pending)
+			if err := e.suspendForApproval(stepCtx, workflow, instance, i, stepExec, pending); err != nil {
+				now := time.Now()
+				instance.Status = "failed"
+				instance.Error = fmt.Sprintf("Step '%s' failed: %v", step.Name, err)
+				instance.EndTime = &now
+				e.persistence.SaveWorkflowInstance(instance)
+				return fmt.Errorf("workflow failed at step '%s': %w", step.Name, err)
+			}
+			return nil
+		}
+
 		if err != nil {
 			stepExec.Status = "failed"
 			stepExec.Error = err.Error()
@@ -134,11 +381,16 @@ func (e *Engine) ExecuteWorkflow(ctx context.Context, workflow *Workflow, eventC
 			stepExec.EndTime = &now
 			instance.EndTime = &now
 			instance.Steps = append(instance.Steps, stepExec)
+			eventCtx.SetStepState(step.Name, &persistence.StepState{
+				Status: "failed",
+				Output: stepExec.Output,
+				Error:  stepExec.Error,
+			})
 
 			// Save failed state
 			e.persistence.SaveWorkflowInstance(instance)
 
-			return instanceID, fmt.Errorf("workflow failed at step '%s': %w", step.Name, err)
+			return fmt.Errorf("workflow failed at step '%s': %w", step.Name, err)
 		}
 
 		stepExec.Status = "completed"
@@ -152,6 +404,20 @@ func (e *Engine) ExecuteWorkflow(ctx context.Context, workflow *Workflow, eventC
 		}
 	}
 
+	// Record workflow-level outputs
+	if len(workflow.Outputs) > 0 {
+		outputs, err := e.resolveOutputs(workflow.Outputs, eventCtx)
+		if err != nil {
+			now := time.Now()
+			instance.Status = "failed"
+			instance.Error = fmt.Sprintf("Workflow outputs failed: %v", err)
+			instance.EndTime = &now
+			e.persistence.SaveWorkflowInstance(instance)
+			return fmt.Errorf("workflow outputs failed: %w", err)
+		}
+		instance.Outputs = outputs
+	}
+
 	// Mark workflow as completed
 	instance.Status = "completed"
 	now := time.Now()
@@ -162,10 +428,10 @@ func (e *Engine) ExecuteWorkflow(ctx context.Context, workflow *Workflow, eventC
 	}
 
 	e.logger.Info("Workflow execution completed",
-		zap.String("instance_id", instanceID),
+		zap.String("instance_id", instance.ID),
 		zap.String("workflow", workflow.Name))
 
-	return instanceID, nil
+	return nil
 }
 
 // executeStep executes a single workflow step
@@ -175,12 +441,34 @@ func (e *Engine) executeStep(ctx context.Context, step *WorkflowStep, eventCtx *
 		return fmt.Errorf("action not found: %s", step.Action)
 	}
 
+	// Fields parsed as command lines get their template values quoted
+	quoted := make(map[string]bool)
+	if q, ok := action.(actions.QuotingAction); ok {
+		for _, key := range q.QuotedFields(step.Config) {
+			quoted[key] = true
+		}
+	}
+	typed := make(map[string]bool)
+	if t, ok := action.(actions.TypedAction); ok {
+		for _, key := range t.TypedFields(step.Config) {
+			typed[key] = true
+		}
+	}
+
 	// Prepare step input by resolving templates
 	stepInput := make(map[string]interface{})
 	for key, value := range step.Config {
-		resolvedValue, err := eventCtx.ResolveTemplate(fmt.Sprintf("%v", value))
+		if str, ok := value.(string); ok && quoted[key] {
+			resolved, err := eventCtx.ResolveQuotedTemplate(str)
+			if err != nil {
+				return fmt.Errorf("template resolution failed for %s: %w", key, err)
+			}
+			stepInput[key] = resolved
+			continue
+		}
+		resolvedValue, err := e.resolveValue(key, value, eventCtx, typed[key])
 		if err != nil {
-			return fmt.Errorf("template resolution failed for %s: %w", key, err)
+			return err
 		}
 		stepInput[key] = resolvedValue
 	}
@@ -190,6 +478,9 @@ func (e *Engine) executeStep(ctx context.Context, step *WorkflowStep, eventCtx *
 	// Execute the action
 	output, err := action.Execute(ctx, stepInput)
 	if err != nil {
+		if errors.Is(err, actions.ErrApprovalPending) {
+			stepExec.Output = output
+		}
 		return err
 	}
 
@@ -199,10 +490,78 @@ func (e *Engine) executeStep(ctx context.Context, step *WorkflowStep, eventCtx *
 	if stepExec.Output != nil {
 		eventCtx.Variables[step.Name] = stepExec.Output
 	}
+	state := &persistence.StepState{Status: "completed", Output: stepExec.Output}
+	eventCtx.SetStepState(step.Name, state)
+
+	// Extract declared outputs, which may refer to this step's own output
+	if len(step.Outputs) > 0 {
+		outputs, err := e.resolveOutputs(step.Outputs, eventCtx)
+		if err != nil {
+			return err
+		}
+		stepExec.Outputs = outputs
+		state.Outputs = outputs
+	}
 
 	return nil
 }
 
+// resolveOutputs evaluates an outputs mapping. A value that is a single
+// reference keeps the type of the referenced value.
+func (e *Engine) resolveOutputs(outputs map[string]string, eventCtx *persistence.EventContext) (map[string]interface{}, error) {
+	resolved := make(map[string]interface{}, len(outputs))
+	for key, templateStr := range outputs {
+		value, err := eventCtx.ResolveValue(templateStr)
+		if err != nil {
+			return nil, fmt.Errorf("output %s: %w", key, err)
+		}
+		resolved[key] = value
+	}
+	return resolved, nil
+}
+
+// resolveValue resolves templates in strings, recursing into maps and lists.
+// The path names the config key in errors, e.g. "body.text". With typed, a
+// string that is a single reference resolves to the referenced value.
+func (e *Engine) resolveValue(path string, value interface{}, eventCtx *persistence.EventContext, typed bool) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		var resolved interface{}
+		var err error
+		if typed {
+			resolved, err = eventCtx.ResolveValue(v)
+		} else {
+			resolved, err = eventCtx.ResolveTemplate(v)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("template resolution failed for %s: %w", path, err)
+		}
+		return resolved, nil
+	case map[string]interface{}:
+		resolved := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			r, err := e.resolveValue(path+"."+key, item, eventCtx, typed)
+			if err != nil {
+				return nil, err
+			}
+			resolved[key] = r
+		}
+		return resolved, nil
+	case []interface{}:
+		resolved := make([]interface{}, len(v))
+		for i, item := range v {
+			r, err := e.resolveValue(fmt.Sprintf("%s[%d]", path, i), item, eventCtx, typed)
+			if err != nil {
+				return nil, err
+			}
+			resolved[i] = r
+		}
+		return resolved, nil
+	default:
+		return v, nil
+	}
+}
+
 // evaluateCondition evaluates a condition string against the event context
 func (e *Engine) evaluateCondition(condition string, eventCtx *persistence.EventContext) (bool, error) {
 	// Simple condition evaluation - in a real implementation, you'd use a more sophisticated expression evaluator
@@ -241,3 +600,28 @@ func (e *Engine) calculateBackoff(retry *RetryConfig, attempt int) time.Duration
 func (e *Engine) GetWorkflowInstance(instanceID string) (*persistence.WorkflowInstance, error) {
 	return e.persistence.GetWorkflowInstance(instanceID)
 }
+
+// ListInstances returns instance metadata, newest first, optionally filtered
+// by workflow name and status. Instances are not decrypted.
+func (e *Engine) ListInstances(workflow, status string) ([]*persistence.InstanceSummary, error) {
+	summaries, err := e.persistence.ListInstanceSummaries()
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := make([]*persistence.InstanceSummary, 0, len(summaries))
+	for _, summary := range summaries {
+		if workflow != "" && summary.WorkflowName != workflow {
+			continue
+		}
+		if status != "" && summary.Status != status {
+			continue
+		}
+		filtered = append(filtered, summary)
+	}
+
+	sort.Slice(filtered, func(i, j int) bool {
+		return filtered[i].StartTime.After(filtered[j].StartTime)
+	})
+	return filtered, nil
+}
@@ -0,0 +1,290 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// InputSpec declares the expected shape of an event payload field
+type InputSpec struct {
+	Type        string                `yaml:"type" json:"type"`
+	Description string                `yaml:"description,omitempty" json:"description,omitempty"`
+	Required    bool                  `yaml:"required,omitempty" json:"required,omitempty"`
+	Default     interface{}           `yaml:"default,omitempty" json:"default,omitempty"`
+	Enum        []interface{}         `yaml:"enum,omitempty" json:"enum,omitempty"`
+	Properties  map[string]*InputSpec `yaml:"properties,omitempty" json:"properties,omitempty"`
+	Items       *InputSpec            `yaml:"items,omitempty" json:"items,omitempty"`
+}
+
+// FieldError describes a payload field that failed validation
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// InputValidationError is returned when an event payload does not match
+// the workflow's declared inputs
+type InputValidationError struct {
+	Workflow string       `json:"workflow"`
+	Fields   []FieldError `json:"fields"`
+}
+
+func (e *InputValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		messages = append(messages, fmt.Sprintf("%s: %s", field.Field, field.Message))
+	}
+	return fmt.Sprintf("invalid input for workflow '%s': %s", e.Workflow, strings.Join(messages, "; "))
+}
+
+// validInputTypes lists the supported input types
+var validInputTypes = map[string]bool{
+	"string":  true,
+	"number":  true,
+	"integer": true,
+	"boolean": true,
+	"object":  true,
+	"array":   true,
+	"any":     true,
+}
+
+// ValidateInputs checks a payload against the workflow's declared inputs and
+// fills in defaults for missing optional fields. The payload is modified in place.
+func (w *Workflow) ValidateInputs(payload map[string]interface{}) error {
+	if len(w.Inputs) == 0 {
+		return nil
+	}
+
+	fields := validateProperties("", w.Inputs, payload)
+	if len(fields) > 0 {
+		return &InputValidationError{Workflow: w.Name, Fields: fields}
+	}
+	return nil
+}
+
+// JSONSchema returns the workflow's inputs as a JSON Schema document
+func (w *Workflow) JSONSchema() map[string]interface{} {
+	schema := objectSchema(w.Inputs)
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = w.Name
+	return schema
+}
+
+// validateProperties validates the fields of an object against their specs
+func validateProperties(prefix string, specs map[string]*InputSpec, object map[string]interface{}) []FieldError {
+	var errors []FieldError
+
+	for _, name := range sortedKeys(specs) {
+		spec := specs[name]
+		field := joinField(prefix, name)
+
+		value, exists := object[name]
+		if !exists || value == nil {
+			if spec.Default != nil {
+				object[name] = spec.Default
+				continue
+			}
+			if spec.Required {
+				errors = append(errors, FieldError{Field: field, Message: "is required"})
+			}
+			continue
+		}
+
+		errors = append(errors, validateValue(field, spec, value)...)
+	}
+
+	return errors
+}
+
+// validateValue validates a single value against its spec
+func validateValue(field string, spec *InputSpec, value interface{}) []FieldError {
+	if !matchesType(spec.Type, value) {
+		return []FieldError{{Field: field, Message: fmt.Sprintf("must be of type %s", spec.Type)}}
+	}
+
+	if len(spec.Enum) > 0 && !inEnum(spec.Enum, value) {
+		return []FieldError{{Field: field, Message: fmt.Sprintf("must be one of %v", spec.Enum)}}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(spec.Properties) > 0 {
+			return validateProperties(field, spec.Properties, v)
+		}
+	case []interface{}:
+		if spec.Items != nil {
+			var errors []FieldError
+			for i, item := range v {
+				errors = append(errors, validateValue(fmt.Sprintf("%s[%d]", field, i), spec.Items, item)...)
+			}
+			return errors
+		}
+	}
+
+	return nil
+}
+
+// matchesType reports whether a decoded JSON or YAML value has the given type
+func matchesType(typ string, value interface{}) bool {
+	switch typ {
+	case "", "any":
+		return true
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		_, ok := toFloat(value)
+		return ok
+	case "integer":
+		f, ok := toFloat(value)
+		return ok && f == math.Trunc(f)
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	}
+	return false
+}
+
+// toFloat converts numeric values to float64
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// inEnum reports whether value is one of the allowed values
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, allowed := range enum {
+		if reflect.DeepEqual(allowed, value) {
+			return true
+		}
+		// Numbers from JSON and YAML decode to different Go types
+		a, aok := toFloat(allowed)
+		b, bok := toFloat(value)
+		if aok && bok && a == b {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateInputSpecs checks that declared inputs are well formed
+func ValidateInputSpecs(specs map[string]*InputSpec) error {
+	return validateInputSpecs("", specs)
+}
+
+// validateInputSpecs checks input specs below a field prefix
+func validateInputSpecs(prefix string, specs map[string]*InputSpec) error {
+	for _, name := range sortedKeys(specs) {
+		spec := specs[name]
+		field := joinField(prefix, name)
+
+		if spec == nil {
+			return fmt.Errorf("input %s: spec is empty", field)
+		}
+		if !validInputTypes[spec.Type] && spec.Type != "" {
+			return fmt.Errorf("input %s: unsupported type '%s'", field, spec.Type)
+		}
+		if spec.Default != nil && !matchesType(spec.Type, spec.Default) {
+			return fmt.Errorf("input %s: default must be of type %s", field, spec.Type)
+		}
+		if spec.Default != nil && len(spec.Enum) > 0 && !inEnum(spec.Enum, spec.Default) {
+			return fmt.Errorf("input %s: default must be one of %v", field, spec.Enum)
+		}
+		if err := validateInputSpecs(field, spec.Properties); err != nil {
+			return err
+		}
+		if spec.Items != nil {
+			if err := validateInputSpecs(field, map[string]*InputSpec{"[]": spec.Items}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// objectSchema converts input specs into a JSON Schema object
+func objectSchema(specs map[string]*InputSpec) map[string]interface{} {
+	properties := make(map[string]interface{}, len(specs))
+	required := make([]string, 0)
+
+	for _, name := range sortedKeys(specs) {
+		spec := specs[name]
+		properties[name] = specSchema(spec)
+		if spec.Required && spec.Default == nil {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// specSchema converts a single input spec into JSON Schema
+func specSchema(spec *InputSpec) map[string]interface{} {
+	schema := make(map[string]interface{})
+	if len(spec.Properties) > 0 {
+		schema = objectSchema(spec.Properties)
+	}
+	if spec.Type != "" && spec.Type != "any" {
+		schema["type"] = spec.Type
+	}
+	if spec.Description != "" {
+		schema["description"] = spec.Description
+	}
+	if spec.Default != nil {
+		schema["default"] = spec.Default
+	}
+	if len(spec.Enum) > 0 {
+		schema["enum"] = spec.Enum
+	}
+	if spec.Items != nil {
+		schema["items"] = specSchema(spec.Items)
+	}
+	return schema
+}
+
+// joinField builds a dotted field path
+func joinField(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// sortedKeys returns map keys in a stable order
+func sortedKeys(specs map[string]*InputSpec) []string {
+	keys := make([]string, 0, len(specs))
+	for key := range specs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
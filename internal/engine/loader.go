@@ -0,0 +1,328 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// maxReloadEvents bounds the in-memory reload history
+const maxReloadEvents = 100
+
+// ReloadEvent describes the outcome of loading a single workflow file
+type ReloadEvent struct {
+	Time     time.Time `json:"time"`
+	File     string    `json:"file"`
+	Workflow string    `json:"workflow,omitempty"`
+	Version  string    `json:"version,omitempty"`
+	Action   string    `json:"action"` // added, updated, removed, rejected
+	Error    string    `json:"error,omitempty"`
+}
+
+// loadedFile tracks the workflow currently registered from a file
+type loadedFile struct {
+	name     string
+	checksum string
+}
+
+// Loader keeps the engine in sync with the workflow files in a directory
+type Loader struct {
+	engine *Engine
+	logger *zap.Logger
+	dir    string
+
+	mu       sync.Mutex
+	files    map[string]loadedFile
+	rejected map[string][sha256.Size]byte
+	events   []ReloadEvent
+
+	watcher  *fsnotify.Watcher
+	debounce *time.Timer
+}
+
+// NewLoader creates a new workflow loader for a directory
+func NewLoader(engine *Engine, logger *zap.Logger, dir string) *Loader {
+	return &Loader{
+		engine:   engine,
+		logger:   logger,
+		dir:      dir,
+		files:    make(map[string]loadedFile),
+		rejected: make(map[string][sha256.Size]byte),
+		events:   make([]ReloadEvent, 0),
+	}
+}
+
+// Reload rescans the directory, registering new and changed workflows and
+// unregistering workflows whose files were removed. Files that fail
+// validation are rejected and the previously loaded version keeps running.
+func (l *Loader) Reload() ([]ReloadEvent, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.reloadLocked()
+}
+
+// reloadLocked performs a reload; the caller must hold l.mu
+func (l *Loader) reloadLocked() ([]ReloadEvent, error) {
+	paths := make([]string, 0)
+	err := filepath.Walk(l.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && isWorkflowFile(path) {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan workflow directory: %w", err)
+	}
+	sort.Strings(paths)
+
+	changes := make([]ReloadEvent, 0)
+	seen := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		seen[path] = true
+	}
+
+	for _, path := range paths {
+		previous, known := l.files[path]
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue // The file may have been removed mid-scan
+		}
+
+		sum := sha256.Sum256(data)
+
+		workflow, err := LoadWorkflowFromYAML(data)
+		if err == nil {
+			err = l.engine.checkPolicy(workflow)
+		}
+		if err != nil {
+			if l.reject(path, sum) {
+				changes = append(changes, l.record(ReloadEvent{
+					File:     path,
+					Workflow: previous.name,
+					Action:   "rejected",
+					Error:    err.Error(),
+				}))
+			}
+			continue
+		}
+
+		if known && previous.checksum == workflow.Checksum && previous.name == workflow.Name {
+			continue
+		}
+
+		if conflict := l.fileForWorkflow(workflow.Name, path, seen); conflict != "" {
+			if l.reject(path, sum) {
+				changes = append(changes, l.record(ReloadEvent{
+					File:     path,
+					Workflow: workflow.Name,
+					Action:   "rejected",
+					Error:    fmt.Sprintf("workflow '%s' is already defined in %s", workflow.Name, conflict),
+				}))
+			}
+			continue
+		}
+		if err := l.engine.RegisterWorkflow(workflow); err != nil {
+			if l.reject(path, sum) {
+				changes = append(changes, l.record(ReloadEvent{
+					File:     path,
+					Workflow: workflow.Name,
+					Action:   "rejected",
+					Error:    err.Error(),
+				}))
+			}
+			continue
+		}
+		delete(l.rejected, path)
+
+		// The file now defines a different workflow
+		if known && previous.name != workflow.Name {
+			l.engine.UnregisterWorkflow(previous.name)
+		}
+
+		l.files[path] = loadedFile{name: workflow.Name, checksum: workflow.Checksum}
+
+		action := "added"
+		if known {
+			action = "updated"
+		}
+		changes = append(changes, l.record(ReloadEvent{
+			File:     path,
+			Workflow: workflow.Name,
+			Version:  workflow.Version,
+			Action:   action,
+		}))
+	}
+
+	for path := range l.rejected {
+		if !seen[path] {
+			delete(l.rejected, path)
+		}
+	}
+
+	for path, previous := range l.files {
+		if seen[path] {
+			continue
+		}
+		l.engine.UnregisterWorkflow(previous.name)
+		delete(l.files, path)
+		changes = append(changes, l.record(ReloadEvent{
+			File:     path,
+			Workflow: previous.name,
+			Action:   "removed",
+		}))
+	}
+
+	return changes, nil
+}
+
+// Watch reloads workflows whenever YAML files in the directory change
+func (l *Loader) Watch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	err = filepath.Walk(l.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return watcher.Add(path)
+		}
+		return nil
+	})
+	if err != nil {
+		watcher.Close()
+		return err
+	}
+
+	l.mu.Lock()
+	l.watcher = watcher
+	l.mu.Unlock()
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if isWorkflowFile(event.Name) {
+					l.scheduleReload()
+				}
+
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				l.logger.Error("Workflow watcher error", zap.Error(err))
+			}
+		}
+	}()
+
+	l.logger.Info("Watching workflow directory for changes", zap.String("dir", l.dir))
+	return nil
+}
+
+// Stop stops watching the workflow directory
+func (l *Loader) Stop() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.debounce != nil {
+		l.debounce.Stop()
+	}
+	if l.watcher != nil {
+		l.watcher.Close()
+		l.watcher = nil
+	}
+}
+
+// Events returns the recent reload history, newest last
+func (l *Loader) Events() []ReloadEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	events := make([]ReloadEvent, len(l.events))
+	copy(events, l.events)
+	return events
+}
+
+// scheduleReload coalesces bursts of file events into a single reload
+func (l *Loader) scheduleReload() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.debounce != nil {
+		l.debounce.Stop()
+	}
+	l.debounce = time.AfterFunc(250*time.Millisecond, func() {
+		if _, err := l.Reload(); err != nil {
+			l.logger.Error("Workflow reload failed", zap.Error(err))
+		}
+	})
+}
+
+// record logs a reload event and appends it to the history
+func (l *Loader) record(event ReloadEvent) ReloadEvent {
+	event.Time = time.Now()
+
+	fields := []zap.Field{
+		zap.String("file", event.File),
+		zap.String("action", event.Action),
+		zap.String("workflow", event.Workflow),
+	}
+	if event.Version != "" {
+		fields = append(fields, zap.String("version", event.Version))
+	}
+
+	if event.Error != "" {
+		l.logger.Warn("Workflow reload rejected", append(fields, zap.String("error", event.Error))...)
+	} else {
+		l.logger.Info("Workflow reloaded", fields...)
+	}
+
+	l.events = append(l.events, event)
+	if len(l.events) > maxReloadEvents {
+		l.events = l.events[len(l.events)-maxReloadEvents:]
+	}
+	return event
+}
+
+// reject remembers a rejected file and reports whether its content is new,
+// so unchanged invalid files are not reported on every reload
+func (l *Loader) reject(path string, sum [sha256.Size]byte) bool {
+	if last, ok := l.rejected[path]; ok && last == sum {
+		return false
+	}
+	l.rejected[path] = sum
+	return true
+}
+
+// fileForWorkflow returns another existing file that already defines the named workflow
+func (l *Loader) fileForWorkflow(name, path string, present map[string]bool) string {
+	for other, loaded := range l.files {
+		if other != path && present[other] && loaded.name == name {
+			return other
+		}
+	}
+	return ""
+}
+
+// isWorkflowFile reports whether a path looks like a workflow definition
+func isWorkflowFile(path string) bool {
+	ext := filepath.Ext(path)
+	return ext == ".yaml" || ext == ".yml"
+}
@@ -0,0 +1,158 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/logimos/conduktr/internal/actions"
+	"github.com/logimos/conduktr/internal/persistence"
+)
+
+// SetShellPolicy restricts what shell.exec steps may run. Workflows that
+// violate the policy are rejected when loaded.
+func (e *Engine) SetShellPolicy(policy *actions.ShellPolicy) {
+	e.shellPolicy = policy
+	if action, err := e.registry.GetAction("shell.exec"); err == nil {
+		if shell, ok := action.(*actions.ShellAction); ok {
+			shell.SetPolicy(policy)
+		}
+	}
+}
+
+// SetWASMConfig registers WebAssembly modules and sets wasm.run limits
+func (e *Engine) SetWASMConfig(config *actions.WASMConfig) {
+	if action, err := e.registry.GetAction("wasm.run"); err == nil {
+		if wasm, ok := action.(*actions.WASMAction); ok {
+			wasm.SetConfig(config)
+		}
+	}
+}
+
+// SetScriptConfig sets the limits of script.run steps
+func (e *Engine) SetScriptConfig(config *actions.ScriptConfig) {
+	if action, err := e.registry.GetAction("script.run"); err == nil {
+		if script, ok := action.(*actions.ScriptAction); ok {
+			script.SetConfig(config)
+		}
+	}
+}
+
+// SetEmailConfig sets the SMTP server email.send steps use
+func (e *Engine) SetEmailConfig(config *actions.EmailConfig) {
+	if action, err := e.registry.GetAction("email.send"); err == nil {
+		if email, ok := action.(*actions.EmailAction); ok {
+			email.SetConfig(config)
+		}
+	}
+}
+
+// SetDatabases opens the connections db.query and db.exec steps use
+func (e *Engine) SetDatabases(databases map[string]actions.DatabaseConfig) error {
+	names := make([]string, 0, len(databases))
+	for name := range databases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := e.registry.RegisterDatabase(name, databases[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetFilePolicy restricts the paths file and archive steps may use
+func (e *Engine) SetFilePolicy(policy *actions.FilePolicy) {
+	e.registry.SetFilePolicy(policy)
+}
+
+// SetProtectedPaths sets paths file and archive steps may never use
+func (e *Engine) SetProtectedPaths(paths ...string) {
+	e.registry.SetProtectedPaths(paths...)
+}
+
+// SetEgressPolicy restricts the destinations http.request steps may reach,
+// and the SMTP servers email.send steps may choose
+func (e *Engine) SetEgressPolicy(policy *actions.EgressPolicy) {
+	if action, err := e.registry.GetAction("http.request"); err == nil {
+		if httpAction, ok := action.(*actions.HTTPAction); ok {
+			httpAction.SetEgressPolicy(policy)
+		}
+	}
+	if action, err := e.registry.GetAction("email.send"); err == nil {
+		if email, ok := action.(*actions.EmailAction); ok {
+			email.SetEgressPolicy(policy)
+		}
+	}
+}
+
+// CheckShellPolicy statically checks shell.exec steps against a policy and
+// rejects templates placed inside quotes in command lines, which a nil
+// policy does not disable. Templated values are checked when the step runs.
+func (w *Workflow) CheckShellPolicy(policy *actions.ShellPolicy) []error {
+	policy = policy.ForWorkflow(w.Name)
+	var errs []error
+	for _, step := range w.Workflow {
+		if step.Action != "shell.exec" {
+			continue
+		}
+		for _, key := range new(actions.ShellAction).QuotedFields(step.Config) {
+			if command, ok := step.Config[key].(string); ok {
+				if err := persistence.CheckQuotedTemplate(command); err != nil {
+					errs = append(errs, fmt.Errorf("step %s: %s: %w", step.Name, key, err))
+				}
+			}
+		}
+		for _, err := range policy.CheckConfig(step.Config) {
+			errs = append(errs, fmt.Errorf("step %s: %w", step.Name, err))
+		}
+	}
+	return errs
+}
+
+// checkPolicy returns the policy violations of a workflow as one error
+func (e *Engine) checkPolicy(workflow *Workflow) error {
+	return errors.Join(workflow.CheckShellPolicy(e.shellPolicy)...)
+}
+
+// templatedFields returns the config keys whose values contain templates.
+// Keys inside nested maps are listed as well, joined with dots.
+func templatedFields(config map[string]interface{}) []string {
+	var fields []string
+	for key, value := range config {
+		if !containsTemplate(value) {
+			continue
+		}
+		fields = append(fields, key)
+		if nested, ok := value.(map[string]interface{}); ok {
+			for _, field := range templatedFields(nested) {
+				fields = append(fields, key+"."+field)
+			}
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// containsTemplate reports whether a config value contains a template
+func containsTemplate(value interface{}) bool {
+	switch v := value.(type) {
+	case string:
+		return strings.Contains(v, "{{")
+	case map[string]interface{}:
+		for _, item := range v {
+			if containsTemplate(item) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if containsTemplate(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
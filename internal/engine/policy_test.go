@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckShellPolicyRejectsQuotedTemplates(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		args    bool
+		err     string
+	}{
+		{name: "unquoted", command: "echo {{ .event.payload.name }}"},
+		{name: "single quoted", command: "echo '{{ .event.payload.name }}'", err: "inside single quotes"},
+		{name: "double quoted", command: `printf '[%s]' "{{ .event.payload.name }}"`, err: "inside double quotes"},
+		{name: "args give the argv", command: "{{ .event.payload.tool }}", args: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workflow := &Workflow{
+				Name: "quoting",
+				Workflow: []WorkflowStep{{
+					Name:   "run",
+					Action: "shell.exec",
+					Config: map[string]interface{}{"command": tt.command},
+				}},
+			}
+			if tt.args {
+				workflow.Workflow[0].Config["args"] = []interface{}{"'{{ .event.payload.name }}'"}
+			}
+
+			errs := workflow.CheckShellPolicy(nil)
+			if tt.err == "" {
+				if len(errs) > 0 {
+					t.Fatalf("errors = %v", errs)
+				}
+				return
+			}
+			if len(errs) != 1 || !strings.Contains(errs[0].Error(), tt.err) {
+				t.Fatalf("errors = %v, want %q", errs, tt.err)
+			}
+		})
+	}
+}
+
+func TestShippedWorkflowsPassShellChecks(t *testing.T) {
+	files, err := filepath.Glob("../../workflows/*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	examples, err := filepath.Glob("../../examples/*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range append(files, examples...) {
+		workflow, err := LoadWorkflowFromFile(file)
+		if err != nil {
+			continue // Not every example is a workflow definition
+		}
+		for _, err := range workflow.CheckShellPolicy(nil) {
+			t.Errorf("%s: %v", file, err)
+		}
+	}
+}
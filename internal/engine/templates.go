@@ -0,0 +1,203 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/logimos/conduktr/internal/persistence"
+)
+
+// TemplateIssue describes a template reference that cannot be resolved statically
+type TemplateIssue struct {
+	Step      string `json:"step"`
+	Key       string `json:"key"`
+	Reference string `json:"reference,omitempty"`
+	Message   string `json:"message"`
+}
+
+func (i TemplateIssue) String() string {
+	if i.Reference == "" {
+		return fmt.Sprintf("step '%s', %s: %s", i.Step, i.Key, i.Message)
+	}
+	return fmt.Sprintf("step '%s', %s: .%s %s", i.Step, i.Key, i.Reference, i.Message)
+}
+
+// eventFields lists the fields available under .event
+var eventFields = map[string]bool{
+	"type":      true,
+	"payload":   true,
+	"metadata":  true,
+	"timestamp": true,
+}
+
+// stepFields lists the fields available under .steps.<name>
+var stepFields = map[string]bool{
+	"status":  true,
+	"output":  true,
+	"outputs": true,
+	"error":   true,
+}
+
+// CheckTemplates statically checks template references in step configuration
+// and outputs against the declared inputs and the names of prior steps
+func (w *Workflow) CheckTemplates() []TemplateIssue {
+	issues := make([]TemplateIssue, 0)
+
+	stepIndex := make(map[string]int, len(w.Workflow))
+	for i, step := range w.Workflow {
+		if _, exists := stepIndex[step.Name]; !exists {
+			stepIndex[step.Name] = i
+		}
+	}
+
+	// check reports references in a template that are not available to
+	// the given step; outputs may also refer to the step itself
+	check := func(stepName string, current int, self bool, key, templateStr string) {
+		refs, err := persistence.TemplateReferences(templateStr)
+		if err != nil {
+			issues = append(issues, TemplateIssue{Step: stepName, Key: key, Message: err.Error()})
+			return
+		}
+		for _, ref := range refs {
+			if msg := w.checkReference(ref, current, self, stepIndex); msg != "" {
+				issues = append(issues, TemplateIssue{Step: stepName, Key: key, Reference: ref, Message: msg})
+			}
+		}
+	}
+
+	for i, step := range w.Workflow {
+		i, name := i, step.Name
+		checkConfig := func(key, templateStr string) {
+			check(name, i, false, key, templateStr)
+		}
+
+		if step.If != "" {
+			checkConfig("if", step.If)
+		}
+
+		keys := make([]string, 0, len(step.Config))
+		for key := range step.Config {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			walkTemplates(key, step.Config[key], checkConfig)
+		}
+
+		for _, key := range sortedOutputKeys(step.Outputs) {
+			check(name, i, true, "outputs."+key, step.Outputs[key])
+		}
+	}
+
+	// Workflow outputs run after every step
+	for _, key := range sortedOutputKeys(w.Outputs) {
+		check("(workflow)", len(w.Workflow), false, "outputs."+key, w.Outputs[key])
+	}
+
+	return issues
+}
+
+// checkReference returns a message when a reference cannot be resolved
+func (w *Workflow) checkReference(ref string, current int, self bool, stepIndex map[string]int) string {
+	parts := strings.Split(ref, ".")
+
+	switch parts[0] {
+	case "event":
+		if len(parts) < 2 {
+			return ""
+		}
+		if !eventFields[parts[1]] {
+			return "is not an event field; expected one of type, payload, metadata, timestamp"
+		}
+		if parts[1] == "payload" && len(parts) > 2 && len(w.Inputs) > 0 {
+			return checkInputPath(w.Inputs, parts[2:])
+		}
+	case "variables", "steps":
+		if len(parts) < 2 {
+			return ""
+		}
+		index, exists := stepIndex[parts[1]]
+		if parts[0] == "variables" && (!exists || index > current) {
+			// Triggers also copy the event payload into .variables, so
+			// other names are declared inputs or, without inputs, any
+			// payload key
+			if _, declared := w.Inputs[parts[1]]; declared {
+				return checkInputPath(w.Inputs, parts[1:])
+			}
+			if !exists && len(w.Inputs) == 0 {
+				return ""
+			}
+			if !exists {
+				return "is neither a prior step nor a declared input"
+			}
+		}
+		switch {
+		case !exists:
+			return "is not the name of a prior step"
+		case index == current && !self:
+			return "refers to the step's own output"
+		case index > current:
+			return fmt.Sprintf("refers to step '%s', which runs later", parts[1])
+		}
+		if parts[0] == "steps" && len(parts) > 2 {
+			if !stepFields[parts[2]] {
+				return "is not a step field; expected one of status, output, outputs, error"
+			}
+			if parts[2] == "outputs" && len(parts) > 3 {
+				if _, declared := w.Workflow[index].Outputs[parts[3]]; !declared {
+					return fmt.Sprintf("is not a declared output of step '%s'", parts[1])
+				}
+			}
+		}
+	default:
+		return "is not available; templates can reference .event, .steps and .variables"
+	}
+
+	return ""
+}
+
+// sortedOutputKeys returns outputs mapping keys in a stable order
+func sortedOutputKeys(outputs map[string]string) []string {
+	keys := make([]string, 0, len(outputs))
+	for key := range outputs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// checkInputPath checks a payload path against declared inputs
+func checkInputPath(specs map[string]*InputSpec, path []string) string {
+	spec, exists := specs[path[0]]
+	if !exists {
+		return "is not a declared input"
+	}
+	if len(path) > 1 && len(spec.Properties) > 0 {
+		if msg := checkInputPath(spec.Properties, path[1:]); msg != "" {
+			return msg
+		}
+	}
+	return ""
+}
+
+// walkTemplates calls fn for every string in a config value
+func walkTemplates(path string, value interface{}, fn func(key, templateStr string)) {
+	switch v := value.(type) {
+	case string:
+		fn(path, v)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			walkTemplates(path+"."+key, v[key], fn)
+		}
+	case []interface{}:
+		for i, item := range v {
+			walkTemplates(fmt.Sprintf("%s[%d]", path, i), item, fn)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckTemplatesVariables(t *testing.T) {
+	tests := []struct {
+		name     string
+		inputs   string
+		template string
+		issue    string
+	}{
+		{name: "trigger key without inputs", template: "{{ .variables.workflow_id }}"},
+		{name: "prior step", template: "{{ .variables.first.status }}"},
+		{name: "declared input", inputs: "inputs:\n  user_id:\n    type: string\n", template: "{{ .variables.user_id }}"},
+		{name: "undeclared key with inputs", inputs: "inputs:\n  user_id:\n    type: string\n", template: "{{ .variables.workflow_id }}", issue: "neither a prior step nor a declared input"},
+		{name: "own step", template: "{{ .variables.second.status }}", issue: "own output"},
+		{name: "later step", template: "{{ .variables.third.status }}", issue: "runs later"},
+		{name: "unknown step", template: "{{ .steps.user_id.status }}", issue: "not the name of a prior step"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workflow := mustLoadWorkflow(t, `name: refs
+on:
+  event: refs.requested
+`+tt.inputs+`workflow:
+  - name: first
+    action: log.info
+    message: hello
+  - name: second
+    action: log.info
+    message: "`+tt.template+`"
+  - name: third
+    action: log.info
+    message: bye
+`)
+			issues := workflow.CheckTemplates()
+			if tt.issue == "" {
+				if len(issues) > 0 {
+					t.Fatalf("issues = %v", issues)
+				}
+				return
+			}
+			if len(issues) != 1 || !strings.Contains(issues[0].Message, tt.issue) {
+				t.Fatalf("issues = %v, want %q", issues, tt.issue)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package engine
+
+import (
+	"sort"
+
+	"github.com/logimos/conduktr/internal/persistence"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// restoreVersions loads the workflow versions saved by earlier runs, so
+// instances paused on them can resume
+func (e *Engine) restoreVersions() {
+	if e.persistence == nil {
+		return
+	}
+
+	records, err := e.persistence.ListWorkflowVersionRecords()
+	if err != nil {
+		e.logger.Warn("Failed to load workflow versions", zap.Error(err))
+		return
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].RegisteredAt.Before(records[j].RegisteredAt)
+	})
+
+	for _, record := range records {
+		var workflow Workflow
+		if err := yaml.Unmarshal([]byte(record.Definition), &workflow); err != nil {
+			e.logger.Warn("Skipping unreadable workflow version",
+				zap.String("name", record.Name),
+				zap.String("version", record.Version),
+				zap.Error(err))
+			continue
+		}
+		workflow.Version = record.Version
+		workflow.Checksum = record.Checksum
+
+		e.versions[record.Name] = append(e.versions[record.Name], &WorkflowVersion{
+			Version:      record.Version,
+			Checksum:     record.Checksum,
+			RegisteredAt: record.RegisteredAt,
+			Workflow:     &workflow,
+		})
+	}
+}
+
+// saveVersion persists a newly registered workflow version. Failures are
+// logged; the version stays usable until the engine restarts.
+func (e *Engine) saveVersion(version *WorkflowVersion) {
+	if e.persistence == nil {
+		return
+	}
+
+	definition, err := yaml.Marshal(version.Workflow)
+	if err == nil {
+		err = e.persistence.SaveWorkflowVersion(&persistence.WorkflowVersionRecord{
+			Name:         version.Workflow.Name,
+			Version:      version.Version,
+			Checksum:     version.Checksum,
+			RegisteredAt: version.RegisteredAt,
+			Definition:   string(definition),
+		})
+	}
+	if err != nil {
+		e.logger.Warn("Failed to persist workflow version",
+			zap.String("name", version.Workflow.Name),
+			zap.String("version", version.Version),
+			zap.Error(err))
+	}
+}
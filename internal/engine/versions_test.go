@@ -0,0 +1,150 @@
+package engine
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/logimos/conduktr/internal/persistence"
+
+	"go.uber.org/zap"
+)
+
+const versionedWorkflow = `name: greet
+version: "1"
+on:
+  event: greet.requested
+workflow:
+  - name: say
+    action: shell.exec
+    command: echo hello
+`
+
+func mustLoadWorkflow(t *testing.T, source string) *Workflow {
+	t.Helper()
+	workflow, err := LoadWorkflowFromYAML([]byte(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return workflow
+}
+
+func TestRegisterWorkflowRejectsReusedVersion(t *testing.T) {
+	e := NewEngine(zap.NewNop(), persistence.NewJSONPersistence(t.TempDir()))
+	if err := e.RegisterWorkflow(mustLoadWorkflow(t, versionedWorkflow)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Registering identical content again is fine
+	if err := e.RegisterWorkflow(mustLoadWorkflow(t, versionedWorkflow)); err != nil {
+		t.Fatalf("re-registering the same content: %v", err)
+	}
+
+	changed := strings.Replace(versionedWorkflow, "echo hello", "echo bye", 1)
+	err := e.RegisterWorkflow(mustLoadWorkflow(t, changed))
+	if !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("err = %v, want ErrVersionConflict", err)
+	}
+
+	active, _ := e.getWorkflowByName("greet")
+	if got := active.Workflow[0].Config["command"]; got != "echo hello" {
+		t.Errorf("active command = %v, want the original", got)
+	}
+
+	bumped := strings.Replace(changed, `version: "1"`, `version: "2"`, 1)
+	if err := e.RegisterWorkflow(mustLoadWorkflow(t, bumped)); err != nil {
+		t.Fatalf("new version: %v", err)
+	}
+	if versions, _ := e.ListWorkflowVersions("greet"); len(versions) != 2 {
+		t.Errorf("versions = %d, want 2", len(versions))
+	}
+}
+
+func TestWorkflowVersionsSurviveRestart(t *testing.T) {
+	dir := t.TempDir()
+	e := NewEngine(zap.NewNop(), persistence.NewJSONPersistence(dir))
+	if err := e.RegisterWorkflow(mustLoadWorkflow(t, versionedWorkflow)); err != nil {
+		t.Fatal(err)
+	}
+	bumped := strings.NewReplacer(`version: "1"`, `version: "2"`, "echo hello", "echo bye").Replace(versionedWorkflow)
+	if err := e.RegisterWorkflow(mustLoadWorkflow(t, bumped)); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := NewEngine(zap.NewNop(), persistence.NewJSONPersistence(dir))
+	old, ok := restarted.GetWorkflowVersion("greet", "1")
+	if !ok {
+		t.Fatal("version 1 was not restored")
+	}
+	if got := old.Workflow[0].Config["command"]; got != "echo hello" {
+		t.Errorf("restored command = %v, want echo hello", got)
+	}
+	if _, active := restarted.getWorkflowByName("greet"); active {
+		t.Error("restored versions must not become active")
+	}
+
+	// The restored version still guards against reuse
+	changed := strings.Replace(versionedWorkflow, "echo hello", "echo changed", 1)
+	if err := restarted.RegisterWorkflow(mustLoadWorkflow(t, changed)); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("err = %v, want ErrVersionConflict", err)
+	}
+	if err := restarted.RegisterWorkflow(mustLoadWorkflow(t, versionedWorkflow)); err != nil {
+		t.Fatalf("re-registering restored content: %v", err)
+	}
+}
+
+func TestLoaderRejectsReusedVersion(t *testing.T) {
+	loader, _ := newTestLoader(t)
+	if _, err := loader.CreateDefinition([]byte(versionedWorkflow)); err != nil {
+		t.Fatal(err)
+	}
+
+	changed := strings.Replace(versionedWorkflow, "echo hello", "echo bye", 1)
+	_, err := loader.UpdateDefinition("greet", []byte(changed))
+	if err == nil || !strings.Contains(err.Error(), "different content") {
+		t.Fatalf("err = %v, want a version conflict", err)
+	}
+	definition, err := loader.GetDefinition("greet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if definition.Source != versionedWorkflow {
+		t.Errorf("source = %q, want the original", definition.Source)
+	}
+}
+
+// unmarshalable fails to encode as YAML
+type unmarshalable struct{}
+
+func (unmarshalable) MarshalYAML() (interface{}, error) {
+	return nil, errors.New("cannot encode")
+}
+
+func TestRegisterWorkflowWithoutChecksum(t *testing.T) {
+	step := WorkflowStep{Name: "say", Action: "log.info", Config: map[string]interface{}{"message": "hi"}}
+	tests := []struct {
+		name     string
+		workflow *Workflow
+		err      string
+	}{
+		{
+			name:     "unmarshalable config",
+			workflow: &Workflow{Name: "greet", Workflow: []WorkflowStep{{Name: "say", Action: "log.info", Config: map[string]interface{}{"message": unmarshalable{}}}}},
+			err:      "failed to compute checksum",
+		},
+		{
+			name:     "short checksum",
+			workflow: &Workflow{Name: "greet", Checksum: "abc", Workflow: []WorkflowStep{step}},
+			err:      "too short",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewEngine(zap.NewNop(), persistence.NewJSONPersistence(t.TempDir()))
+			err := e.RegisterWorkflow(tt.workflow)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("err = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
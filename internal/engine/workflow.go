@@ -1,6 +1,8 @@
 package engine
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
 
@@ -9,29 +11,35 @@ import (
 
 // Workflow represents a complete workflow definition
 type Workflow struct {
-	Name     string         `yaml:"name"`
-	On       TriggerConfig  `yaml:"on"`
-	Workflow []WorkflowStep `yaml:"workflow"`
+	Name            string                `yaml:"name" json:"name"`
+	Version         string                `yaml:"version,omitempty" json:"version"`
+	On              TriggerConfig         `yaml:"on" json:"on"`
+	Inputs          map[string]*InputSpec `yaml:"inputs,omitempty" json:"inputs,omitempty"`
+	StrictTemplates bool                  `yaml:"strict_templates,omitempty" json:"strict_templates,omitempty"`
+	Workflow        []WorkflowStep        `yaml:"workflow" json:"workflow"`
+	Outputs         map[string]string     `yaml:"outputs,omitempty" json:"outputs,omitempty"`
+	Checksum        string                `yaml:"-" json:"checksum"`
 }
 
 // TriggerConfig defines what triggers the workflow
 type TriggerConfig struct {
-	Event string `yaml:"event"`
+	Event string `yaml:"event" json:"event"`
 }
 
 // WorkflowStep represents a single step in a workflow
 type WorkflowStep struct {
-	Name   string                 `yaml:"name"`
-	Action string                 `yaml:"action"`
-	If     string                 `yaml:"if,omitempty"`
-	Config map[string]interface{} `yaml:",inline"`
-	Retry  *RetryConfig           `yaml:"retry,omitempty"`
+	Name    string                 `yaml:"name" json:"name"`
+	Action  string                 `yaml:"action" json:"action"`
+	If      string                 `yaml:"if,omitempty" json:"if,omitempty"`
+	Config  map[string]interface{} `yaml:",inline" json:"config,omitempty"`
+	Retry   *RetryConfig           `yaml:"retry,omitempty" json:"retry,omitempty"`
+	Outputs map[string]string      `yaml:"outputs,omitempty" json:"outputs,omitempty"`
 }
 
 // RetryConfig defines retry behavior for a step
 type RetryConfig struct {
-	Max     int    `yaml:"max"`
-	Backoff string `yaml:"backoff"`
+	Max     int    `yaml:"max" json:"max"`
+	Backoff string `yaml:"backoff" json:"backoff"`
 }
 
 // LoadWorkflowFromFile loads a workflow definition from a YAML file
@@ -55,9 +63,33 @@ func LoadWorkflowFromYAML(data []byte) (*Workflow, error) {
 		return nil, fmt.Errorf("workflow validation failed: %w", err)
 	}
 
+	if err := workflow.ensureVersion(); err != nil {
+		return nil, err
+	}
+
 	return &workflow, nil
 }
 
+// ensureVersion computes the content checksum and defaults the version to it
+func (w *Workflow) ensureVersion() error {
+	if w.Checksum == "" {
+		data, err := yaml.Marshal(w)
+		if err != nil {
+			return fmt.Errorf("failed to compute checksum of workflow %s: %w", w.Name, err)
+		}
+		sum := sha256.Sum256(data)
+		w.Checksum = hex.EncodeToString(sum[:])
+	}
+
+	if w.Version == "" {
+		if len(w.Checksum) < 12 {
+			return fmt.Errorf("workflow %s: checksum %q is too short to name a version", w.Name, w.Checksum)
+		}
+		w.Version = w.Checksum[:12]
+	}
+	return nil
+}
+
 // validateWorkflow validates a workflow definition
 func validateWorkflow(workflow *Workflow) error {
 	if workflow.Name == "" {
@@ -72,6 +104,10 @@ func validateWorkflow(workflow *Workflow) error {
 		return fmt.Errorf("workflow must have at least one step")
 	}
 
+	if err := validateInputSpecs("", workflow.Inputs); err != nil {
+		return err
+	}
+
 	for i, step := range workflow.Workflow {
 		if step.Name == "" {
 			return fmt.Errorf("step %d: name is required", i)
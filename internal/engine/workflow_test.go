@@ -0,0 +1,80 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/logimos/conduktr/internal/actions"
+	"github.com/logimos/conduktr/internal/config"
+	"github.com/logimos/conduktr/internal/persistence"
+
+	"go.uber.org/zap"
+)
+
+func TestFileProcessorWorkflow(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		step    string
+		want    string
+	}{
+		{name: "text", file: "notes.txt", content: "one\ntwo\nthree\n", step: "log_line_count", want: "Read 3 lines from notes.txt"},
+		{name: "csv", file: "orders.csv", content: "id,total\n1,9.99\n2,5.00\n", step: "log_row_count", want: "Parsed 2 rows from orders.csv"},
+	}
+
+	for _, definition := range []string{"../../workflows/file-processor.yaml", "../../examples/file-processor.yaml"} {
+		for _, tt := range tests {
+			t.Run(filepath.Base(filepath.Dir(definition))+"/"+tt.name, func(t *testing.T) {
+				workflow, err := LoadWorkflowFromFile(definition)
+				if err != nil {
+					t.Fatal(err)
+				}
+				root, err := filepath.EvalSymlinks(t.TempDir())
+				if err != nil {
+					t.Fatal(err)
+				}
+				path := filepath.Join(root, tt.file)
+				if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+
+				e := NewEngine(zap.NewNop(), persistence.NewJSONPersistence(t.TempDir()))
+				// The default file policy, with root as the watched directory
+				policy := actions.FilePolicy(*config.Default().Files)
+				policy.ReadOnlyRoots = config.DefaultFilePolicy(root).ReadOnlyRoots
+				e.SetFilePolicy(&policy)
+				instanceID, err := e.ExecuteWorkflow(context.Background(), workflow, &persistence.EventContext{
+					Event: &persistence.Event{
+						Type: "file.created",
+						Payload: map[string]interface{}{
+							"file_path": path,
+							"file_name": tt.file,
+							"file_ext":  filepath.Ext(tt.file),
+						},
+					},
+					Variables: map[string]interface{}{},
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				instance, err := e.GetWorkflowInstance(instanceID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, step := range instance.Steps {
+					if step.Name == tt.step {
+						if step.Input["message"] != tt.want {
+							t.Errorf("message = %q, want %q", step.Input["message"], tt.want)
+						}
+						return
+					}
+				}
+				t.Fatalf("step %s did not run", tt.step)
+			})
+		}
+	}
+}
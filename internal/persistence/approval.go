@@ -0,0 +1,121 @@
+package persistence
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Approval represents a pending or decided human approval for a workflow step
+type Approval struct {
+	ID         string     `json:"id"`
+	InstanceID string     `json:"instance_id"`
+	Workflow   string     `json:"workflow"`
+	Step       string     `json:"step"`
+	StepIndex  int        `json:"step_index"`
+	Message    string     `json:"message"`
+	Approvers  []string   `json:"approvers"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	DecidedBy  string     `json:"decided_by,omitempty"`
+	DecidedAt  *time.Time `json:"decided_at,omitempty"`
+	Comment    string     `json:"comment,omitempty"`
+	// TokenHash is the SHA-256 of the token a decision must present when
+	// anyone may decide
+	TokenHash string `json:"token_hash,omitempty"`
+	// TokenHashes maps each approver to the SHA-256 of their token
+	TokenHashes map[string]string `json:"token_hashes,omitempty"`
+}
+
+// approvalDir returns the directory holding approval records
+func (j *JSONPersistence) approvalDir() string {
+	return filepath.Join(j.dataDir, "approvals")
+}
+
+// SaveApproval saves an approval record to a JSON file
+func (j *JSONPersistence) SaveApproval(approval *Approval) error {
+	if err := os.MkdirAll(j.approvalDir(), 0700); err != nil {
+		return fmt.Errorf("failed to create approval directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(approval, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal approval: %w", err)
+	}
+
+	data, err = j.redact(data)
+	if err != nil {
+		return fmt.Errorf("failed to redact approval: %w", err)
+	}
+
+	data, err = j.seal(data, approvalMetadata(approval))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt approval: %w", err)
+	}
+
+	filename := filepath.Join(j.approvalDir(), fmt.Sprintf("%s.json", approval.ID))
+	if err := writeRecord(filename, data); err != nil {
+		return fmt.Errorf("failed to write approval file: %w", err)
+	}
+
+	return nil
+}
+
+// GetApproval retrieves an approval record from a JSON file
+func (j *JSONPersistence) GetApproval(approvalID string) (*Approval, error) {
+	filename := filepath.Join(j.approvalDir(), fmt.Sprintf("%s.json", approvalID))
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("approval not found: %s", approvalID)
+		}
+		return nil, fmt.Errorf("failed to read approval file: %w", err)
+	}
+
+	data, err = j.open(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read approval %s: %w", approvalID, err)
+	}
+
+	var approval Approval
+	if err := json.Unmarshal(data, &approval); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal approval: %w", err)
+	}
+
+	return &approval, nil
+}
+
+// ListApprovals retrieves all approval records
+func (j *JSONPersistence) ListApprovals() ([]*Approval, error) {
+	files, err := filepath.Glob(filepath.Join(j.approvalDir(), "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list approval files: %w", err)
+	}
+
+	approvals := make([]*Approval, 0, len(files))
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue // Skip files that can't be read
+		}
+
+		data, err = j.open(data)
+		if err != nil {
+			continue // Skip files that can't be decrypted
+		}
+
+		var approval Approval
+		if err := json.Unmarshal(data, &approval); err != nil {
+			continue // Skip files that can't be parsed
+		}
+
+		approvals = append(approvals, &approval)
+	}
+
+	return approvals, nil
+}
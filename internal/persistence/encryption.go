@@ -0,0 +1,325 @@
+package persistence
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/logimos/conduktr/internal/secrets"
+)
+
+// DataKeyEnv holds a data key as "id:base64" and overrides the key file
+const DataKeyEnv = "CONDUKTR_DATA_KEY"
+
+// ErrNoDataKey is returned when reading encrypted data without a keyring
+var ErrNoDataKey = errors.New("data is encrypted but no data key is configured")
+
+// Keyring holds the key encryption keys for data at rest. New data is
+// sealed with the active key; older keys stay available for reading.
+type Keyring struct {
+	active string
+	keys   map[string][]byte
+}
+
+// LoadKeyring reads keys from CONDUKTR_DATA_KEY or from a key file with one
+// "id:base64key" per line, the first line being the active key
+func LoadKeyring(path string) (*Keyring, error) {
+	if value := os.Getenv(DataKeyEnv); value != "" {
+		keyring := &Keyring{keys: make(map[string][]byte)}
+		if err := keyring.addLine(value, DataKeyEnv); err != nil {
+			return nil, err
+		}
+		return keyring, nil
+	}
+
+	if path == "" {
+		return nil, fmt.Errorf("no data key configured; set %s or a key file", DataKeyEnv)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open data key file: %w", err)
+	}
+	defer file.Close()
+
+	keyring := &Keyring{keys: make(map[string][]byte)}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := keyring.addLine(line, path); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read data key file: %w", err)
+	}
+	if keyring.active == "" {
+		return nil, fmt.Errorf("data key file %s contains no keys", path)
+	}
+	return keyring, nil
+}
+
+// addLine parses an "id:base64key" entry; a bare key gets the id "default"
+func (k *Keyring) addLine(line, source string) error {
+	id, encoded := "default", line
+	if i := strings.Index(line, ":"); i >= 0 {
+		id, encoded = line[:i], line[i+1:]
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(key) != 32 {
+		return fmt.Errorf("data key %s in %s must be 32 bytes encoded as base64", id, source)
+	}
+	if _, exists := k.keys[id]; exists {
+		return fmt.Errorf("duplicate data key id %s in %s", id, source)
+	}
+
+	k.keys[id] = key
+	if k.active == "" {
+		k.active = id
+	}
+	return nil
+}
+
+// ActiveKeyID returns the id of the key used for new data
+func (k *Keyring) ActiveKeyID() string {
+	return k.active
+}
+
+// GenerateDataKey adds a new random key to the key file and makes it active
+func GenerateDataKey(path string) (string, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return "", fmt.Errorf("failed to generate data key: %w", err)
+	}
+	id := fmt.Sprintf("k%s-%x", time.Now().UTC().Format("20060102150405"), key[:2])
+
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read data key file: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", fmt.Errorf("failed to create key directory: %w", err)
+	}
+	content := id + ":" + base64.StdEncoding.EncodeToString(key) + "\n" + string(existing)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		return "", fmt.Errorf("failed to write data key file: %w", err)
+	}
+	return id, nil
+}
+
+// envelope is the on-disk form of encrypted records. Metadata stays in
+// plaintext so records can be listed and filtered without decrypting.
+type envelope struct {
+	Encrypted  bool                   `json:"encrypted"`
+	KeyID      string                 `json:"key_id"`
+	WrappedKey string                 `json:"wrapped_key"`
+	Ciphertext string                 `json:"ciphertext"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// SetKeyring enables envelope encryption for records written from now on
+func (j *JSONPersistence) SetKeyring(keyring *Keyring) {
+	j.keyring = keyring
+}
+
+// seal encrypts data with a fresh data key wrapped by the active key
+func (j *JSONPersistence) seal(data []byte, metadata map[string]interface{}) ([]byte, error) {
+	if j.keyring == nil {
+		return data, nil
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	ciphertext, err := secrets.Encrypt(dataKey, data)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := secrets.Encrypt(j.keyring.keys[j.keyring.active], dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(envelope{
+		Encrypted:  true,
+		KeyID:      j.keyring.active,
+		WrappedKey: wrapped,
+		Ciphertext: ciphertext,
+		Metadata:   metadata,
+	}, "", "  ")
+}
+
+// open returns the plaintext of a record, decrypting it when needed
+func (j *JSONPersistence) open(data []byte) ([]byte, error) {
+	env, ok := parseEnvelope(data)
+	if !ok {
+		return data, nil
+	}
+
+	dataKey, err := j.unwrap(env)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := secrets.Decrypt(dataKey, env.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt record: %w", err)
+	}
+	return plaintext, nil
+}
+
+// unwrap decrypts an envelope's data key
+func (j *JSONPersistence) unwrap(env *envelope) ([]byte, error) {
+	if j.keyring == nil {
+		return nil, ErrNoDataKey
+	}
+	key, exists := j.keyring.keys[env.KeyID]
+	if !exists {
+		return nil, fmt.Errorf("data key %s is not in the keyring", env.KeyID)
+	}
+	dataKey, err := secrets.Decrypt(key, env.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key %s: %w", env.KeyID, err)
+	}
+	return dataKey, nil
+}
+
+// parseEnvelope reports whether data is an encrypted record
+func parseEnvelope(data []byte) (*envelope, bool) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil || !env.Encrypted {
+		return nil, false
+	}
+	return &env, true
+}
+
+// RekeyResult counts the records touched by Rekey
+type RekeyResult struct {
+	Rewrapped int `json:"rewrapped"`
+	Encrypted int `json:"encrypted"`
+	Skipped   int `json:"skipped"`
+}
+
+// Rekey rewraps the data keys of all encrypted records with the active key
+// and encrypts records that are still stored in plaintext
+func (j *JSONPersistence) Rekey() (*RekeyResult, error) {
+	if j.keyring == nil {
+		return nil, ErrNoDataKey
+	}
+
+	instanceFiles, err := filepath.Glob(filepath.Join(j.dataDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	approvalFiles, err := filepath.Glob(filepath.Join(j.approvalDir(), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	versionFiles, err := filepath.Glob(filepath.Join(j.versionDir(), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RekeyResult{}
+	rekey := func(file string, metadata func([]byte) (map[string]interface{}, error)) error {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		var out []byte
+		if env, ok := parseEnvelope(data); ok {
+			if env.KeyID == j.keyring.active {
+				result.Skipped++
+				return nil
+			}
+			dataKey, err := j.unwrap(env)
+			if err != nil {
+				return err
+			}
+			env.WrappedKey, err = secrets.Encrypt(j.keyring.keys[j.keyring.active], dataKey)
+			if err != nil {
+				return err
+			}
+			env.KeyID = j.keyring.active
+			if out, err = json.MarshalIndent(env, "", "  "); err != nil {
+				return err
+			}
+			result.Rewrapped++
+		} else {
+			meta, err := metadata(data)
+			if err != nil {
+				return err
+			}
+			if out, err = j.seal(data, meta); err != nil {
+				return err
+			}
+			result.Encrypted++
+		}
+
+		return writeRecord(file, out)
+	}
+
+	for _, file := range instanceFiles {
+		if err := rekey(file, func(data []byte) (map[string]interface{}, error) {
+			var instance WorkflowInstance
+			if err := json.Unmarshal(data, &instance); err != nil {
+				return nil, err
+			}
+			return instanceMetadata(&instance), nil
+		}); err != nil {
+			return result, fmt.Errorf("failed to rekey %s: %w", file, err)
+		}
+	}
+	for _, file := range approvalFiles {
+		if err := rekey(file, func(data []byte) (map[string]interface{}, error) {
+			var approval Approval
+			if err := json.Unmarshal(data, &approval); err != nil {
+				return nil, err
+			}
+			return approvalMetadata(&approval), nil
+		}); err != nil {
+			return result, fmt.Errorf("failed to rekey %s: %w", file, err)
+		}
+	}
+	for _, file := range versionFiles {
+		if err := rekey(file, func(data []byte) (map[string]interface{}, error) {
+			var record WorkflowVersionRecord
+			if err := json.Unmarshal(data, &record); err != nil {
+				return nil, err
+			}
+			return versionMetadata(&record), nil
+		}); err != nil {
+			return result, fmt.Errorf("failed to rekey %s: %w", file, err)
+		}
+	}
+
+	return result, nil
+}
+
+// writeRecord writes a record atomically with owner-only permissions
+func writeRecord(filename string, data []byte) error {
+	tmp := filename + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filename); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package persistence
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testKeyLine returns a random "id:base64key" key file entry
+func testKeyLine(t *testing.T, id string) string {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return id + ":" + base64.StdEncoding.EncodeToString(key)
+}
+
+// testKeyring writes the entries to a key file and loads it
+func testKeyring(t *testing.T, lines ...string) *Keyring {
+	t.Helper()
+	t.Setenv(DataKeyEnv, "")
+	path := filepath.Join(t.TempDir(), "data.key")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	keyring, err := LoadKeyring(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keyring
+}
+
+// saveTestRecords writes one record of each kind holding the secret
+func saveTestRecords(t *testing.T, store *JSONPersistence, secret string) {
+	t.Helper()
+	instance := &WorkflowInstance{
+		ID:           "instance-1",
+		WorkflowName: "orders",
+		Status:       "completed",
+		StartTime:    time.Now(),
+		Context:      &EventContext{Variables: map[string]interface{}{"card": secret}},
+	}
+	if err := store.SaveWorkflowInstance(instance); err != nil {
+		t.Fatal(err)
+	}
+	approval := &Approval{ID: "approval-1", InstanceID: "instance-1", Workflow: "orders", Status: "pending", Message: secret}
+	if err := store.SaveApproval(approval); err != nil {
+		t.Fatal(err)
+	}
+	record := &WorkflowVersionRecord{Name: "orders", Version: "1.0.0", Checksum: "abc", Definition: "description: " + secret}
+	if err := store.SaveWorkflowVersion(record); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checkTestRecords reads back the records written by saveTestRecords
+func checkTestRecords(t *testing.T, store *JSONPersistence, secret string) {
+	t.Helper()
+	instance, err := store.GetWorkflowInstance("instance-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if instance.Context.Variables["card"] != secret {
+		t.Errorf("instance variables = %v", instance.Context.Variables)
+	}
+	approval, err := store.GetApproval("approval-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if approval.Message != secret {
+		t.Errorf("approval message = %q", approval.Message)
+	}
+	records, err := store.ListWorkflowVersionRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Definition != "description: "+secret {
+		t.Errorf("version records = %+v", records)
+	}
+}
+
+// recordFiles returns the instance, approval and version files of a store
+func recordFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	var files []string
+	for _, pattern := range []string{"*.json", "approvals/*.json", "versions/*.json"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, matches...)
+	}
+	if len(files) != 3 {
+		t.Fatalf("record files = %v, want 3", files)
+	}
+	return files
+}
+
+func TestEncryptedRecordsRoundTrip(t *testing.T) {
+	const secret = "4111-1111-1111-1111"
+	dir := t.TempDir()
+	store := NewJSONPersistence(dir)
+	store.SetKeyring(testKeyring(t, testKeyLine(t, "k1")))
+
+	saveTestRecords(t, store, secret)
+
+	for _, file := range recordFiles(t, dir) {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), secret) {
+			t.Errorf("%s contains the plaintext secret", file)
+		}
+		env, ok := parseEnvelope(data)
+		if !ok || env.KeyID != "k1" || len(env.Metadata) == 0 {
+			t.Errorf("%s is not an envelope sealed with k1: %s", file, data)
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("%s mode = %v, want 0600", file, info.Mode().Perm())
+		}
+	}
+
+	checkTestRecords(t, store, secret)
+
+	tests := []struct {
+		name    string
+		keyring *Keyring
+		err     string
+	}{
+		{name: "no keyring", err: ErrNoDataKey.Error()},
+		{name: "unknown key", keyring: testKeyring(t, testKeyLine(t, "k2")), err: "data key k1 is not in the keyring"},
+		{name: "wrong key material", keyring: testKeyring(t, testKeyLine(t, "k1")), err: "failed to unwrap data key k1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			other := NewJSONPersistence(dir)
+			if tt.keyring != nil {
+				other.SetKeyring(tt.keyring)
+			}
+			_, err := other.GetWorkflowInstance("instance-1")
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("err = %v, want %q", err, tt.err)
+			}
+			if tt.keyring == nil && !errors.Is(err, ErrNoDataKey) {
+				t.Errorf("err = %v, want %v", err, ErrNoDataKey)
+			}
+		})
+	}
+}
+
+func TestRekey(t *testing.T) {
+	const secret = "correct horse battery staple"
+	dir := t.TempDir()
+	old, current := testKeyLine(t, "k1"), testKeyLine(t, "k2")
+
+	// Records written before encryption was enabled
+	plain := NewJSONPersistence(dir)
+	saveTestRecords(t, plain, secret)
+	if _, err := plain.Rekey(); !errors.Is(err, ErrNoDataKey) {
+		t.Fatalf("Rekey without keyring err = %v, want %v", err, ErrNoDataKey)
+	}
+
+	steps := []struct {
+		name  string
+		keys  []string
+		want  RekeyResult
+		keyID string
+	}{
+		{name: "encrypt plaintext", keys: []string{old}, want: RekeyResult{Encrypted: 3}, keyID: "k1"},
+		{name: "rewrap with new active key", keys: []string{current, old}, want: RekeyResult{Rewrapped: 3}, keyID: "k2"},
+		{name: "skip current records", keys: []string{current, old}, want: RekeyResult{Skipped: 3}, keyID: "k2"},
+		{name: "old key retired", keys: []string{current}, want: RekeyResult{Skipped: 3}, keyID: "k2"},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			store := NewJSONPersistence(dir)
+			store.SetKeyring(testKeyring(t, step.keys...))
+
+			result, err := store.Rekey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *result != step.want {
+				t.Errorf("result = %+v, want %+v", *result, step.want)
+			}
+
+			for _, file := range recordFiles(t, dir) {
+				data, err := os.ReadFile(file)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if env, ok := parseEnvelope(data); !ok || env.KeyID != step.keyID {
+					t.Errorf("%s is not sealed with %s", file, step.keyID)
+				}
+			}
+			checkTestRecords(t, store, secret)
+		})
+	}
+}
+
+func TestLoadKeyring(t *testing.T) {
+	k1, k2 := testKeyLine(t, "k1"), testKeyLine(t, "k2")
+	bare := strings.TrimPrefix(k1, "k1:")
+
+	tests := []struct {
+		name    string
+		env     string
+		content string
+		active  string
+		err     string
+	}{
+		{name: "first line is active", content: "# keys\n" + k2 + "\n\n" + k1 + "\n", active: "k2"},
+		{name: "bare key", content: bare + "\n", active: "default"},
+		{name: "environment overrides file", env: k1, content: k2 + "\n", active: "k1"},
+		{name: "short key", content: "k1:" + base64.StdEncoding.EncodeToString([]byte("short")) + "\n", err: "must be 32 bytes"},
+		{name: "duplicate id", content: k1 + "\n" + k1 + "\n", err: "duplicate data key id k1"},
+		{name: "no keys", content: "# empty\n", err: "contains no keys"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(DataKeyEnv, tt.env)
+			path := filepath.Join(t.TempDir(), "data.key")
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			keyring, err := LoadKeyring(path)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if keyring.ActiveKeyID() != tt.active {
+				t.Errorf("active key = %q, want %q", keyring.ActiveKeyID(), tt.active)
+			}
+		})
+	}
+}
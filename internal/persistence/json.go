@@ -15,11 +15,17 @@ import (
 type WorkflowInstance struct {
         ID           string                 `json:"id"`
         WorkflowName string                 `json:"workflow_name"`
+        WorkflowVersion string              `json:"workflow_version,omitempty"`
+        // CorrelationID is shared by all instances of a chain of emitted events
+        CorrelationID string                `json:"correlation_id,omitempty"`
+        // CausationID is the instance whose step emitted this instance's event
+        CausationID  string                 `json:"causation_id,omitempty"`
         Status       string                 `json:"status"`
         StartTime    time.Time              `json:"start_time"`
         EndTime      *time.Time             `json:"end_time,omitempty"`
         Context      *EventContext          `json:"context"`
         Steps        []StepExecution        `json:"steps"`
+        Outputs      map[string]interface{} `json:"outputs,omitempty"`
         Error        string                 `json:"error,omitempty"`
 }
 
@@ -31,6 +37,7 @@ type StepExecution struct {
         EndTime   *time.Time             `json:"end_time,omitempty"`
         Input     map[string]interface{} `json:"input"`
         Output    map[string]interface{} `json:"output"`
+        Outputs   map[string]interface{} `json:"outputs,omitempty"`
         Error     string                 `json:"error,omitempty"`
         Retries   int                    `json:"retries"`
 }
@@ -47,6 +54,16 @@ type Event struct {
 type EventContext struct {
         Event     *Event                 `json:"event"`
         Variables map[string]interface{} `json:"variables"`
+        Steps     map[string]*StepState  `json:"steps,omitempty"`
+
+        // Secrets resolves {{ secret "name" }} references
+        Secrets SecretResolver `json:"-"`
+        // Env resolves {{ env "NAME" }} references
+        Env EnvResolver `json:"-"`
+
+        // StrictTemplates makes references to missing keys fail instead of
+        // rendering "<no value>"
+        StrictTemplates bool `json:"-"`
 }
 
 // ResolveTemplate resolves template variables in a string using the event context
@@ -72,17 +89,25 @@ type Store interface {
         SaveWorkflowInstance(instance *WorkflowInstance) error
         GetWorkflowInstance(instanceID string) (*WorkflowInstance, error)
         ListWorkflowInstances() ([]*WorkflowInstance, error)
+        ListInstanceSummaries() ([]*InstanceSummary, error)
+        SaveApproval(approval *Approval) error
+        GetApproval(approvalID string) (*Approval, error)
+        ListApprovals() ([]*Approval, error)
+        SaveWorkflowVersion(record *WorkflowVersionRecord) error
+        ListWorkflowVersionRecords() ([]*WorkflowVersionRecord, error)
 }
 
 // JSONPersistence implements file-based JSON persistence
 type JSONPersistence struct {
-        dataDir string
+        dataDir  string
+        redactor Redactor
+        keyring  *Keyring
 }
 
 // NewJSONPersistence creates a new JSON persistence store
 func NewJSONPersistence(dataDir string) *JSONPersistence {
         // Create data directory if it doesn't exist
-        os.MkdirAll(dataDir, 0755)
+        os.MkdirAll(dataDir, 0700)
         
         return &JSONPersistence{
                 dataDir: dataDir,
@@ -98,7 +123,17 @@ func (j *JSONPersistence) SaveWorkflowInstance(instance *WorkflowInstance) error
                 return fmt.Errorf("failed to marshal instance: %w", err)
         }
 
-        if err := os.WriteFile(filename, data, 0644); err != nil {
+        data, err = j.redact(data)
+        if err != nil {
+                return fmt.Errorf("failed to redact instance: %w", err)
+        }
+
+        data, err = j.seal(data, instanceMetadata(instance))
+        if err != nil {
+                return fmt.Errorf("failed to encrypt instance: %w", err)
+        }
+
+        if err := writeRecord(filename, data); err != nil {
                 return fmt.Errorf("failed to write instance file: %w", err)
         }
 
@@ -117,6 +152,11 @@ func (j *JSONPersistence) GetWorkflowInstance(instanceID string) (*WorkflowInsta
                 return nil, fmt.Errorf("failed to read instance file: %w", err)
         }
 
+        data, err = j.open(data)
+        if err != nil {
+                return nil, fmt.Errorf("failed to read instance %s: %w", instanceID, err)
+        }
+
         var instance WorkflowInstance
         if err := json.Unmarshal(data, &instance); err != nil {
                 return nil, fmt.Errorf("failed to unmarshal instance: %w", err)
@@ -140,6 +180,11 @@ func (j *JSONPersistence) ListWorkflowInstances() ([]*WorkflowInstance, error) {
                         continue // Skip files that can't be read
                 }
 
+                data, err = j.open(data)
+                if err != nil {
+                        continue // Skip files that can't be decrypted
+                }
+
                 var instance WorkflowInstance
                 if err := json.Unmarshal(data, &instance); err != nil {
                         continue // Skip files that can't be parsed
@@ -158,24 +203,21 @@ func resolveTemplate(templateStr string, ctx *EventContext) (string, error) {
         }
 
         // Create template data structure
-        templateData := map[string]interface{}{
-                "event": map[string]interface{}{
-                        "type":      ctx.Event.Type,
-                        "payload":   ctx.Event.Payload,
-                        "metadata":  ctx.Event.Metadata,
-                        "timestamp": ctx.Event.Timestamp,
-                },
-                "variables": ctx.Variables,
-        }
+        templateData := ctx.templateData()
 
         // Create template with custom functions
         tmpl, err := template.New("workflow").
                 Funcs(templateFunctions()).
+                Funcs(ctx.secretFunctions()).
                 Parse(templateStr)
         if err != nil {
                 return "", fmt.Errorf("template parse error: %w", err)
         }
 
+        if ctx.StrictTemplates {
+                tmpl.Option("missingkey=error")
+        }
+
         // Execute template
         var buf bytes.Buffer
         if err := tmpl.Execute(&buf, templateData); err != nil {
@@ -185,9 +227,18 @@ func resolveTemplate(templateStr string, ctx *EventContext) (string, error) {
         return buf.String(), nil
 }
 
-// templateFunctions returns custom template functions
+// templateFunctions returns the built-in and registered template functions
 func templateFunctions() template.FuncMap {
-        return template.FuncMap{
+        funcs := builtinFunctions()
+        for name, fn := range registeredFunctions() {
+                funcs[name] = fn
+        }
+        return funcs
+}
+
+// builtinFunctions returns the template functions that ship with conduktr
+func builtinFunctions() template.FuncMap {
+        funcs := template.FuncMap{
                 "default": func(defaultValue, value interface{}) interface{} {
                         if value == nil || isEmptyValue(reflect.ValueOf(value)) {
                                 return defaultValue
@@ -212,7 +263,17 @@ func templateFunctions() template.FuncMap {
                 "contains": func(haystack, needle string) bool {
                         return bytes.Contains([]byte(haystack), []byte(needle))
                 },
+                "secret": func(name string) (string, error) {
+                        return "", errNoSecrets
+                },
+                "env": func(name string) (string, error) {
+                        return "", errNoEnv
+                },
+        }
+        for name, fn := range libraryFunctions() {
+                funcs[name] = fn
         }
+        return funcs
 }
 
 // isEmptyValue checks if a reflect.Value is empty
@@ -0,0 +1,192 @@
+package persistence
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// ResolveQuotedTemplate resolves a template that will be interpreted by a
+// shell. The output of every action is passed through shellquote unless it
+// already ends in shellquote or raw. Actions inside quotes or after a
+// backslash are rejected, since a quoted word cannot be spliced there safely.
+func (ctx *EventContext) ResolveQuotedTemplate(templateStr string) (string, error) {
+	if templateStr == "" {
+		return "", nil
+	}
+
+	tmpl, err := template.New("workflow").
+		Funcs(templateFunctions()).
+		Funcs(ctx.secretFunctions()).
+		Parse(templateStr)
+	if err != nil {
+		return "", fmt.Errorf("template parse error: %w", err)
+	}
+	if ctx.StrictTemplates {
+		tmpl.Option("missingkey=error")
+	}
+
+	if tmpl.Tree != nil {
+		if err := checkQuoteContext(tmpl.Tree.Root, &shellState{}); err != nil {
+			return "", err
+		}
+		quoteActions(tmpl.Tree, tmpl.Tree.Root)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, ctx.templateData()); err != nil {
+		return "", fmt.Errorf("template execution error: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// CheckQuotedTemplate reports template actions in a shell command line that
+// sit inside single or double quotes or follow a backslash
+func CheckQuotedTemplate(templateStr string) error {
+	if !strings.Contains(templateStr, "{{") {
+		return nil
+	}
+
+	// Secret functions are only resolved when the template runs
+	tmpl, err := template.New("workflow").
+		Funcs(templateFunctions()).
+		Funcs((&EventContext{}).secretFunctions()).
+		Parse(templateStr)
+	if err != nil {
+		return fmt.Errorf("template parse error: %w", err)
+	}
+	if tmpl.Tree == nil {
+		return nil
+	}
+	return checkQuoteContext(tmpl.Tree.Root, &shellState{})
+}
+
+// shellState is the quoting in effect at a point of a shell command line
+type shellState struct {
+	quote   byte // 0, '\'' or '"'
+	escaped bool
+}
+
+// scan advances the state over literal command text
+func (s *shellState) scan(text []byte) {
+	for _, c := range text {
+		if s.escaped {
+			s.escaped = false
+			continue
+		}
+		switch s.quote {
+		case 0:
+			switch c {
+			case '\\':
+				s.escaped = true
+			case '\'', '"':
+				s.quote = c
+			}
+		case '\'':
+			if c == '\'' {
+				s.quote = 0
+			}
+		case '"':
+			switch c {
+			case '\\':
+				s.escaped = true
+			case '"':
+				s.quote = 0
+			}
+		}
+	}
+}
+
+// checkQuoteContext walks the template in order and rejects actions whose
+// output would land inside quotes or directly after a backslash
+func checkQuoteContext(node parse.Node, state *shellState) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := checkQuoteContext(child, state); err != nil {
+				return err
+			}
+		}
+	case *parse.TextNode:
+		state.scan(n.Text)
+	case *parse.ActionNode:
+		pipe := n.Pipe
+		if len(pipe.Decl) > 0 || len(pipe.Cmds) == 0 {
+			return nil
+		}
+		last := pipe.Cmds[len(pipe.Cmds)-1]
+		if ident, ok := last.Args[0].(*parse.IdentifierNode); ok && ident.Ident == "raw" {
+			return nil
+		}
+		switch {
+		case state.quote == '\'':
+			return fmt.Errorf("template %s is inside single quotes; values are quoted automatically, so remove the quotes", n)
+		case state.quote == '"':
+			return fmt.Errorf("template %s is inside double quotes; values are quoted automatically, so remove the quotes", n)
+		case state.escaped:
+			return fmt.Errorf("template %s follows a backslash; values are quoted automatically, so remove the backslash", n)
+		}
+	case *parse.IfNode:
+		return checkBranches(n.List, n.ElseList, state)
+	case *parse.RangeNode:
+		return checkBranches(n.List, n.ElseList, state)
+	case *parse.WithNode:
+		return checkBranches(n.List, n.ElseList, state)
+	}
+	return nil
+}
+
+// checkBranches checks both branches from the same starting state. The
+// quoting after them is taken from the first branch.
+func checkBranches(list, elseList *parse.ListNode, state *shellState) error {
+	elseState := *state
+	if err := checkQuoteContext(list, state); err != nil {
+		return err
+	}
+	if elseList == nil {
+		return nil
+	}
+	return checkQuoteContext(elseList, &elseState)
+}
+
+// quoteActions appends shellquote to every action that produces output
+func quoteActions(tree *parse.Tree, node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			quoteActions(tree, child)
+		}
+	case *parse.ActionNode:
+		pipe := n.Pipe
+		if len(pipe.Decl) > 0 || len(pipe.Cmds) == 0 {
+			return
+		}
+		last := pipe.Cmds[len(pipe.Cmds)-1]
+		if ident, ok := last.Args[0].(*parse.IdentifierNode); ok && (ident.Ident == "shellquote" || ident.Ident == "raw") {
+			return
+		}
+		quote := parse.NewIdentifier("shellquote").SetTree(tree).SetPos(n.Pos)
+		pipe.Cmds = append(pipe.Cmds, &parse.CommandNode{
+			NodeType: parse.NodeCommand,
+			Pos:      n.Pos,
+			Args:     []parse.Node{quote},
+		})
+	case *parse.IfNode:
+		quoteActions(tree, n.List)
+		quoteActions(tree, n.ElseList)
+	case *parse.RangeNode:
+		quoteActions(tree, n.List)
+		quoteActions(tree, n.ElseList)
+	case *parse.WithNode:
+		quoteActions(tree, n.List)
+		quoteActions(tree, n.ElseList)
+	}
+}
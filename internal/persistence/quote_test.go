@@ -0,0 +1,63 @@
+package persistence
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveQuotedTemplate(t *testing.T) {
+	ctx := &EventContext{
+		Event: &Event{Payload: map[string]interface{}{
+			"name": "a b",
+			"evil": `x'; echo INJECTED; '`,
+		}},
+		Variables: map[string]interface{}{},
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		err      string
+	}{
+		{name: "plain text", template: "echo hello", want: "echo hello"},
+		{name: "quoted value", template: "echo {{ .event.payload.name }}", want: "echo 'a b'"},
+		{name: "single quote escaped", template: "echo {{ .event.payload.evil }}", want: `echo 'x'\''; echo INJECTED; '\'''`},
+		{name: "joined to a word", template: "cp f /tmp/{{ .event.payload.name }}.txt", want: "cp f /tmp/'a b'.txt"},
+		{name: "explicit shellquote", template: "echo {{ shellquote .event.payload.name }}", want: "echo 'a b'"},
+		{name: "raw opts out", template: "echo {{ raw .event.payload.name }}", want: "echo a b"},
+		{name: "inside branch", template: "echo {{ if .event.payload.name }}{{ .event.payload.name }}{{ end }}", want: "echo 'a b'"},
+		{name: "after closed quotes", template: `echo 'x' "y" {{ .event.payload.name }}`, want: `echo 'x' "y" 'a b'`},
+		{name: "escaped quote stays unquoted", template: `echo \' {{ .event.payload.name }}`, want: `echo \' 'a b'`},
+		{name: "inside single quotes", template: "echo '{{ .event.payload.name }}'", err: "inside single quotes"},
+		{name: "inside double quotes", template: `printf '[%s]' "{{ .event.payload.name }}"`, err: "inside double quotes"},
+		{name: "double quotes within a word", template: `echo --name="{{ .event.payload.name }}"`, err: "inside double quotes"},
+		{name: "escaped quote inside double quotes", template: `echo "a\" {{ .event.payload.name }}"`, err: "inside double quotes"},
+		{name: "after a backslash", template: `echo \{{ .event.payload.name }}`, err: "follows a backslash"},
+		{name: "inside quotes in a branch", template: `echo {{ if true }}"{{ .event.payload.name }}"{{ end }}`, err: "inside double quotes"},
+		{name: "inside quotes in else branch", template: `echo '{{ if false }}x{{ else }}{{ .event.payload.name }}{{ end }}'`, err: "inside single quotes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ctx.ResolveQuotedTemplate(tt.template)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				if checkErr := CheckQuotedTemplate(tt.template); checkErr == nil || !strings.Contains(checkErr.Error(), tt.err) {
+					t.Errorf("CheckQuotedTemplate err = %v, want %q", checkErr, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if err := CheckQuotedTemplate(tt.template); err != nil {
+				t.Errorf("CheckQuotedTemplate err = %v", err)
+			}
+		})
+	}
+}
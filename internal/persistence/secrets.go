@@ -0,0 +1,74 @@
+package persistence
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"text/template"
+)
+
+// SecretResolver resolves secret names to values for templates
+type SecretResolver interface {
+	Resolve(name string) (string, error)
+}
+
+// EnvResolver returns the environment variables templates may read
+type EnvResolver interface {
+	Env(name string) (string, error)
+}
+
+// Redactor removes secret values before data is persisted
+type Redactor interface {
+	RedactValue(value interface{}) interface{}
+}
+
+var (
+	// errNoSecrets is returned by the secret function when no resolver is set
+	errNoSecrets = errors.New("no secrets provider configured")
+	// errNoEnv is returned by the env function when no variables are allowed
+	errNoEnv = errors.New("no environment variables are allowed in templates")
+)
+
+// secretFunctions binds the secret and env template functions to the
+// context's resolvers
+func (ctx *EventContext) secretFunctions() template.FuncMap {
+	return template.FuncMap{
+		"secret": func(name string) (string, error) {
+			if ctx.Secrets == nil {
+				return "", errNoSecrets
+			}
+			return ctx.Secrets.Resolve(name)
+		},
+		"env": func(name string) (string, error) {
+			if ctx.Env == nil {
+				return "", errNoEnv
+			}
+			return ctx.Env.Env(name)
+		},
+	}
+}
+
+// SetRedactor sets the redactor applied to instances and approvals before
+// they are written
+func (j *JSONPersistence) SetRedactor(redactor Redactor) {
+	j.redactor = redactor
+}
+
+// redact re-encodes JSON data with secret values replaced
+func (j *JSONPersistence) redact(data []byte) ([]byte, error) {
+	if j.redactor == nil {
+		return data, nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+
+	// Keep the original encoding when nothing needed redacting
+	redacted := j.redactor.RedactValue(value)
+	if reflect.DeepEqual(value, redacted) {
+		return data, nil
+	}
+	return json.MarshalIndent(redacted, "", "  ")
+}
@@ -0,0 +1,95 @@
+package persistence
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// StepState is the result of a step as seen by later steps under .steps.<name>
+type StepState struct {
+	Status  string                 `json:"status"`
+	Output  map[string]interface{} `json:"output,omitempty"`
+	Outputs map[string]interface{} `json:"outputs,omitempty"`
+	Error   string                 `json:"error,omitempty"`
+}
+
+// SetStepState records the result of a step
+func (ctx *EventContext) SetStepState(name string, state *StepState) {
+	if ctx.Steps == nil {
+		ctx.Steps = make(map[string]*StepState)
+	}
+	ctx.Steps[name] = state
+}
+
+// templateData builds the data templates are executed against
+func (ctx *EventContext) templateData() map[string]interface{} {
+	steps := make(map[string]interface{}, len(ctx.Steps))
+	for name, state := range ctx.Steps {
+		steps[name] = map[string]interface{}{
+			"status":  state.Status,
+			"output":  state.Output,
+			"outputs": state.Outputs,
+			"error":   state.Error,
+		}
+	}
+
+	return map[string]interface{}{
+		"event": map[string]interface{}{
+			"type":      ctx.Event.Type,
+			"payload":   ctx.Event.Payload,
+			"metadata":  ctx.Event.Metadata,
+			"timestamp": ctx.Event.Timestamp,
+		},
+		"variables": ctx.Variables,
+		"steps":     steps,
+	}
+}
+
+// ResolveValue resolves a template like ResolveTemplate, except that a
+// template consisting of a single reference such as "{{ .steps.a.output.id }}"
+// returns the referenced value with its original type
+func (ctx *EventContext) ResolveValue(templateStr string) (interface{}, error) {
+	path, ok := singleReference(templateStr)
+	if !ok {
+		return ctx.ResolveTemplate(templateStr)
+	}
+
+	value, found, err := lookupPath(ctx.templateData(), path)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if ctx.StrictTemplates {
+			return nil, fmt.Errorf("template execution error: map has no entry for .%s", path)
+		}
+		return nil, nil
+	}
+	return value, nil
+}
+
+// singleReference reports whether a template is exactly one field reference
+// and returns its dotted path
+func singleReference(templateStr string) (string, bool) {
+	trimmed := strings.TrimSpace(templateStr)
+	if !strings.HasPrefix(trimmed, "{{") || !strings.HasSuffix(trimmed, "}}") {
+		return "", false
+	}
+
+	tmpl, err := template.New("workflow").Funcs(templateFunctions()).Parse(trimmed)
+	if err != nil || tmpl.Tree == nil || len(tmpl.Tree.Root.Nodes) != 1 {
+		return "", false
+	}
+
+	action, ok := tmpl.Tree.Root.Nodes[0].(*parse.ActionNode)
+	if !ok || len(action.Pipe.Decl) > 0 || len(action.Pipe.Cmds) != 1 || len(action.Pipe.Cmds[0].Args) != 1 {
+		return "", false
+	}
+
+	field, ok := action.Pipe.Cmds[0].Args[0].(*parse.FieldNode)
+	if !ok {
+		return "", false
+	}
+	return strings.Join(field.Ident, "."), true
+}
@@ -0,0 +1,90 @@
+package persistence
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// InstanceSummary is the plaintext metadata of a workflow instance, readable
+// without decrypting the instance
+type InstanceSummary struct {
+	ID              string     `json:"id"`
+	WorkflowName    string     `json:"workflow_name"`
+	WorkflowVersion string     `json:"workflow_version,omitempty"`
+	CorrelationID   string     `json:"correlation_id,omitempty"`
+	CausationID     string     `json:"causation_id,omitempty"`
+	Status          string     `json:"status"`
+	StartTime       time.Time  `json:"start_time"`
+	EndTime         *time.Time `json:"end_time,omitempty"`
+	Encrypted       bool       `json:"encrypted"`
+}
+
+// instanceMetadata returns the fields of an instance kept in plaintext
+func instanceMetadata(instance *WorkflowInstance) map[string]interface{} {
+	metadata := map[string]interface{}{
+		"id":               instance.ID,
+		"workflow_name":    instance.WorkflowName,
+		"workflow_version": instance.WorkflowVersion,
+		"status":           instance.Status,
+		"start_time":       instance.StartTime,
+	}
+	if instance.EndTime != nil {
+		metadata["end_time"] = instance.EndTime
+	}
+	if instance.CorrelationID != "" {
+		metadata["correlation_id"] = instance.CorrelationID
+	}
+	if instance.CausationID != "" {
+		metadata["causation_id"] = instance.CausationID
+	}
+	return metadata
+}
+
+// approvalMetadata returns the fields of an approval kept in plaintext
+func approvalMetadata(approval *Approval) map[string]interface{} {
+	return map[string]interface{}{
+		"id":          approval.ID,
+		"instance_id": approval.InstanceID,
+		"workflow":    approval.Workflow,
+		"step":        approval.Step,
+		"status":      approval.Status,
+		"created_at":  approval.CreatedAt,
+		"expires_at":  approval.ExpiresAt,
+	}
+}
+
+// ListInstanceSummaries lists instance metadata without decrypting instances
+func (j *JSONPersistence) ListInstanceSummaries() ([]*InstanceSummary, error) {
+	files, err := filepath.Glob(filepath.Join(j.dataDir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list instance files: %w", err)
+	}
+
+	summaries := make([]*InstanceSummary, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue // Skip files that can't be read
+		}
+
+		encrypted := false
+		if env, ok := parseEnvelope(data); ok {
+			encrypted = true
+			if data, err = json.Marshal(env.Metadata); err != nil {
+				continue
+			}
+		}
+
+		var summary InstanceSummary
+		if err := json.Unmarshal(data, &summary); err != nil || summary.ID == "" {
+			continue // Skip files that can't be parsed
+		}
+		summary.Encrypted = encrypted
+		summaries = append(summaries, &summary)
+	}
+
+	return summaries, nil
+}
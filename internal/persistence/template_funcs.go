@@ -0,0 +1,399 @@
+package persistence
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	customFunctionsMu sync.RWMutex
+	customFunctions   = template.FuncMap{}
+)
+
+// RegisterTemplateFunction makes a Go function available to workflow
+// templates. Names of built-in functions cannot be reused.
+func RegisterTemplateFunction(name string, fn interface{}) error {
+	if name == "" {
+		return errors.New("template function name is required")
+	}
+	if reflect.TypeOf(fn) == nil || reflect.TypeOf(fn).Kind() != reflect.Func {
+		return fmt.Errorf("template function %s must be a function", name)
+	}
+	if _, exists := builtinFunctions()[name]; exists {
+		return fmt.Errorf("template function %s is built in and cannot be replaced", name)
+	}
+
+	// Let text/template check the name and signature
+	if err := tryFuncs(template.FuncMap{name: fn}); err != nil {
+		return fmt.Errorf("invalid template function %s: %v", name, err)
+	}
+
+	customFunctionsMu.Lock()
+	defer customFunctionsMu.Unlock()
+	customFunctions[name] = fn
+	return nil
+}
+
+// tryFuncs recovers the panic text/template raises for invalid functions
+func tryFuncs(funcs template.FuncMap) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	template.New("check").Funcs(funcs)
+	return nil
+}
+
+// registeredFunctions returns a copy of the custom template functions
+func registeredFunctions() template.FuncMap {
+	customFunctionsMu.RLock()
+	defer customFunctionsMu.RUnlock()
+
+	funcs := make(template.FuncMap, len(customFunctions))
+	for name, fn := range customFunctions {
+		funcs[name] = fn
+	}
+	return funcs
+}
+
+// libraryFunctions returns the general purpose template function library
+func libraryFunctions() template.FuncMap {
+	return template.FuncMap{
+		// JSON
+		"toJson":       toJSON,
+		"toPrettyJson": toPrettyJSON,
+		"fromJson":     fromJSON,
+
+		// Strings
+		"upper":      strings.ToUpper,
+		"lower":      strings.ToLower,
+		"trim":       strings.TrimSpace,
+		"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+		"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+		"hasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+		"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+		"split":      func(sep, s string) []string { return strings.Split(s, sep) },
+		"join":       join,
+		"toString":   toString,
+
+		// Time
+		"now":      time.Now,
+		"date":     formatDate,
+		"duration": time.ParseDuration,
+
+		// Encoding and hashing
+		"sha256":       sha256Hex,
+		"base64":       func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+		"base64Decode": base64Decode,
+		"urlquery":     url.QueryEscape,
+		"shellquote":   shellQuote,
+		"raw":          func(value interface{}) interface{} { return value },
+		"uuid":         func() string { return uuid.New().String() },
+
+		// Math
+		"add":   binaryOp(func(x, y float64) float64 { return x + y }),
+		"sub":   binaryOp(func(x, y float64) float64 { return x - y }),
+		"mul":   binaryOp(func(x, y float64) float64 { return x * y }),
+		"div":   divide,
+		"mod":   modulo,
+		"min":   binaryOp(math.Min),
+		"max":   binaryOp(math.Max),
+		"round": unaryOp(math.Round),
+		"floor": unaryOp(math.Floor),
+		"ceil":  unaryOp(math.Ceil),
+
+		// Data access
+		"get":      get,
+		"jsonpath": jsonPath,
+		"indexOr":  indexOr,
+	}
+}
+
+func toJSON(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func toPrettyJSON(value interface{}) (string, error) {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func fromJSON(s string) (interface{}, error) {
+	var value interface{}
+	if err := json.Unmarshal([]byte(s), &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// join joins a list of any element type with a separator
+func join(sep string, list interface{}) (string, error) {
+	if list == nil {
+		return "", nil
+	}
+	if strs, ok := list.([]string); ok {
+		return strings.Join(strs, sep), nil
+	}
+
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return "", fmt.Errorf("join: expected a list, got %T", list)
+	}
+	parts := make([]string, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		parts[i] = toString(v.Index(i).Interface())
+	}
+	return strings.Join(parts, sep), nil
+}
+
+// toString formats a value without the exponent notation fmt uses for large floats
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// formatDate formats a time, RFC 3339 string or Unix timestamp using a Go layout
+func formatDate(layout string, value interface{}) (string, error) {
+	t, err := toTime(value)
+	if err != nil {
+		return "", err
+	}
+	return t.Format(layout), nil
+}
+
+// toTime converts template values into a time
+func toTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case *time.Time:
+		if v != nil {
+			return *v, nil
+		}
+	case string:
+		return time.Parse(time.RFC3339Nano, v)
+	default:
+		if f, ok := toFloat(value); ok {
+			sec, frac := math.Modf(f)
+			return time.Unix(int64(sec), int64(frac*1e9)).UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("date: cannot convert %T to a time", value)
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func base64Decode(s string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// shellQuote quotes a value as a single POSIX shell word
+func shellQuote(value interface{}) string {
+	return "'" + strings.ReplaceAll(toString(value), "'", `'\''`) + "'"
+}
+
+// toFloat converts numeric values and numeric strings to float64
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func arith(a, b interface{}, op func(x, y float64) float64) (float64, error) {
+	x, ok := toFloat(a)
+	if !ok {
+		return 0, fmt.Errorf("expected a number, got %T", a)
+	}
+	y, ok := toFloat(b)
+	if !ok {
+		return 0, fmt.Errorf("expected a number, got %T", b)
+	}
+	return op(x, y), nil
+}
+
+// binaryOp adapts a float operation to accept any numeric template values
+func binaryOp(op func(x, y float64) float64) func(a, b interface{}) (float64, error) {
+	return func(a, b interface{}) (float64, error) {
+		return arith(a, b, op)
+	}
+}
+
+// unaryOp adapts a float function to accept any numeric template value
+func unaryOp(op func(x float64) float64) func(a interface{}) (float64, error) {
+	return func(a interface{}) (float64, error) {
+		x, ok := toFloat(a)
+		if !ok {
+			return 0, fmt.Errorf("expected a number, got %T", a)
+		}
+		return op(x), nil
+	}
+}
+
+func divide(a, b interface{}) (float64, error) {
+	y, ok := toFloat(b)
+	if ok && y == 0 {
+		return 0, errors.New("division by zero")
+	}
+	return arith(a, b, func(x, y float64) float64 { return x / y })
+}
+
+func modulo(a, b interface{}) (float64, error) {
+	y, ok := toFloat(b)
+	if ok && y == 0 {
+		return 0, errors.New("division by zero")
+	}
+	return arith(a, b, math.Mod)
+}
+
+// get looks up a dotted path such as "body.items[0].id" and returns the
+// optional default when any part of the path is missing
+func get(data interface{}, path string, defaultValue ...interface{}) (interface{}, error) {
+	value, found, err := lookupPath(data, path)
+	if err != nil {
+		return nil, err
+	}
+	if !found && len(defaultValue) > 0 {
+		return defaultValue[0], nil
+	}
+	return value, nil
+}
+
+// jsonPath looks up a simple JSONPath expression such as "$.body.items[0].id"
+func jsonPath(data interface{}, path string) (interface{}, error) {
+	value, _, err := JSONPath(data, path)
+	return value, err
+}
+
+// JSONPath looks up a simple JSONPath expression such as "$.items[0].id" and
+// reports whether it was found
+func JSONPath(data interface{}, path string) (interface{}, bool, error) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	return lookupPath(data, path)
+}
+
+// indexOr indexes a map or list and returns the default when the key is missing
+func indexOr(collection, key, defaultValue interface{}) interface{} {
+	v := reflect.ValueOf(collection)
+	switch v.Kind() {
+	case reflect.Map:
+		k := reflect.ValueOf(key)
+		if !k.IsValid() || !k.Type().AssignableTo(v.Type().Key()) {
+			return defaultValue
+		}
+		if item := v.MapIndex(k); item.IsValid() {
+			return item.Interface()
+		}
+	case reflect.Slice, reflect.Array:
+		if f, ok := toFloat(key); ok && f >= 0 && int(f) < v.Len() {
+			return v.Index(int(f)).Interface()
+		}
+	}
+	return defaultValue
+}
+
+// lookupPath walks maps and lists following a dotted path with [n] indexes
+func lookupPath(data interface{}, path string) (interface{}, bool, error) {
+	current := data
+	if path == "" {
+		return current, true, nil
+	}
+
+	for _, part := range strings.Split(path, ".") {
+		name := part
+		var indexes []int
+		if open := strings.Index(part, "["); open >= 0 {
+			name = part[:open]
+			for rest := part[open:]; rest != ""; {
+				end := strings.Index(rest, "]")
+				if rest[0] != '[' || end < 0 {
+					return nil, false, fmt.Errorf("invalid path segment %q", part)
+				}
+				n, err := strconv.Atoi(rest[1:end])
+				if err != nil {
+					return nil, false, fmt.Errorf("invalid index in path segment %q", part)
+				}
+				indexes = append(indexes, n)
+				rest = rest[end+1:]
+			}
+		}
+
+		keys := make([]interface{}, 0, len(indexes)+1)
+		if name != "" {
+			keys = append(keys, name)
+		}
+		for _, n := range indexes {
+			keys = append(keys, n)
+		}
+		for _, key := range keys {
+			next := indexOr(current, key, missing{})
+			if _, absent := next.(missing); absent {
+				return nil, false, nil
+			}
+			current = next
+		}
+	}
+
+	return current, true, nil
+}
+
+// missing marks absent keys during path lookups
+type missing struct{}
@@ -0,0 +1,60 @@
+package persistence
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type envStub map[string]string
+
+func (e envStub) Env(name string) (string, error) {
+	value, ok := e[name]
+	if !ok {
+		return "", errors.New("not allowed")
+	}
+	return value, nil
+}
+
+func TestEnvFunction(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     EnvResolver
+		want    string
+		wantErr bool
+	}{
+		{name: "no resolver", wantErr: true},
+		{name: "allowed", env: envStub{"APP_REGION": "eu-west-1"}, want: "eu-west-1"},
+		{name: "not allowed", env: envStub{}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := &EventContext{Event: &Event{}, Env: tt.env}
+			got, err := ctx.ResolveTemplate(`{{ env "APP_REGION" }}`)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterTemplateFunctionRejectsBuiltins(t *testing.T) {
+	for _, name := range []string{"shellquote", "raw", "secret", "env", "default", "toJson"} {
+		err := RegisterTemplateFunction(name, func(s string) string { return s })
+		if err == nil || !strings.Contains(err.Error(), "built in") {
+			t.Errorf("RegisterTemplateFunction(%q) err = %v, want built-in error", name, err)
+		}
+	}
+
+	if err := RegisterTemplateFunction("slugTest", strings.ToLower); err != nil {
+		t.Fatalf("RegisterTemplateFunction: %v", err)
+	}
+	got, err := (&EventContext{Event: &Event{}}).ResolveTemplate(`{{ slugTest "ABC" }}`)
+	if err != nil || got != "abc" {
+		t.Errorf("got %q, %v", got, err)
+	}
+}
@@ -0,0 +1,71 @@
+package persistence
+
+import (
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// TemplateReferences returns the dotted data paths referenced by a template,
+// such as "event.payload.email". References inside range and with blocks are
+// relative to a different dot and are not returned.
+func TemplateReferences(templateStr string) ([]string, error) {
+	tmpl, err := template.New("workflow").
+		Funcs(templateFunctions()).
+		Parse(templateStr)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make([]string, 0)
+	if tmpl.Tree != nil {
+		collectReferences(tmpl.Tree.Root, &refs)
+	}
+	return refs, nil
+}
+
+// collectReferences walks a template parse tree collecting root-relative fields
+func collectReferences(node parse.Node, refs *[]string) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			collectReferences(child, refs)
+		}
+	case *parse.ActionNode:
+		collectReferences(n.Pipe, refs)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			collectReferences(cmd, refs)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			collectReferences(arg, refs)
+		}
+	case *parse.FieldNode:
+		*refs = append(*refs, strings.Join(n.Ident, "."))
+	case *parse.VariableNode:
+		// $ always refers to the root data
+		if len(n.Ident) > 1 && n.Ident[0] == "$" {
+			*refs = append(*refs, strings.Join(n.Ident[1:], "."))
+		}
+	case *parse.ChainNode:
+		collectReferences(n.Node, refs)
+	case *parse.IfNode:
+		collectReferences(n.Pipe, refs)
+		collectReferences(n.List, refs)
+		collectReferences(n.ElseList, refs)
+	case *parse.RangeNode:
+		// Only the range pipeline is evaluated against the root
+		collectReferences(n.Pipe, refs)
+		collectReferences(n.ElseList, refs)
+	case *parse.WithNode:
+		collectReferences(n.Pipe, refs)
+		collectReferences(n.ElseList, refs)
+	}
+}
@@ -0,0 +1,89 @@
+package persistence
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// WorkflowVersionRecord is a registered revision of a workflow definition,
+// kept so instances paused on it can resume after a restart
+type WorkflowVersionRecord struct {
+	Name         string    `json:"name"`
+	Version      string    `json:"version"`
+	Checksum     string    `json:"checksum"`
+	RegisteredAt time.Time `json:"registered_at"`
+	// Definition is the workflow as YAML
+	Definition string `json:"definition"`
+}
+
+// versionDir returns the directory holding workflow version records
+func (j *JSONPersistence) versionDir() string {
+	return filepath.Join(j.dataDir, "versions")
+}
+
+// versionMetadata returns the fields of a version record kept in plaintext
+func versionMetadata(record *WorkflowVersionRecord) map[string]interface{} {
+	return map[string]interface{}{
+		"name":          record.Name,
+		"version":       record.Version,
+		"checksum":      record.Checksum,
+		"registered_at": record.RegisteredAt,
+	}
+}
+
+// SaveWorkflowVersion saves a workflow version record to a JSON file
+func (j *JSONPersistence) SaveWorkflowVersion(record *WorkflowVersionRecord) error {
+	if err := os.MkdirAll(j.versionDir(), 0700); err != nil {
+		return fmt.Errorf("failed to create version directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal workflow version: %w", err)
+	}
+
+	data, err = j.seal(data, versionMetadata(record))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt workflow version: %w", err)
+	}
+
+	name := fmt.Sprintf("%s@%s.json", url.PathEscape(record.Name), url.PathEscape(record.Version))
+	if err := writeRecord(filepath.Join(j.versionDir(), name), data); err != nil {
+		return fmt.Errorf("failed to write workflow version file: %w", err)
+	}
+
+	return nil
+}
+
+// ListWorkflowVersionRecords retrieves all workflow version records
+func (j *JSONPersistence) ListWorkflowVersionRecords() ([]*WorkflowVersionRecord, error) {
+	files, err := filepath.Glob(filepath.Join(j.versionDir(), "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workflow version files: %w", err)
+	}
+
+	records := make([]*WorkflowVersionRecord, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue // Skip files that can't be read
+		}
+
+		data, err = j.open(data)
+		if err != nil {
+			continue // Skip files that can't be decrypted
+		}
+
+		var record WorkflowVersionRecord
+		if err := json.Unmarshal(data, &record); err != nil || record.Name == "" {
+			continue // Skip files that can't be parsed
+		}
+		records = append(records, &record)
+	}
+
+	return records, nil
+}
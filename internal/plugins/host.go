@@ -0,0 +1,237 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/logimos/conduktr/internal/actions"
+
+	"go.uber.org/zap"
+)
+
+// stopTimeout is how long plugins get to exit after their stdin is closed
+const stopTimeout = 5 * time.Second
+
+// Host runs the plugin executables in a directory and exposes their actions
+type Host struct {
+	logger *zap.Logger
+	dir    string
+	// envPassthrough lists daemon variables plugins receive besides the
+	// defaults; names ending in * match a prefix
+	envPassthrough []string
+
+	mu      sync.Mutex
+	plugins []*Plugin
+}
+
+// PluginInfo describes a running plugin for the API
+type PluginInfo struct {
+	Name     string        `json:"name"`
+	Version  string        `json:"version,omitempty"`
+	Path     string        `json:"path"`
+	Running  bool          `json:"running"`
+	Restarts int           `json:"restarts"`
+	Actions  []ActionSpec  `json:"actions"`
+	Triggers []TriggerSpec `json:"triggers,omitempty"`
+}
+
+// NewHost creates a plugin host for a directory
+func NewHost(logger *zap.Logger, dir string) *Host {
+	return &Host{
+		logger: logger,
+		dir:    dir,
+	}
+}
+
+// SetEnvPassthrough names daemon environment variables plugins receive in
+// addition to PATH, HOME, TMPDIR, LANG and TZ; call it before Start
+func (h *Host) SetEnvPassthrough(names ...string) {
+	h.envPassthrough = names
+}
+
+// Start launches every executable in the directory. Plugins that fail to
+// start are logged and skipped; a missing directory means no plugins.
+func (h *Host) Start() error {
+	entries, err := os.ReadDir(h.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read plugin directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || info.IsDir() || info.Mode()&0111 == 0 {
+			continue
+		}
+
+		path, err := filepath.Abs(filepath.Join(h.dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		plugin := newPlugin(path, h.logger)
+		plugin.env = pluginEnv(h.envPassthrough)
+		if err := plugin.start(); err != nil {
+			h.logger.Error("Failed to start plugin", zap.String("path", path), zap.Error(err))
+			continue
+		}
+
+		manifest := plugin.Manifest()
+		h.logger.Info("Plugin started",
+			zap.String("plugin", manifest.Name),
+			zap.String("version", manifest.Version),
+			zap.Int("actions", len(manifest.Actions)),
+			zap.Int("triggers", len(manifest.Triggers)))
+
+		h.mu.Lock()
+		h.plugins = append(h.plugins, plugin)
+		h.mu.Unlock()
+	}
+
+	return nil
+}
+
+// Register adds the plugins' actions to a registry. Names already taken by
+// built-in actions or earlier plugins are skipped.
+func (h *Host) Register(registry *actions.Registry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, plugin := range h.plugins {
+		manifest := plugin.Manifest()
+		for _, spec := range manifest.Actions {
+			if _, err := registry.GetAction(spec.Name); err == nil {
+				h.logger.Warn("Plugin action name already registered, skipping",
+					zap.String("plugin", manifest.Name),
+					zap.String("action", spec.Name))
+				continue
+			}
+			registry.RegisterAction(spec.Name, &pluginAction{plugin: plugin, name: spec.Name})
+		}
+	}
+}
+
+// ProvidesTrigger reports whether a plugin provides a trigger type
+func (h *Host) ProvidesTrigger(kind string) bool {
+	_, _, ok := h.triggerPlugin(kind)
+	return ok
+}
+
+// StartTrigger starts a trigger in the plugin that provides its type.
+// Events are passed to handler, which must not block.
+func (h *Host) StartTrigger(ctx context.Context, kind, name string, config map[string]interface{}, handler func(Event)) (*TriggerSession, error) {
+	plugin, spec, ok := h.triggerPlugin(kind)
+	if !ok {
+		return nil, fmt.Errorf("no plugin provides trigger type %s", kind)
+	}
+	if err := checkRequired(spec.ConfigSchema, config); err != nil {
+		return nil, err
+	}
+	if config == nil {
+		config = make(map[string]interface{})
+	}
+	return plugin.startTrigger(ctx, TriggerStartParams{Type: kind, Name: name, Config: config}, handler)
+}
+
+// triggerPlugin finds the first plugin providing a trigger type
+func (h *Host) triggerPlugin(kind string) (*Plugin, TriggerSpec, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, plugin := range h.plugins {
+		for _, spec := range plugin.Manifest().Triggers {
+			if spec.Type == kind {
+				return plugin, spec, true
+			}
+		}
+	}
+	return nil, TriggerSpec{}, false
+}
+
+// Plugins describes the running plugins
+func (h *Host) Plugins() []PluginInfo {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	infos := make([]PluginInfo, 0, len(h.plugins))
+	for _, plugin := range h.plugins {
+		plugin.mu.Lock()
+		infos = append(infos, PluginInfo{
+			Name:     plugin.manifest.Name,
+			Version:  plugin.manifest.Version,
+			Path:     plugin.path,
+			Running:  plugin.proc != nil,
+			Restarts: plugin.restarts,
+			Actions:  plugin.manifest.Actions,
+			Triggers: plugin.manifest.Triggers,
+		})
+		plugin.mu.Unlock()
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// Stop asks every plugin to exit
+func (h *Host) Stop() {
+	h.mu.Lock()
+	plugins := h.plugins
+	h.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, plugin := range plugins {
+		wg.Add(1)
+		go func(plugin *Plugin) {
+			defer wg.Done()
+			plugin.stop(stopTimeout)
+		}(plugin)
+	}
+	wg.Wait()
+}
+
+// pluginAction forwards Execute calls to a plugin
+type pluginAction struct {
+	plugin *Plugin
+	name   string
+}
+
+// Execute checks required inputs against the plugin's current manifest and
+// calls the plugin, passing the context deadline so it can stop in time
+func (a *pluginAction) Execute(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+	spec, ok := a.plugin.Manifest().action(a.name)
+	if !ok {
+		return nil, fmt.Errorf("plugin %s no longer provides action %s", a.plugin.Manifest().Name, a.name)
+	}
+	if err := checkRequired(spec.InputSchema, input); err != nil {
+		return nil, err
+	}
+
+	params := ExecuteParams{
+		Action: a.name,
+		Input:  input,
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		params.Deadline = &deadline
+	}
+	if info, ok := actions.StepInfoFromContext(ctx); ok {
+		params.Workflow = info.Workflow
+		params.Step = info.Step
+		params.InstanceID = info.InstanceID
+	}
+
+	var result ExecuteResult
+	if err := a.plugin.Call(ctx, MethodExecute, params, &result); err != nil {
+		var callErr *CallError
+		if errors.As(err, &callErr) {
+			return callErr.Output, err
+		}
+		return nil, fmt.Errorf("plugin action %s failed: %w", a.name, err)
+	}
+	return result.Output, nil
+}
@@ -0,0 +1,492 @@
+package plugins
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// describeTimeout bounds how long a starting plugin may take to describe itself
+	describeTimeout = 10 * time.Second
+	// maxRestartDelay caps the backoff between restarts of a crashing plugin
+	maxRestartDelay = 30 * time.Second
+	// stableRuntime resets the backoff once a plugin has run this long
+	stableRuntime = time.Minute
+	// maxMessageSize bounds a single protocol message
+	maxMessageSize = 16 << 20
+)
+
+// ErrPluginStopped is returned for calls made after the host stopped
+var ErrPluginStopped = errors.New("plugin stopped")
+
+// Plugin is a running plugin executable, restarted when it exits
+type Plugin struct {
+	path   string
+	logger *zap.Logger
+	// env is the environment the executable starts with
+	env []string
+
+	mu       sync.Mutex
+	manifest *Manifest
+	proc     *process
+	ready    chan struct{} // closed while a process is running
+	stopped  bool
+	restarts int
+	triggers map[string]*TriggerSession
+}
+
+// process is one run of a plugin executable
+type process struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan *Response
+	exited  chan struct{}
+	err     error
+
+	// notify handles messages that are not responses
+	notify func(Notification)
+}
+
+// defaultEnv lists the daemon variables every plugin receives; keys and
+// secrets in the daemon's environment are not passed on
+var defaultEnv = []string{"PATH", "HOME", "TMPDIR", "LANG", "TZ"}
+
+// pluginEnv returns the default variables and those named in passthrough
+// from the daemon's environment; names ending in * match a prefix
+func pluginEnv(passthrough []string) []string {
+	names := append(append([]string{}, defaultEnv...), passthrough...)
+	env := make([]string, 0)
+	for _, entry := range os.Environ() {
+		name := entry
+		if i := strings.Index(entry, "="); i >= 0 {
+			name = entry[:i]
+		}
+		for _, pattern := range names {
+			if name == pattern || (strings.HasSuffix(pattern, "*") && strings.HasPrefix(name, strings.TrimSuffix(pattern, "*"))) {
+				env = append(env, entry)
+				break
+			}
+		}
+	}
+	return env
+}
+
+// newPlugin creates a plugin for an executable; call start to launch it
+func newPlugin(path string, logger *zap.Logger) *Plugin {
+	return &Plugin{
+		path:   path,
+		logger: logger.With(zap.String("plugin", filepath.Base(path))),
+		ready:  make(chan struct{}),
+	}
+}
+
+// Manifest returns what the plugin advertised when it last started
+func (p *Plugin) Manifest() *Manifest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.manifest
+}
+
+// start launches the plugin and reads its manifest
+func (p *Plugin) start() error {
+	proc, err := p.launch()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), describeTimeout)
+	defer cancel()
+
+	var manifest Manifest
+	if err := proc.call(ctx, MethodDescribe, nil, &manifest); err != nil {
+		proc.kill()
+		return fmt.Errorf("plugin %s did not describe itself: %w", filepath.Base(p.path), err)
+	}
+	if manifest.Name == "" {
+		manifest.Name = filepath.Base(p.path)
+	}
+
+	p.mu.Lock()
+	if p.stopped {
+		p.mu.Unlock()
+		proc.kill()
+		return ErrPluginStopped
+	}
+	p.manifest = &manifest
+	p.proc = proc
+	close(p.ready)
+	p.mu.Unlock()
+
+	go p.supervise(proc, time.Now())
+	return nil
+}
+
+// launch starts the executable and the goroutines that read its output
+func (p *Plugin) launch() (*process, error) {
+	cmd := exec.Command(p.path)
+	cmd.Dir = filepath.Dir(p.path)
+	cmd.Env = p.env
+	if cmd.Env == nil {
+		cmd.Env = pluginEnv(nil)
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start plugin %s: %w", filepath.Base(p.path), err)
+	}
+
+	proc := &process{
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: make(map[uint64]chan *Response),
+		exited:  make(chan struct{}),
+		notify:  p.handleNotification,
+	}
+
+	// Plugin stderr goes to the log
+	stderrDone := make(chan struct{})
+	go func() {
+		defer close(stderrDone)
+		scanner := bufio.NewScanner(stderr)
+		scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+		for scanner.Scan() {
+			p.logger.Info("Plugin output", zap.String("line", scanner.Text()))
+		}
+		// Keep draining after an oversized line so the plugin never blocks
+		io.Copy(io.Discard, stderr)
+	}()
+
+	go func() {
+		// A broken stream cannot be resynchronised; restart the plugin
+		readErr := proc.readResponses(stdout, p.logger)
+		if readErr != nil {
+			p.logger.Error("Plugin output unreadable, stopping plugin", zap.Error(readErr))
+			proc.kill()
+			io.Copy(io.Discard, stdout)
+		}
+
+		// Wait must not run until the pipes have been read to the end
+		<-stderrDone
+		err := cmd.Wait()
+		if readErr != nil {
+			err = readErr
+		}
+		proc.finish(err)
+	}()
+
+	return proc, nil
+}
+
+// supervise restarts the plugin with backoff whenever its process exits
+func (p *Plugin) supervise(proc *process, started time.Time) {
+	delay := time.Second
+	for {
+		<-proc.exited
+
+		p.mu.Lock()
+		if p.stopped {
+			p.mu.Unlock()
+			return
+		}
+		p.ready = make(chan struct{})
+		p.proc = nil
+		p.restarts++
+		sessions := p.takeSessions()
+		p.mu.Unlock()
+
+		// Triggers do not survive the process; their owners start them again
+		for _, session := range sessions {
+			session.finish(fmt.Errorf("plugin exited: %w", proc.err))
+		}
+
+		if time.Since(started) > stableRuntime {
+			delay = time.Second
+		}
+		p.logger.Warn("Plugin exited, restarting",
+			zap.Error(proc.err),
+			zap.Duration("delay", delay))
+
+		for {
+			time.Sleep(delay)
+			if delay *= 2; delay > maxRestartDelay {
+				delay = maxRestartDelay
+			}
+
+			p.mu.Lock()
+			stopped := p.stopped
+			p.mu.Unlock()
+			if stopped {
+				return
+			}
+
+			next, err := p.restart()
+			if err == nil {
+				proc, started = next, time.Now()
+				break
+			}
+			p.logger.Error("Plugin restart failed", zap.Error(err))
+		}
+	}
+}
+
+// restart launches a new process and reads its manifest again
+func (p *Plugin) restart() (*process, error) {
+	proc, err := p.launch()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), describeTimeout)
+	defer cancel()
+
+	var manifest Manifest
+	if err := proc.call(ctx, MethodDescribe, nil, &manifest); err != nil {
+		proc.kill()
+		return nil, err
+	}
+	if manifest.Name == "" {
+		manifest.Name = filepath.Base(p.path)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped {
+		proc.kill()
+		return nil, ErrPluginStopped
+	}
+	for _, previous := range p.manifest.Actions {
+		if !manifest.provides(previous.Name) {
+			p.logger.Warn("Restarted plugin no longer provides action", zap.String("action", previous.Name))
+		}
+	}
+	for _, previous := range p.manifest.Triggers {
+		if !manifest.providesTrigger(previous.Type) {
+			p.logger.Warn("Restarted plugin no longer provides trigger", zap.String("trigger", previous.Type))
+		}
+	}
+	// Calls are checked against what the new process advertises
+	p.manifest = &manifest
+	p.proc = proc
+	close(p.ready)
+	p.logger.Info("Plugin restarted")
+	return proc, nil
+}
+
+// Call sends a request, waiting for the plugin to be running first
+func (p *Plugin) Call(ctx context.Context, method string, params, result interface{}) error {
+	for {
+		p.mu.Lock()
+		proc, ready, stopped := p.proc, p.ready, p.stopped
+		p.mu.Unlock()
+
+		if stopped {
+			return ErrPluginStopped
+		}
+		if proc != nil {
+			return proc.call(ctx, method, params, result)
+		}
+
+		select {
+		case <-ready:
+		case <-ctx.Done():
+			return fmt.Errorf("plugin %s is not running: %w", filepath.Base(p.path), ctx.Err())
+		}
+	}
+}
+
+// stop closes the plugin's stdin and kills it if it does not exit in time
+func (p *Plugin) stop(timeout time.Duration) {
+	p.mu.Lock()
+	p.stopped = true
+	proc := p.proc
+	sessions := p.takeSessions()
+	p.mu.Unlock()
+
+	for _, session := range sessions {
+		session.finish(ErrPluginStopped)
+	}
+
+	if proc == nil {
+		return
+	}
+	proc.stdin.Close()
+	select {
+	case <-proc.exited:
+	case <-time.After(timeout):
+		proc.kill()
+		<-proc.exited
+	}
+}
+
+// call sends a request and waits for its response. Cancelling ctx sends a
+// cancel notification and returns without waiting.
+func (proc *process) call(ctx context.Context, method string, params, result interface{}) error {
+	proc.mu.Lock()
+	if proc.err != nil {
+		proc.mu.Unlock()
+		return fmt.Errorf("plugin exited: %w", proc.err)
+	}
+	proc.nextID++
+	id := proc.nextID
+	reply := make(chan *Response, 1)
+	proc.pending[id] = reply
+	proc.mu.Unlock()
+
+	defer func() {
+		proc.mu.Lock()
+		delete(proc.pending, id)
+		proc.mu.Unlock()
+	}()
+
+	if err := proc.send(Request{ID: id, Method: method, Params: params}); err != nil {
+		return fmt.Errorf("failed to send request to plugin: %w", err)
+	}
+
+	select {
+	case resp := <-reply:
+		if resp == nil {
+			return fmt.Errorf("plugin exited during %s: %w", method, proc.err)
+		}
+		if resp.Error != nil {
+			return &CallError{Message: resp.Error.Message, Output: resp.Error.Output}
+		}
+		if result != nil && len(resp.Result) > 0 {
+			if err := json.Unmarshal(resp.Result, result); err != nil {
+				return fmt.Errorf("invalid %s result from plugin: %w", method, err)
+			}
+		}
+		return nil
+	case <-ctx.Done():
+		proc.send(Request{Method: MethodCancel, Params: CancelParams{ID: id}})
+		return ctx.Err()
+	}
+}
+
+// send writes one message to the plugin
+func (proc *process) send(req Request) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	proc.writeMu.Lock()
+	defer proc.writeMu.Unlock()
+	_, err = proc.stdin.Write(append(data, '\n'))
+	return err
+}
+
+// readResponses delivers responses to their callers and notifications to
+// the plugin until stdout closes. It returns an error if a message could not
+// be read, such as one larger than maxMessageSize.
+func (proc *process) readResponses(stdout io.Reader, logger *zap.Logger) error {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+	for scanner.Scan() {
+		var msg struct {
+			Response
+			Notification
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			logger.Warn("Ignoring invalid plugin message", zap.Error(err))
+			continue
+		}
+		if msg.Method != "" {
+			proc.notify(msg.Notification)
+			continue
+		}
+
+		resp := msg.Response
+		proc.mu.Lock()
+		reply, exists := proc.pending[resp.ID]
+		delete(proc.pending, resp.ID)
+		proc.mu.Unlock()
+		if exists {
+			reply <- &resp
+		}
+	}
+	return scanner.Err()
+}
+
+// finish records the exit and fails outstanding calls
+func (proc *process) finish(err error) {
+	if err == nil {
+		err = errors.New("exited")
+	}
+	proc.mu.Lock()
+	proc.err = err
+	for id, reply := range proc.pending {
+		close(reply)
+		delete(proc.pending, id)
+	}
+	proc.mu.Unlock()
+	close(proc.exited)
+}
+
+// kill terminates the process
+func (proc *process) kill() {
+	if proc.cmd.Process != nil {
+		proc.cmd.Process.Kill()
+	}
+}
+
+// provides reports whether the manifest lists an action
+func (m *Manifest) provides(name string) bool {
+	_, ok := m.action(name)
+	return ok
+}
+
+// action returns the spec of an action the manifest lists
+func (m *Manifest) action(name string) (ActionSpec, bool) {
+	for _, action := range m.Actions {
+		if action.Name == name {
+			return action, true
+		}
+	}
+	return ActionSpec{}, false
+}
+
+// providesTrigger reports whether the manifest lists a trigger type
+func (m *Manifest) providesTrigger(kind string) bool {
+	for _, trigger := range m.Triggers {
+		if trigger.Type == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// CallError is an error returned by a plugin
+type CallError struct {
+	Message string
+	Output  map[string]interface{}
+}
+
+func (e *CallError) Error() string {
+	return e.Message
+}
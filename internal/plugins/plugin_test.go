@@ -0,0 +1,99 @@
+package plugins
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// flakyPlugin answers describe, then breaks the protocol with a message
+// larger than maxMessageSize. Later runs advertise a different action.
+const flakyPlugin = `#!/bin/sh
+runs="$(dirname "$0")/runs"
+n=$(cat "$runs" 2>/dev/null || echo 0)
+n=$((n + 1))
+echo $n > "$runs"
+read line
+if [ $n -eq 1 ]; then
+  echo '{"id":1,"result":{"name":"demo","actions":[{"name":"demo.old"}]}}'
+  read line
+  head -c 17000000 /dev/zero | tr '\0' 'a'
+  echo
+else
+  echo '{"id":1,"result":{"name":"demo","actions":[{"name":"demo.new"}]}}'
+fi
+while read line; do :; done
+`
+
+func TestPluginRestartsOnOversizedMessage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "demo")
+	if err := os.WriteFile(path, []byte(flakyPlugin), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	plugin := newPlugin(path, zap.NewNop())
+	if err := plugin.start(); err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.stop(time.Second)
+
+	old := &pluginAction{plugin: plugin, name: "demo.old"}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := old.Execute(ctx, nil); err == nil || !strings.Contains(err.Error(), "token too long") {
+		t.Fatalf("err = %v, want the oversized message to end the call", err)
+	}
+
+	// The restarted process advertises a new manifest
+	deadline := time.Now().Add(10 * time.Second)
+	for !plugin.Manifest().provides("demo.new") {
+		if time.Now().After(deadline) {
+			t.Fatal("plugin was not restarted with its new manifest")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if _, err := old.Execute(ctx, nil); err == nil || !strings.Contains(err.Error(), "no longer provides") {
+		t.Fatalf("err = %v, want the removed action to be refused", err)
+	}
+}
+
+func TestPluginEnvironment(t *testing.T) {
+	t.Setenv("CONDUKTR_DATA_KEY", "data-key")
+	t.Setenv("CONDUKTR_SECRET_API", "secret")
+	t.Setenv("APP_MODE", "ci")
+
+	dir := t.TempDir()
+	out := filepath.Join(t.TempDir(), "env")
+	script := "#!/bin/sh\nenv > " + out + "\nread line\necho '{\"id\":1,\"result\":{\"name\":\"env\"}}'\nwhile read line; do :; done\n"
+	if err := os.WriteFile(filepath.Join(dir, "env"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	host := NewHost(zap.NewNop(), dir)
+	host.SetEnvPassthrough("APP_*")
+	if err := host.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer host.Stop()
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := string(data)
+	for _, name := range []string{"CONDUKTR_DATA_KEY", "CONDUKTR_SECRET_API"} {
+		if strings.Contains(env, name+"=") {
+			t.Errorf("plugin received %s", name)
+		}
+	}
+	for _, entry := range []string{"APP_MODE=ci", "PATH="} {
+		if !strings.Contains(env, entry) {
+			t.Errorf("plugin environment lacks %s:\n%s", entry, env)
+		}
+	}
+}
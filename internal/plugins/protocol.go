@@ -0,0 +1,134 @@
+package plugins
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// The plugin protocol is newline-delimited JSON over the plugin's stdin and
+// stdout. The host sends requests; the plugin answers each with a response
+// carrying the same id, in any order. Anything written to stderr is logged.
+//
+//	-> {"id":1,"method":"describe"}
+//	<- {"id":1,"result":{"name":"slack","actions":[{"name":"slack.post","input_schema":{...}}]}}
+//	-> {"id":2,"method":"execute","params":{"action":"slack.post","input":{...},"deadline":"..."}}
+//	<- {"id":2,"result":{"output":{...}}}
+//	-> {"method":"cancel","params":{"id":2}}
+//
+// Plugins that list triggers in their manifest are asked to start them. A
+// running trigger sends event notifications, without an id, until it is
+// stopped; trigger.failed reports that it can no longer deliver events.
+//
+//	-> {"id":3,"method":"trigger.start","params":{"type":"github.poll","name":"repos","config":{...}}}
+//	<- {"id":3,"result":{}}
+//	<- {"method":"event","params":{"trigger":"repos","event":"github.push","data":{...}}}
+//	<- {"method":"trigger.failed","params":{"trigger":"repos","error":"..."}}
+//	-> {"id":4,"method":"trigger.stop","params":{"name":"repos"}}
+//
+// Closing stdin asks the plugin to exit.
+const (
+	MethodDescribe      = "describe"
+	MethodExecute       = "execute"
+	MethodCancel        = "cancel"
+	MethodTriggerStart  = "trigger.start"
+	MethodTriggerStop   = "trigger.stop"
+	MethodEvent         = "event"
+	MethodTriggerFailed = "trigger.failed"
+)
+
+// Request is a message from the host to a plugin. Notifications such as
+// cancel have no id and get no response.
+type Request struct {
+	ID     uint64      `json:"id,omitempty"`
+	Method string      `json:"method"`
+	Params interface{} `json:"params,omitempty"`
+}
+
+// Response answers a request
+type Response struct {
+	ID     uint64          `json:"id"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *Error          `json:"error,omitempty"`
+}
+
+// Notification is a message from a plugin that is not a response
+type Notification struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// Error is a failed request. Output is kept with the step like the output
+// of a failed built-in action.
+type Error struct {
+	Message string                 `json:"message"`
+	Output  map[string]interface{} `json:"output,omitempty"`
+}
+
+// Manifest describes a plugin and the actions and triggers it provides
+type Manifest struct {
+	Name     string        `json:"name"`
+	Version  string        `json:"version,omitempty"`
+	Actions  []ActionSpec  `json:"actions"`
+	Triggers []TriggerSpec `json:"triggers,omitempty"`
+}
+
+// ActionSpec describes one action. InputSchema is a JSON Schema for the
+// step's input; its "required" list is checked before calls are sent.
+type ActionSpec struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"input_schema,omitempty"`
+}
+
+// TriggerSpec describes one trigger type. ConfigSchema is a JSON Schema for
+// the trigger's configuration; its "required" list is checked before starting.
+type TriggerSpec struct {
+	Type         string                 `json:"type"`
+	Description  string                 `json:"description,omitempty"`
+	ConfigSchema map[string]interface{} `json:"config_schema,omitempty"`
+}
+
+// ExecuteParams are the parameters of an execute request
+type ExecuteParams struct {
+	Action     string                 `json:"action"`
+	Input      map[string]interface{} `json:"input"`
+	Deadline   *time.Time             `json:"deadline,omitempty"`
+	Workflow   string                 `json:"workflow,omitempty"`
+	Step       string                 `json:"step,omitempty"`
+	InstanceID string                 `json:"instance_id,omitempty"`
+}
+
+// ExecuteResult is the result of an execute request
+type ExecuteResult struct {
+	Output map[string]interface{} `json:"output"`
+}
+
+// CancelParams identify the request to cancel
+type CancelParams struct {
+	ID uint64 `json:"id"`
+}
+
+// TriggerStartParams are the parameters of a trigger.start request
+type TriggerStartParams struct {
+	Type   string                 `json:"type"`
+	Name   string                 `json:"name"`
+	Config map[string]interface{} `json:"config"`
+}
+
+// TriggerStopParams are the parameters of a trigger.stop request
+type TriggerStopParams struct {
+	Name string `json:"name"`
+}
+
+// Event is an event notification from a running trigger
+type Event struct {
+	Trigger string                 `json:"trigger"`
+	Event   string                 `json:"event"`
+	Data    map[string]interface{} `json:"data"`
+}
+
+// TriggerFailure is a trigger.failed notification
+type TriggerFailure struct {
+	Trigger string `json:"trigger"`
+	Error   string `json:"error"`
+}
@@ -0,0 +1,164 @@
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// TriggerSession is a trigger running inside a plugin. It ends when the
+// trigger is stopped, reports a failure, or the plugin process exits.
+type TriggerSession struct {
+	plugin  *Plugin
+	name    string
+	handler func(Event)
+
+	once sync.Once
+	done chan struct{}
+	err  error
+}
+
+// Done is closed when the session ends
+func (s *TriggerSession) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err explains why the session ended; it is nil after Stop
+func (s *TriggerSession) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Stop asks the plugin to stop the trigger
+func (s *TriggerSession) Stop(ctx context.Context) error {
+	select {
+	case <-s.done:
+		return nil
+	default:
+	}
+
+	s.plugin.mu.Lock()
+	if s.plugin.triggers[s.name] == s {
+		delete(s.plugin.triggers, s.name)
+	}
+	s.plugin.mu.Unlock()
+	s.finish(nil)
+
+	if err := s.plugin.Call(ctx, MethodTriggerStop, TriggerStopParams{Name: s.name}, nil); err != nil && !errors.Is(err, ErrPluginStopped) {
+		return fmt.Errorf("failed to stop plugin trigger %s: %w", s.name, err)
+	}
+	return nil
+}
+
+// finish ends the session once
+func (s *TriggerSession) finish(err error) {
+	s.once.Do(func() {
+		s.err = err
+		close(s.done)
+	})
+}
+
+// startTrigger asks the plugin to start a trigger, delivering its events to
+// handler. Events are accepted as soon as the request is sent.
+func (p *Plugin) startTrigger(ctx context.Context, params TriggerStartParams, handler func(Event)) (*TriggerSession, error) {
+	session := &TriggerSession{
+		plugin:  p,
+		name:    params.Name,
+		handler: handler,
+		done:    make(chan struct{}),
+	}
+
+	p.mu.Lock()
+	if _, exists := p.triggers[params.Name]; exists {
+		p.mu.Unlock()
+		return nil, fmt.Errorf("trigger %s is already running", params.Name)
+	}
+	if p.triggers == nil {
+		p.triggers = make(map[string]*TriggerSession)
+	}
+	p.triggers[params.Name] = session
+	p.mu.Unlock()
+
+	if err := p.Call(ctx, MethodTriggerStart, params, nil); err != nil {
+		p.mu.Lock()
+		if p.triggers[params.Name] == session {
+			delete(p.triggers, params.Name)
+		}
+		p.mu.Unlock()
+		return nil, fmt.Errorf("plugin trigger %s failed to start: %w", params.Name, err)
+	}
+	return session, nil
+}
+
+// takeSessions removes every trigger session; p.mu must be held
+func (p *Plugin) takeSessions() []*TriggerSession {
+	sessions := make([]*TriggerSession, 0, len(p.triggers))
+	for _, session := range p.triggers {
+		sessions = append(sessions, session)
+	}
+	p.triggers = nil
+	return sessions
+}
+
+// handleNotification delivers trigger events and failures
+func (p *Plugin) handleNotification(n Notification) {
+	switch n.Method {
+	case MethodEvent:
+		var event Event
+		if err := json.Unmarshal(n.Params, &event); err != nil {
+			p.logger.Warn("Ignoring invalid plugin event", zap.Error(err))
+			return
+		}
+		p.mu.Lock()
+		session := p.triggers[event.Trigger]
+		p.mu.Unlock()
+		if session == nil {
+			p.logger.Warn("Ignoring event for unknown trigger", zap.String("trigger", event.Trigger))
+			return
+		}
+		session.handler(event)
+
+	case MethodTriggerFailed:
+		var failure TriggerFailure
+		if err := json.Unmarshal(n.Params, &failure); err != nil {
+			p.logger.Warn("Ignoring invalid trigger failure", zap.Error(err))
+			return
+		}
+		p.mu.Lock()
+		session := p.triggers[failure.Trigger]
+		if session != nil {
+			delete(p.triggers, failure.Trigger)
+		}
+		p.mu.Unlock()
+		if session != nil {
+			if failure.Error == "" {
+				failure.Error = "trigger failed"
+			}
+			session.finish(errors.New(failure.Error))
+		}
+
+	default:
+		p.logger.Warn("Ignoring unknown plugin notification", zap.String("method", n.Method))
+	}
+}
+
+// checkRequired checks the "required" list of a JSON Schema
+func checkRequired(schema, values map[string]interface{}) error {
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, name := range required {
+			key, _ := name.(string)
+			if _, exists := values[key]; key != "" && !exists {
+				return fmt.Errorf("%s parameter is required", key)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeyEnv holds a base64 encoded 32 byte key that overrides the key file
+const KeyEnv = "CONDUKTR_KEYSTORE_KEY"
+
+// KeystoreEntry is an encrypted secret in the local keystore
+type KeystoreEntry struct {
+	Value     string    `json:"value"` // base64 nonce followed by AES-GCM ciphertext
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SecretInfo describes a stored secret without its value
+type SecretInfo struct {
+	Name      string    `json:"name"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Keystore is a local file of secrets encrypted with AES-256-GCM
+type Keystore struct {
+	path    string
+	keyPath string
+	mu      sync.Mutex
+}
+
+// NewKeystore creates a keystore backed by a JSON file. The key is read from
+// CONDUKTR_KEYSTORE_KEY or from keyPath, which is created on first write.
+func NewKeystore(path, keyPath string) *Keystore {
+	return &Keystore{path: path, keyPath: keyPath}
+}
+
+// Name returns the provider name
+func (k *Keystore) Name() string {
+	return "keystore"
+}
+
+// Get decrypts a secret from the keystore
+func (k *Keystore) Get(name string) (string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	entries, err := k.load()
+	if err != nil {
+		return "", err
+	}
+	entry, exists := entries[name]
+	if !exists {
+		return "", ErrSecretNotFound
+	}
+
+	key, err := LoadKey(k.keyPath, false)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := Decrypt(key, entry.Value)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// Set encrypts and stores a secret
+func (k *Keystore) Set(name, value string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	entries, err := k.load()
+	if err != nil {
+		return err
+	}
+
+	key, err := LoadKey(k.keyPath, true)
+	if err != nil {
+		return err
+	}
+	ciphertext, err := Encrypt(key, []byte(value))
+	if err != nil {
+		return err
+	}
+
+	entries[name] = KeystoreEntry{Value: ciphertext, UpdatedAt: time.Now()}
+	return k.save(entries)
+}
+
+// Delete removes a secret and reports whether it existed
+func (k *Keystore) Delete(name string) (bool, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	entries, err := k.load()
+	if err != nil {
+		return false, err
+	}
+	if _, exists := entries[name]; !exists {
+		return false, nil
+	}
+
+	delete(entries, name)
+	return true, k.save(entries)
+}
+
+// List returns the stored secret names, sorted
+func (k *Keystore) List() ([]SecretInfo, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	entries, err := k.load()
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]SecretInfo, 0, len(entries))
+	for name, entry := range entries {
+		infos = append(infos, SecretInfo{Name: name, UpdatedAt: entry.UpdatedAt})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos, nil
+}
+
+// load reads the keystore file; a missing file is an empty keystore
+func (k *Keystore) load() (map[string]KeystoreEntry, error) {
+	entries := make(map[string]KeystoreEntry)
+
+	data, err := os.ReadFile(k.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return entries, nil
+		}
+		return nil, fmt.Errorf("failed to read keystore: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse keystore: %w", err)
+	}
+	return entries, nil
+}
+
+// save writes the keystore file atomically with owner-only permissions
+func (k *Keystore) save(entries map[string]KeystoreEntry) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal keystore: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(k.path), 0700); err != nil {
+		return fmt.Errorf("failed to create keystore directory: %w", err)
+	}
+
+	tmp := k.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write keystore: %w", err)
+	}
+	if err := os.Rename(tmp, k.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write keystore: %w", err)
+	}
+	return nil
+}
+
+// LoadKey returns the keystore key from CONDUKTR_KEYSTORE_KEY or the key
+// file, generating the key file when create is set and it does not exist
+func LoadKey(keyPath string, create bool) ([]byte, error) {
+	if encoded := os.Getenv(KeyEnv); encoded != "" {
+		return decodeKey(encoded, KeyEnv)
+	}
+
+	data, err := os.ReadFile(keyPath)
+	if err == nil {
+		return decodeKey(string(data), keyPath)
+	}
+	if !os.IsNotExist(err) || !create {
+		return nil, fmt.Errorf("failed to read keystore key: %w", err)
+	}
+
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, fmt.Errorf("failed to generate keystore key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create key directory: %w", err)
+	}
+	if err := os.WriteFile(keyPath, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write keystore key: %w", err)
+	}
+	return key, nil
+}
+
+// decodeKey decodes a base64 AES-256 key
+func decodeKey(encoded, source string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("key in %s must be 32 bytes encoded as base64", source)
+	}
+	return key, nil
+}
+
+// Encrypt seals plaintext with AES-256-GCM and returns base64(nonce || ciphertext)
+func Encrypt(key, plaintext []byte) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := gcm.Seal(nonce, nonce, plaintext, nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value produced by Encrypt
+func Decrypt(key []byte, encoded string) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
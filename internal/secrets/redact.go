@@ -0,0 +1,215 @@
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Redacted replaces secret values in persisted data, logs and API responses
+const Redacted = "[REDACTED]"
+
+// minRedactLength avoids redacting short values such as "1" or "yes" everywhere
+const minRedactLength = 4
+
+// Redactor replaces known secret values in strings
+type Redactor struct {
+	mu       sync.RWMutex
+	values   map[string]bool
+	replacer *strings.Replacer
+}
+
+// NewRedactor creates an empty redactor
+func NewRedactor() *Redactor {
+	return &Redactor{values: make(map[string]bool)}
+}
+
+// Add registers a secret value for redaction
+func (r *Redactor) Add(value string) {
+	if len(value) < minRedactLength {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.values[value] {
+		return
+	}
+	r.values[value] = true
+
+	// Replace longer values first so a secret containing another is fully hidden
+	values := make([]string, 0, len(r.values))
+	for v := range r.values {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return len(values[i]) > len(values[j])
+	})
+
+	pairs := make([]string, 0, len(values)*2)
+	for _, v := range values {
+		pairs = append(pairs, v, Redacted)
+	}
+	r.replacer = strings.NewReplacer(pairs...)
+}
+
+// Redact replaces secret values in a string
+func (r *Redactor) Redact(s string) string {
+	r.mu.RLock()
+	replacer := r.replacer
+	r.mu.RUnlock()
+
+	if replacer == nil || s == "" {
+		return s
+	}
+	return replacer.Replace(s)
+}
+
+// RedactValue returns a copy of a decoded JSON or YAML value with secret
+// values replaced in every string
+func (r *Redactor) RedactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return r.Redact(v)
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			redacted[key] = r.RedactValue(item)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, item := range v {
+			redacted[i] = r.RedactValue(item)
+		}
+		return redacted
+	case []string:
+		redacted := make([]string, len(v))
+		for i, item := range v {
+			redacted[i] = r.Redact(item)
+		}
+		return redacted
+	case []byte:
+		return []byte(r.Redact(string(v)))
+	}
+	return value
+}
+
+// empty reports whether no secrets have been registered yet
+func (r *Redactor) empty() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.replacer == nil
+}
+
+// WrapCore returns a zap core that redacts secret values from log messages
+// and fields
+func (r *Redactor) WrapCore(core zapcore.Core) zapcore.Core {
+	return &redactingCore{Core: core, redactor: r}
+}
+
+// redactingCore redacts entries before passing them to the wrapped core
+type redactingCore struct {
+	zapcore.Core
+	redactor *Redactor
+}
+
+func (c *redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactingCore{Core: c.Core.With(c.redactFields(fields)), redactor: c.redactor}
+}
+
+func (c *redactingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *redactingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	entry.Message = c.redactor.Redact(entry.Message)
+	return c.Core.Write(entry, c.redactFields(fields))
+}
+
+// redactFields redacts every field type that can carry text: strings,
+// byte strings, errors, stringers, reflected values and array, object and
+// inline marshalers, which are encoded first and then scrubbed
+func (c *redactingCore) redactFields(fields []zapcore.Field) []zapcore.Field {
+	if c.redactor.empty() {
+		return fields
+	}
+
+	redacted := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		switch field.Type {
+		case zapcore.StringType:
+			field.String = c.redactor.Redact(field.String)
+		case zapcore.ByteStringType:
+			if data, ok := field.Interface.([]byte); ok {
+				field = zap.ByteString(field.Key, []byte(c.redactor.Redact(string(data))))
+			}
+		case zapcore.BinaryType:
+			if data, ok := field.Interface.([]byte); ok {
+				if clean := c.redactor.Redact(string(data)); clean != string(data) {
+					field = zap.Binary(field.Key, []byte(clean))
+				}
+			}
+		case zapcore.ErrorType:
+			if err, ok := field.Interface.(error); ok {
+				field = zap.String(field.Key, c.redactor.Redact(err.Error()))
+			}
+		case zapcore.StringerType:
+			field = zap.String(field.Key, c.redactor.Redact(fmt.Sprint(field.Interface)))
+		case zapcore.ReflectType:
+			if data, err := json.Marshal(field.Interface); err == nil {
+				var value interface{}
+				if json.Unmarshal(data, &value) == nil {
+					field = zap.Any(field.Key, c.redactor.RedactValue(value))
+				}
+			}
+		case zapcore.ArrayMarshalerType:
+			if array, ok := field.Interface.(zapcore.ArrayMarshaler); ok {
+				enc := zapcore.NewMapObjectEncoder()
+				if enc.AddArray(field.Key, array) == nil {
+					field = zap.Any(field.Key, c.redactor.RedactValue(enc.Fields[field.Key]))
+				}
+			}
+		case zapcore.ObjectMarshalerType, zapcore.InlineMarshalerType:
+			if object, ok := field.Interface.(zapcore.ObjectMarshaler); ok {
+				enc := zapcore.NewMapObjectEncoder()
+				if object.MarshalLogObject(enc) == nil {
+					clean := redactedObject(c.redactor.RedactValue(enc.Fields).(map[string]interface{}))
+					if field.Type == zapcore.InlineMarshalerType {
+						field = zap.Inline(clean)
+					} else {
+						field = zap.Object(field.Key, clean)
+					}
+				}
+			}
+		}
+		redacted[i] = field
+	}
+	return redacted
+}
+
+// redactedObject logs the redacted fields of an encoded object
+type redactedObject map[string]interface{}
+
+func (o redactedObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	keys := make([]string, 0, len(o))
+	for key := range o {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := enc.AddReflected(key, o[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const testSecret = "hunter2-token"
+
+type stringer struct{ s string }
+
+func (s stringer) String() string { return s.s }
+
+type user struct {
+	Name  string
+	Token string
+}
+
+func (u user) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("name", u.Name)
+	enc.AddString("token", u.Token)
+	return enc.AddArray("tags", zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+		arr.AppendString("tag-" + u.Token)
+		return nil
+	}))
+}
+
+func TestRedactingCoreFieldTypes(t *testing.T) {
+	tests := []struct {
+		name  string
+		field zap.Field
+	}{
+		{name: "string", field: zap.String("k", "token="+testSecret)},
+		{name: "byte string", field: zap.ByteString("k", []byte("token="+testSecret))},
+		{name: "binary", field: zap.Binary("k", []byte("token="+testSecret))},
+		{name: "error", field: zap.Error(errors.New("auth failed for " + testSecret))},
+		{name: "stringer", field: zap.Stringer("k", stringer{"token=" + testSecret})},
+		{name: "reflect", field: zap.Any("k", map[string]interface{}{"nested": []string{testSecret}})},
+		{name: "strings", field: zap.Strings("argv", []string{"curl", "-H", "Authorization: Bearer " + testSecret})},
+		{name: "errors", field: zap.Errors("k", []error{errors.New(testSecret)})},
+		{name: "array", field: zap.Array("k", zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+			arr.AppendString(testSecret)
+			return nil
+		}))},
+		{name: "object", field: zap.Object("k", user{Name: "ada", Token: testSecret})},
+		{name: "inline", field: zap.Inline(user{Name: "ada", Token: testSecret})},
+		{name: "message", field: zap.Skip()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redactor := NewRedactor()
+			redactor.Add(testSecret)
+
+			var buf bytes.Buffer
+			core := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(&buf), zapcore.DebugLevel)
+			logger := zap.New(redactor.WrapCore(core))
+
+			logger.Info("running with "+testSecret, tt.field)
+			logger.With(tt.field).Info("with")
+
+			out := buf.String()
+			encoded := base64.StdEncoding.EncodeToString([]byte("token=" + testSecret))
+			if strings.Contains(out, testSecret) || strings.Contains(out, encoded) {
+				t.Errorf("secret logged: %s", out)
+			}
+			if !strings.Contains(out, Redacted) && !strings.Contains(out, base64.StdEncoding.EncodeToString([]byte("token="+Redacted))) {
+				t.Errorf("no redaction marker: %s", out)
+			}
+		})
+	}
+}
+
+func TestRedactorLongestFirst(t *testing.T) {
+	redactor := NewRedactor()
+	redactor.Add("abcd")
+	redactor.Add("abcdefgh")
+	redactor.Add("no") // too short to redact
+
+	if got := redactor.Redact("x abcdefgh y abcd no"); got != "x "+Redacted+" y "+Redacted+" no" {
+		t.Errorf("Redact = %q", got)
+	}
+}
+
+func TestRedactValue(t *testing.T) {
+	redactor := NewRedactor()
+	redactor.Add(testSecret)
+
+	value := map[string]interface{}{
+		"url":   "https://hooks.example.com/" + testSecret,
+		"list":  []interface{}{testSecret, 3.0, map[string]interface{}{"k": testSecret}},
+		"count": 2.0,
+	}
+	got := redactor.RedactValue(value).(map[string]interface{})
+	if got["url"] != "https://hooks.example.com/"+Redacted {
+		t.Errorf("url = %v", got["url"])
+	}
+	list := got["list"].([]interface{})
+	if list[0] != Redacted || list[1] != 3.0 || list[2].(map[string]interface{})["k"] != Redacted {
+		t.Errorf("list = %v", list)
+	}
+	if value["url"] == got["url"] {
+		t.Error("RedactValue modified its input")
+	}
+}
@@ -0,0 +1,167 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	// ErrSecretNotFound is returned when no provider has the requested secret
+	ErrSecretNotFound = errors.New("secret not found")
+	// ErrInvalidName is returned for secret names that are not allowed
+	ErrInvalidName = errors.New("invalid secret name")
+)
+
+// namePattern restricts secret names so they are safe as file and env names
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// Provider looks up secret values by name
+type Provider interface {
+	Name() string
+	Get(name string) (string, error)
+}
+
+// ValidateName checks that a secret name is allowed
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+	return nil
+}
+
+// Manager resolves secrets from an ordered list of providers and remembers
+// resolved values so they can be redacted
+type Manager struct {
+	providers []Provider
+	redactor  *Redactor
+	// templateEnv lists the variables the env template function may read
+	templateEnv []string
+}
+
+// NewManager creates a new secrets manager; providers are consulted in order
+func NewManager(providers ...Provider) *Manager {
+	return &Manager{
+		providers: providers,
+		redactor:  NewRedactor(),
+	}
+}
+
+// Resolve returns the value of a secret and registers it for redaction
+func (m *Manager) Resolve(name string) (string, error) {
+	if err := ValidateName(name); err != nil {
+		return "", err
+	}
+
+	for _, provider := range m.providers {
+		value, err := provider.Get(name)
+		if errors.Is(err, ErrSecretNotFound) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("secret %s: %s provider: %w", name, provider.Name(), err)
+		}
+		m.redactor.Add(value)
+		return value, nil
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrSecretNotFound, name)
+}
+
+// SetTemplateEnv sets the environment variables templates may read with
+// {{ env "NAME" }}. Entries are names or prefixes ending in "*".
+func (m *Manager) SetTemplateEnv(patterns []string) {
+	m.templateEnv = patterns
+}
+
+// Env returns an allowed environment variable and registers its value for
+// redaction. Variables starting with CONDUKTR_ hold keys and secrets and are
+// never returned.
+func (m *Manager) Env(name string) (string, error) {
+	if strings.HasPrefix(strings.ToUpper(name), reservedEnvPrefix) || !matchesPattern(m.templateEnv, name) {
+		return "", fmt.Errorf("environment variable %s is not allowed in templates", name)
+	}
+	value := os.Getenv(name)
+	m.redactor.Add(value)
+	return value, nil
+}
+
+// reservedEnvPrefix marks the daemon's own key and secret variables
+const reservedEnvPrefix = "CONDUKTR_"
+
+// matchesPattern reports whether name equals a pattern or starts with a
+// pattern ending in "*"
+func matchesPattern(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if pattern == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Redactor returns the redactor holding every resolved secret value
+func (m *Manager) Redactor() *Redactor {
+	return m.redactor
+}
+
+// EnvProvider reads secrets from environment variables, e.g. the secret
+// "slack_webhook" is read from CONDUKTR_SECRET_SLACK_WEBHOOK
+type EnvProvider struct {
+	prefix string
+}
+
+// NewEnvProvider creates a new environment variable provider
+func NewEnvProvider(prefix string) *EnvProvider {
+	return &EnvProvider{prefix: prefix}
+}
+
+// Name returns the provider name
+func (p *EnvProvider) Name() string {
+	return "env"
+}
+
+// Get returns the secret from the environment
+func (p *EnvProvider) Get(name string) (string, error) {
+	key := p.prefix + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	return value, nil
+}
+
+// FileProvider reads secrets from one file per secret, as mounted by
+// Docker and Kubernetes
+type FileProvider struct {
+	dir string
+}
+
+// NewFileProvider creates a new file provider for a directory
+func NewFileProvider(dir string) *FileProvider {
+	return &FileProvider{dir: dir}
+}
+
+// Name returns the provider name
+func (p *FileProvider) Name() string {
+	return "file"
+}
+
+// Get returns the contents of the secret file without a trailing newline
+func (p *FileProvider) Get(name string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(p.dir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", ErrSecretNotFound
+		}
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
@@ -0,0 +1,41 @@
+package secrets
+
+import (
+	"testing"
+)
+
+func TestManagerEnv(t *testing.T) {
+	t.Setenv("APP_REGION", "eu-west-1")
+	t.Setenv("APP_TOKEN", "token-value")
+	t.Setenv("HOME_DIR", "/home/app")
+	t.Setenv("CONDUKTR_SECRET_API", "secret-value")
+	t.Setenv("CONDUKTR_DATA_KEY", "data-key")
+
+	m := NewManager()
+	m.SetTemplateEnv([]string{"APP_*", "CONDUKTR_*"})
+
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "APP_REGION", want: "eu-west-1"},
+		{name: "APP_TOKEN", want: "token-value"},
+		{name: "HOME_DIR", wantErr: true},
+		{name: "CONDUKTR_SECRET_API", wantErr: true},
+		{name: "CONDUKTR_DATA_KEY", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := m.Env(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Env(%q) err = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("Env(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if got := m.Redactor().Redact("token is token-value"); got != "token is "+Redacted {
+		t.Errorf("env value not redacted: %q", got)
+	}
+}
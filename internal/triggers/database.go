@@ -16,6 +16,8 @@ import (
 
 // DatabaseTrigger implements database change detection
 type DatabaseTrigger struct {
+	monitor
+	name   string
 	db     *sql.DB
 	engine *engine.Engine
 	logger *zap.Logger
@@ -57,6 +59,7 @@ func NewDatabaseTrigger(config DatabaseConfig, engine *engine.Engine, logger *za
 	ctx, cancel := context.WithCancel(context.Background())
 
 	return &DatabaseTrigger{
+		name:   "database",
 		engine: engine,
 		logger: logger,
 		config: config,
@@ -65,16 +68,28 @@ func NewDatabaseTrigger(config DatabaseConfig, engine *engine.Engine, logger *za
 	}
 }
 
+// Name returns the trigger's name
+func (d *DatabaseTrigger) Name() string {
+	return d.name
+}
+
 // Start begins monitoring database changes
-func (d *DatabaseTrigger) Start() error {
+func (d *DatabaseTrigger) Start(ctx context.Context) error {
+	if d.config.PollInterval <= 0 {
+		return fmt.Errorf("poll_interval must be positive")
+	}
+
 	var err error
 	d.db, err = sql.Open(d.config.Driver, d.config.DSN)
 	if err != nil {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
+	d.ctx, d.cancel = context.WithCancel(ctx)
 
 	// Test connection
 	if err := d.db.PingContext(d.ctx); err != nil {
+		d.cancel()
+		d.db.Close()
 		return fmt.Errorf("failed to ping database: %w", err)
 	}
 
@@ -96,12 +111,14 @@ func (d *DatabaseTrigger) Start() error {
 		go d.pollChanges() // Generic polling
 	}
 
+	d.setStatus(StatusRunning, nil)
 	return nil
 }
 
 // Stop stops the database trigger
-func (d *DatabaseTrigger) Stop() error {
+func (d *DatabaseTrigger) Stop(ctx context.Context) error {
 	d.cancel()
+	d.setStatus(StatusStopped, nil)
 	if d.db != nil {
 		return d.db.Close()
 	}
@@ -139,9 +156,13 @@ func (d *DatabaseTrigger) pollTable(table TableConfig, lastCheck time.Time) {
 
 	rows, err := d.db.QueryContext(d.ctx, query)
 	if err != nil {
+		if d.ctx.Err() != nil {
+			return
+		}
 		d.logger.Error("Failed to poll table",
 			zap.String("table", table.Name),
 			zap.Error(err))
+		d.recordError(err)
 		return
 	}
 	defer rows.Close()
@@ -161,6 +182,7 @@ func (d *DatabaseTrigger) pollTable(table TableConfig, lastCheck time.Time) {
 
 		if err := rows.Scan(valuePtrs...); err != nil {
 			d.logger.Error("Failed to scan row", zap.Error(err))
+			d.recordError(err)
 			continue
 		}
 
@@ -248,7 +270,7 @@ func (d *DatabaseTrigger) handleChange(change ChangeRecord) {
 	}
 
 	// Execute workflow asynchronously
-	go executeWorkflow(d.ctx, d.engine, d.logger, eventType, context)
+	go d.dispatch(d.ctx, d.engine, d.logger, eventType, context)
 }
 
 // CreateTrigger creates database triggers for change detection (PostgreSQL)
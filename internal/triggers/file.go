@@ -2,6 +2,7 @@ package triggers
 
 import (
 	"context"
+	"fmt"
 	"path/filepath"
 	"time"
 
@@ -14,28 +15,46 @@ import (
 
 // FileTrigger handles file system event triggers
 type FileTrigger struct {
-	logger  *zap.Logger
-	engine  *engine.Engine
-	watcher *fsnotify.Watcher
-	done    chan bool
+	monitor
+	name     string
+	logger   *zap.Logger
+	engine   *engine.Engine
+	watchDir string
+	watcher  *fsnotify.Watcher
+	cancel   context.CancelFunc
 }
 
-// NewFileTrigger creates a new file trigger
-func NewFileTrigger(logger *zap.Logger, engine *engine.Engine) *FileTrigger {
+// NewFileTrigger creates a new file trigger for a directory
+func NewFileTrigger(logger *zap.Logger, engine *engine.Engine, watchDir string) *FileTrigger {
 	return &FileTrigger{
-		logger: logger,
-		engine: engine,
-		done:   make(chan bool),
+		name:     "file",
+		logger:   logger,
+		engine:   engine,
+		watchDir: watchDir,
 	}
 }
 
+// Name returns the trigger's name
+func (f *FileTrigger) Name() string {
+	return f.name
+}
+
 // Start starts watching for file system events
-func (f *FileTrigger) Start(watchDir string) error {
+func (f *FileTrigger) Start(ctx context.Context) error {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return err
 	}
+
+	// Add the directory to watch
+	if err := watcher.Add(f.watchDir); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch %s: %w", f.watchDir, err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
 	f.watcher = watcher
+	f.cancel = cancel
 
 	go func() {
 		defer watcher.Close()
@@ -51,6 +70,12 @@ func (f *FileTrigger) Start(watchDir string) error {
 					zap.String("file", event.Name),
 					zap.String("op", event.Op.String()))
 
+				// Nothing more arrives once the watched directory is gone
+				if filepath.Clean(event.Name) == filepath.Clean(f.watchDir) && event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+					f.setStatus(StatusFailed, fmt.Errorf("watched directory %s was removed", f.watchDir))
+					return
+				}
+
 				f.handleFileEvent(event)
 
 			case err, ok := <-watcher.Errors:
@@ -58,29 +83,29 @@ func (f *FileTrigger) Start(watchDir string) error {
 					return
 				}
 				f.logger.Error("File watcher error", zap.Error(err))
+				f.recordError(err)
 
-			case <-f.done:
+			case <-ctx.Done():
 				return
 			}
 		}
 	}()
 
-	// Add the directory to watch
-	err = watcher.Add(watchDir)
-	if err != nil {
-		return err
-	}
-
-	f.logger.Info("File trigger started", zap.String("watch_dir", watchDir))
+	f.setStatus(StatusRunning, nil)
+	f.logger.Info("File trigger started", zap.String("watch_dir", f.watchDir))
 	return nil
 }
 
 // Stop stops the file trigger
-func (f *FileTrigger) Stop() {
-	close(f.done)
+func (f *FileTrigger) Stop(ctx context.Context) error {
+	if f.cancel != nil {
+		f.cancel()
+	}
 	if f.watcher != nil {
 		f.watcher.Close()
 	}
+	f.setStatus(StatusStopped, nil)
+	return nil
 }
 
 // handleFileEvent processes a file system event
@@ -103,6 +128,8 @@ func (f *FileTrigger) handleFileEvent(event fsnotify.Event) {
 		return
 	}
 
+	f.recordEvent()
+
 	// Check if there's a workflow for this event type
 	workflow, exists := f.engine.GetWorkflowForEvent(eventType)
 	if !exists {
@@ -140,6 +167,7 @@ func (f *FileTrigger) handleFileEvent(event fsnotify.Event) {
 			f.logger.Error("Workflow execution failed",
 				zap.String("instance_id", instanceID),
 				zap.Error(err))
+			f.recordError(err)
 		} else {
 			f.logger.Info("Workflow execution completed",
 				zap.String("instance_id", instanceID))
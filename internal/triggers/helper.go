@@ -11,25 +11,32 @@ import (
 )
 
 // executeWorkflow is a helper function that all triggers can use to execute workflows
-func executeWorkflow(ctx context.Context, engine *engine.Engine, logger *zap.Logger, eventType string, contextData map[string]interface{}) {
+func executeWorkflow(ctx context.Context, engine *engine.Engine, logger *zap.Logger, eventType string, contextData map[string]interface{}) error {
 	workflow, exists := engine.GetWorkflowForEvent(eventType)
 	if !exists {
 		logger.Warn("No workflow found for event", zap.String("event", eventType))
-		return
+		return nil
 	}
 
+	// Step outputs are written to the variables, which must not alias the payload
+	variables := make(map[string]interface{}, len(contextData))
+	for key, value := range contextData {
+		variables[key] = value
+	}
 	eventCtx := &persistence.EventContext{
 		Event: &persistence.Event{
 			Type:      eventType,
 			Payload:   contextData,
 			Timestamp: time.Now().Unix(),
 		},
-		Variables: contextData,
+		Variables: variables,
 	}
 
 	if _, err := engine.ExecuteWorkflow(ctx, workflow, eventCtx); err != nil {
 		logger.Error("Workflow execution failed",
 			zap.String("event", eventType),
 			zap.Error(err))
+		return err
 	}
+	return nil
 }
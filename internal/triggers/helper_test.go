@@ -0,0 +1,55 @@
+package triggers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/logimos/conduktr/internal/engine"
+	"github.com/logimos/conduktr/internal/persistence"
+
+	"go.uber.org/zap"
+)
+
+func TestExecuteWorkflowKeepsPayloadApartFromStepOutputs(t *testing.T) {
+	e := engine.NewEngine(zap.NewNop(), persistence.NewJSONPersistence(t.TempDir()))
+	workflow, err := engine.LoadWorkflowFromYAML([]byte(`name: greet
+on:
+  event: greet.requested
+workflow:
+  - name: say
+    action: log.info
+    message: hello
+  - name: echo
+    action: log.info
+    message: "payload={{ .event.payload.say }} step={{ .steps.say.status }}"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.RegisterWorkflow(workflow); err != nil {
+		t.Fatal(err)
+	}
+
+	payload := map[string]interface{}{"say": "from the payload"}
+	if err := executeWorkflow(context.Background(), e, zap.NewNop(), "greet.requested", payload); err != nil {
+		t.Fatal(err)
+	}
+	if len(payload) != 1 || payload["say"] != "from the payload" {
+		t.Errorf("payload was modified: %v", payload)
+	}
+
+	summaries, err := e.ListInstances("greet", "")
+	if err != nil || len(summaries) != 1 {
+		t.Fatalf("instances = %v, %v", summaries, err)
+	}
+	instance, err := e.GetWorkflowInstance(summaries[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := instance.Context.Event.Payload; len(got) != 1 || got["say"] != "from the payload" {
+		t.Errorf("stored payload = %v", got)
+	}
+	if got := instance.Steps[1].Input["message"]; got != "payload=from the payload step=completed" {
+		t.Errorf("message = %q", got)
+	}
+}
@@ -2,13 +2,20 @@ package triggers
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/logimos/conduktr/internal/engine"
 	"github.com/logimos/conduktr/internal/persistence"
+	"github.com/logimos/conduktr/internal/plugins"
 	"github.com/logimos/conduktr/internal/web"
 
 	"github.com/gorilla/mux"
@@ -17,11 +24,19 @@ import (
 
 // HTTPTrigger handles HTTP-based event triggers
 type HTTPTrigger struct {
-	logger *zap.Logger
-	engine *engine.Engine
-	server *http.Server
-	port   int
-	router *mux.Router
+	monitor
+	logger   *zap.Logger
+	engine   *engine.Engine
+	server   *http.Server
+	port     int
+	router   *mux.Router
+	routes   sync.Once
+	loader   *engine.Loader
+	plugins  *plugins.Host
+	triggers *TriggerManager
+	// adminToken guards the endpoints that change workflows; they are not
+	// served when it is empty
+	adminToken string
 }
 
 // EventPayload represents the payload of an HTTP event
@@ -69,8 +84,63 @@ func (h *HTTPTrigger) RegisterMarketplaceRoutes(marketplaceService interface{})
 	}
 }
 
-// Start starts the HTTP trigger server
-func (h *HTTPTrigger) Start() error {
+// SetWorkflowLoader enables the workflow reload admin endpoints
+func (h *HTTPTrigger) SetWorkflowLoader(loader *engine.Loader) {
+	h.loader = loader
+}
+
+// SetAdminToken enables the workflow management and reload endpoints.
+// Requests must send the token as "Authorization: Bearer <token>".
+func (h *HTTPTrigger) SetAdminToken(token string) {
+	h.adminToken = token
+}
+
+// SetPluginHost enables the plugin listing endpoint
+func (h *HTTPTrigger) SetPluginHost(host *plugins.Host) {
+	h.plugins = host
+}
+
+// SetTriggerManager enables the trigger status endpoint
+func (h *HTTPTrigger) SetTriggerManager(manager *TriggerManager) {
+	h.triggers = manager
+}
+
+// Name returns the trigger's name
+func (h *HTTPTrigger) Name() string {
+	return "http"
+}
+
+// Start starts the HTTP trigger server, returning once it is listening
+func (h *HTTPTrigger) Start(ctx context.Context) error {
+	h.routes.Do(h.registerRoutes)
+
+	h.server = &http.Server{
+		Addr:         fmt.Sprintf("0.0.0.0:%d", h.port),
+		Handler:      h.router,
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15 * time.Second,
+	}
+
+	listener, err := net.Listen("tcp", h.server.Addr)
+	if err != nil {
+		return err
+	}
+
+	h.logger.Info("Starting HTTP trigger server", zap.Int("port", h.port))
+	h.setStatus(StatusRunning, nil)
+
+	server := h.server
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			h.logger.Error("HTTP trigger failed", zap.Error(err))
+			h.setStatus(StatusFailed, err)
+		}
+	}()
+	return nil
+}
+
+// registerRoutes adds the built-in endpoints to the router
+func (h *HTTPTrigger) registerRoutes() {
 	// Advanced Dashboard endpoint
 	advancedDashboard := web.NewAdvancedDashboardHandler(h.engine, h.logger)
 	h.router.Handle("/", advancedDashboard).Methods("GET")
@@ -88,21 +158,64 @@ func (h *HTTPTrigger) Start() error {
 
 	// Workflow management endpoints
 	h.router.HandleFunc("/workflows", h.handleListWorkflows).Methods("GET")
+	h.router.HandleFunc("/workflows/{name}/versions", h.handleListWorkflowVersions).Methods("GET")
+	h.router.HandleFunc("/workflows/{name}/schema", h.handleGetWorkflowSchema).Methods("GET")
+	if h.loader != nil {
+		h.router.HandleFunc("/workflows/{name}", h.handleGetWorkflow).Methods("GET")
+	}
+	if h.loader != nil && h.adminToken != "" {
+		h.router.HandleFunc("/workflows", h.requireAdmin(h.handleCreateWorkflow)).Methods("POST")
+		h.router.HandleFunc("/workflows/{name}", h.requireAdmin(h.handleUpdateWorkflow)).Methods("PUT")
+		h.router.HandleFunc("/workflows/{name}", h.requireAdmin(h.handleDeleteWorkflow)).Methods("DELETE")
+		h.router.HandleFunc("/workflows/{name}/{state:enable|disable}", h.requireAdmin(h.handleSetWorkflowEnabled)).Methods("POST")
+	}
+	if h.plugins != nil {
+		h.router.HandleFunc("/plugins", h.handleListPlugins).Methods("GET")
+	}
+	if h.triggers != nil {
+		h.router.HandleFunc("/triggers", h.handleListTriggers).Methods("GET")
+	}
+	h.router.HandleFunc("/instances", h.handleListInstances).Methods("GET")
 	h.router.HandleFunc("/instances/{id}", h.handleGetInstance).Methods("GET")
 
-	h.server = &http.Server{
-		Addr:         fmt.Sprintf("0.0.0.0:%d", h.port),
-		Handler:      h.router,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
+	// Admin endpoints
+	if h.loader != nil {
+		h.router.HandleFunc("/admin/reloads", h.handleListReloads).Methods("GET")
+	}
+	if h.loader != nil && h.adminToken != "" {
+		h.router.HandleFunc("/admin/reload", h.requireAdmin(h.handleReload)).Methods("POST")
 	}
 
-	h.logger.Info("Starting HTTP trigger server", zap.Int("port", h.port))
-	return h.server.ListenAndServe()
+	// Approval endpoints
+	h.router.HandleFunc("/approvals", h.handleListApprovals).Methods("GET")
+	h.router.HandleFunc("/approvals/{id}", h.handleGetApproval).Methods("GET")
+	h.router.HandleFunc("/approvals/{id}/{decision:approve|reject}", h.handleDecideApproval).Methods("POST")
+}
+
+// requireAdmin rejects requests that do not carry the admin token
+func (h *HTTPTrigger) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !h.isAdmin(r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Admin token required", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// isAdmin reports whether a request carries the admin token
+func (h *HTTPTrigger) isAdmin(r *http.Request) bool {
+	if h.adminToken == "" {
+		return false
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) == 1
 }
 
 // Stop stops the HTTP trigger server
 func (h *HTTPTrigger) Stop(ctx context.Context) error {
+	h.setStatus(StatusStopped, nil)
 	if h.server != nil {
 		return h.server.Shutdown(ctx)
 	}
@@ -143,6 +256,8 @@ func (h *HTTPTrigger) handleEvent(w http.ResponseWriter, r *http.Request) {
 
 // triggerWorkflow triggers a workflow for the given event
 func (h *HTTPTrigger) triggerWorkflow(w http.ResponseWriter, r *http.Request, eventType string, data map[string]interface{}) {
+	h.recordEvent()
+
 	workflow, exists := h.engine.GetWorkflowForEvent(eventType)
 	if !exists {
 		h.logger.Warn("No workflow found for event", zap.String("event", eventType))
@@ -150,6 +265,27 @@ func (h *HTTPTrigger) triggerWorkflow(w http.ResponseWriter, r *http.Request, ev
 		return
 	}
 
+	// Reject payloads that do not match the workflow's declared inputs
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+	if err := workflow.ValidateInputs(data); err != nil {
+		var inputErr *engine.InputValidationError
+		if errors.As(err, &inputErr) {
+			response := map[string]interface{}{
+				"error":    "invalid event payload",
+				"workflow": workflow.Name,
+				"fields":   inputErr.Fields,
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Create event context
 	eventCtx := &persistence.EventContext{
 		Event: &persistence.Event{
@@ -177,6 +313,7 @@ func (h *HTTPTrigger) triggerWorkflow(w http.ResponseWriter, r *http.Request, ev
 			h.logger.Error("Workflow execution failed",
 				zap.String("instance_id", instanceID),
 				zap.Error(err))
+			h.recordError(err)
 		} else {
 			h.logger.Info("Workflow execution completed",
 				zap.String("instance_id", instanceID))
@@ -206,11 +343,98 @@ func (h *HTTPTrigger) handleHealth(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// WorkflowSummary describes a registered workflow in API responses
+type WorkflowSummary struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Event   string `json:"event"`
+	Steps   int    `json:"steps"`
+}
+
 // handleListWorkflows lists all registered workflows
 func (h *HTTPTrigger) handleListWorkflows(w http.ResponseWriter, r *http.Request) {
-	// In a real implementation, you'd get this from the engine
+	workflows := make([]WorkflowSummary, 0)
+	for _, workflow := range h.engine.ListWorkflows() {
+		workflows = append(workflows, WorkflowSummary{
+			Name:    workflow.Name,
+			Version: workflow.Version,
+			Event:   workflow.On.Event,
+			Steps:   len(workflow.Workflow),
+		})
+	}
+
 	response := map[string]interface{}{
-		"workflows": []string{}, // Placeholder
+		"workflows": workflows,
+		"timestamp": time.Now().Unix(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleGetWorkflowSchema returns the JSON Schema of a workflow's inputs
+func (h *HTTPTrigger) handleGetWorkflowSchema(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	workflow, exists := h.engine.GetWorkflowVersion(name, r.URL.Query().Get("version"))
+	if !exists {
+		http.Error(w, fmt.Sprintf("Workflow not found: %s", name), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/schema+json")
+	json.NewEncoder(w).Encode(workflow.JSONSchema())
+}
+
+// handleListWorkflowVersions lists the registered versions of a workflow
+func (h *HTTPTrigger) handleListWorkflowVersions(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	versions, exists := h.engine.ListWorkflowVersions(name)
+	if !exists {
+		http.Error(w, fmt.Sprintf("Workflow not found: %s", name), http.StatusNotFound)
+		return
+	}
+
+	current := ""
+	if workflow, ok := h.engine.GetWorkflowVersion(name, ""); ok {
+		current = workflow.Version
+	}
+
+	response := map[string]interface{}{
+		"name":      name,
+		"current":   current,
+		"versions":  versions,
+		"timestamp": time.Now().Unix(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleListInstances lists workflow instances, filtered by ?workflow=,
+// ?status= and ?correlation_id=
+func (h *HTTPTrigger) handleListInstances(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	instances, err := h.engine.ListInstances(query.Get("workflow"), query.Get("status"))
+	if err != nil {
+		h.logger.Error("Failed to list workflow instances", zap.Error(err))
+		http.Error(w, "Failed to list instances", http.StatusInternalServerError)
+		return
+	}
+	if correlationID := query.Get("correlation_id"); correlationID != "" {
+		linked := instances[:0]
+		for _, instance := range instances {
+			if instance.CorrelationID == correlationID {
+				linked = append(linked, instance)
+			}
+		}
+		instances = linked
+	}
+
+	response := map[string]interface{}{
+		"instances": instances,
+		"count":     len(instances),
 		"timestamp": time.Now().Unix(),
 	}
 
@@ -235,3 +459,257 @@ func (h *HTTPTrigger) handleGetInstance(w http.ResponseWriter, r *http.Request)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(instance)
 }
+
+// maxWorkflowSize limits the size of uploaded workflow definitions
+const maxWorkflowSize = 1 << 20
+
+// handleGetWorkflow returns the source and parsed form of a workflow
+func (h *HTTPTrigger) handleGetWorkflow(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	definition, err := h.loader.GetDefinition(name)
+	if err != nil {
+		h.writeDefinitionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(definition)
+}
+
+// handleCreateWorkflow creates a workflow from a YAML body
+func (h *HTTPTrigger) handleCreateWorkflow(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxWorkflowSize))
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	workflow, err := h.loader.CreateDefinition(data)
+	if err != nil {
+		h.writeDefinitionError(w, err)
+		return
+	}
+
+	h.logger.Info("Workflow created via API", zap.String("name", workflow.Name))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(workflow)
+}
+
+// handleUpdateWorkflow replaces a workflow with a YAML body
+func (h *HTTPTrigger) handleUpdateWorkflow(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxWorkflowSize))
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	workflow, err := h.loader.UpdateDefinition(name, data)
+	if err != nil {
+		h.writeDefinitionError(w, err)
+		return
+	}
+
+	h.logger.Info("Workflow updated via API",
+		zap.String("name", workflow.Name),
+		zap.String("version", workflow.Version))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(workflow)
+}
+
+// handleDeleteWorkflow deletes a workflow definition
+func (h *HTTPTrigger) handleDeleteWorkflow(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	if err := h.loader.DeleteDefinition(name); err != nil {
+		h.writeDefinitionError(w, err)
+		return
+	}
+
+	h.logger.Info("Workflow deleted via API", zap.String("name", name))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleSetWorkflowEnabled enables or disables a workflow
+func (h *HTTPTrigger) handleSetWorkflowEnabled(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name := vars["name"]
+	enabled := vars["state"] == "enable"
+
+	if err := h.loader.SetDefinitionEnabled(name, enabled); err != nil {
+		h.writeDefinitionError(w, err)
+		return
+	}
+
+	h.logger.Info("Workflow state changed via API",
+		zap.String("name", name),
+		zap.Bool("enabled", enabled))
+
+	response := map[string]interface{}{
+		"name":      name,
+		"enabled":   enabled,
+		"timestamp": time.Now().Unix(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// writeDefinitionError maps workflow definition errors to HTTP status codes
+func (h *HTTPTrigger) writeDefinitionError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, engine.ErrWorkflowNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, engine.ErrWorkflowExists):
+		status = http.StatusConflict
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// handleReload reloads workflow definitions from the workflow directory
+func (h *HTTPTrigger) handleReload(w http.ResponseWriter, r *http.Request) {
+	changes, err := h.loader.Reload()
+	if err != nil {
+		h.logger.Error("Workflow reload failed", zap.Error(err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"changes":   changes,
+		"timestamp": time.Now().Unix(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleListReloads returns the recent workflow reload history
+func (h *HTTPTrigger) handleListReloads(w http.ResponseWriter, r *http.Request) {
+	response := map[string]interface{}{
+		"reloads":   h.loader.Events(),
+		"timestamp": time.Now().Unix(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleListPlugins lists plugins and the actions they provide
+func (h *HTTPTrigger) handleListPlugins(w http.ResponseWriter, r *http.Request) {
+	response := map[string]interface{}{
+		"plugins":   h.plugins.Plugins(),
+		"timestamp": time.Now().Unix(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleListTriggers reports the health and stats of every trigger
+func (h *HTTPTrigger) handleListTriggers(w http.ResponseWriter, r *http.Request) {
+	response := map[string]interface{}{
+		"triggers":  h.triggers.Statuses(),
+		"timestamp": time.Now().Unix(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ApprovalDecision represents the body of an approve or reject request
+type ApprovalDecision struct {
+	// DecidedBy may be left out when the token belongs to an approver
+	DecidedBy string `json:"decided_by"`
+	Comment   string `json:"comment"`
+	// Token is the approval's token; it may also be given as ?token=
+	Token string `json:"token"`
+}
+
+// handleListApprovals lists approvals, optionally filtered by status
+func (h *HTTPTrigger) handleListApprovals(w http.ResponseWriter, r *http.Request) {
+	approvals, err := h.engine.ListApprovals(r.URL.Query().Get("status"))
+	if err != nil {
+		h.logger.Error("Failed to list approvals", zap.Error(err))
+		http.Error(w, "Failed to list approvals", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"approvals": approvals,
+		"timestamp": time.Now().Unix(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleGetApproval retrieves an approval
+func (h *HTTPTrigger) handleGetApproval(w http.ResponseWriter, r *http.Request) {
+	approvalID := mux.Vars(r)["id"]
+
+	approval, err := h.engine.GetApproval(approvalID)
+	if err != nil {
+		http.Error(w, "Approval not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(approval)
+}
+
+// handleDecideApproval approves or rejects a pending approval
+func (h *HTTPTrigger) handleDecideApproval(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	approvalID := vars["id"]
+
+	var decision ApprovalDecision
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&decision); err != nil {
+			http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if decision.Token == "" {
+		decision.Token = r.URL.Query().Get("token")
+	}
+
+	// Operators holding the admin token may decide without the approval's token
+	approved := vars["decision"] == "approve"
+	var approval *persistence.Approval
+	var err error
+	if decision.Token == "" && h.isAdmin(r) {
+		approval, err = h.engine.DecideApprovalAsAdmin(approvalID, approved, decision.DecidedBy, decision.Comment)
+	} else {
+		approval, err = h.engine.DecideApproval(approvalID, approved, decision.DecidedBy, decision.Comment, decision.Token)
+	}
+	if err != nil {
+		h.logger.Warn("Approval decision rejected",
+			zap.String("approval_id", approvalID),
+			zap.Error(err))
+
+		status := http.StatusConflict
+		switch {
+		case approval == nil:
+			status = http.StatusNotFound
+		case errors.Is(err, engine.ErrDecidedByRequired):
+			status = http.StatusBadRequest
+		case errors.Is(err, engine.ErrInvalidApprovalToken):
+			status = http.StatusUnauthorized
+		case errors.Is(err, engine.ErrNotApprover):
+			status = http.StatusForbidden
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(approval)
+}
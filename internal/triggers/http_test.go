@@ -0,0 +1,61 @@
+package triggers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/logimos/conduktr/internal/engine"
+	"github.com/logimos/conduktr/internal/persistence"
+
+	"go.uber.org/zap"
+)
+
+const testWorkflow = `name: greet
+on:
+  event: greet.requested
+workflow:
+  - name: say
+    action: log.info
+    message: hello
+`
+
+func newTestHTTPTrigger(t *testing.T, token string) *HTTPTrigger {
+	t.Helper()
+	e := engine.NewEngine(zap.NewNop(), persistence.NewJSONPersistence(t.TempDir()))
+	h := NewHTTPTrigger(zap.NewNop(), e, 0)
+	h.SetWorkflowLoader(engine.NewLoader(e, zap.NewNop(), t.TempDir()))
+	h.SetAdminToken(token)
+	h.routes.Do(h.registerRoutes)
+	return h
+}
+
+func TestWorkflowManagementRequiresAdminToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		want   int
+	}{
+		{name: "disabled without token", token: "", header: "", want: http.StatusMethodNotAllowed},
+		{name: "missing header", token: "s3cret-token", header: "", want: http.StatusUnauthorized},
+		{name: "wrong token", token: "s3cret-token", header: "Bearer nope", want: http.StatusUnauthorized},
+		{name: "valid token", token: "s3cret-token", header: "Bearer s3cret-token", want: http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHTTPTrigger(t, tt.token)
+			req := httptest.NewRequest(http.MethodPost, "/workflows", strings.NewReader(testWorkflow))
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			h.router.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
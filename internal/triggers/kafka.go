@@ -15,6 +15,9 @@ import (
 
 // KafkaTrigger implements Kafka-based event triggering
 type KafkaTrigger struct {
+	monitor
+	name    string
+	config  KafkaConfig
 	readers []*kafka.Reader
 	writer  *kafka.Writer
 	engine  *engine.Engine
@@ -35,38 +38,55 @@ type KafkaConfig struct {
 func NewKafkaTrigger(config KafkaConfig, engine *engine.Engine, logger *zap.Logger) *KafkaTrigger {
 	ctx, cancel := context.WithCancel(context.Background())
 
-	var readers []*kafka.Reader
-	for _, topic := range config.Topics {
+	k := &KafkaTrigger{
+		name:   "kafka",
+		config: config,
+		engine: engine,
+		logger: logger,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	k.connect()
+	return k
+}
+
+// connect creates the topic readers and the event writer
+func (k *KafkaTrigger) connect() {
+	k.readers = nil
+	for _, topic := range k.config.Topics {
 		reader := kafka.NewReader(kafka.ReaderConfig{
-			Brokers:     config.Brokers,
+			Brokers:     k.config.Brokers,
 			Topic:       topic,
-			GroupID:     config.GroupID,
+			GroupID:     k.config.GroupID,
 			StartOffset: kafka.LastOffset,
 			MinBytes:    10e3, // 10KB
 			MaxBytes:    10e6, // 10MB
 		})
-		readers = append(readers, reader)
+		k.readers = append(k.readers, reader)
 	}
 
-	writer := &kafka.Writer{
-		Addr:                   kafka.TCP(config.Brokers...),
-		Topic:                  "reactor-events",
+	// Messages name their topic, so one writer serves every topic
+	k.writer = &kafka.Writer{
+		Addr:                   kafka.TCP(k.config.Brokers...),
 		Balancer:               &kafka.LeastBytes{},
 		AllowAutoTopicCreation: true,
 	}
+}
 
-	return &KafkaTrigger{
-		readers: readers,
-		writer:  writer,
-		engine:  engine,
-		logger:  logger,
-		ctx:     ctx,
-		cancel:  cancel,
-	}
+// Name returns the trigger's name
+func (k *KafkaTrigger) Name() string {
+	return k.name
 }
 
 // Start begins consuming from Kafka topics
-func (k *KafkaTrigger) Start() error {
+func (k *KafkaTrigger) Start(ctx context.Context) error {
+	// A stopped trigger closed its readers
+	if k.ctx.Err() != nil {
+		k.connect()
+	}
+	k.ctx, k.cancel = context.WithCancel(ctx)
+
+	k.setStatus(StatusRunning, nil)
 	k.logger.Info("Kafka trigger started", zap.Int("topics", len(k.readers)))
 
 	// Start a consumer for each topic
@@ -78,8 +98,9 @@ func (k *KafkaTrigger) Start() error {
 }
 
 // Stop stops the Kafka trigger
-func (k *KafkaTrigger) Stop() error {
+func (k *KafkaTrigger) Stop(ctx context.Context) error {
 	k.cancel()
+	k.setStatus(StatusStopped, nil)
 
 	for _, reader := range k.readers {
 		if err := reader.Close(); err != nil {
@@ -92,8 +113,6 @@ func (k *KafkaTrigger) Stop() error {
 
 // consume reads messages from a Kafka topic
 func (k *KafkaTrigger) consume(reader *kafka.Reader) {
-	defer reader.Close()
-
 	for {
 		select {
 		case <-k.ctx.Done():
@@ -101,10 +120,11 @@ func (k *KafkaTrigger) consume(reader *kafka.Reader) {
 		default:
 			msg, err := reader.ReadMessage(k.ctx)
 			if err != nil {
-				if err == context.Canceled {
+				if k.ctx.Err() != nil {
 					return
 				}
 				k.logger.Error("Kafka read error", zap.Error(err))
+				k.recordError(err)
 				time.Sleep(time.Second * 5) // Backoff on error
 				continue
 			}
@@ -126,6 +146,7 @@ func (k *KafkaTrigger) handleMessage(msg kafka.Message) {
 	var eventData map[string]interface{}
 	if err := json.Unmarshal(msg.Value, &eventData); err != nil {
 		k.logger.Error("Failed to parse Kafka message", zap.Error(err))
+		k.recordError(err)
 		return
 	}
 
@@ -158,7 +179,7 @@ func (k *KafkaTrigger) handleMessage(msg kafka.Message) {
 	}
 
 	// Execute workflow asynchronously with retry
-	go executeWorkflow(k.ctx, k.engine, k.logger, eventType, context)
+	go k.dispatch(k.ctx, k.engine, k.logger, eventType, context)
 }
 
 // extractEventType determines event type from Kafka message
@@ -193,37 +214,11 @@ func (k *KafkaTrigger) extractEventType(msg kafka.Message) string {
 
 // PublishEvent publishes an event to Kafka (utility method)
 func (k *KafkaTrigger) PublishEvent(eventType string, data map[string]interface{}) error {
-	// Add event type to data
-	data["event_type"] = eventType
-	data["timestamp"] = time.Now().Unix()
-
-	jsonData, err := json.Marshal(data)
-	if err != nil {
-		return fmt.Errorf("failed to marshal event data: %w", err)
-	}
-
-	message := kafka.Message{
-		Key:   []byte(eventType),
-		Value: jsonData,
-		Headers: []kafka.Header{
-			{Key: "event-type", Value: []byte(eventType)},
-			{Key: "source", Value: []byte("reactor")},
-		},
-	}
-
-	return k.writer.WriteMessages(k.ctx, message)
+	return k.PublishToTopic("reactor-events", eventType, data)
 }
 
 // PublishToTopic publishes an event to a specific Kafka topic
 func (k *KafkaTrigger) PublishToTopic(topic, eventType string, data map[string]interface{}) error {
-	writer := &kafka.Writer{
-		Addr:                   k.writer.Addr,
-		Topic:                  topic,
-		Balancer:               &kafka.LeastBytes{},
-		AllowAutoTopicCreation: true,
-	}
-	defer writer.Close()
-
 	data["event_type"] = eventType
 	data["timestamp"] = time.Now().Unix()
 
@@ -232,14 +227,21 @@ func (k *KafkaTrigger) PublishToTopic(topic, eventType string, data map[string]i
 		return fmt.Errorf("failed to marshal event data: %w", err)
 	}
 
+	return k.Produce(k.ctx, topic, []byte(eventType), jsonData, map[string]string{
+		"event-type": eventType,
+		"source":     "reactor",
+	})
+}
+
+// Produce writes a message to a topic through the trigger's connection
+func (k *KafkaTrigger) Produce(ctx context.Context, topic string, key, value []byte, headers map[string]string) error {
 	message := kafka.Message{
-		Key:   []byte(eventType),
-		Value: jsonData,
-		Headers: []kafka.Header{
-			{Key: "event-type", Value: []byte(eventType)},
-			{Key: "source", Value: []byte("reactor")},
-		},
+		Topic: topic,
+		Key:   key,
+		Value: value,
 	}
-
-	return writer.WriteMessages(k.ctx, message)
+	for name, header := range headers {
+		message.Headers = append(message.Headers, kafka.Header{Key: name, Value: []byte(header)})
+	}
+	return k.writer.WriteMessages(ctx, message)
 }
@@ -0,0 +1,371 @@
+package triggers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/logimos/conduktr/internal/engine"
+	"github.com/logimos/conduktr/internal/plugins"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// healthCheckInterval is how often running triggers are checked for failure
+	healthCheckInterval = time.Second
+	// maxRestartDelay caps the backoff between restarts of a failing trigger
+	maxRestartDelay = 30 * time.Second
+	// stableRuntime resets the backoff once a trigger has run this long
+	stableRuntime = time.Minute
+	// restartStopTimeout bounds stopping a failed trigger before it is restarted
+	restartStopTimeout = 10 * time.Second
+)
+
+// TriggerConfig is one entry of the triggers list. Keys other than name,
+// type and enabled are the trigger's own options.
+type TriggerConfig struct {
+	Name    string
+	Type    string
+	Enabled *bool
+	Options map[string]interface{}
+}
+
+// TriggerStatus reports on a managed trigger
+type TriggerStatus struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Health   Health `json:"health"`
+	Stats    Stats  `json:"stats"`
+	Restarts int    `json:"restarts"`
+}
+
+// TriggerManager starts triggers and restarts them when they fail
+type TriggerManager struct {
+	logger  *zap.Logger
+	engine  *engine.Engine
+	plugins *plugins.Host
+
+	mu      sync.Mutex
+	entries []*managedTrigger
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// managedTrigger is a trigger and its supervision state
+type managedTrigger struct {
+	trigger Trigger
+	kind    string
+
+	mu       sync.Mutex
+	running  bool
+	restarts int
+	failure  *Health // why a trigger that is not running last failed
+}
+
+// NewTriggerManager creates a trigger manager. Trigger types provided by
+// plugins are available when host is not nil.
+func NewTriggerManager(logger *zap.Logger, engine *engine.Engine, host *plugins.Host) *TriggerManager {
+	return &TriggerManager{
+		logger:  logger,
+		engine:  engine,
+		plugins: host,
+	}
+}
+
+// Add manages a trigger created by the caller
+func (m *TriggerManager) Add(kind string, trigger Trigger) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, entry := range m.entries {
+		if entry.trigger.Name() == trigger.Name() {
+			return fmt.Errorf("trigger name %s is used more than once", trigger.Name())
+		}
+	}
+	m.entries = append(m.entries, &managedTrigger{trigger: trigger, kind: kind})
+	return nil
+}
+
+// Build creates and adds the configured triggers. Disabled entries are
+// skipped.
+func (m *TriggerManager) Build(configs []TriggerConfig) error {
+	for i, cfg := range configs {
+		if cfg.Enabled != nil && !*cfg.Enabled {
+			continue
+		}
+		if cfg.Type == "" {
+			return fmt.Errorf("triggers[%d]: type is required", i)
+		}
+		if cfg.Name == "" {
+			cfg.Name = cfg.Type
+		}
+
+		trigger, err := m.build(cfg)
+		if err != nil {
+			return fmt.Errorf("trigger %s: %w", cfg.Name, err)
+		}
+		if err := m.Add(cfg.Type, trigger); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// build creates a built-in trigger, or one provided by a plugin
+func (m *TriggerManager) build(cfg TriggerConfig) (Trigger, error) {
+	switch cfg.Type {
+	case "file":
+		var options struct {
+			Dir string `yaml:"dir"`
+		}
+		if err := decodeOptions(cfg.Options, &options); err != nil {
+			return nil, err
+		}
+		if options.Dir == "" {
+			return nil, fmt.Errorf("dir is required")
+		}
+		trigger := NewFileTrigger(m.logger, m.engine, options.Dir)
+		trigger.name = cfg.Name
+		return trigger, nil
+
+	case "redis":
+		var options RedisConfig
+		if err := decodeOptions(cfg.Options, &options); err != nil {
+			return nil, err
+		}
+		if options.Address == "" {
+			return nil, fmt.Errorf("address is required")
+		}
+		trigger := NewRedisTrigger(options, m.engine, m.logger)
+		trigger.name = cfg.Name
+		m.engine.Registry().RegisterRedisConnection(cfg.Name, trigger)
+		return trigger, nil
+
+	case "kafka":
+		var options KafkaConfig
+		if err := decodeOptions(cfg.Options, &options); err != nil {
+			return nil, err
+		}
+		// Without topics the trigger only produces for kafka.publish steps
+		if len(options.Brokers) == 0 {
+			return nil, fmt.Errorf("brokers are required")
+		}
+		trigger := NewKafkaTrigger(options, m.engine, m.logger)
+		trigger.name = cfg.Name
+		m.engine.Registry().RegisterKafkaConnection(cfg.Name, trigger)
+		return trigger, nil
+
+	case "scheduler":
+		// Configured jobs are enabled unless they say otherwise
+		if jobs, ok := cfg.Options["jobs"].([]interface{}); ok {
+			for _, job := range jobs {
+				if fields, ok := job.(map[string]interface{}); ok {
+					if _, set := fields["enabled"]; !set {
+						fields["enabled"] = true
+					}
+				}
+			}
+		}
+		var options ScheduleConfig
+		if err := decodeOptions(cfg.Options, &options); err != nil {
+			return nil, err
+		}
+		trigger := NewSchedulerTrigger(options, m.engine, m.logger)
+		trigger.name = cfg.Name
+		for _, job := range options.Jobs {
+			if err := trigger.AddJob(job); err != nil {
+				return nil, err
+			}
+		}
+		return trigger, nil
+
+	case "database":
+		var options DatabaseConfig
+		if err := decodeOptions(cfg.Options, &options); err != nil {
+			return nil, err
+		}
+		if options.Driver == "" || options.DSN == "" {
+			return nil, fmt.Errorf("driver and dsn are required")
+		}
+		if options.PollInterval == 0 {
+			options.PollInterval = 30 * time.Second
+		}
+		trigger := NewDatabaseTrigger(options, m.engine, m.logger)
+		trigger.name = cfg.Name
+		return trigger, nil
+	}
+
+	if m.plugins != nil && m.plugins.ProvidesTrigger(cfg.Type) {
+		return NewPluginTrigger(cfg.Name, cfg.Type, cfg.Options, m.plugins, m.engine, m.logger), nil
+	}
+	return nil, fmt.Errorf("unknown trigger type %s", cfg.Type)
+}
+
+// decodeOptions decodes trigger options into a config struct through its
+// yaml tags, rejecting unknown keys
+func decodeOptions(options map[string]interface{}, target interface{}) error {
+	data, err := yaml.Marshal(options)
+	if err != nil {
+		return err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(target); err != nil {
+		return fmt.Errorf("invalid options: %w", err)
+	}
+	return nil
+}
+
+// Start starts every trigger, restarting each with backoff whenever it
+// fails to start or reports a failure, until Stop is called
+func (m *TriggerManager) Start(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	m.mu.Lock()
+	m.cancel = cancel
+	entries := m.entries
+	m.mu.Unlock()
+
+	for _, entry := range entries {
+		m.wg.Add(1)
+		go m.supervise(ctx, entry)
+	}
+}
+
+// supervise runs one trigger until ctx is cancelled
+func (m *TriggerManager) supervise(ctx context.Context, entry *managedTrigger) {
+	defer m.wg.Done()
+
+	logger := m.logger.With(zap.String("trigger", entry.trigger.Name()))
+	delay := time.Second
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			entry.mu.Lock()
+			entry.restarts++
+			entry.mu.Unlock()
+		}
+
+		started := time.Now()
+		if err := entry.trigger.Start(ctx); err != nil {
+			logger.Error("Trigger failed to start", zap.Error(err), zap.Duration("retry_in", delay))
+			entry.setFailure(err)
+		} else {
+			entry.mu.Lock()
+			entry.running = true
+			entry.failure = nil
+			entry.mu.Unlock()
+
+			health, failed := m.waitForFailure(ctx, entry.trigger)
+			if !failed {
+				return
+			}
+			logger.Warn("Trigger failed, restarting",
+				zap.String("error", health.Error),
+				zap.Duration("delay", delay))
+
+			stopCtx, cancel := context.WithTimeout(context.Background(), restartStopTimeout)
+			if err := entry.trigger.Stop(stopCtx); err != nil {
+				logger.Warn("Failed to stop trigger", zap.Error(err))
+			}
+			cancel()
+			entry.setFailure(errors.New(health.Error))
+
+			if time.Since(started) > stableRuntime {
+				delay = time.Second
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > maxRestartDelay {
+			delay = maxRestartDelay
+		}
+	}
+}
+
+// waitForFailure polls a running trigger's health. It returns the failed
+// health, or false once ctx is cancelled.
+func (m *TriggerManager) waitForFailure(ctx context.Context, trigger Trigger) (Health, bool) {
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return Health{}, false
+		case <-ticker.C:
+			if health := trigger.Health(); health.Status == StatusFailed {
+				return health, true
+			}
+		}
+	}
+}
+
+// setFailure records that the trigger is not running because of err
+func (e *managedTrigger) setFailure(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.running = false
+	e.failure = &Health{Status: StatusFailed, Error: err.Error(), Since: time.Now()}
+}
+
+// Statuses reports the health and stats of every trigger
+func (m *TriggerManager) Statuses() []TriggerStatus {
+	m.mu.Lock()
+	entries := m.entries
+	m.mu.Unlock()
+
+	statuses := make([]TriggerStatus, 0, len(entries))
+	for _, entry := range entries {
+		status := TriggerStatus{
+			Name:   entry.trigger.Name(),
+			Type:   entry.kind,
+			Health: entry.trigger.Health(),
+			Stats:  entry.trigger.Stats(),
+		}
+		entry.mu.Lock()
+		status.Restarts = entry.restarts
+		if !entry.running && entry.failure != nil {
+			status.Health = *entry.failure
+		}
+		entry.mu.Unlock()
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// Stop stops supervising and stops every running trigger
+func (m *TriggerManager) Stop(ctx context.Context) error {
+	m.mu.Lock()
+	cancel := m.cancel
+	entries := m.entries
+	m.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+	m.wg.Wait()
+
+	var errs []error
+	for _, entry := range entries {
+		entry.mu.Lock()
+		running := entry.running
+		entry.running = false
+		entry.mu.Unlock()
+		if !running {
+			continue
+		}
+		if err := entry.trigger.Stop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("trigger %s: %w", entry.trigger.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
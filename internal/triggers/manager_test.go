@@ -0,0 +1,188 @@
+package triggers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// fakeTrigger fails to start startFailures times, then reports itself
+// failed on its first run if failFirstRun is set
+type fakeTrigger struct {
+	name          string
+	startFailures int
+	failFirstRun  bool
+
+	mu     sync.Mutex
+	starts int
+	runs   int
+	stops  int
+}
+
+func (f *fakeTrigger) Name() string { return f.name }
+
+func (f *fakeTrigger) Start(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.starts++
+	if f.starts <= f.startFailures {
+		return errors.New("broker unavailable")
+	}
+	f.runs++
+	return nil
+}
+
+func (f *fakeTrigger) Stop(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stops++
+	return nil
+}
+
+func (f *fakeTrigger) Health() Health {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failFirstRun && f.runs == 1 {
+		return Health{Status: StatusFailed, Error: "connection lost"}
+	}
+	return Health{Status: StatusRunning}
+}
+
+func (f *fakeTrigger) Stats() Stats { return Stats{} }
+
+// counts returns how often the trigger was started and stopped
+func (f *fakeTrigger) counts() (int, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.starts, f.stops
+}
+
+// waitForStarts waits until the trigger has been started n times
+func waitForStarts(t *testing.T, trigger *fakeTrigger, n int) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if starts, _ := trigger.counts(); starts >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("trigger %s was not started %d times", trigger.name, n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// waitForStatus waits until ok accepts the manager's status for the named
+// trigger, which the supervisor updates after Start returns
+func waitForStatus(t *testing.T, m *TriggerManager, name string, ok func(TriggerStatus) bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		var status TriggerStatus
+		for _, s := range m.Statuses() {
+			if s.Name == name {
+				status = s
+			}
+		}
+		if ok(status) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s status = %+v", name, status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTriggerManagerRestartsFailedTriggers(t *testing.T) {
+	failsToStart := &fakeTrigger{name: "start", startFailures: 1}
+	failsWhileRunning := &fakeTrigger{name: "health", failFirstRun: true}
+
+	m := NewTriggerManager(zap.NewNop(), nil, nil)
+	for _, trigger := range []*fakeTrigger{failsToStart, failsWhileRunning} {
+		if err := m.Add("fake", trigger); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.Start(context.Background())
+
+	// A failed start is reported until the retry
+	waitForStatus(t, m, "start", func(status TriggerStatus) bool {
+		return status.Health.Status == StatusFailed && status.Health.Error == "broker unavailable"
+	})
+
+	for _, trigger := range []*fakeTrigger{failsToStart, failsWhileRunning} {
+		waitForStarts(t, trigger, 2)
+		waitForStatus(t, m, trigger.name, func(status TriggerStatus) bool {
+			return status.Restarts == 1 && status.Health.Status == StatusRunning && status.Type == "fake"
+		})
+	}
+
+	// The failed run was stopped before the restart
+	if _, stops := failsWhileRunning.counts(); stops != 1 {
+		t.Errorf("stops before restart = %d, want 1", stops)
+	}
+
+	if err := m.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, stops := failsToStart.counts(); stops != 1 {
+		t.Errorf("start trigger stops = %d, want 1", stops)
+	}
+	if _, stops := failsWhileRunning.counts(); stops != 2 {
+		t.Errorf("health trigger stops = %d, want 2", stops)
+	}
+}
+
+func TestTriggerManagerBuild(t *testing.T) {
+	disabled := false
+	tests := []struct {
+		name    string
+		configs []TriggerConfig
+		err     string
+		count   int
+	}{
+		{name: "named after type", configs: []TriggerConfig{{Type: "scheduler"}}, count: 1},
+		{name: "disabled skipped", configs: []TriggerConfig{{Type: "unknown", Enabled: &disabled}}},
+		{
+			name:    "duplicate name",
+			configs: []TriggerConfig{{Type: "scheduler"}, {Type: "scheduler"}},
+			err:     "trigger name scheduler is used more than once",
+		},
+		{
+			name:    "distinct names",
+			configs: []TriggerConfig{{Type: "scheduler"}, {Name: "nightly", Type: "scheduler"}},
+			count:   2,
+		},
+		{name: "unknown type", configs: []TriggerConfig{{Name: "q", Type: "sqs"}}, err: "trigger q: unknown trigger type sqs"},
+		{name: "missing type", configs: []TriggerConfig{{Name: "q"}}, err: "triggers[0]: type is required"},
+		{
+			name:    "unknown option",
+			configs: []TriggerConfig{{Type: "file", Options: map[string]interface{}{"dir": "x", "recursive": true}}},
+			err:     "invalid options",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewTriggerManager(zap.NewNop(), nil, nil)
+			err := m.Build(tt.configs)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := len(m.Statuses()); got != tt.count {
+				t.Errorf("triggers = %d, want %d", got, tt.count)
+			}
+		})
+	}
+}
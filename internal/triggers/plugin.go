@@ -0,0 +1,106 @@
+package triggers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/logimos/conduktr/internal/engine"
+	"github.com/logimos/conduktr/internal/plugins"
+
+	"go.uber.org/zap"
+)
+
+// PluginTrigger runs a trigger provided by an out-of-process plugin
+type PluginTrigger struct {
+	monitor
+	name    string
+	kind    string
+	config  map[string]interface{}
+	host    *plugins.Host
+	engine  *engine.Engine
+	logger  *zap.Logger
+	ctx     context.Context
+	cancel  context.CancelFunc
+	session *plugins.TriggerSession
+}
+
+// NewPluginTrigger creates a trigger of a type provided by a plugin
+func NewPluginTrigger(name, kind string, config map[string]interface{}, host *plugins.Host, engine *engine.Engine, logger *zap.Logger) *PluginTrigger {
+	return &PluginTrigger{
+		name:   name,
+		kind:   kind,
+		config: config,
+		host:   host,
+		engine: engine,
+		logger: logger.With(zap.String("trigger", name)),
+	}
+}
+
+// Name returns the trigger's name
+func (p *PluginTrigger) Name() string {
+	return p.name
+}
+
+// Start asks the plugin to start the trigger. The trigger fails when the
+// plugin reports a failure or exits.
+func (p *PluginTrigger) Start(ctx context.Context) error {
+	p.ctx, p.cancel = context.WithCancel(ctx)
+
+	startCtx, cancel := context.WithTimeout(p.ctx, 30*time.Second)
+	defer cancel()
+
+	session, err := p.host.StartTrigger(startCtx, p.kind, p.name, p.config, p.handleEvent)
+	if err != nil {
+		p.cancel()
+		return err
+	}
+	p.session = session
+
+	go func() {
+		select {
+		case <-session.Done():
+			if err := session.Err(); err != nil {
+				p.logger.Error("Plugin trigger failed", zap.Error(err))
+				p.setStatus(StatusFailed, err)
+			}
+		case <-p.ctx.Done():
+		}
+	}()
+
+	p.setStatus(StatusRunning, nil)
+	p.logger.Info("Plugin trigger started", zap.String("type", p.kind))
+	return nil
+}
+
+// Stop asks the plugin to stop the trigger
+func (p *PluginTrigger) Stop(ctx context.Context) error {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.setStatus(StatusStopped, nil)
+	if p.session != nil {
+		return p.session.Stop(ctx)
+	}
+	return nil
+}
+
+// handleEvent runs the workflow for an event sent by the plugin
+func (p *PluginTrigger) handleEvent(event plugins.Event) {
+	if event.Event == "" {
+		p.recordError(fmt.Errorf("plugin event has no event type"))
+		return
+	}
+
+	contextData := map[string]interface{}{
+		"trigger_type": "plugin",
+		"trigger":      p.name,
+		"event_type":   event.Event,
+		"timestamp":    time.Now().Unix(),
+	}
+	for k, v := range event.Data {
+		contextData[k] = v
+	}
+
+	go p.dispatch(context.Background(), p.engine, p.logger, event.Event, contextData)
+}
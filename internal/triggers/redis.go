@@ -14,6 +14,9 @@ import (
 
 // RedisTrigger implements Redis-based event triggering
 type RedisTrigger struct {
+	monitor
+	name   string
+	config RedisConfig
 	client *redis.Client
 	engine *engine.Engine
 	logger *zap.Logger
@@ -34,14 +37,10 @@ type RedisConfig struct {
 func NewRedisTrigger(config RedisConfig, engine *engine.Engine, logger *zap.Logger) *RedisTrigger {
 	ctx, cancel := context.WithCancel(context.Background())
 
-	client := redis.NewClient(&redis.Options{
-		Addr:     config.Address,
-		Password: config.Password,
-		DB:       config.DB,
-	})
-
 	return &RedisTrigger{
-		client: client,
+		name:   "redis",
+		config: config,
+		client: newRedisClient(config),
 		engine: engine,
 		logger: logger,
 		ctx:    ctx,
@@ -49,13 +48,36 @@ func NewRedisTrigger(config RedisConfig, engine *engine.Engine, logger *zap.Logg
 	}
 }
 
+// newRedisClient creates a client for the configured server
+func newRedisClient(config RedisConfig) *redis.Client {
+	return redis.NewClient(&redis.Options{
+		Addr:     config.Address,
+		Password: config.Password,
+		DB:       config.DB,
+	})
+}
+
+// Name returns the trigger's name
+func (r *RedisTrigger) Name() string {
+	return r.name
+}
+
 // Start begins listening for Redis events
-func (r *RedisTrigger) Start() error {
+func (r *RedisTrigger) Start(ctx context.Context) error {
+	// A stopped trigger closed its client
+	if r.ctx.Err() != nil {
+		r.client = newRedisClient(r.config)
+	}
+	r.ctx, r.cancel = context.WithCancel(ctx)
+
 	// Test connection
 	if err := r.client.Ping(r.ctx).Err(); err != nil {
+		r.cancel()
+		r.client.Close()
 		return fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
+	r.setStatus(StatusRunning, nil)
 	r.logger.Info("Redis trigger started", zap.String("address", r.client.Options().Addr))
 
 	// Start pub/sub listener
@@ -68,8 +90,9 @@ func (r *RedisTrigger) Start() error {
 }
 
 // Stop stops the Redis trigger
-func (r *RedisTrigger) Stop() error {
+func (r *RedisTrigger) Stop(ctx context.Context) error {
 	r.cancel()
+	r.setStatus(StatusStopped, nil)
 	return r.client.Close()
 }
 
@@ -81,7 +104,13 @@ func (r *RedisTrigger) listenPubSub() {
 	ch := pubsub.Channel()
 	for {
 		select {
-		case msg := <-ch:
+		case msg, ok := <-ch:
+			if !ok {
+				if r.ctx.Err() == nil {
+					r.setStatus(StatusFailed, fmt.Errorf("redis subscription closed"))
+				}
+				return
+			}
 			r.handlePubSubMessage(msg)
 		case <-r.ctx.Done():
 			return
@@ -89,9 +118,10 @@ func (r *RedisTrigger) listenPubSub() {
 	}
 }
 
-// listenStreams listens to Redis streams
+// listenStreams listens to Redis streams. A read error marks the trigger
+// failed so it is restarted once Redis is reachable again.
 func (r *RedisTrigger) listenStreams() {
-	streams := []string{"reactor:events", ">"}
+	lastID := "$"
 
 	for {
 		select {
@@ -99,21 +129,26 @@ func (r *RedisTrigger) listenStreams() {
 			return
 		default:
 			result, err := r.client.XRead(r.ctx, &redis.XReadArgs{
-				Streams: streams,
+				Streams: []string{"reactor:events", lastID},
 				Block:   time.Second * 5,
 				Count:   10,
 			}).Result()
 
 			if err != nil {
-				if err != redis.Nil {
+				if err == redis.Nil {
+					continue
+				}
+				if r.ctx.Err() == nil {
 					r.logger.Error("Redis stream read error", zap.Error(err))
+					r.setStatus(StatusFailed, err)
 				}
-				continue
+				return
 			}
 
 			for _, stream := range result {
 				for _, message := range stream.Messages {
 					r.handleStreamMessage(message)
+					lastID = message.ID
 				}
 			}
 		}
@@ -129,6 +164,7 @@ func (r *RedisTrigger) handlePubSubMessage(msg *redis.Message) {
 	var eventData map[string]interface{}
 	if err := json.Unmarshal([]byte(msg.Payload), &eventData); err != nil {
 		r.logger.Error("Failed to parse Redis message", zap.Error(err))
+		r.recordError(err)
 		return
 	}
 
@@ -161,6 +197,7 @@ func (r *RedisTrigger) handleStreamMessage(msg redis.XMessage) {
 	eventType, ok := msg.Values["event"].(string)
 	if !ok {
 		r.logger.Error("No event type in stream message")
+		r.recordError(fmt.Errorf("no event type in stream message %s", msg.ID))
 		return
 	}
 
@@ -191,7 +228,8 @@ func (r *RedisTrigger) PublishEvent(eventType string, data map[string]interface{
 	}
 
 	channel := fmt.Sprintf("reactor:%s", eventType)
-	return r.client.Publish(r.ctx, channel, jsonData).Err()
+	_, err = r.Publish(r.ctx, channel, jsonData)
+	return err
 }
 
 // AddToStream adds an event to Redis stream (utility method)
@@ -203,13 +241,26 @@ func (r *RedisTrigger) AddToStream(eventType string, data map[string]interface{}
 		streamData[k] = v
 	}
 
-	return r.client.XAdd(r.ctx, &redis.XAddArgs{
-		Stream: "reactor:events",
-		Values: streamData,
-	}).Err()
+	_, err := r.XAdd(r.ctx, "reactor:events", streamData, 0)
+	return err
+}
+
+// Publish sends a message to a channel through the trigger's connection
+func (r *RedisTrigger) Publish(ctx context.Context, channel string, message []byte) (int64, error) {
+	return r.client.Publish(ctx, channel, message).Result()
+}
+
+// XAdd appends an entry to a stream through the trigger's connection
+func (r *RedisTrigger) XAdd(ctx context.Context, stream string, values map[string]interface{}, maxLen int64) (string, error) {
+	return r.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: stream,
+		MaxLen: maxLen,
+		Approx: maxLen > 0,
+		Values: values,
+	}).Result()
 }
 
 // executeWorkflow helper function to execute workflows
 func (r *RedisTrigger) executeWorkflow(eventType string, context map[string]interface{}) {
-	executeWorkflow(r.ctx, r.engine, r.logger, eventType, context)
+	r.dispatch(r.ctx, r.engine, r.logger, eventType, context)
 }
@@ -13,6 +13,8 @@ import (
 
 // SchedulerTrigger implements cron-based scheduling
 type SchedulerTrigger struct {
+	monitor
+	name   string
 	cron   *cron.Cron
 	engine *engine.Engine
 	logger *zap.Logger
@@ -39,6 +41,7 @@ func NewSchedulerTrigger(config ScheduleConfig, engine *engine.Engine, logger *z
 	c := cron.New(cron.WithSeconds())
 
 	return &SchedulerTrigger{
+		name:   "scheduler",
 		cron:   c,
 		engine: engine,
 		logger: logger,
@@ -46,16 +49,27 @@ func NewSchedulerTrigger(config ScheduleConfig, engine *engine.Engine, logger *z
 	}
 }
 
+// Name returns the trigger's name
+func (s *SchedulerTrigger) Name() string {
+	return s.name
+}
+
 // Start begins the scheduler
-func (s *SchedulerTrigger) Start() error {
+func (s *SchedulerTrigger) Start(ctx context.Context) error {
 	s.logger.Info("Scheduler trigger started")
 	s.cron.Start()
+	s.setStatus(StatusRunning, nil)
 	return nil
 }
 
-// Stop stops the scheduler
-func (s *SchedulerTrigger) Stop() error {
-	s.cron.Stop()
+// Stop stops the scheduler, waiting for running jobs until ctx is done
+func (s *SchedulerTrigger) Stop(ctx context.Context) error {
+	s.setStatus(StatusStopped, nil)
+	select {
+	case <-s.cron.Stop().Done():
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 	s.logger.Info("Scheduler trigger stopped")
 	return nil
 }
@@ -120,7 +134,7 @@ func (s *SchedulerTrigger) executeJob(job JobConfig) {
 	}
 
 	// Execute workflow asynchronously
-	go executeWorkflow(context.Background(), s.engine, s.logger, job.EventType, contextData)
+	go s.dispatch(context.Background(), s.engine, s.logger, job.EventType, contextData)
 }
 
 // ListJobs returns all scheduled jobs
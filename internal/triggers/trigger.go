@@ -0,0 +1,107 @@
+package triggers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/logimos/conduktr/internal/engine"
+
+	"go.uber.org/zap"
+)
+
+// Trigger statuses reported by Health
+const (
+	StatusRunning = "running"
+	StatusFailed  = "failed"
+	StatusStopped = "stopped"
+)
+
+// Trigger is a source of events that start workflows
+type Trigger interface {
+	// Name identifies the trigger in status reports
+	Name() string
+	// Start returns once the trigger is delivering events. It runs until
+	// Stop is called or ctx is cancelled; later failures are reported
+	// through Health.
+	Start(ctx context.Context) error
+	// Stop releases the trigger's resources; ctx bounds a graceful shutdown
+	Stop(ctx context.Context) error
+	Health() Health
+	Stats() Stats
+}
+
+// Health is the current state of a trigger
+type Health struct {
+	Status string    `json:"status"`
+	Error  string    `json:"error,omitempty"`
+	Since  time.Time `json:"since"`
+}
+
+// Stats counts the events a trigger has received
+type Stats struct {
+	Events    uint64     `json:"events"`
+	Errors    uint64     `json:"errors"`
+	LastEvent *time.Time `json:"last_event,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+// monitor tracks health and stats; triggers embed it to implement Health
+// and Stats
+type monitor struct {
+	mu     sync.Mutex
+	health Health
+	stats  Stats
+}
+
+// setStatus records a status change
+func (m *monitor) setStatus(status string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.health = Health{Status: status, Since: time.Now()}
+	if err != nil {
+		m.health.Error = err.Error()
+	}
+}
+
+// recordEvent counts a received event
+func (m *monitor) recordEvent() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	m.stats.Events++
+	m.stats.LastEvent = &now
+}
+
+// recordError counts an event that could not be handled
+func (m *monitor) recordError(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stats.Errors++
+	m.stats.LastError = err.Error()
+}
+
+// Health returns the trigger's current state
+func (m *monitor) Health() Health {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.health.Status == "" {
+		return Health{Status: StatusStopped}
+	}
+	return m.health
+}
+
+// Stats returns the trigger's event counters
+func (m *monitor) Stats() Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stats
+}
+
+// dispatch counts an event and runs its workflow, counting failures
+func (m *monitor) dispatch(ctx context.Context, engine *engine.Engine, logger *zap.Logger, eventType string, contextData map[string]interface{}) {
+	m.recordEvent()
+	if err := executeWorkflow(ctx, engine, logger, eventType, contextData); err != nil {
+		m.recordError(err)
+	}
+}
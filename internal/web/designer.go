@@ -4,8 +4,11 @@ import (
         "encoding/json"
         "fmt"
         "net/http"
+        "sort"
         "time"
 
+        "github.com/logimos/conduktr/internal/engine"
+
         "github.com/gorilla/mux"
 )
 
@@ -61,10 +64,12 @@ type VisualWorkflow struct {
 
 // Variable represents workflow variables
 type Variable struct {
-        Name         string      `json:"name"`
-        Type         string      `json:"type"`
-        DefaultValue interface{} `json:"defaultValue"`
-        Description  string      `json:"description"`
+        Name         string        `json:"name"`
+        Type         string        `json:"type"`
+        DefaultValue interface{}   `json:"defaultValue"`
+        Description  string        `json:"description"`
+        Required     bool          `json:"required"`
+        Enum         []interface{} `json:"enum,omitempty"`
 }
 
 // WorkflowSettings contains workflow execution settings
@@ -328,6 +333,11 @@ func (ds *DesignerService) validateWorkflow(workflow VisualWorkflow) []string {
                 errors = append(errors, "Workflow must have at least one trigger node")
         }
         
+        // Validate declared inputs with the engine's schema rules
+        if err := engine.ValidateInputSpecs(inputSpecs(workflow.Variables)); err != nil {
+                errors = append(errors, err.Error())
+        }
+        
         // Validate node connections
         nodeIDs := make(map[string]bool)
         for _, node := range workflow.Nodes {
@@ -353,29 +363,59 @@ version: %s
 
 `, workflow.Name, workflow.Description, workflow.Version)
         
-        // Add triggers
-        yaml += "triggers:\n"
+        // Add inputs
+        if len(workflow.Variables) > 0 {
+                yaml += "inputs:\n"
+                for _, variable := range workflow.Variables {
+                        yaml += fmt.Sprintf("  %s:\n", variable.Name)
+                        yaml += fmt.Sprintf("    type: %s\n", variable.Type)
+                        if variable.Description != "" {
+                                yaml += fmt.Sprintf("    description: %q\n", variable.Description)
+                        }
+                        if variable.Required {
+                                yaml += "    required: true\n"
+                        }
+                        if variable.DefaultValue != nil {
+                                value, _ := json.Marshal(variable.DefaultValue)
+                                yaml += fmt.Sprintf("    default: %s\n", value)
+                        }
+                        if len(variable.Enum) > 0 {
+                                value, _ := json.Marshal(variable.Enum)
+                                yaml += fmt.Sprintf("    enum: %s\n", value)
+                        }
+                }
+                yaml += "\n"
+        }
+        
+        // The first trigger names the event that starts the workflow; the
+        // triggers themselves are configured on the daemon
         for _, node := range workflow.Nodes {
                 if node.Type == NodeTypeTrigger {
-                        yaml += fmt.Sprintf("  - type: %s\n", node.Config["type"])
-                        if config, ok := node.Config["config"].(map[string]interface{}); ok {
-                                for key, value := range config {
-                                        yaml += fmt.Sprintf("    %s: %v\n", key, value)
-                                }
+                        event, _ := node.Config["event"].(string)
+                        if event == "" {
+                                event = fmt.Sprintf("%v", node.Config["type"])
                         }
+                        yaml += fmt.Sprintf("on:\n  event: %s\n\n", event)
+                        break
                 }
         }
         
-        // Add steps
-        yaml += "\nsteps:\n"
+        // Add steps; the engine reads step options inline, next to action
+        yaml += "workflow:\n"
         for _, node := range workflow.Nodes {
                 if node.Type == NodeTypeAction {
                         yaml += fmt.Sprintf("  - name: %s\n", node.Name)
                         yaml += fmt.Sprintf("    action: %s\n", node.Config["action"])
                         if config, ok := node.Config["config"].(map[string]interface{}); ok {
-                                yaml += "    config:\n"
-                                for key, value := range config {
-                                        yaml += fmt.Sprintf("      %s: %v\n", key, value)
+                                keys := make([]string, 0, len(config))
+                                for key := range config {
+                                        keys = append(keys, key)
+                                }
+                                sort.Strings(keys)
+                                for _, key := range keys {
+                                        // JSON is valid YAML and keeps nested mappings intact
+                                        encoded, _ := json.Marshal(config[key])
+                                        yaml += fmt.Sprintf("    %s: %s\n", key, encoded)
                                 }
                         }
                 }
@@ -384,6 +424,21 @@ version: %s
         return yaml, nil
 }
 
+// inputSpecs converts designer variables into workflow input specs
+func inputSpecs(variables []Variable) map[string]*engine.InputSpec {
+        specs := make(map[string]*engine.InputSpec, len(variables))
+        for _, variable := range variables {
+                specs[variable.Name] = &engine.InputSpec{
+                        Type:        variable.Type,
+                        Description: variable.Description,
+                        Required:    variable.Required,
+                        Default:     variable.DefaultValue,
+                        Enum:        variable.Enum,
+                }
+        }
+        return specs
+}
+
 // getDefaultNodeTemplates returns predefined node templates
 func getDefaultNodeTemplates() []NodeTemplate {
         return []NodeTemplate{
@@ -395,7 +450,8 @@ func getDefaultNodeTemplates() []NodeTemplate {
                         Category:    "Triggers",
                         Icon:        "🌐",
                         Config: map[string]interface{}{
-                                "type": "http",
+                                "type":  "http",
+                                "event": "webhook.received",
                                 "config": map[string]interface{}{
                                         "port": 8080,
                                         "path": "/webhook",
@@ -411,7 +467,8 @@ func getDefaultNodeTemplates() []NodeTemplate {
                         Category:    "Triggers",
                         Icon:        "📁",
                         Config: map[string]interface{}{
-                                "type": "file",
+                                "type":  "file",
+                                "event": "file.created",
                                 "config": map[string]interface{}{
                                         "path":   "./watch",
                                         "events": []string{"create", "modify"},
@@ -427,7 +484,8 @@ func getDefaultNodeTemplates() []NodeTemplate {
                         Category:    "Triggers",
                         Icon:        "🔴",
                         Config: map[string]interface{}{
-                                "type": "redis",
+                                "type":  "redis",
+                                "event": "redis.message",
                                 "config": map[string]interface{}{
                                         "host":    "localhost:6379",
                                         "channel": "events",
@@ -460,14 +518,34 @@ func getDefaultNodeTemplates() []NodeTemplate {
                         Category:    "Actions",
                         Icon:        "📧",
                         Config: map[string]interface{}{
-                                "action": "email",
+                                "action": "email.send",
                                 "config": map[string]interface{}{
-                                        "smtp_host": "smtp.gmail.com",
-                                        "smtp_port": 587,
+                                        "to":      "{{ .event.payload.email }}",
+                                        "subject": "Notification",
+                                        "text":    "",
                                 },
                         },
                         Inputs: []PortDefinition{{Name: "message", Type: "object", Description: "Email content"}},
                 },
+                {
+                        ID:          "transform-action",
+                        Type:        NodeTypeAction,
+                        Name:        "Transform",
+                        Description: "Reshape data with a declarative mapping",
+                        Category:    "Actions",
+                        Icon:        "🔄",
+                        Config: map[string]interface{}{
+                                "action": "transform.map",
+                                "config": map[string]interface{}{
+                                        "source": "{{ .event.payload }}",
+                                        "mapping": map[string]interface{}{
+                                                "id": "id",
+                                        },
+                                },
+                        },
+                        Inputs:  []PortDefinition{{Name: "data", Type: "object", Description: "Data to transform"}},
+                        Outputs: []PortDefinition{{Name: "result", Type: "object", Description: "Mapped fields"}},
+                },
                 {
                         ID:          "condition",
                         Type:        NodeTypeCondition,
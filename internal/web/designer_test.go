@@ -0,0 +1,59 @@
+package web
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/logimos/conduktr/internal/engine"
+)
+
+// templateNode returns a node built from a default template
+func templateNode(t *testing.T, id, name string) WorkflowNode {
+	t.Helper()
+	for _, template := range getDefaultNodeTemplates() {
+		if template.ID == id {
+			return WorkflowNode{ID: id, Type: template.Type, Name: name, Config: template.Config}
+		}
+	}
+	t.Fatalf("no template %s", id)
+	return WorkflowNode{}
+}
+
+func TestGeneratedYAMLLoadsInEngine(t *testing.T) {
+	designer := NewDesignerService()
+	source, err := designer.generateYAMLFromWorkflow(VisualWorkflow{
+		Name:      "reshape",
+		Version:   "1",
+		Variables: []Variable{{Name: "id", Type: "string", Required: true}},
+		Nodes: []WorkflowNode{
+			templateNode(t, "http-trigger", "webhook"),
+			templateNode(t, "transform-action", "map_user"),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	workflow, err := engine.LoadWorkflowFromYAML([]byte(source))
+	if err != nil {
+		t.Fatalf("%v\n%s", err, source)
+	}
+	if workflow.On.Event != "webhook.received" {
+		t.Errorf("event = %q", workflow.On.Event)
+	}
+	if spec := workflow.Inputs["id"]; spec == nil || !spec.Required {
+		t.Errorf("inputs = %v", workflow.Inputs)
+	}
+	if len(workflow.Workflow) != 1 {
+		t.Fatalf("steps = %+v\n%s", workflow.Workflow, source)
+	}
+
+	step := workflow.Workflow[0]
+	want := map[string]interface{}{
+		"source":  "{{ .event.payload }}",
+		"mapping": map[string]interface{}{"id": "id"},
+	}
+	if step.Action != "transform.map" || !reflect.DeepEqual(step.Config, want) {
+		t.Errorf("step = %s %#v, want the options inline\n%s", step.Action, step.Config, source)
+	}
+}